@@ -39,6 +39,7 @@ export interface NoteData {
 export interface EmailPrefData {
   inactiveReminder: boolean;
   productUpdate: boolean;
+  noteReminder: boolean;
 }
 
 export interface UserData {
@@ -52,12 +52,14 @@ export interface GetEmailPreferenceParams {
 export interface GetEmailPreferenceResponse {
   inactive_reminder: boolean;
   product_update: boolean;
+  note_reminder: boolean;
 }
 
 export interface UpdateEmailPreferenceParams {
   token?: string;
   inactiveReminder?: boolean;
   productUpdate?: boolean;
+  noteReminder?: boolean;
 }
 
 export interface ResetPasswordParams {
@@ -148,7 +150,8 @@ export default function init(config: HttpClientConfig) {
     updateEmailPreference: ({
       token,
       inactiveReminder,
-      productUpdate
+      productUpdate,
+      noteReminder
     }: UpdateEmailPreferenceParams): Promise<EmailPrefData> => {
       const payload: any = {};
 
@@ -158,6 +161,9 @@ export default function init(config: HttpClientConfig) {
       if (productUpdate !== undefined) {
         payload.product_update = productUpdate;
       }
+      if (noteReminder !== undefined) {
+        payload.note_reminder = noteReminder;
+      }
 
       let endpoint = '/account/email-preference';
       if (token) {
@@ -169,7 +175,8 @@ export default function init(config: HttpClientConfig) {
         .then(res => {
           return {
             inactiveReminder: res.inactive_reminder,
-            productUpdate: res.product_update
+            productUpdate: res.product_update,
+            noteReminder: res.note_reminder
           };
         });
     },
@@ -185,7 +192,8 @@ export default function init(config: HttpClientConfig) {
       return client.get<GetEmailPreferenceResponse>(endpoint).then(res => {
         return {
           inactiveReminder: res.inactive_reminder,
-          productUpdate: res.product_update
+          productUpdate: res.product_update,
+          noteReminder: res.note_reminder
         };
       });
     },
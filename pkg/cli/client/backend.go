@@ -0,0 +1,111 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"strings"
+
+	"github.com/dnote/dnote/pkg/cli/context"
+)
+
+// fileEndpointPrefix is the prefix of the endpoints that sync with a directory
+// instead of a server
+const fileEndpointPrefix = "file://"
+
+// Backend is the transport with which the data is synced. It keeps the changes made
+// to the books and notes, and assigns each change an update sequence number (USN).
+type Backend interface {
+	GetSyncState(ctx context.DnoteCtx) (GetSyncStateResp, error)
+	GetSyncFragment(ctx context.DnoteCtx, afterUSN int) (GetSyncFragmentResp, error)
+	GetBooks(ctx context.DnoteCtx) (GetBooksResp, error)
+	CreateBook(ctx context.DnoteCtx, label string, pinned, archived bool) (CreateBookResp, error)
+	UpdateBook(ctx context.DnoteCtx, label, uuid string, pinned, archived bool) (UpdateBookResp, error)
+	DeleteBook(ctx context.DnoteCtx, uuid string) (DeleteBookResp, error)
+	CreateNote(ctx context.DnoteCtx, bookUUID, content string, dueOn, remindOn int64, pinned, archived bool) (CreateNoteResp, error)
+	UpdateNote(ctx context.DnoteCtx, uuid, bookUUID, content string, public bool, dueOn, remindOn int64, pinned, archived bool) (UpdateNoteResp, error)
+	DeleteNote(ctx context.DnoteCtx, uuid string) (DeleteNoteResp, error)
+}
+
+// httpBackend syncs with the Dnote server
+type httpBackend struct{}
+
+// IsFileEndpoint returns true if the endpoint is a directory rather than a server
+func IsFileEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, fileEndpointPrefix)
+}
+
+// IsAuthorized returns true if the backend for the configured endpoint can be used.
+// The server requires a session, while a directory does not.
+func IsAuthorized(ctx context.DnoteCtx) bool {
+	return ctx.SessionKey != "" || IsFileEndpoint(ctx.APIEndpoint)
+}
+
+// GetBackend returns the backend for the configured endpoint
+func GetBackend(ctx context.DnoteCtx) Backend {
+	if IsFileEndpoint(ctx.APIEndpoint) {
+		return fileBackend{dir: strings.TrimPrefix(ctx.APIEndpoint, fileEndpointPrefix)}
+	}
+
+	return httpBackend{}
+}
+
+// GetSyncState gets the sync state from the backend
+func GetSyncState(ctx context.DnoteCtx) (GetSyncStateResp, error) {
+	return GetBackend(ctx).GetSyncState(ctx)
+}
+
+// GetSyncFragment gets the sync fragment after the given usn from the backend
+func GetSyncFragment(ctx context.DnoteCtx, afterUSN int) (GetSyncFragmentResp, error) {
+	return GetBackend(ctx).GetSyncFragment(ctx, afterUSN)
+}
+
+// GetBooks gets the books from the backend
+func GetBooks(ctx context.DnoteCtx) (GetBooksResp, error) {
+	return GetBackend(ctx).GetBooks(ctx)
+}
+
+// CreateBook creates a new book in the backend
+func CreateBook(ctx context.DnoteCtx, label string, pinned, archived bool) (CreateBookResp, error) {
+	return GetBackend(ctx).CreateBook(ctx, label, pinned, archived)
+}
+
+// UpdateBook updates a book in the backend
+func UpdateBook(ctx context.DnoteCtx, label, uuid string, pinned, archived bool) (UpdateBookResp, error) {
+	return GetBackend(ctx).UpdateBook(ctx, label, uuid, pinned, archived)
+}
+
+// DeleteBook deletes a book and its notes in the backend
+func DeleteBook(ctx context.DnoteCtx, uuid string) (DeleteBookResp, error) {
+	return GetBackend(ctx).DeleteBook(ctx, uuid)
+}
+
+// CreateNote creates a note in the backend
+func CreateNote(ctx context.DnoteCtx, bookUUID, content string, dueOn, remindOn int64, pinned, archived bool) (CreateNoteResp, error) {
+	return GetBackend(ctx).CreateNote(ctx, bookUUID, content, dueOn, remindOn, pinned, archived)
+}
+
+// UpdateNote updates a note in the backend
+func UpdateNote(ctx context.DnoteCtx, uuid, bookUUID, content string, public bool, dueOn, remindOn int64, pinned, archived bool) (UpdateNoteResp, error) {
+	return GetBackend(ctx).UpdateNote(ctx, uuid, bookUUID, content, public, dueOn, remindOn, pinned, archived)
+}
+
+// DeleteNote deletes a note in the backend
+func DeleteNote(ctx context.DnoteCtx, uuid string) (DeleteNoteResp, error) {
+	return GetBackend(ctx).DeleteNote(ctx, uuid)
+}
@@ -21,13 +21,17 @@
 package client
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dnote/dnote/pkg/cli/context"
@@ -44,11 +48,125 @@ var ErrContentTypeMismatch = errors.New("content type mismatch")
 var contentTypeApplicationJSON = "application/json"
 var contentTypeNone = ""
 
+// syncStreamingHeader is the request header with which the client advertises that it
+// applies sync fragments as they arrive, so that the server can send larger fragments
+var syncStreamingHeader = "X-Sync-Streaming"
+
 // requestOptions contians options for requests
 type requestOptions struct {
 	HTTPClient *http.Client
 	// ExpectedContentType is the Content-Type that the client is expecting from the server
 	ExpectedContentType *string
+	// Header contains additional headers for the request
+	Header http.Header
+}
+
+// fromCacheHeader is set on a response that is served from the cache because the
+// server responded with 304 Not Modified. The rest of the headers are from the 304 response.
+const fromCacheHeader = "X-From-Cache"
+
+// cachedResp is a response body cached along with its ETag
+type cachedResp struct {
+	etag        string
+	contentType string
+	body        []byte
+}
+
+// respCache holds the responses to GET requests that had an ETag, so that the
+// server can respond with 304 Not Modified if they have not changed
+var respCache = struct {
+	sync.Mutex
+	items map[string]cachedResp
+}{items: map[string]cachedResp{}}
+
+func getCacheKey(ctx context.DnoteCtx, req *http.Request) string {
+	return fmt.Sprintf("%s %s", ctx.SessionKey, req.URL.String())
+}
+
+func getCachedResp(key string) (cachedResp, bool) {
+	respCache.Lock()
+	defer respCache.Unlock()
+
+	ret, ok := respCache.items[key]
+	return ret, ok
+}
+
+func setCachedResp(key string, c cachedResp) {
+	respCache.Lock()
+	defer respCache.Unlock()
+
+	respCache.items[key] = c
+}
+
+// gzipReadCloser reads a gzip encoded body and closes the underlying body
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (r gzipReadCloser) Close() error {
+	if err := r.Reader.Close(); err != nil {
+		return err
+	}
+
+	return r.body.Close()
+}
+
+// decodeBody replaces the body of the given response with a decompressed one if it is gzip encoded
+func decodeBody(res *http.Response) error {
+	if res.Header.Get("Content-Encoding") != "gzip" {
+		return nil
+	}
+
+	gr, err := gzip.NewReader(res.Body)
+	if err != nil {
+		return errors.Wrap(err, "reading the gzip header")
+	}
+
+	res.Body = gzipReadCloser{Reader: gr, body: res.Body}
+	res.Header.Del("Content-Encoding")
+	res.ContentLength = -1
+
+	return nil
+}
+
+// useCache serves a response that has not been modified from the cache, and caches a
+// response that has an ETag. It must be called after the body is decoded.
+func useCache(key string, res *http.Response) error {
+	if res.StatusCode == http.StatusNotModified {
+		c, ok := getCachedResp(key)
+		if !ok {
+			return errors.New("server responded with 304 for a request that is not cached")
+		}
+
+		res.Body.Close()
+		res.StatusCode = http.StatusOK
+		res.Header.Set("Content-Type", c.contentType)
+		res.Header.Set(fromCacheHeader, "1")
+		res.Body = ioutil.NopCloser(bytes.NewReader(c.body))
+
+		return nil
+	}
+
+	etag := res.Header.Get("ETag")
+	if res.StatusCode != http.StatusOK || etag == "" {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return errors.Wrap(err, "reading the response body")
+	}
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	setCachedResp(key, cachedResp{
+		etag:        etag,
+		contentType: res.Header.Get("Content-Type"),
+		body:        body,
+	})
+
+	return nil
 }
 
 var defaultRequestOptions = requestOptions{
@@ -63,6 +181,7 @@ func getReq(ctx context.DnoteCtx, path, method, body string) (*http.Request, err
 	}
 
 	req.Header.Set("CLI-Version", ctx.Version)
+	req.Header.Set("Accept-Encoding", "gzip")
 
 	if ctx.SessionKey != "" {
 		credential := fmt.Sprintf("Bearer %s", ctx.SessionKey)
@@ -122,6 +241,22 @@ func doReq(ctx context.DnoteCtx, method, path, body string, options *requestOpti
 		return nil, errors.Wrap(err, "getting request")
 	}
 
+	if options != nil {
+		for key, values := range options.Header {
+			for _, v := range values {
+				req.Header.Add(key, v)
+			}
+		}
+	}
+
+	cacheKey := getCacheKey(ctx, req)
+	isCacheable := method == "GET"
+	if isCacheable {
+		if c, ok := getCachedResp(cacheKey); ok {
+			req.Header.Set("If-None-Match", c.etag)
+		}
+	}
+
 	log.Debug("HTTP request: %+v\n", req)
 
 	hc := getHTTPClient(options)
@@ -132,6 +267,16 @@ func doReq(ctx context.DnoteCtx, method, path, body string, options *requestOpti
 
 	log.Debug("HTTP response: %+v\n", res)
 
+	if err := decodeBody(res); err != nil {
+		return res, errors.Wrap(err, "decoding the response body")
+	}
+
+	if isCacheable {
+		if err := useCache(cacheKey, res); err != nil {
+			return res, errors.Wrap(err, "using the cached response")
+		}
+	}
+
 	if err = checkRespErr(res); err != nil {
 		return res, errors.Wrap(err, "server responded with an error")
 	}
@@ -161,7 +306,7 @@ type GetSyncStateResp struct {
 }
 
 // GetSyncState gets the sync state response from the server
-func GetSyncState(ctx context.DnoteCtx) (GetSyncStateResp, error) {
+func (httpBackend) GetSyncState(ctx context.DnoteCtx) (GetSyncStateResp, error) {
 	var ret GetSyncStateResp
 
 	res, err := doAuthorizedReq(ctx, "GET", "/v3/sync/state", "", nil)
@@ -178,6 +323,16 @@ func GetSyncState(ctx context.DnoteCtx) (GetSyncStateResp, error) {
 		return ret, errors.Wrap(err, "unmarshalling the payload")
 	}
 
+	// the cached state has the time of the request it was cached from
+	if res.Header.Get(fromCacheHeader) != "" {
+		date, err := http.ParseTime(res.Header.Get("Date"))
+		if err != nil {
+			return ret, errors.Wrap(err, "parsing the Date header")
+		}
+
+		ret.CurrentTime = date.Unix()
+	}
+
 	return ret, nil
 }
 
@@ -194,6 +349,10 @@ type SyncFragNote struct {
 	Body      string    `json:"content"`
 	Public    bool      `json:"public"`
 	Deleted   bool      `json:"deleted"`
+	DueOn     int64     `json:"due_on"`
+	RemindOn  int64     `json:"remind_on"`
+	Pinned    bool      `json:"pinned"`
+	Archived  bool      `json:"archived"`
 }
 
 // SyncFragBook represents a book in a sync fragment and contains only the necessary information
@@ -206,6 +365,8 @@ type SyncFragBook struct {
 	AddedOn   int64     `json:"added_on"`
 	Label     string    `json:"label"`
 	Deleted   bool      `json:"deleted"`
+	Pinned    bool      `json:"pinned"`
+	Archived  bool      `json:"archived"`
 }
 
 // SyncFragment contains a piece of information about the server's state.
@@ -225,13 +386,22 @@ type GetSyncFragmentResp struct {
 }
 
 // GetSyncFragment gets a sync fragment response from the server
-func GetSyncFragment(ctx context.DnoteCtx, afterUSN int) (GetSyncFragmentResp, error) {
+func (httpBackend) GetSyncFragment(ctx context.DnoteCtx, afterUSN int) (GetSyncFragmentResp, error) {
 	v := url.Values{}
 	v.Set("after_usn", strconv.Itoa(afterUSN))
 	queryStr := v.Encode()
 
+	opts := requestOptions{
+		Header: http.Header{
+			syncStreamingHeader: []string{"true"},
+		},
+	}
+
 	path := fmt.Sprintf("/v3/sync/fragment?%s", queryStr)
-	res, err := doAuthorizedReq(ctx, "GET", path, "", nil)
+	res, err := doAuthorizedReq(ctx, "GET", path, "", &opts)
+	if err != nil {
+		return GetSyncFragmentResp{}, errors.Wrap(err, "making the request")
+	}
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
@@ -258,7 +428,9 @@ type RespBook struct {
 
 // CreateBookPayload is a payload for creating a book
 type CreateBookPayload struct {
-	Name string `json:"name"`
+	Name     string `json:"name"`
+	Pinned   bool   `json:"pinned,omitempty"`
+	Archived bool   `json:"archived,omitempty"`
 }
 
 // CreateBookResp is the response from create book api
@@ -267,9 +439,11 @@ type CreateBookResp struct {
 }
 
 // CreateBook creates a new book in the server
-func CreateBook(ctx context.DnoteCtx, label string) (CreateBookResp, error) {
+func (httpBackend) CreateBook(ctx context.DnoteCtx, label string, pinned, archived bool) (CreateBookResp, error) {
 	payload := CreateBookPayload{
-		Name: label,
+		Name:     label,
+		Pinned:   pinned,
+		Archived: archived,
 	}
 	b, err := json.Marshal(payload)
 	if err != nil {
@@ -290,7 +464,9 @@ func CreateBook(ctx context.DnoteCtx, label string) (CreateBookResp, error) {
 }
 
 type updateBookPayload struct {
-	Name *string `json:"name"`
+	Name     *string `json:"name"`
+	Pinned   *bool   `json:"pinned"`
+	Archived *bool   `json:"archived"`
 }
 
 // UpdateBookResp is the response from create book api
@@ -299,9 +475,11 @@ type UpdateBookResp struct {
 }
 
 // UpdateBook updates a book in the server
-func UpdateBook(ctx context.DnoteCtx, label, uuid string) (UpdateBookResp, error) {
+func (httpBackend) UpdateBook(ctx context.DnoteCtx, label, uuid string, pinned, archived bool) (UpdateBookResp, error) {
 	payload := updateBookPayload{
-		Name: &label,
+		Name:     &label,
+		Pinned:   &pinned,
+		Archived: &archived,
 	}
 	b, err := json.Marshal(payload)
 	if err != nil {
@@ -329,7 +507,7 @@ type DeleteBookResp struct {
 }
 
 // DeleteBook deletes a book in the server
-func DeleteBook(ctx context.DnoteCtx, uuid string) (DeleteBookResp, error) {
+func (httpBackend) DeleteBook(ctx context.DnoteCtx, uuid string) (DeleteBookResp, error) {
 	endpoint := fmt.Sprintf("/v3/books/%s", uuid)
 	res, err := doAuthorizedReq(ctx, "DELETE", endpoint, "", nil)
 	if err != nil {
@@ -348,6 +526,10 @@ func DeleteBook(ctx context.DnoteCtx, uuid string) (DeleteBookResp, error) {
 type CreateNotePayload struct {
 	BookUUID string `json:"book_uuid"`
 	Body     string `json:"content"`
+	DueOn    int64  `json:"due_on,omitempty"`
+	RemindOn int64  `json:"remind_on,omitempty"`
+	Pinned   bool   `json:"pinned,omitempty"`
+	Archived bool   `json:"archived,omitempty"`
 }
 
 // CreateNoteResp is the response from create note endpoint
@@ -378,10 +560,14 @@ type RespNote struct {
 }
 
 // CreateNote creates a note in the server
-func CreateNote(ctx context.DnoteCtx, bookUUID, content string) (CreateNoteResp, error) {
+func (httpBackend) CreateNote(ctx context.DnoteCtx, bookUUID, content string, dueOn, remindOn int64, pinned, archived bool) (CreateNoteResp, error) {
 	payload := CreateNotePayload{
 		BookUUID: bookUUID,
 		Body:     content,
+		DueOn:    dueOn,
+		RemindOn: remindOn,
+		Pinned:   pinned,
+		Archived: archived,
 	}
 	b, err := json.Marshal(payload)
 	if err != nil {
@@ -405,6 +591,10 @@ type updateNotePayload struct {
 	BookUUID *string `json:"book_uuid"`
 	Body     *string `json:"content"`
 	Public   *bool   `json:"public"`
+	DueOn    *int64  `json:"due_on"`
+	RemindOn *int64  `json:"remind_on"`
+	Pinned   *bool   `json:"pinned"`
+	Archived *bool   `json:"archived"`
 }
 
 // UpdateNoteResp is the response from create book api
@@ -414,11 +604,15 @@ type UpdateNoteResp struct {
 }
 
 // UpdateNote updates a note in the server
-func UpdateNote(ctx context.DnoteCtx, uuid, bookUUID, content string, public bool) (UpdateNoteResp, error) {
+func (httpBackend) UpdateNote(ctx context.DnoteCtx, uuid, bookUUID, content string, public bool, dueOn, remindOn int64, pinned, archived bool) (UpdateNoteResp, error) {
 	payload := updateNotePayload{
 		BookUUID: &bookUUID,
 		Body:     &content,
 		Public:   &public,
+		DueOn:    &dueOn,
+		RemindOn: &remindOn,
+		Pinned:   &pinned,
+		Archived: &archived,
 	}
 	b, err := json.Marshal(payload)
 	if err != nil {
@@ -446,7 +640,7 @@ type DeleteNoteResp struct {
 }
 
 // DeleteNote removes a note in the server
-func DeleteNote(ctx context.DnoteCtx, uuid string) (DeleteNoteResp, error) {
+func (httpBackend) DeleteNote(ctx context.DnoteCtx, uuid string) (DeleteNoteResp, error) {
 	endpoint := fmt.Sprintf("/v3/notes/%s", uuid)
 	res, err := doAuthorizedReq(ctx, "DELETE", endpoint, "", nil)
 	if err != nil {
@@ -468,7 +662,7 @@ type GetBooksResp []struct {
 }
 
 // GetBooks gets books from the server
-func GetBooks(ctx context.DnoteCtx, sessionKey string) (GetBooksResp, error) {
+func (httpBackend) GetBooks(ctx context.DnoteCtx) (GetBooksResp, error) {
 	res, err := doAuthorizedReq(ctx, "GET", "/v3/books", "", nil)
 	if err != nil {
 		return GetBooksResp{}, errors.Wrap(err, "making http request")
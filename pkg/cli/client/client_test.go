@@ -1,11 +1,13 @@
 package client
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/dnote/dnote/pkg/assert"
 	"github.com/dnote/dnote/pkg/cli/context"
@@ -139,3 +141,67 @@ func TestSignOut(t *testing.T) {
 		assert.Equal(t, errors.Cause(err), ErrContentTypeMismatch, "error cause mismatch")
 	})
 }
+
+func TestGetSyncState_compressedAndCached(t *testing.T) {
+	var requestCount, notModifiedCount int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.String() != "/v3/sync/state" || r.Method != "GET" {
+			t.Fatalf("unrecognized endpoint reached Method: %s Path: %s", r.Method, r.URL.Path)
+		}
+
+		requestCount++
+		assert.Equal(t, r.Header.Get("Accept-Encoding"), "gzip", "Accept-Encoding mismatch")
+
+		now := time.Unix(1550436135+int64(requestCount), 0)
+		w.Header().Set("Date", now.UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", `W/"1-12"`)
+		if r.Header.Get("If-None-Match") == `W/"1-12"` {
+			notModifiedCount++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+
+		gw := gzip.NewWriter(w)
+		if err := json.NewEncoder(gw).Encode(GetSyncStateResp{FullSyncBefore: 1, MaxUSN: 12, CurrentTime: now.Unix()}); err != nil {
+			t.Fatal(errors.Wrap(err, "encoding the response"))
+		}
+		gw.Close()
+	}))
+	defer ts.Close()
+
+	ctx := context.DnoteCtx{APIEndpoint: ts.URL, SessionKey: "somekey"}
+
+	for i := 0; i < 2; i++ {
+		got, err := GetSyncState(ctx)
+		if err != nil {
+			t.Fatal(errors.Wrapf(err, "getting the sync state %d", i))
+		}
+
+		// the current time of a cached state is taken from the Date header
+		expected := GetSyncStateResp{FullSyncBefore: 1, MaxUSN: 12, CurrentTime: 1550436136 + int64(i)}
+		assert.Equal(t, got, expected, fmt.Sprintf("result mismatch for request %d", i))
+	}
+
+	assert.Equal(t, requestCount, 2, "request count mismatch")
+	assert.Equal(t, notModifiedCount, 1, "the second request should have been served from the cache")
+}
+
+func TestGetSyncFragment_streaming(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Header.Get(syncStreamingHeader), "true", "streaming header mismatch")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(testutils.MustMarshalJSON(t, GetSyncFragmentResp{Fragment: SyncFragment{FragMaxUSN: 3}}))
+	}))
+	defer ts.Close()
+
+	got, err := GetSyncFragment(context.DnoteCtx{APIEndpoint: ts.URL, SessionKey: "somekey"}, 0)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the fragment"))
+	}
+
+	assert.Equal(t, got.Fragment.FragMaxUSN, 3, "FragMaxUSN mismatch")
+}
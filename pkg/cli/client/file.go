@@ -0,0 +1,841 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	// journalDirName is the name of the directory holding the journal entries
+	journalDirName = "journal"
+	// journalLockFilename is the name of the file held while writing to the journal
+	journalLockFilename = "journal.lock"
+	// fileFragmentLimit is the maximum number of changes in a sync fragment
+	fileFragmentLimit = 1000
+
+	entryTypeBook = "book"
+	entryTypeNote = "note"
+)
+
+var (
+	// journalLockTimeout is how long to wait for another writer to release the journal
+	journalLockTimeout = 10 * time.Second
+	// journalLockRefreshInterval is how often a writer refreshes the lock it holds
+	journalLockRefreshInterval = 10 * time.Second
+	// journalLockStaleAfter is the duration after which a lock held on another machine
+	// that has not been refreshed is considered abandoned
+	journalLockStaleAfter = time.Minute
+)
+
+// errUSNTaken is an error for writing an entry with a USN that another entry already has
+var errUSNTaken = errors.New("the usn was taken by another sync")
+
+// fileBackend syncs with an append-only journal of changes in a directory. The directory
+// can be shared between machines using a network file system or a file syncing service.
+// Each change is written to its own file named after its USN, so that no file is ever
+// modified and no two changes can have the same USN. Writers also hold a lock file in
+// the directory so that they do not compete for the next USN.
+type fileBackend struct {
+	dir string
+}
+
+// journalEntry is a change in the journal. It holds the whole state of the resource
+// after the change.
+type journalEntry struct {
+	USN      int    `json:"usn"`
+	Type     string `json:"type"`
+	UUID     string `json:"uuid"`
+	Deleted  bool   `json:"deleted"`
+	Label    string `json:"label,omitempty"`
+	BookUUID string `json:"book_uuid,omitempty"`
+	Body     string `json:"body,omitempty"`
+	AddedOn  int64  `json:"added_on,omitempty"`
+	EditedOn int64  `json:"edited_on,omitempty"`
+	Public   bool   `json:"public,omitempty"`
+	DueOn    int64  `json:"due_on,omitempty"`
+	RemindOn int64  `json:"remind_on,omitempty"`
+	Pinned   bool   `json:"pinned,omitempty"`
+	Archived bool   `json:"archived,omitempty"`
+	// Replaces is the name of the stray entry that this entry appends again
+	Replaces string `json:"replaces,omitempty"`
+
+	// name is the name of the file containing the entry
+	name string
+}
+
+// journal is the state obtained by replaying the journal entries in the order of USNs.
+// Entries are only ever appended, so that a reader that has seen the entries up to a
+// USN never misses an entry before it.
+type journal struct {
+	files map[string]bool
+	// entries are the entries without a gap in the USNs, in the order of USNs
+	entries []journalEntry
+	// pending are the entries that follow a USN whose entry has not arrived yet,
+	// which can happen while a file syncing service is copying the directory
+	pending map[int]journalEntry
+	// strays are the entries written under a USN that another entry has, such as the
+	// conflicted copies made by a file syncing service. They are appended again by
+	// the next writer so that readers who have gone past their USN get them.
+	strays   []journalEntry
+	replaced map[string]bool
+	books    map[string]journalEntry
+	notes    map[string]journalEntry
+	maxUSN   int
+}
+
+func newJournal() *journal {
+	return &journal{
+		files:    map[string]bool{},
+		pending:  map[int]journalEntry{},
+		replaced: map[string]bool{},
+		books:    map[string]journalEntry{},
+		notes:    map[string]journalEntry{},
+	}
+}
+
+// append adds the entry that has the next USN to the end of the journal
+func (j *journal) append(e journalEntry) {
+	j.entries = append(j.entries, e)
+	j.maxUSN = e.USN
+
+	switch e.Type {
+	case entryTypeBook:
+		j.books[e.UUID] = e
+	case entryTypeNote:
+		j.notes[e.UUID] = e
+	}
+
+	if e.Replaces != "" {
+		j.replaced[e.Replaces] = true
+	}
+}
+
+// add adds the given entries read from the journal directory. Entries are appended
+// only when all the entries before them have been added.
+func (j *journal) add(entries []journalEntry) {
+	for _, e := range entries {
+		if e.name == getEntryFilename(e.USN) {
+			j.pending[e.USN] = e
+		} else {
+			j.strays = append(j.strays, e)
+		}
+	}
+
+	for {
+		e, ok := j.pending[j.maxUSN+1]
+		if !ok {
+			break
+		}
+
+		delete(j.pending, e.USN)
+		j.append(e)
+	}
+}
+
+// getStrays returns the stray entries that need to be appended again, in the order
+// of the file names. A stray entry is dropped if its resource has changed since.
+func (j *journal) getStrays() []journalEntry {
+	var ret []journalEntry
+	for _, e := range j.strays {
+		if j.replaced[e.name] || e.USN > j.maxUSN {
+			continue
+		}
+
+		var cur journalEntry
+		switch e.Type {
+		case entryTypeBook:
+			cur = j.books[e.UUID]
+		case entryTypeNote:
+			cur = j.notes[e.UUID]
+		}
+		if cur.USN > e.USN {
+			continue
+		}
+
+		ret = append(ret, e)
+	}
+
+	sort.Slice(ret, func(i, k int) bool {
+		return ret[i].name < ret[k].name
+	})
+
+	return ret
+}
+
+// isLatest returns true if the given entry holds the current state of its resource
+func (j *journal) isLatest(e journalEntry) bool {
+	var cur journalEntry
+	switch e.Type {
+	case entryTypeBook:
+		cur = j.books[e.UUID]
+	case entryTypeNote:
+		cur = j.notes[e.UUID]
+	}
+
+	return cur.USN == e.USN
+}
+
+func (j *journal) findBookByLabel(label string) (journalEntry, bool) {
+	for _, b := range j.books {
+		if !b.Deleted && b.Label == label {
+			return b, true
+		}
+	}
+
+	return journalEntry{}, false
+}
+
+// journalCache holds the journals read by this process so that only the entries
+// written since the last read need to be read again
+var journalCache = struct {
+	sync.Mutex
+	journals map[string]*journal
+}{journals: map[string]*journal{}}
+
+func getEntryFilename(usn int) string {
+	return fmt.Sprintf("%010d.json", usn)
+}
+
+func (b fileBackend) journalDir() string {
+	return filepath.Join(b.dir, journalDirName)
+}
+
+// load reads the new entries in the journal. The caller must hold the journal cache.
+func (b fileBackend) load() (*journal, error) {
+	if _, err := os.Stat(b.dir); err != nil {
+		return nil, errors.Wrapf(err, "checking the sync directory %s", b.dir)
+	}
+
+	j, ok := journalCache.journals[b.dir]
+	if !ok {
+		j = newJournal()
+		journalCache.journals[b.dir] = j
+	}
+
+	files, err := ioutil.ReadDir(b.journalDir())
+	if os.IsNotExist(err) {
+		return j, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "listing the journal")
+	}
+
+	var entries []journalEntry
+	for _, f := range files {
+		name := f.Name()
+		// skip the files being written
+		if strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") || j.files[name] {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(b.journalDir(), name))
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading the journal entry %s", name)
+		}
+
+		var e journalEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			// the entry may still be being copied to this machine. It is read again next time.
+			log.Debug("unmarshalling the journal entry %s: %s\n", name, err)
+			continue
+		}
+
+		e.name = name
+		j.files[name] = true
+		entries = append(entries, e)
+	}
+
+	j.add(entries)
+
+	return j, nil
+}
+
+// read returns the up-to-date journal. If there are stray entries, they are appended
+// again first.
+func (b fileBackend) read() (*journal, error) {
+	journalCache.Lock()
+	j, err := b.load()
+	journalCache.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(j.getStrays()) == 0 {
+		return j, nil
+	}
+
+	err = b.update(func(j *journal, nextUSN func() int) ([]journalEntry, error) {
+		return nil, nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "appending the stray entries")
+	}
+
+	return j, nil
+}
+
+// lockOwner identifies the process holding the lock of the journal
+type lockOwner struct {
+	Hostname string `json:"hostname"`
+	PID      int    `json:"pid"`
+}
+
+func getLockOwner() lockOwner {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Debug("getting the hostname: %s\n", err)
+	}
+
+	return lockOwner{Hostname: hostname, PID: os.Getpid()}
+}
+
+// isLockAbandoned returns true if the lock was left behind by a writer that did not
+// finish. The liveness of the holder can only be checked on the same machine. The lock
+// held on another machine is abandoned if it has not been refreshed for a while.
+func isLockAbandoned(path string, self lockOwner) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	var owner lockOwner
+	if data, err := ioutil.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &owner); err != nil {
+			log.Debug("unmarshalling the journal lock: %s\n", err)
+		}
+	}
+
+	if owner.Hostname != "" && owner.Hostname == self.Hostname {
+		return !utils.ProcessExists(owner.PID)
+	}
+
+	return time.Since(info.ModTime()) > journalLockStaleAfter
+}
+
+// createJournalLock exclusively creates the lock file and writes the owner into it
+func createJournalLock(path string, owner lockOwner) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := json.NewEncoder(f).Encode(owner); err != nil {
+		return errors.Wrap(err, "writing the owner")
+	}
+
+	return nil
+}
+
+// lock acquires the lock file of the journal, waiting for other writers if necessary.
+// The lock is refreshed until it is released so that it does not look abandoned.
+func (b fileBackend) lock() (func(), error) {
+	path := filepath.Join(b.dir, journalLockFilename)
+	deadline := time.Now().Add(journalLockTimeout)
+	self := getLockOwner()
+
+	for {
+		err := createJournalLock(path, self)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, errors.Wrap(err, "creating the lock file")
+		}
+
+		if isLockAbandoned(path, self) {
+			log.Debug("taking over an abandoned journal lock\n")
+			os.Remove(path)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, errors.Errorf("the journal is locked by another sync. If no sync is running, remove %s", path)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(journalLockRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				now := time.Now()
+				if err := os.Chtimes(path, now, now); err != nil {
+					log.Debug("refreshing the journal lock: %s\n", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		os.Remove(path)
+	}, nil
+}
+
+// claim writes the entry to the file named after its USN. It returns errUSNTaken if
+// the file already exists.
+func (b fileBackend) claim(e journalEntry) (string, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", errors.Wrap(err, "marshalling the entry")
+	}
+
+	id, err := utils.GenerateUUID()
+	if err != nil {
+		return "", errors.Wrap(err, "generating an id for the entry")
+	}
+
+	name := getEntryFilename(e.USN)
+	path := filepath.Join(b.journalDir(), name)
+
+	// write to a hidden file first so that readers never see a partially written entry,
+	// and link it so that an existing entry is never replaced
+	tmpPath := filepath.Join(b.journalDir(), fmt.Sprintf(".%s-%s", name, id))
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return "", errors.Wrap(err, "writing the entry")
+	}
+	defer os.Remove(tmpPath)
+
+	err = os.Link(tmpPath, path)
+	if err == nil {
+		return name, nil
+	} else if os.IsExist(err) {
+		return "", errUSNTaken
+	}
+
+	// fall back to creating the file exclusively where hard links are not supported
+	log.Debug("linking the entry: %s\n", err)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return "", errUSNTaken
+	} else if err != nil {
+		return "", errors.Wrap(err, "creating the entry")
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return "", errors.Wrap(err, "writing the entry")
+	}
+
+	return name, nil
+}
+
+// write appends the given entries to the journal
+func (b fileBackend) write(j *journal, entries []journalEntry) error {
+	if err := os.MkdirAll(b.journalDir(), 0755); err != nil {
+		return errors.Wrap(err, "creating the journal directory")
+	}
+
+	for _, e := range entries {
+		name, err := b.claim(e)
+		if err != nil {
+			return errors.Wrapf(err, "writing the entry %d", e.USN)
+		}
+
+		e.name = name
+		j.files[name] = true
+		j.append(e)
+	}
+
+	return nil
+}
+
+// update records the changes made by the given function. The function is given the
+// up-to-date journal and the next USN, and returns the entries to append. The stray
+// entries are appended again before the function is called.
+func (b fileBackend) update(fn func(j *journal, nextUSN func() int) ([]journalEntry, error)) error {
+	journalCache.Lock()
+	defer journalCache.Unlock()
+
+	unlock, err := b.lock()
+	if err != nil {
+		return errors.Wrap(err, "locking the journal")
+	}
+	defer unlock()
+
+	j, err := b.load()
+	if err != nil {
+		return errors.Wrap(err, "reading the journal")
+	}
+	if len(j.pending) > 0 {
+		return errors.Errorf("the journal is missing the entry %d. Wait for the sync directory to be copied completely", j.maxUSN+1)
+	}
+
+	usn := j.maxUSN
+	nextUSN := func() int {
+		usn++
+		return usn
+	}
+
+	var strays []journalEntry
+	for _, e := range j.getStrays() {
+		e.Replaces = e.name
+		e.USN = nextUSN()
+		strays = append(strays, e)
+	}
+	if err := b.write(j, strays); err != nil {
+		return errors.Wrap(err, "appending the stray entries")
+	}
+
+	entries, err := fn(j, nextUSN)
+	if err != nil {
+		return err
+	}
+
+	return b.write(j, entries)
+}
+
+func (b fileBackend) GetSyncState(ctx context.DnoteCtx) (GetSyncStateResp, error) {
+	j, err := b.read()
+	if err != nil {
+		return GetSyncStateResp{}, errors.Wrap(err, "reading the journal")
+	}
+
+	return GetSyncStateResp{
+		FullSyncBefore: 0,
+		MaxUSN:         j.maxUSN,
+		CurrentTime:    ctx.Clock.Now().Unix(),
+	}, nil
+}
+
+func (b fileBackend) GetSyncFragment(ctx context.DnoteCtx, afterUSN int) (GetSyncFragmentResp, error) {
+	j, err := b.read()
+	if err != nil {
+		return GetSyncFragmentResp{}, errors.Wrap(err, "reading the journal")
+	}
+
+	frag := SyncFragment{
+		UserMaxUSN:    j.maxUSN,
+		CurrentTime:   ctx.Clock.Now().Unix(),
+		Notes:         []SyncFragNote{},
+		Books:         []SyncFragBook{},
+		ExpungedNotes: []string{},
+		ExpungedBooks: []string{},
+	}
+
+	idx := sort.Search(len(j.entries), func(i int) bool {
+		return j.entries[i].USN > afterUSN
+	})
+
+	for count := 0; idx < len(j.entries) && count < fileFragmentLimit; idx, count = idx+1, count+1 {
+		e := j.entries[idx]
+		frag.FragMaxUSN = e.USN
+
+		// only the latest change of a resource is sent, as it holds the current state
+		if !j.isLatest(e) {
+			continue
+		}
+
+		switch e.Type {
+		case entryTypeNote:
+			if e.Deleted {
+				frag.ExpungedNotes = append(frag.ExpungedNotes, e.UUID)
+			} else {
+				frag.Notes = append(frag.Notes, SyncFragNote{
+					UUID:     e.UUID,
+					BookUUID: e.BookUUID,
+					USN:      e.USN,
+					AddedOn:  e.AddedOn,
+					EditedOn: e.EditedOn,
+					Body:     e.Body,
+					Public:   e.Public,
+					DueOn:    e.DueOn,
+					RemindOn: e.RemindOn,
+					Pinned:   e.Pinned,
+					Archived: e.Archived,
+				})
+			}
+		case entryTypeBook:
+			if e.Deleted {
+				frag.ExpungedBooks = append(frag.ExpungedBooks, e.UUID)
+			} else {
+				frag.Books = append(frag.Books, SyncFragBook{
+					UUID:     e.UUID,
+					USN:      e.USN,
+					Label:    e.Label,
+					Pinned:   e.Pinned,
+					Archived: e.Archived,
+				})
+			}
+		}
+	}
+
+	return GetSyncFragmentResp{Fragment: frag}, nil
+}
+
+func (b fileBackend) GetBooks(ctx context.DnoteCtx) (GetBooksResp, error) {
+	j, err := b.read()
+	if err != nil {
+		return GetBooksResp{}, errors.Wrap(err, "reading the journal")
+	}
+
+	ret := GetBooksResp{}
+	for _, book := range j.books {
+		if book.Deleted {
+			continue
+		}
+
+		ret = append(ret, GetBooksResp{{UUID: book.UUID, Label: book.Label}}...)
+	}
+
+	sort.Slice(ret, func(i, k int) bool {
+		return ret[i].Label < ret[k].Label
+	})
+
+	return ret, nil
+}
+
+func newRespBook(e journalEntry) RespBook {
+	return RespBook{
+		UUID:  e.UUID,
+		USN:   e.USN,
+		Label: e.Label,
+	}
+}
+
+func newRespNote(e journalEntry, book journalEntry) RespNote {
+	return RespNote{
+		UUID:    e.UUID,
+		Body:    e.Body,
+		AddedOn: e.AddedOn,
+		Public:  e.Public,
+		USN:     e.USN,
+		Book: respNoteBook{
+			UUID:  book.UUID,
+			Label: book.Label,
+		},
+	}
+}
+
+func (b fileBackend) CreateBook(ctx context.DnoteCtx, label string, pinned, archived bool) (CreateBookResp, error) {
+	var ret CreateBookResp
+
+	err := b.update(func(j *journal, nextUSN func() int) ([]journalEntry, error) {
+		if _, ok := j.findBookByLabel(label); ok {
+			return nil, errors.New("duplicate book exists")
+		}
+
+		uuid, err := utils.GenerateUUID()
+		if err != nil {
+			return nil, errors.Wrap(err, "generating uuid")
+		}
+
+		e := journalEntry{USN: nextUSN(), Type: entryTypeBook, UUID: uuid, Label: label, Pinned: pinned, Archived: archived}
+		ret.Book = newRespBook(e)
+
+		return []journalEntry{e}, nil
+	})
+	if err != nil {
+		return ret, errors.Wrap(err, "creating a book in the journal")
+	}
+
+	return ret, nil
+}
+
+func (b fileBackend) UpdateBook(ctx context.DnoteCtx, label, uuid string, pinned, archived bool) (UpdateBookResp, error) {
+	var ret UpdateBookResp
+
+	err := b.update(func(j *journal, nextUSN func() int) ([]journalEntry, error) {
+		e, ok := j.books[uuid]
+		if !ok {
+			return nil, errors.Errorf("book %s not found", uuid)
+		}
+		if dup, ok := j.findBookByLabel(label); ok && dup.UUID != uuid {
+			return nil, errors.New("duplicate book exists")
+		}
+
+		e.USN = nextUSN()
+		e.Label = label
+		e.Pinned = pinned
+		e.Archived = archived
+		e.Deleted = false
+		ret.Book = newRespBook(e)
+
+		return []journalEntry{e}, nil
+	})
+	if err != nil {
+		return ret, errors.Wrap(err, "updating a book in the journal")
+	}
+
+	return ret, nil
+}
+
+func (b fileBackend) DeleteBook(ctx context.DnoteCtx, uuid string) (DeleteBookResp, error) {
+	var ret DeleteBookResp
+
+	err := b.update(func(j *journal, nextUSN func() int) ([]journalEntry, error) {
+		book, ok := j.books[uuid]
+		if !ok {
+			return nil, errors.Errorf("book %s not found", uuid)
+		}
+
+		var notes []journalEntry
+		for _, n := range j.notes {
+			if n.BookUUID == uuid && !n.Deleted {
+				notes = append(notes, n)
+			}
+		}
+		sort.Slice(notes, func(i, k int) bool {
+			return notes[i].USN < notes[k].USN
+		})
+
+		// delete the notes first, as the server does
+		var entries []journalEntry
+		for _, n := range notes {
+			n.USN = nextUSN()
+			n.Deleted = true
+			n.Body = ""
+			entries = append(entries, n)
+		}
+
+		book.USN = nextUSN()
+		book.Deleted = true
+		book.Label = ""
+		entries = append(entries, book)
+
+		ret.Status = 200
+		ret.Book = newRespBook(book)
+
+		return entries, nil
+	})
+	if err != nil {
+		return ret, errors.Wrap(err, "deleting a book in the journal")
+	}
+
+	return ret, nil
+}
+
+func (b fileBackend) CreateNote(ctx context.DnoteCtx, bookUUID, content string, dueOn, remindOn int64, pinned, archived bool) (CreateNoteResp, error) {
+	var ret CreateNoteResp
+
+	err := b.update(func(j *journal, nextUSN func() int) ([]journalEntry, error) {
+		book, ok := j.books[bookUUID]
+		if !ok || book.Deleted {
+			return nil, errors.Errorf("book %s not found", bookUUID)
+		}
+
+		uuid, err := utils.GenerateUUID()
+		if err != nil {
+			return nil, errors.Wrap(err, "generating uuid")
+		}
+
+		e := journalEntry{
+			USN:      nextUSN(),
+			Type:     entryTypeNote,
+			UUID:     uuid,
+			BookUUID: bookUUID,
+			Body:     content,
+			AddedOn:  ctx.Clock.Now().UnixNano(),
+			DueOn:    dueOn,
+			RemindOn: remindOn,
+			Pinned:   pinned,
+			Archived: archived,
+		}
+		ret.Result = newRespNote(e, book)
+
+		return []journalEntry{e}, nil
+	})
+	if err != nil {
+		return ret, errors.Wrap(err, "creating a note in the journal")
+	}
+
+	return ret, nil
+}
+
+func (b fileBackend) UpdateNote(ctx context.DnoteCtx, uuid, bookUUID, content string, public bool, dueOn, remindOn int64, pinned, archived bool) (UpdateNoteResp, error) {
+	var ret UpdateNoteResp
+
+	err := b.update(func(j *journal, nextUSN func() int) ([]journalEntry, error) {
+		e, ok := j.notes[uuid]
+		if !ok {
+			return nil, errors.Errorf("note %s not found", uuid)
+		}
+		book, ok := j.books[bookUUID]
+		if !ok || book.Deleted {
+			return nil, errors.Errorf("book %s not found", bookUUID)
+		}
+
+		e.USN = nextUSN()
+		e.BookUUID = bookUUID
+		e.Body = content
+		e.Public = public
+		e.DueOn = dueOn
+		e.RemindOn = remindOn
+		e.Pinned = pinned
+		e.Archived = archived
+		e.EditedOn = ctx.Clock.Now().UnixNano()
+		e.Deleted = false
+
+		ret.Status = 200
+		ret.Result = newRespNote(e, book)
+
+		return []journalEntry{e}, nil
+	})
+	if err != nil {
+		return ret, errors.Wrap(err, "updating a note in the journal")
+	}
+
+	return ret, nil
+}
+
+func (b fileBackend) DeleteNote(ctx context.DnoteCtx, uuid string) (DeleteNoteResp, error) {
+	var ret DeleteNoteResp
+
+	err := b.update(func(j *journal, nextUSN func() int) ([]journalEntry, error) {
+		e, ok := j.notes[uuid]
+		if !ok {
+			return nil, errors.Errorf("note %s not found", uuid)
+		}
+
+		e.USN = nextUSN()
+		e.Deleted = true
+		e.Body = ""
+
+		ret.Status = 200
+		ret.Result = newRespNote(e, j.books[e.BookUUID])
+
+		return []journalEntry{e}, nil
+	})
+	if err != nil {
+		return ret, errors.Wrap(err, "deleting a note in the journal")
+	}
+
+	return ret, nil
+}
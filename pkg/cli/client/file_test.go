@@ -0,0 +1,340 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/clock"
+	"github.com/pkg/errors"
+)
+
+func setupFileBackend(t *testing.T) (context.DnoteCtx, string) {
+	dir, err := ioutil.TempDir("", "dnote-journal")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating the directory"))
+	}
+
+	ctx := context.DnoteCtx{
+		APIEndpoint: fileEndpointPrefix + dir,
+		Clock:       clock.NewMock(),
+	}
+
+	return ctx, dir
+}
+
+func TestGetBackend(t *testing.T) {
+	_, ok := GetBackend(context.DnoteCtx{APIEndpoint: "https://api.getdnote.com"}).(httpBackend)
+	assert.Equal(t, ok, true, "http backend mismatch")
+
+	b, ok := GetBackend(context.DnoteCtx{APIEndpoint: "file:///mnt/dnote"}).(fileBackend)
+	assert.Equal(t, ok, true, "file backend mismatch")
+	assert.Equal(t, b.dir, "/mnt/dnote", "dir mismatch")
+
+	assert.Equal(t, IsAuthorized(context.DnoteCtx{APIEndpoint: "https://api.getdnote.com"}), false, "authorized without session mismatch")
+	assert.Equal(t, IsAuthorized(context.DnoteCtx{APIEndpoint: "https://api.getdnote.com", SessionKey: "key"}), true, "authorized with session mismatch")
+	assert.Equal(t, IsAuthorized(context.DnoteCtx{APIEndpoint: "file:///mnt/dnote"}), true, "authorized file mismatch")
+}
+
+func TestFileBackend(t *testing.T) {
+	ctx, dir := setupFileBackend(t)
+	defer os.RemoveAll(dir)
+
+	b1, err := CreateBook(ctx, "js", true, false)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating b1"))
+	}
+	assert.Equal(t, b1.Book.USN, 1, "b1 usn mismatch")
+
+	if _, err := CreateBook(ctx, "js", false, false); err == nil {
+		t.Fatal("a duplicate book should not be created")
+	}
+
+	n1, err := CreateNote(ctx, b1.Book.UUID, "n1 body", 0, 0, false, false)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating n1"))
+	}
+	assert.Equal(t, n1.Result.USN, 2, "n1 usn mismatch")
+	assert.Equal(t, n1.Result.Book.Label, "js", "n1 book mismatch")
+
+	n2, err := CreateNote(ctx, b1.Book.UUID, "n2 body", 0, 0, false, false)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating n2"))
+	}
+
+	if _, err := UpdateNote(ctx, n1.Result.UUID, b1.Book.UUID, "n1 body edited", false, 0, 0, false, true); err != nil {
+		t.Fatal(errors.Wrap(err, "updating n1"))
+	}
+	if _, err := DeleteNote(ctx, n2.Result.UUID); err != nil {
+		t.Fatal(errors.Wrap(err, "deleting n2"))
+	}
+	if _, err := CreateNote(ctx, "nonexistent-book-uuid", "body", 0, 0, false, false); err == nil {
+		t.Fatal("a note should not be created in a nonexistent book")
+	}
+
+	state, err := GetSyncState(ctx)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the sync state"))
+	}
+	assert.Equal(t, state.MaxUSN, 5, "max usn mismatch")
+	assert.Equal(t, state.FullSyncBefore, 0, "full sync before mismatch")
+
+	t.Run("all changes", func(t *testing.T) {
+		resp, err := GetSyncFragment(ctx, 0)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "getting the fragment"))
+		}
+
+		frag := resp.Fragment
+		assert.Equal(t, frag.FragMaxUSN, 5, "FragMaxUSN mismatch")
+		assert.Equal(t, frag.UserMaxUSN, 5, "UserMaxUSN mismatch")
+		assert.Equal(t, len(frag.Books), 1, "books length mismatch")
+		assert.Equal(t, frag.Books[0].Label, "js", "book label mismatch")
+		assert.Equal(t, frag.Books[0].Pinned, true, "book pinned mismatch")
+		// the creation of n1 is superseded by the update
+		assert.Equal(t, len(frag.Notes), 1, "notes length mismatch")
+		assert.Equal(t, frag.Notes[0].Body, "n1 body edited", "note body mismatch")
+		assert.Equal(t, frag.Notes[0].USN, 4, "note usn mismatch")
+		assert.Equal(t, frag.Notes[0].Archived, true, "note archived mismatch")
+		assert.DeepEqual(t, frag.ExpungedNotes, []string{n2.Result.UUID}, "expunged notes mismatch")
+	})
+
+	t.Run("after usn", func(t *testing.T) {
+		resp, err := GetSyncFragment(ctx, 4)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "getting the fragment"))
+		}
+
+		frag := resp.Fragment
+		assert.Equal(t, frag.FragMaxUSN, 5, "FragMaxUSN mismatch")
+		assert.Equal(t, len(frag.Books), 0, "books length mismatch")
+		assert.Equal(t, len(frag.Notes), 0, "notes length mismatch")
+		assert.DeepEqual(t, frag.ExpungedNotes, []string{n2.Result.UUID}, "expunged notes mismatch")
+	})
+
+	t.Run("no more changes", func(t *testing.T) {
+		resp, err := GetSyncFragment(ctx, 5)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "getting the fragment"))
+		}
+
+		assert.Equal(t, resp.Fragment.FragMaxUSN, 0, "FragMaxUSN mismatch")
+	})
+
+	t.Run("delete book", func(t *testing.T) {
+		resp, err := DeleteBook(ctx, b1.Book.UUID)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "deleting the book"))
+		}
+		// n1 is deleted before the book
+		assert.Equal(t, resp.Book.USN, 7, "book usn mismatch")
+
+		books, err := GetBooks(ctx)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "getting books"))
+		}
+		assert.Equal(t, len(books), 0, "books length mismatch")
+
+		frag, err := GetSyncFragment(ctx, 5)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "getting the fragment"))
+		}
+		assert.DeepEqual(t, frag.Fragment.ExpungedNotes, []string{n1.Result.UUID}, "expunged notes mismatch")
+		assert.DeepEqual(t, frag.Fragment.ExpungedBooks, []string{b1.Book.UUID}, "expunged books mismatch")
+	})
+}
+
+func TestFileBackend_entriesFromAnotherMachine(t *testing.T) {
+	ctx, dir := setupFileBackend(t)
+	defer os.RemoveAll(dir)
+
+	b1, err := CreateBook(ctx, "js", false, false)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating b1"))
+	}
+	if _, err := UpdateBook(ctx, "javascript", b1.Book.UUID, false, false); err != nil {
+		t.Fatal(errors.Wrap(err, "updating b1"))
+	}
+
+	frag, err := GetSyncFragment(ctx, 0)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the first fragment"))
+	}
+	assert.Equal(t, frag.Fragment.FragMaxUSN, 2, "first fragment max usn mismatch")
+
+	// a conflicted copy of an entry written on another machine with the same usn
+	// as the latest entry arrives after this machine read the journal
+	data := `{"usn":2,"type":"book","uuid":"` + b1.Book.UUID + `","deleted":false,"label":"ecmascript"}`
+	if err := ioutil.WriteFile(filepath.Join(dir, journalDirName, "0000000002 (conflicted copy).json"), []byte(data), 0644); err != nil {
+		t.Fatal(errors.Wrap(err, "writing the entry"))
+	}
+
+	// the entry is appended again so that the reader who has gone past its usn gets it
+	frag, err = GetSyncFragment(ctx, 2)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the second fragment"))
+	}
+	assert.Equal(t, frag.Fragment.FragMaxUSN, 3, "second fragment max usn mismatch")
+	assert.Equal(t, len(frag.Fragment.Books), 1, "second fragment books length mismatch")
+	assert.Equal(t, frag.Fragment.Books[0].Label, "ecmascript", "book label mismatch")
+
+	// the entry is appended only once
+	if _, err := GetSyncState(ctx); err != nil {
+		t.Fatal(errors.Wrap(err, "getting the sync state"))
+	}
+	files, err := ioutil.ReadDir(filepath.Join(dir, journalDirName))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "listing the journal"))
+	}
+	assert.Equal(t, len(files), 4, "journal files length mismatch")
+}
+
+func TestFileBackend_missingEntry(t *testing.T) {
+	ctx, dir := setupFileBackend(t)
+	defer os.RemoveAll(dir)
+
+	b1, err := CreateBook(ctx, "js", false, false)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating b1"))
+	}
+
+	// the entry 3 arrives before the entry 2
+	entry3 := `{"usn":3,"type":"book","uuid":"` + b1.Book.UUID + `","deleted":false,"label":"ecmascript"}`
+	if err := ioutil.WriteFile(filepath.Join(dir, journalDirName, "0000000003.json"), []byte(entry3), 0644); err != nil {
+		t.Fatal(errors.Wrap(err, "writing the entry 3"))
+	}
+
+	state, err := GetSyncState(ctx)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the sync state"))
+	}
+	assert.Equal(t, state.MaxUSN, 1, "max usn mismatch before the entry 2 arrives")
+
+	if _, err := CreateBook(ctx, "css", false, false); err == nil {
+		t.Fatal("the journal should not be written while an entry is missing")
+	}
+
+	entry2 := `{"usn":2,"type":"book","uuid":"` + b1.Book.UUID + `","deleted":false,"label":"javascript"}`
+	if err := ioutil.WriteFile(filepath.Join(dir, journalDirName, "0000000002.json"), []byte(entry2), 0644); err != nil {
+		t.Fatal(errors.Wrap(err, "writing the entry 2"))
+	}
+
+	frag, err := GetSyncFragment(ctx, 1)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the fragment"))
+	}
+	assert.Equal(t, frag.Fragment.FragMaxUSN, 3, "fragment max usn mismatch")
+	assert.Equal(t, frag.Fragment.Books[0].Label, "ecmascript", "book label mismatch")
+}
+
+func TestFileBackend_usnTaken(t *testing.T) {
+	_, dir := setupFileBackend(t)
+	defer os.RemoveAll(dir)
+
+	b := fileBackend{dir: dir}
+	if err := os.MkdirAll(b.journalDir(), 0755); err != nil {
+		t.Fatal(errors.Wrap(err, "creating the journal directory"))
+	}
+
+	if _, err := b.claim(journalEntry{USN: 1, Type: entryTypeBook, UUID: "b1-uuid", Label: "js"}); err != nil {
+		t.Fatal(errors.Wrap(err, "claiming the usn"))
+	}
+
+	_, err := b.claim(journalEntry{USN: 1, Type: entryTypeBook, UUID: "b2-uuid", Label: "css"})
+	assert.Equal(t, err, errUSNTaken, "error mismatch")
+
+	data, err := ioutil.ReadFile(filepath.Join(b.journalDir(), getEntryFilename(1)))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "reading the entry"))
+	}
+	assert.Equal(t, strings.Contains(string(data), "b1-uuid"), true, "the entry should not be replaced")
+}
+
+func TestFileBackend_missingDir(t *testing.T) {
+	ctx := context.DnoteCtx{
+		APIEndpoint: "file:///nonexistent/dnote",
+		Clock:       clock.NewMock(),
+	}
+
+	if _, err := GetSyncState(ctx); err == nil {
+		t.Fatal("an error should be returned for a missing directory")
+	}
+}
+
+func TestFileBackend_lock(t *testing.T) {
+	ctx, dir := setupFileBackend(t)
+	defer os.RemoveAll(dir)
+
+	defaultTimeout := journalLockTimeout
+	journalLockTimeout = 0
+	defer func() { journalLockTimeout = defaultTimeout }()
+
+	self := getLockOwner()
+	lockPath := filepath.Join(dir, journalLockFilename)
+	staleAt := time.Now().Add(-2 * journalLockStaleAfter)
+
+	writeLock := func(owner lockOwner, modTime time.Time) {
+		if err := ioutil.WriteFile(lockPath, []byte(fmt.Sprintf(`{"hostname":"%s","pid":%d}`, owner.Hostname, owner.PID)), 0644); err != nil {
+			t.Fatal(errors.Wrap(err, "writing the lock"))
+		}
+		if err := os.Chtimes(lockPath, modTime, modTime); err != nil {
+			t.Fatal(errors.Wrap(err, "setting the lock time"))
+		}
+	}
+
+	// a lock held by a running process on this machine is never taken over
+	writeLock(self, staleAt)
+	if _, err := CreateBook(ctx, "js", false, false); err == nil {
+		t.Fatal("the journal should not be written while locked by a running process")
+	}
+
+	// a recent lock held on another machine is respected
+	writeLock(lockOwner{Hostname: self.Hostname + "-other", PID: 1}, time.Now())
+	if _, err := CreateBook(ctx, "js", false, false); err == nil {
+		t.Fatal("the journal should not be written while locked on another machine")
+	}
+
+	// a lock held on another machine that has not been refreshed is taken over
+	writeLock(lockOwner{Hostname: self.Hostname + "-other", PID: 1}, staleAt)
+	if _, err := CreateBook(ctx, "js", false, false); err != nil {
+		t.Fatal(errors.Wrap(err, "creating a book after a stale lock"))
+	}
+
+	// a lock left behind by a process on this machine that exited is taken over
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(errors.Wrap(err, "running a process"))
+	}
+	writeLock(lockOwner{Hostname: self.Hostname, PID: cmd.Process.Pid}, time.Now())
+	if _, err := CreateBook(ctx, "css", false, false); err != nil {
+		t.Fatal(errors.Wrap(err, "creating a book after an abandoned lock"))
+	}
+
+	_, err := os.Stat(lockPath)
+	assert.Equal(t, os.IsNotExist(err), true, "the lock should be released")
+}
@@ -19,30 +19,33 @@
 package add
 
 import (
-	"database/sql"
-	"time"
-
 	"github.com/dnote/dnote/pkg/cli/context"
 	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/dates"
+	"github.com/dnote/dnote/pkg/cli/draft"
 	"github.com/dnote/dnote/pkg/cli/infra"
 	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/operations"
 	"github.com/dnote/dnote/pkg/cli/output"
-	"github.com/dnote/dnote/pkg/cli/ui"
 	"github.com/dnote/dnote/pkg/cli/upgrade"
-	"github.com/dnote/dnote/pkg/cli/utils"
 	"github.com/dnote/dnote/pkg/cli/validate"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 var contentFlag string
+var dueFlag string
+var remindFlag string
 
 var example = `
  * Open an editor to write content
  dnote add git
 
  * Skip the editor by providing content directly
- dnote add git -c "time is a part of the commit hash"`
+ dnote add git -c "time is a part of the commit hash"
+
+ * Set a due date and a reminder
+ dnote add chores -c "renew the passport" --due "2020-06-01" --remind "in 3 days at 5pm"`
 
 func preRun(cmd *cobra.Command, args []string) error {
 	if len(args) != 1 {
@@ -65,119 +68,129 @@ func NewCmd(ctx context.DnoteCtx) *cobra.Command {
 
 	f := cmd.Flags()
 	f.StringVarP(&contentFlag, "content", "c", "", "The new content for the note")
+	f.StringVarP(&dueFlag, "due", "", "", "The due date of the note (e.g. \"tomorrow\", \"friday at 3pm\", \"2020-06-01\")")
+	f.StringVarP(&remindFlag, "remind", "r", "", "When to be reminded of the note (e.g. \"in 3 days\", \"next monday at 9:30am\")")
 
 	return cmd
 }
 
-func getContent(ctx context.DnoteCtx) (string, error) {
-	if contentFlag != "" {
-		return contentFlag, nil
-	}
-
-	fpath, err := ui.GetTmpContentPath(ctx)
-	if err != nil {
-		return "", errors.Wrap(err, "getting temporarily content file path")
-	}
-
-	c, err := ui.GetEditorInput(ctx, fpath)
-	if err != nil {
-		return "", errors.Wrap(err, "Failed to get editor input")
-	}
-
-	return c, nil
+// schedule holds the due and reminder times of a new note in unix nanoseconds
+type schedule struct {
+	dueOn    int64
+	remindOn int64
 }
 
-func newRun(ctx context.DnoteCtx) infra.RunEFunc {
-	return func(cmd *cobra.Command, args []string) error {
-		bookName := args[0]
-		if err := validate.BookName(bookName); err != nil {
-			return errors.Wrap(err, "invalid book name")
-		}
+func parseSchedule(ctx context.DnoteCtx) (schedule, error) {
+	var ret schedule
+	now := ctx.Clock.Now()
 
-		content, err := getContent(ctx)
+	if dueFlag != "" {
+		t, err := dates.Parse(dueFlag, now)
 		if err != nil {
-			return errors.Wrap(err, "getting content")
-		}
-		if content == "" {
-			return errors.New("Empty content")
+			return ret, errors.Wrap(err, "invalid due date")
 		}
 
-		ts := time.Now().UnixNano()
-		noteRowID, err := writeNote(ctx, bookName, content, ts)
+		ret.dueOn = t.UnixNano()
+	}
+	if remindFlag != "" {
+		t, err := dates.Parse(remindFlag, now)
 		if err != nil {
-			return errors.Wrap(err, "Failed to write note")
+			return ret, errors.Wrap(err, "invalid reminder")
 		}
 
-		log.Successf("added to %s\n", bookName)
+		ret.remindOn = t.UnixNano()
+	}
 
-		db := ctx.DB
-		info, err := database.GetNoteInfo(db, noteRowID)
-		if err != nil {
-			return err
-		}
+	return ret, nil
+}
 
-		output.NoteInfo(info)
+// ResumeDraft opens the draft in an editor and adds a note with the content
+func ResumeDraft(ctx context.DnoteCtx, d draft.Draft) error {
+	return resumeDraft(ctx, d, schedule{})
+}
 
-		if err := upgrade.Check(ctx); err != nil {
-			log.Error(errors.Wrap(err, "automatically checking updates").Error())
+func resumeDraft(ctx context.DnoteCtx, d draft.Draft, s schedule) error {
+	content, err := d.Edit(ctx)
+	if err != nil {
+		d.Keep()
+		return errors.Wrap(err, "Failed to get editor input")
+	}
+	if content == "" {
+		if err := d.Discard(); err != nil {
+			log.Debug("discarding the draft: %s\n", err)
 		}
 
-		return nil
+		return errors.New("Empty content")
 	}
-}
 
-func writeNote(ctx context.DnoteCtx, bookLabel string, content string, ts int64) (int, error) {
-	tx, err := ctx.DB.Begin()
-	if err != nil {
-		return 0, errors.Wrap(err, "beginning a transaction")
+	if err := save(ctx, d.BookLabel, content, s); err != nil {
+		d.Keep()
+		return err
 	}
 
-	var bookUUID string
-	err = tx.QueryRow("SELECT uuid FROM books WHERE label = ?", bookLabel).Scan(&bookUUID)
-	if err == sql.ErrNoRows {
-		bookUUID, err = utils.GenerateUUID()
-		if err != nil {
-			return 0, errors.Wrap(err, "generating uuid")
-		}
+	if err := d.Discard(); err != nil {
+		log.Error(errors.Wrap(err, "discarding the draft").Error())
+	}
 
-		b := database.NewBook(bookUUID, bookLabel, 0, false, true)
-		err = b.Insert(tx)
-		if err != nil {
-			tx.Rollback()
-			return 0, errors.Wrap(err, "creating the book")
-		}
+	return nil
+}
+
+func save(ctx context.DnoteCtx, bookName, content string, s schedule) error {
+	note, err := operations.CreateNote(ctx, operations.NewNoteParams{
+		Book:     bookName,
+		Content:  content,
+		DueOn:    s.dueOn,
+		RemindOn: s.remindOn,
+	})
+	if _, ok := errors.Cause(err).(operations.InvalidError); ok {
+		return err
 	} else if err != nil {
-		return 0, errors.Wrap(err, "finding the book")
+		return errors.Wrap(err, "Failed to write note")
 	}
 
-	noteUUID, err := utils.GenerateUUID()
+	log.Successf("added to %s\n", bookName)
+
+	info, err := database.GetNoteInfo(ctx.DB, note.RowID)
 	if err != nil {
-		return 0, errors.Wrap(err, "generating uuid")
+		return err
 	}
 
-	n := database.NewNote(noteUUID, bookUUID, content, ts, 0, 0, false, false, true)
+	output.NoteInfo(info)
 
-	err = n.Insert(tx)
-	if err != nil {
-		tx.Rollback()
-		return 0, errors.Wrap(err, "creating the note")
-	}
+	return nil
+}
 
-	var noteRowID int
-	err = tx.QueryRow(`SELECT notes.rowid
-			FROM notes
-			WHERE notes.uuid = ?`, noteUUID).
-		Scan(&noteRowID)
-	if err != nil {
-		tx.Rollback()
-		return noteRowID, errors.Wrap(err, "getting the note rowid")
-	}
+func newRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		bookName := args[0]
+		if err := validate.BookName(bookName); err != nil {
+			return errors.Wrap(err, "invalid book name")
+		}
 
-	err = tx.Commit()
-	if err != nil {
-		tx.Rollback()
-		return noteRowID, errors.Wrap(err, "committing a transaction")
-	}
+		s, err := parseSchedule(ctx)
+		if err != nil {
+			return err
+		}
 
-	return noteRowID, nil
+		if contentFlag != "" {
+			if err := save(ctx, bookName, contentFlag, s); err != nil {
+				return err
+			}
+		} else {
+			d, err := draft.Get(ctx, draft.KindAdd, bookName, "", "")
+			if err != nil {
+				return errors.Wrap(err, "getting a draft")
+			}
+
+			if err := resumeDraft(ctx, d, s); err != nil {
+				return err
+			}
+		}
+
+		if err := upgrade.Check(ctx); err != nil {
+			log.Error(errors.Wrap(err, "automatically checking updates").Error())
+		}
+
+		return nil
+	}
 }
@@ -0,0 +1,166 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package agenda
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/infra"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var daysFlag int
+
+var example = `
+ * List the notes that are overdue or due and to be reminded of in the next 7 days
+ dnote agenda
+
+ * Look ahead 30 days
+ dnote agenda --days 30`
+
+const (
+	itemDue    = "due"
+	itemRemind = "remind"
+)
+
+// item is a due date or a reminder of a note
+type item struct {
+	RowID     int
+	BookLabel string
+	Body      string
+	Kind      string
+	At        int64
+}
+
+// agenda is the list of items grouped by whether they are overdue
+type agenda struct {
+	Overdue  []item
+	Upcoming []item
+}
+
+// NewCmd returns a new agenda command
+func NewCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "agenda",
+		Short:   "List upcoming and overdue notes",
+		Example: example,
+		Args:    cobra.NoArgs,
+		RunE:    newRun(ctx),
+	}
+
+	f := cmd.Flags()
+	f.IntVarP(&daysFlag, "days", "d", 7, "The number of days to look ahead")
+
+	return cmd
+}
+
+// getAgenda returns the overdue due dates and the due dates and reminders that
+// fall before the given number of days from now, in chronological order. Past
+// reminders are left out because they have already served their purpose.
+func getAgenda(db *database.DB, now time.Time, days int) (agenda, error) {
+	var ret agenda
+
+	nowTs := now.UnixNano()
+	until := now.AddDate(0, 0, days).UnixNano()
+
+	rows, err := db.Query(`SELECT * FROM (
+		SELECT notes.rowid, books.label, notes.body, ? AS kind, notes.due_on AS at
+		FROM notes
+		INNER JOIN books ON books.uuid = notes.book_uuid
+		WHERE notes.deleted = false AND notes.due_on > 0 AND notes.due_on <= ?
+		UNION ALL
+		SELECT notes.rowid, books.label, notes.body, ? AS kind, notes.remind_on AS at
+		FROM notes
+		INNER JOIN books ON books.uuid = notes.book_uuid
+		WHERE notes.deleted = false AND notes.remind_on >= ? AND notes.remind_on <= ?
+	) ORDER BY at ASC, rowid ASC`, itemDue, until, itemRemind, nowTs, until)
+	if err != nil {
+		return ret, errors.Wrap(err, "querying notes")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i item
+		if err := rows.Scan(&i.RowID, &i.BookLabel, &i.Body, &i.Kind, &i.At); err != nil {
+			return ret, errors.Wrap(err, "scanning a row")
+		}
+
+		if i.At < nowTs {
+			ret.Overdue = append(ret.Overdue, i)
+		} else {
+			ret.Upcoming = append(ret.Upcoming, i)
+		}
+	}
+
+	return ret, nil
+}
+
+// getTitle returns the first line of the note body
+func getTitle(body string) string {
+	lines := strings.SplitN(strings.TrimSpace(body), "\n", 2)
+
+	return strings.TrimSpace(lines[0])
+}
+
+func printItems(items []item) {
+	for _, i := range items {
+		at := time.Unix(0, i.At).Format("Mon Jan 2 3:04pm")
+		rowid := log.ColorYellow.Sprintf("(%d)", i.RowID)
+
+		log.Plainf("  %s %-6s %s %s %s\n", at, i.Kind, rowid, getTitle(i.Body), log.ColorGray.Sprintf("[%s]", i.BookLabel))
+	}
+}
+
+func newRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		if daysFlag < 0 {
+			return errors.New("--days cannot be negative")
+		}
+
+		a, err := getAgenda(ctx.DB, ctx.Clock.Now(), daysFlag)
+		if err != nil {
+			return errors.Wrap(err, "getting the agenda")
+		}
+
+		if len(a.Overdue) == 0 && len(a.Upcoming) == 0 {
+			log.Plainf("nothing due in the next %d days\n", daysFlag)
+			return nil
+		}
+
+		if len(a.Overdue) > 0 {
+			log.Plain(log.ColorRed.Sprint("overdue\n"))
+			printItems(a.Overdue)
+		}
+		if len(a.Upcoming) > 0 {
+			if len(a.Overdue) > 0 {
+				log.Plain("\n")
+			}
+
+			log.Plain(log.ColorGreen.Sprintf("next %d days\n", daysFlag))
+			printItems(a.Upcoming)
+		}
+
+		return nil
+	}
+}
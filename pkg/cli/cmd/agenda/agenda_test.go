@@ -0,0 +1,72 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package agenda
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/pkg/errors"
+)
+
+func TestGetAgenda(t *testing.T) {
+	// set up
+	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+	defer database.TeardownTestDB(t, db)
+
+	now := time.Date(2020, time.April, 1, 12, 0, 0, 0, time.UTC)
+	hour := int64(time.Hour)
+	nowTs := now.UnixNano()
+
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "b1-label", 0, false, true)
+
+	// overdue
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty, due_on, remind_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1 body", 1, 0, false, true, nowTs-hour, 0)
+	// due and to be reminded within the window
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty, due_on, remind_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", "n2 body", 2, 0, false, true, nowTs+48*hour, nowTs+24*hour)
+	// past reminder
+	database.MustExec(t, "inserting n3", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty, due_on, remind_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", "n3-uuid", "b1-uuid", "n3 body", 3, 0, false, true, 0, nowTs-hour)
+	// beyond the window
+	database.MustExec(t, "inserting n4", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty, due_on, remind_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", "n4-uuid", "b1-uuid", "n4 body", 4, 0, false, true, nowTs+8*24*hour, 0)
+	// deleted
+	database.MustExec(t, "inserting n5", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty, due_on, remind_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", "n5-uuid", "b1-uuid", "", 5, 0, true, true, nowTs+hour, 0)
+	// no dates
+	database.MustExec(t, "inserting n6", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n6-uuid", "b1-uuid", "n6 body", 6, 0, false, true)
+
+	var n1RowID, n2RowID int
+	database.MustScan(t, "getting n1 rowid", db.QueryRow("SELECT rowid FROM notes WHERE uuid = ?", "n1-uuid"), &n1RowID)
+	database.MustScan(t, "getting n2 rowid", db.QueryRow("SELECT rowid FROM notes WHERE uuid = ?", "n2-uuid"), &n2RowID)
+
+	// execute
+	result, err := getAgenda(db, now, 7)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	// test
+	assert.DeepEqual(t, result.Overdue, []item{
+		{RowID: n1RowID, BookLabel: "b1-label", Body: "n1 body", Kind: itemDue, At: nowTs - hour},
+	}, "overdue mismatch")
+	assert.DeepEqual(t, result.Upcoming, []item{
+		{RowID: n2RowID, BookLabel: "b1-label", Body: "n2 body", Kind: itemRemind, At: nowTs + 24*hour},
+		{RowID: n2RowID, BookLabel: "b1-label", Body: "n2 body", Kind: itemDue, At: nowTs + 48*hour},
+	}, "upcoming mismatch")
+}
@@ -0,0 +1,131 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package archive
+
+import (
+	"strconv"
+
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/infra"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var example = `
+ * Archive a note by id
+ dnote archive 3
+
+ * Archive a book by name
+ dnote archive js
+ `
+
+var unarchiveExample = `
+ * Unarchive a note by id
+ dnote unarchive 3
+
+ * Unarchive a book by name
+ dnote unarchive js
+ `
+
+func preRun(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Incorrect number of argument")
+	}
+
+	return nil
+}
+
+// NewCmd returns a new archive command
+func NewCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "archive <note id|book name>",
+		Short:   "Archive a note or a book to hide it from view and find",
+		Example: example,
+		PreRunE: preRun,
+		RunE:    newRun(ctx, true),
+	}
+
+	return cmd
+}
+
+// NewUnarchiveCmd returns a new unarchive command
+func NewUnarchiveCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "unarchive <note id|book name>",
+		Short:   "Unarchive a note or a book",
+		Example: unarchiveExample,
+		PreRunE: preRun,
+		RunE:    newRun(ctx, false),
+	}
+
+	return cmd
+}
+
+func newRun(ctx context.DnoteCtx, archived bool) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		target := args[0]
+
+		if utils.IsNumber(target) {
+			if err := runNote(ctx, target, archived); err != nil {
+				return errors.Wrap(err, "updating the note")
+			}
+		} else {
+			if err := runBook(ctx, target, archived); err != nil {
+				return errors.Wrap(err, "updating the book")
+			}
+		}
+
+		if archived {
+			log.Success("archived\n")
+		} else {
+			log.Success("unarchived\n")
+		}
+
+		sync.AfterWrite(ctx)
+
+		return nil
+	}
+}
+
+func runNote(ctx context.DnoteCtx, rowIDArg string, archived bool) error {
+	rowID, err := strconv.Atoi(rowIDArg)
+	if err != nil {
+		return errors.Wrap(err, "invalid rowid")
+	}
+
+	note, err := database.GetActiveNote(ctx.DB, rowID)
+	if err != nil {
+		return err
+	}
+
+	return database.UpdateNoteArchived(ctx.DB, ctx.Clock, note.RowID, archived)
+}
+
+func runBook(ctx context.DnoteCtx, bookLabel string, archived bool) error {
+	bookUUID, err := database.GetBookUUID(ctx.DB, bookLabel)
+	if err != nil {
+		return errors.Wrap(err, "finding book uuid")
+	}
+
+	return database.UpdateBookArchived(ctx.DB, bookUUID, archived)
+}
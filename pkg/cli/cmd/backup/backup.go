@@ -0,0 +1,206 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package backup
+
+import (
+	"fmt"
+
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/infra"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/snapshot"
+	"github.com/dnote/dnote/pkg/cli/ui"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var yesFlag bool
+
+var example = `
+  * Take a snapshot of the database
+  dnote backup create
+
+  * See the snapshots
+  dnote backup list
+
+  * Restore a snapshot
+  dnote backup restore 20201018-153000_full-sync
+
+  * Remove the snapshots beyond the retention policy
+  dnote backup prune`
+
+// NewCmd returns a new backup command
+func NewCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "backup",
+		Short:   "Manage the snapshots of the database",
+		Example: example,
+	}
+
+	cmd.AddCommand(newCreateCmd(ctx))
+	cmd.AddCommand(newListCmd(ctx))
+	cmd.AddCommand(newRestoreCmd(ctx))
+	cmd.AddCommand(newPruneCmd(ctx))
+
+	return cmd
+}
+
+func newCreateCmd(ctx context.DnoteCtx) *cobra.Command {
+	return &cobra.Command{
+		Use:   "create",
+		Short: "Take a snapshot of the database",
+		Args:  cobra.NoArgs,
+		RunE:  newCreateRun(ctx),
+	}
+}
+
+func newListCmd(ctx context.DnoteCtx) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the snapshots of the database",
+		Args:  cobra.NoArgs,
+		RunE:  newListRun(ctx),
+	}
+}
+
+func newRestoreCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore <name>",
+		Short: "Replace the database with a snapshot",
+		Args:  cobra.ExactArgs(1),
+		RunE:  newRestoreRun(ctx),
+	}
+
+	f := cmd.Flags()
+	f.BoolVarP(&yesFlag, "yes", "y", false, "Assume yes to the prompts and run in non-interactive mode")
+
+	return cmd
+}
+
+func newPruneCmd(ctx context.DnoteCtx) *cobra.Command {
+	return &cobra.Command{
+		Use:   "prune",
+		Short: "Remove the snapshots beyond the retention policy",
+		Args:  cobra.NoArgs,
+		RunE:  newPruneRun(ctx),
+	}
+}
+
+func maybeConfirm(message string, defaultValue bool) (bool, error) {
+	if yesFlag {
+		return true, nil
+	}
+
+	return ui.Confirm(message, defaultValue)
+}
+
+// formatSize returns a human readable size
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGT"[exp])
+}
+
+func newCreateRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		s, err := snapshot.Create(ctx, snapshot.ReasonManual)
+		if err != nil {
+			return errors.Wrap(err, "taking a snapshot")
+		}
+
+		log.Successf("created %s\n", s.Name)
+
+		return nil
+	}
+}
+
+func newListRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		snapshots, err := snapshot.List(ctx)
+		if err != nil {
+			return errors.Wrap(err, "listing the snapshots")
+		}
+
+		if len(snapshots) == 0 {
+			log.Plain("no snapshots\n")
+			return nil
+		}
+
+		for i := len(snapshots) - 1; i >= 0; i-- {
+			s := snapshots[i]
+
+			log.Plainf("%s  %s  %-9s  %s\n", log.ColorYellow.Sprint(s.Name), s.CreatedAt.Local().Format("Jan 2, 2006 3:04pm"), s.Reason, formatSize(s.Size))
+		}
+
+		return nil
+	}
+}
+
+func newRestoreRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		s, err := snapshot.Find(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		ok, err := maybeConfirm(fmt.Sprintf("replace all books and notes with the snapshot taken at %s?", s.CreatedAt.Local().Format("Jan 2, 2006 3:04pm")), false)
+		if err != nil {
+			return errors.Wrap(err, "getting confirmation")
+		}
+		if !ok {
+			log.Warnf("aborted by user\n")
+			return nil
+		}
+
+		current, err := snapshot.Restore(ctx, s)
+		if err != nil {
+			return err
+		}
+
+		log.Successf("restored %s\n", s.Name)
+		log.Plainf("The data before the restore is saved in %s.\n", current.Name)
+
+		return nil
+	}
+}
+
+func newPruneRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		removed, err := snapshot.Prune(ctx, snapshot.GetPolicy(ctx))
+		if err != nil {
+			return errors.Wrap(err, "removing snapshots")
+		}
+
+		for _, s := range removed {
+			log.Plainf("removed %s\n", s.Name)
+		}
+		log.Successf("removed %d snapshots\n", len(removed))
+
+		return nil
+	}
+}
@@ -0,0 +1,224 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package book
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/dnote/dnote/pkg/cli/client"
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/infra"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/ui"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var setExample = `
+  * Keep a book on this machine and never sync it
+  dnote book set scratch --local-only
+
+  * Start syncing the book again
+  dnote book set scratch --local-only=false`
+
+var localOnlyFlag bool
+var yesFlag bool
+
+func newSetCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "set <book name>",
+		Short:   "Change the settings of a book",
+		Example: setExample,
+		PreRunE: setPreRun,
+		RunE:    newSetRun(ctx),
+	}
+
+	f := cmd.Flags()
+	f.BoolVarP(&localOnlyFlag, "local-only", "l", false, "keep the book on this machine and exclude it from sync")
+	f.BoolVarP(&yesFlag, "yes", "y", false, "Assume yes to the prompts and run in non-interactive mode")
+
+	return cmd
+}
+
+func setPreRun(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Incorrect number of argument")
+	}
+	if !cmd.Flags().Changed("local-only") {
+		return errors.New("Nothing to set. Please specify a setting such as --local-only")
+	}
+
+	return nil
+}
+
+// bookState is the sync-related state of a book
+type bookState struct {
+	UUID      string
+	USN       int
+	LocalOnly bool
+}
+
+func getBookState(db *database.DB, label string) (bookState, error) {
+	var ret bookState
+	err := db.QueryRow("SELECT uuid, usn, local_only FROM books WHERE label = ? AND NOT deleted", label).
+		Scan(&ret.UUID, &ret.USN, &ret.LocalOnly)
+	if err == sql.ErrNoRows {
+		return ret, errors.Errorf("book '%s' not found", label)
+	} else if err != nil {
+		return ret, errors.Wrap(err, "querying the book")
+	}
+
+	return ret, nil
+}
+
+// markLocalOnly excludes the book with the given uuid from sync. If detach is true,
+// the book and its notes are treated as if they had never been uploaded.
+func markLocalOnly(db *database.DB, uuid string, detach bool) error {
+	if !detach {
+		if _, err := db.Exec("UPDATE books SET local_only = ? WHERE uuid = ?", true, uuid); err != nil {
+			return errors.Wrap(err, "updating the book")
+		}
+
+		return nil
+	}
+
+	if _, err := db.Exec("UPDATE books SET local_only = ?, usn = ?, dirty = ? WHERE uuid = ?", true, 0, false, uuid); err != nil {
+		return errors.Wrap(err, "updating the book")
+	}
+	if _, err := db.Exec("DELETE FROM notes WHERE book_uuid = ? AND deleted", uuid); err != nil {
+		return errors.Wrap(err, "expunging the deleted notes")
+	}
+	if _, err := db.Exec("UPDATE notes SET usn = ?, dirty = ?, base_body = NULL WHERE book_uuid = ?", 0, false, uuid); err != nil {
+		return errors.Wrap(err, "updating the notes")
+	}
+
+	return nil
+}
+
+// markSynced includes the book with the given uuid in sync again, so that the
+// book and its notes are uploaded in the next sync.
+func markSynced(db *database.DB, uuid string) error {
+	if _, err := db.Exec("UPDATE books SET local_only = ?, dirty = ? WHERE uuid = ?", false, true, uuid); err != nil {
+		return errors.Wrap(err, "updating the book")
+	}
+	if _, err := db.Exec("UPDATE notes SET dirty = ? WHERE book_uuid = ?", true, uuid); err != nil {
+		return errors.Wrap(err, "updating the notes")
+	}
+
+	return nil
+}
+
+func setLocalOnly(ctx context.DnoteCtx, label string, book bookState) error {
+	// the book only needs to be removed from the server if it has been uploaded
+	detach := book.USN > 0
+
+	if detach {
+		if !client.IsAuthorized(ctx) {
+			return errors.New("login is required to remove the book from the server. Please run `dnote login`")
+		}
+
+		ok, err := maybeConfirm(fmt.Sprintf("delete book '%s' and all its notes from the server? They will be kept on this machine.", label), false)
+		if err != nil {
+			return errors.Wrap(err, "getting confirmation")
+		}
+		if !ok {
+			log.Warnf("aborted by user\n")
+			return nil
+		}
+
+		if _, err := client.DeleteBook(ctx, book.UUID); err != nil {
+			return errors.Wrap(err, "deleting the book from the server")
+		}
+	}
+
+	tx, err := ctx.DB.Begin()
+	if err != nil {
+		return errors.Wrap(err, "beginning a transaction")
+	}
+
+	if err := markLocalOnly(tx, book.UUID, detach); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "marking the book local-only")
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "committing a transaction")
+	}
+
+	log.Successf("book '%s' is now local-only and will not be synced\n", label)
+
+	return nil
+}
+
+func setSynced(ctx context.DnoteCtx, label string, book bookState) error {
+	tx, err := ctx.DB.Begin()
+	if err != nil {
+		return errors.Wrap(err, "beginning a transaction")
+	}
+
+	if err := markSynced(tx, book.UUID); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "marking the book synced")
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "committing a transaction")
+	}
+
+	log.Successf("book '%s' will be uploaded in the next sync\n", label)
+
+	sync.AfterWrite(ctx)
+
+	return nil
+}
+
+func maybeConfirm(message string, defaultValue bool) (bool, error) {
+	if yesFlag {
+		return true, nil
+	}
+
+	return ui.Confirm(message, defaultValue)
+}
+
+func newSetRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		label := args[0]
+
+		book, err := getBookState(ctx.DB, label)
+		if err != nil {
+			return err
+		}
+
+		if book.LocalOnly == localOnlyFlag {
+			log.Plainf("nothing to change for book '%s'\n", label)
+			return nil
+		}
+
+		if localOnlyFlag {
+			return setLocalOnly(ctx, label, book)
+		}
+
+		return setSynced(ctx, label, book)
+	}
+}
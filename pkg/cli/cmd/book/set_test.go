@@ -0,0 +1,127 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package book
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/pkg/errors"
+)
+
+func TestGetBookState(t *testing.T) {
+	// set up
+	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+	defer database.TeardownTestDB(t, db)
+
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty, local_only) VALUES (?, ?, ?, ?, ?, ?)", "b1-uuid", "b1-label", 3, false, false, true)
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b2-uuid", "b2-label", 4, true, true)
+
+	// execute
+	got, err := getBookState(db, "b1-label")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	// test
+	assert.Equal(t, got, bookState{UUID: "b1-uuid", USN: 3, LocalOnly: true}, "state mismatch")
+
+	_, err = getBookState(db, "b2-label")
+	assert.NotEqual(t, err, nil, "deleted book should not be found")
+}
+
+func TestMarkLocalOnly(t *testing.T) {
+	testCases := []struct {
+		detach        bool
+		expectedUSN   int
+		expectedDirty bool
+		expectedNotes int
+	}{
+		{
+			detach:        false,
+			expectedUSN:   0,
+			expectedDirty: true,
+			expectedNotes: 2,
+		},
+		{
+			detach:        true,
+			expectedUSN:   0,
+			expectedDirty: false,
+			expectedNotes: 1,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			// set up
+			db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+			defer database.TeardownTestDB(t, db)
+
+			database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "b1-label", 0, false, true)
+			database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty, base_body) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", 0, "n1 body", 1541108743, false, true, "n1 body")
+			database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", 0, "", 1541108743, true, true)
+
+			// execute
+			if err := markLocalOnly(db, "b1-uuid", tc.detach); err != nil {
+				t.Fatal(errors.Wrap(err, "executing"))
+			}
+
+			// test
+			var localOnly, dirty bool
+			var usn int
+			database.MustScan(t, "getting b1", db.QueryRow("SELECT local_only, usn, dirty FROM books WHERE uuid = ?", "b1-uuid"), &localOnly, &usn, &dirty)
+			assert.Equal(t, localOnly, true, "local_only mismatch")
+			assert.Equal(t, usn, tc.expectedUSN, "usn mismatch")
+			assert.Equal(t, dirty, tc.expectedDirty, "dirty mismatch")
+
+			var noteCount int
+			database.MustScan(t, "counting notes", db.QueryRow("SELECT count(*) FROM notes"), &noteCount)
+			assert.Equal(t, noteCount, tc.expectedNotes, "note count mismatch")
+
+			var n1Base sql.NullString
+			database.MustScan(t, "getting n1", db.QueryRow("SELECT base_body FROM notes WHERE uuid = ?", "n1-uuid"), &n1Base)
+			assert.Equal(t, n1Base.Valid, !tc.detach, "n1 base_body mismatch")
+		})
+	}
+}
+
+func TestMarkSynced(t *testing.T) {
+	// set up
+	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+	defer database.TeardownTestDB(t, db)
+
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty, local_only) VALUES (?, ?, ?, ?, ?, ?)", "b1-uuid", "b1-label", 0, false, false, true)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", 0, "n1 body", 1541108743, false, false)
+
+	// execute
+	if err := markSynced(db, "b1-uuid"); err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	// test
+	var localOnly, bookDirty, noteDirty bool
+	database.MustScan(t, "getting b1", db.QueryRow("SELECT local_only, dirty FROM books WHERE uuid = ?", "b1-uuid"), &localOnly, &bookDirty)
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT dirty FROM notes WHERE uuid = ?", "n1-uuid"), &noteDirty)
+	assert.Equal(t, localOnly, false, "local_only mismatch")
+	assert.Equal(t, bookDirty, true, "book dirty mismatch")
+	assert.Equal(t, noteDirty, true, "note dirty mismatch")
+}
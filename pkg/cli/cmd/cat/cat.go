@@ -19,13 +19,18 @@
 package cat
 
 import (
+	"os"
 	"strconv"
+	"strings"
 
+	"github.com/dnote/color"
 	"github.com/dnote/dnote/pkg/cli/context"
 	"github.com/dnote/dnote/pkg/cli/database"
 	"github.com/dnote/dnote/pkg/cli/infra"
 	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/markdown"
 	"github.com/dnote/dnote/pkg/cli/output"
+	"github.com/dnote/dnote/pkg/cli/ui"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
@@ -55,7 +60,7 @@ func NewCmd(ctx context.DnoteCtx) *cobra.Command {
 		Aliases:    []string{"c"},
 		Short:      "See a note",
 		Example:    example,
-		RunE:       NewRun(ctx, false),
+		RunE:       NewRun(ctx, false, true, false),
 		PreRunE:    preRun,
 		Deprecated: deprecationWarning,
 	}
@@ -63,9 +68,28 @@ func NewCmd(ctx context.DnoteCtx) *cobra.Command {
 	return cmd
 }
 
-// NewRun returns a new run function
-func NewRun(ctx context.DnoteCtx, contentOnly bool) infra.RunEFunc {
+// shouldRender returns true if the note content should be rendered as Markdown. By
+// default, it is rendered only for a terminal, and the content alone is printed as is
+// so that it can be piped. render forces the rendering, and raw turns it off.
+func shouldRender(contentOnly, raw, render, isTerminal bool) bool {
+	if raw {
+		return false
+	}
+	if render {
+		return true
+	}
+
+	return isTerminal && !contentOnly
+}
+
+// NewRun returns a new run function. The note content is rendered as Markdown
+// if the output is a terminal, unless raw is true. render renders it regardless.
+func NewRun(ctx context.DnoteCtx, contentOnly, raw, render bool) infra.RunEFunc {
 	return func(cmd *cobra.Command, args []string) error {
+		if raw && render {
+			return errors.New("--raw and --render cannot be used together")
+		}
+
 		var noteRowIDArg string
 
 		if len(args) == 2 {
@@ -87,12 +111,41 @@ func NewRun(ctx context.DnoteCtx, contentOnly bool) infra.RunEFunc {
 			return err
 		}
 
-		if contentOnly {
-			output.NoteContent(info)
-		} else {
-			output.NoteInfo(info)
+		width, _, isTerminal := ui.TerminalSize()
+		if !shouldRender(contentOnly, raw, render, isTerminal) {
+			if contentOnly {
+				output.NoteContent(info)
+			} else {
+				output.NoteInfo(info)
+			}
+
+			return nil
 		}
 
-		return nil
+		noColor := os.Getenv("NO_COLOR") != "" || color.NoColor
+		content := markdown.Render(info.Content, getRenderOptions(width, isTerminal, noColor))
+		if !contentOnly {
+			content = output.FormatNoteInfo(info, strings.TrimSuffix(content, "\n"))
+		}
+
+		return ui.PageLong(content)
 	}
 }
+
+// getRenderOptions returns the options to render the notes for the output. The
+// colors are disabled if the output is not a terminal or if noColor is true.
+func getRenderOptions(width int, isTerminal, noColor bool) markdown.Options {
+	var ret markdown.Options
+
+	if !isTerminal {
+		return ret
+	}
+
+	ret.Width = width
+	if !noColor {
+		ret.Color = true
+		ret.Hyperlinks = true
+	}
+
+	return ret
+}
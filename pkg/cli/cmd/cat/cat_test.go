@@ -0,0 +1,99 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cat
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/markdown"
+)
+
+func TestShouldRender(t *testing.T) {
+	testCases := []struct {
+		contentOnly bool
+		raw         bool
+		render      bool
+		isTerminal  bool
+		expected    bool
+	}{
+		{isTerminal: true, expected: true},
+		{isTerminal: false, expected: false},
+		{contentOnly: true, isTerminal: true, expected: false},
+		{raw: true, isTerminal: true, expected: false},
+		{render: true, isTerminal: false, expected: true},
+		{contentOnly: true, render: true, isTerminal: false, expected: true},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			result := shouldRender(tc.contentOnly, tc.raw, tc.render, tc.isTerminal)
+
+			assert.Equal(t, result, tc.expected, "result mismatch")
+		})
+	}
+}
+
+func TestGetRenderOptions(t *testing.T) {
+	testCases := []struct {
+		width      int
+		isTerminal bool
+		noColor    bool
+		expected   markdown.Options
+	}{
+		{
+			width:      80,
+			isTerminal: true,
+			expected:   markdown.Options{Width: 80, Color: true, Hyperlinks: true},
+		},
+		{
+			width:      80,
+			isTerminal: true,
+			noColor:    true,
+			expected:   markdown.Options{Width: 80},
+		},
+		{
+			isTerminal: false,
+			expected:   markdown.Options{},
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			result := getRenderOptions(tc.width, tc.isTerminal, tc.noColor)
+
+			assert.Equal(t, result, tc.expected, "result mismatch")
+		})
+	}
+}
+
+func TestRender_noColor(t *testing.T) {
+	content := "# title\n\nsome **bold** text and a [link](https://example.com)\n"
+
+	for _, isTerminal := range []bool{true, false} {
+		t.Run(fmt.Sprintf("terminal %t", isTerminal), func(t *testing.T) {
+			result := markdown.Render(content, getRenderOptions(80, isTerminal, true))
+
+			assert.Equal(t, strings.Contains(result, "\033"), false, "the output should have no escape sequences")
+			assert.Equal(t, strings.Contains(result, "https://example.com"), true, "the link destination should be printed")
+		})
+	}
+}
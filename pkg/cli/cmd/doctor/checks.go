@@ -0,0 +1,429 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package doctor
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/migrate"
+	"github.com/dnote/dnote/pkg/cli/utils"
+	"github.com/dnote/dnote/pkg/cli/validate"
+	"github.com/pkg/errors"
+)
+
+// recoveredBookLabel is the label of the book for the notes whose book does not exist
+const recoveredBookLabel = "recovered"
+
+// check finds a kind of problem in the local database
+type check struct {
+	name string
+	// find returns a description of each problem found
+	find func(ctx context.DnoteCtx) ([]string, error)
+	// fix repairs the problems and returns a description of each change. It is nil
+	// if the problems cannot be repaired automatically.
+	fix func(ctx context.DnoteCtx) ([]string, error)
+	// hint suggests what to do about the problems that cannot be repaired
+	hint string
+}
+
+var checks = []check{
+	{
+		name: "database integrity",
+		find: findCorruption,
+		hint: "Restore a snapshot with `dnote backup restore`",
+	},
+	{
+		name: "schema version",
+		find: findSchemaMismatch,
+		hint: "Upgrade dnote to the latest version",
+	},
+	{
+		name: "search index",
+		find: findStaleIndex,
+		fix:  fixStaleIndex,
+	},
+	{
+		name: "notes without a book",
+		find: findOrphanNotes,
+		fix:  fixOrphanNotes,
+	},
+	{
+		name: "book names",
+		find: findInvalidLabels,
+		fix:  fixInvalidLabels,
+	},
+	{
+		name: "deleted notes",
+		find: findDeletedWithBody,
+		fix:  fixDeletedWithBody,
+	},
+	{
+		name: "unsynced changes",
+		find: findUnmarkedNew,
+		fix:  fixUnmarkedNew,
+	},
+}
+
+// inTx runs the given function in a transaction
+func inTx(ctx context.DnoteCtx, fn func(tx *database.DB) ([]string, error)) ([]string, error) {
+	tx, err := ctx.DB.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "beginning a transaction")
+	}
+
+	ret, err := fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, errors.Wrap(err, "committing a transaction")
+	}
+
+	return ret, nil
+}
+
+func queryStrings(db *database.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying")
+	}
+	defer rows.Close()
+
+	var ret []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, errors.Wrap(err, "scanning a row")
+		}
+
+		ret = append(ret, s)
+	}
+
+	return ret, rows.Err()
+}
+
+func findCorruption(ctx context.DnoteCtx) ([]string, error) {
+	results, err := queryStrings(ctx.DB, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, errors.Wrap(err, "checking the integrity")
+	}
+
+	var ret []string
+	for _, r := range results {
+		if r != "ok" {
+			ret = append(ret, r)
+		}
+	}
+
+	return ret, nil
+}
+
+// findSchemaMismatch finds a schema that is newer than this version of dnote supports.
+// An older schema cannot be found here because the migrations run when dnote starts,
+// and dnote does not start if they fail.
+func findSchemaMismatch(ctx context.DnoteCtx) ([]string, error) {
+	var schema int
+	if err := database.GetSystem(ctx.DB, consts.SystemSchema, &schema); err != nil {
+		return nil, errors.Wrap(err, "getting the schema")
+	}
+
+	latest := len(migrate.LocalSequence)
+	if schema > latest {
+		return []string{fmt.Sprintf("the schema is %d, which is newer than this version of dnote supports (%d)", schema, latest)}, nil
+	}
+
+	return nil, nil
+}
+
+func findStaleIndex(ctx context.DnoteCtx) ([]string, error) {
+	// for a table with external content, the integrity check also compares the index to the content
+	_, err := ctx.DB.Exec("INSERT INTO note_fts(note_fts, rank) VALUES ('integrity-check', 1)")
+	if isCorrupt(err) {
+		return []string{fmt.Sprintf("the search index does not match the notes: %s", err)}, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "checking the search index")
+	}
+
+	return nil, nil
+}
+
+func fixStaleIndex(ctx context.DnoteCtx) ([]string, error) {
+	return inTx(ctx, func(tx *database.DB) ([]string, error) {
+		if _, err := tx.Exec("INSERT INTO note_fts(note_fts) VALUES ('rebuild')"); err != nil {
+			return nil, errors.Wrap(err, "rebuilding the search index")
+		}
+
+		return []string{"rebuilt the search index"}, nil
+	})
+}
+
+const orphanNotesQuery = `SELECT notes.uuid FROM notes
+	LEFT JOIN books ON books.uuid = notes.book_uuid
+	WHERE NOT notes.deleted AND (books.uuid IS NULL OR books.deleted)`
+
+func findOrphanNotes(ctx context.DnoteCtx) ([]string, error) {
+	uuids, err := queryStrings(ctx.DB, orphanNotesQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding notes without a book")
+	}
+
+	var ret []string
+	for _, uuid := range uuids {
+		ret = append(ret, fmt.Sprintf("note %s is in a book that does not exist", uuid))
+	}
+
+	return ret, nil
+}
+
+func fixOrphanNotes(ctx context.DnoteCtx) ([]string, error) {
+	return inTx(ctx, func(tx *database.DB) ([]string, error) {
+		uuids, err := queryStrings(tx, orphanNotesQuery)
+		if err != nil {
+			return nil, errors.Wrap(err, "finding notes without a book")
+		}
+		if len(uuids) == 0 {
+			return nil, nil
+		}
+
+		var bookUUID string
+		err = tx.QueryRow("SELECT uuid FROM books WHERE label = ? AND NOT deleted", recoveredBookLabel).Scan(&bookUUID)
+		if err == sql.ErrNoRows {
+			bookUUID, err = utils.GenerateUUID()
+			if err != nil {
+				return nil, errors.Wrap(err, "generating uuid")
+			}
+
+			b := database.NewBook(bookUUID, recoveredBookLabel, 0, false, true)
+			if err := b.Insert(tx); err != nil {
+				return nil, errors.Wrap(err, "creating the book for the recovered notes")
+			}
+		} else if err != nil {
+			return nil, errors.Wrap(err, "finding the book for the recovered notes")
+		}
+
+		var ret []string
+		for _, uuid := range uuids {
+			if _, err := tx.Exec("UPDATE notes SET book_uuid = ?, dirty = ? WHERE uuid = ?", bookUUID, true, uuid); err != nil {
+				return nil, errors.Wrapf(err, "moving the note %s", uuid)
+			}
+
+			ret = append(ret, fmt.Sprintf("moved note %s to '%s'", uuid, recoveredBookLabel))
+		}
+
+		return ret, nil
+	})
+}
+
+// labelProblem is a book whose label is invalid or used by another book
+type labelProblem struct {
+	uuid  string
+	label string
+	err   error
+}
+
+func getLabelProblems(db *database.DB) ([]labelProblem, error) {
+	rows, err := db.Query("SELECT uuid, label FROM books WHERE NOT deleted ORDER BY rowid")
+	if err != nil {
+		return nil, errors.Wrap(err, "getting books")
+	}
+	defer rows.Close()
+
+	var ret []labelProblem
+	seen := map[string]bool{}
+	for rows.Next() {
+		var uuid, label string
+		if err := rows.Scan(&uuid, &label); err != nil {
+			return nil, errors.Wrap(err, "scanning a book")
+		}
+
+		if seen[label] {
+			ret = append(ret, labelProblem{uuid: uuid, label: label, err: errors.New("Another book has the same name")})
+			continue
+		}
+		seen[label] = true
+
+		// sync keeps conflicting edits in the reserved 'conflicts' book
+		err := validate.BookName(label)
+		if err == validate.ErrBookNameReserved && label == "conflicts" {
+			err = nil
+		}
+		if err != nil {
+			ret = append(ret, labelProblem{uuid: uuid, label: label, err: err})
+		}
+	}
+
+	return ret, rows.Err()
+}
+
+func findInvalidLabels(ctx context.DnoteCtx) ([]string, error) {
+	problems, err := getLabelProblems(ctx.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	for _, p := range problems {
+		ret = append(ret, fmt.Sprintf("book %q: %s", p.label, p.err))
+	}
+
+	return ret, nil
+}
+
+// getValidLabel returns a label similar to the given one that passes the validation
+func getValidLabel(label string) string {
+	ret := strings.Join(strings.Fields(label), "-")
+	if ret == "" {
+		return "untitled"
+	}
+	if validate.BookName(ret) != nil {
+		return "book-" + ret
+	}
+
+	return ret
+}
+
+func fixInvalidLabels(ctx context.DnoteCtx) ([]string, error) {
+	return inTx(ctx, func(tx *database.DB) ([]string, error) {
+		problems, err := getLabelProblems(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		var ret []string
+		for _, p := range problems {
+			label := getValidLabel(p.label)
+
+			var count int
+			if err := tx.QueryRow("SELECT count(*) FROM books WHERE label = ?", label).Scan(&count); err != nil {
+				return nil, errors.Wrapf(err, "checking for books with the label %s", label)
+			}
+			if count > 0 {
+				label, err = sync.ResolveLabel(tx, label)
+				if err != nil {
+					return nil, errors.Wrap(err, "getting a new book label")
+				}
+			}
+
+			if _, err := tx.Exec("UPDATE books SET label = ?, dirty = ? WHERE uuid = ?", label, true, p.uuid); err != nil {
+				return nil, errors.Wrapf(err, "renaming the book %q", p.label)
+			}
+
+			ret = append(ret, fmt.Sprintf("renamed book %q to %q", p.label, label))
+		}
+
+		return ret, nil
+	})
+}
+
+func findDeletedWithBody(ctx context.DnoteCtx) ([]string, error) {
+	uuids, err := queryStrings(ctx.DB, "SELECT uuid FROM notes WHERE deleted AND body != ''")
+	if err != nil {
+		return nil, errors.Wrap(err, "finding deleted notes")
+	}
+
+	var ret []string
+	for _, uuid := range uuids {
+		ret = append(ret, fmt.Sprintf("note %s is deleted but still has a body", uuid))
+	}
+
+	return ret, nil
+}
+
+func fixDeletedWithBody(ctx context.DnoteCtx) ([]string, error) {
+	return inTx(ctx, func(tx *database.DB) ([]string, error) {
+		res, err := tx.Exec("UPDATE notes SET body = '' WHERE deleted AND body != ''")
+		if err != nil {
+			return nil, errors.Wrap(err, "clearing the bodies")
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, errors.Wrap(err, "counting the cleared bodies")
+		}
+
+		return []string{fmt.Sprintf("cleared the body of %d deleted notes", n)}, nil
+	})
+}
+
+// local-only books are never sent, so they are not dirty without a usn
+const (
+	unmarkedBooksQuery = "SELECT uuid FROM books WHERE usn = 0 AND NOT dirty AND NOT local_only"
+	unmarkedNotesQuery = `SELECT notes.uuid FROM notes
+		LEFT JOIN books ON books.uuid = notes.book_uuid
+		WHERE notes.usn = 0 AND NOT notes.dirty AND NOT IFNULL(books.local_only, false)`
+)
+
+func findUnmarkedNew(ctx context.DnoteCtx) ([]string, error) {
+	books, err := queryStrings(ctx.DB, unmarkedBooksQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding books")
+	}
+	notes, err := queryStrings(ctx.DB, unmarkedNotesQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding notes")
+	}
+
+	var ret []string
+	for _, uuid := range books {
+		ret = append(ret, fmt.Sprintf("book %s has never been synced and is not marked to be sent", uuid))
+	}
+	for _, uuid := range notes {
+		ret = append(ret, fmt.Sprintf("note %s has never been synced and is not marked to be sent", uuid))
+	}
+
+	return ret, nil
+}
+
+func fixUnmarkedNew(ctx context.DnoteCtx) ([]string, error) {
+	return inTx(ctx, func(tx *database.DB) ([]string, error) {
+		books, err := queryStrings(tx, unmarkedBooksQuery)
+		if err != nil {
+			return nil, errors.Wrap(err, "finding books")
+		}
+		notes, err := queryStrings(tx, unmarkedNotesQuery)
+		if err != nil {
+			return nil, errors.Wrap(err, "finding notes")
+		}
+
+		var ret []string
+		for _, uuid := range books {
+			if _, err := tx.Exec("UPDATE books SET dirty = ? WHERE uuid = ?", true, uuid); err != nil {
+				return nil, errors.Wrapf(err, "marking the book %s", uuid)
+			}
+			ret = append(ret, fmt.Sprintf("marked book %s to be sent in the next sync", uuid))
+		}
+		for _, uuid := range notes {
+			if _, err := tx.Exec("UPDATE notes SET dirty = ? WHERE uuid = ?", true, uuid); err != nil {
+				return nil, errors.Wrapf(err, "marking the note %s", uuid)
+			}
+			ret = append(ret, fmt.Sprintf("marked note %s to be sent in the next sync", uuid))
+		}
+
+		return ret, nil
+	})
+}
@@ -0,0 +1,14 @@
+// +build cgo
+
+package doctor
+
+import (
+	"github.com/mattn/go-sqlite3"
+)
+
+// isCorrupt returns true if the error is SQLite reporting a corrupt database
+func isCorrupt(err error) bool {
+	e, ok := err.(sqlite3.Error)
+
+	return ok && e.Code == sqlite3.ErrCorrupt
+}
@@ -0,0 +1,156 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package doctor
+
+import (
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/infra"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/snapshot"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var fixFlag bool
+
+var example = `
+  * Check the local database for problems
+  dnote doctor
+
+  * Repair the problems that can be repaired
+  dnote doctor --fix`
+
+// NewCmd returns a new doctor command
+func NewCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "doctor",
+		Short:   "Check the local database for problems",
+		Example: example,
+		Args:    cobra.NoArgs,
+		RunE:    newRun(ctx),
+	}
+
+	f := cmd.Flags()
+	f.BoolVarP(&fixFlag, "fix", "", false, "Repair the problems found")
+
+	return cmd
+}
+
+// result is the outcome of a check
+type result struct {
+	check    check
+	problems []string
+	changes  []string
+}
+
+// diagnose runs the checks and, if fix is true, repairs the problems found
+func diagnose(ctx context.DnoteCtx, fix bool) ([]result, error) {
+	var ret []result
+
+	for _, c := range checks {
+		problems, err := c.find(ctx)
+		if err != nil {
+			return ret, errors.Wrapf(err, "checking %s", c.name)
+		}
+
+		r := result{check: c, problems: problems}
+		if fix && len(problems) > 0 && c.fix != nil {
+			changes, err := c.fix(ctx)
+			if err != nil {
+				return ret, errors.Wrapf(err, "fixing %s", c.name)
+			}
+
+			r.changes = changes
+		}
+
+		ret = append(ret, r)
+	}
+
+	return ret, nil
+}
+
+func printResults(results []result, fix bool) {
+	var found, fixable, fixed int
+
+	for _, r := range results {
+		if len(r.problems) == 0 {
+			log.Successf("%s\n", r.check.name)
+			continue
+		}
+
+		found += len(r.problems)
+		if r.check.fix != nil {
+			fixable += len(r.problems)
+		}
+
+		log.Errorf("%s\n", r.check.name)
+		for _, p := range r.problems {
+			log.Plainf("    %s\n", p)
+		}
+		for _, c := range r.changes {
+			log.Plainf("    %s %s\n", log.ColorGreen.Sprint("fixed:"), c)
+		}
+		if len(r.changes) > 0 {
+			fixed += len(r.problems)
+		} else if r.check.hint != "" {
+			log.Plainf("    %s\n", r.check.hint)
+		}
+	}
+
+	if found == 0 {
+		log.Plain("\nno problems found\n")
+		return
+	}
+
+	if fix {
+		log.Plainf("\nfound %d problems and fixed %d\n", found, fixed)
+	} else {
+		log.Plainf("\nfound %d problems\n", found)
+		if fixable > 0 {
+			log.Plain("Run `dnote doctor --fix` to repair them.\n")
+		}
+	}
+}
+
+func newRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		if fixFlag {
+			if err := snapshot.Auto(ctx, snapshot.ReasonBulk); err != nil {
+				return errors.Wrap(err, "backing up the database")
+			}
+		}
+
+		results, err := diagnose(ctx, fixFlag)
+		if err != nil {
+			return err
+		}
+
+		printResults(results, fixFlag)
+
+		for _, r := range results {
+			if len(r.changes) > 0 {
+				sync.AfterWrite(ctx)
+				break
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,144 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package doctor
+
+import (
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/pkg/errors"
+)
+
+var paths context.Paths = context.Paths{
+	Home:   "../../tmp",
+	Cache:  "../../tmp",
+	Config: "../../tmp",
+	Data:   "../../tmp",
+}
+
+// countProblems returns the number of problems found by each check
+func countProblems(results []result) map[string]int {
+	ret := map[string]int{}
+	for _, r := range results {
+		if len(r.problems) > 0 {
+			ret[r.check.name] = len(r.problems)
+		}
+	}
+
+	return ret
+}
+
+func TestDiagnose(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, dirty) VALUES (?, ?, ?, ?)", "b1-uuid", "js", 1, false)
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, usn, dirty) VALUES (?, ?, ?, ?)", "b2-uuid", "foo bar", 2, false)
+	database.MustExec(t, "inserting b3", db, "INSERT INTO books (uuid, label, usn, dirty) VALUES (?, ?, ?, ?)", "b3-uuid", "123", 0, false)
+	database.MustExec(t, "inserting b4", db, "INSERT INTO books (uuid, label, usn, dirty, local_only) VALUES (?, ?, ?, ?, ?)", "b4-uuid", "scratch", 0, false, true)
+	database.MustExec(t, "inserting b5", db, "INSERT INTO books (uuid, label, usn, dirty) VALUES (?, ?, ?, ?)", "b5-uuid", "conflicts", 3, false)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, dirty) VALUES (?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1 body", 1541108743, 1, false)
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, dirty) VALUES (?, ?, ?, ?, ?, ?)", "n2-uuid", "missing-uuid", "n2 body", 1541108743, 2, false)
+	database.MustExec(t, "inserting n3", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, dirty, deleted) VALUES (?, ?, ?, ?, ?, ?, ?)", "n3-uuid", "b1-uuid", "n3 body", 1541108743, 3, false, true)
+	database.MustExec(t, "inserting n4", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, dirty) VALUES (?, ?, ?, ?, ?, ?)", "n4-uuid", "b1-uuid", "n4 body", 1541108743, 0, false)
+	database.MustExec(t, "inserting n5", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, dirty) VALUES (?, ?, ?, ?, ?, ?)", "n5-uuid", "b4-uuid", "n5 body", 1541108743, 0, false)
+	// remove n1 from the search index
+	database.MustExec(t, "removing n1 from the index", db, "INSERT INTO note_fts(note_fts, rowid, body) SELECT 'delete', rowid, body FROM notes WHERE uuid = ?", "n1-uuid")
+
+	// execute
+	found, err := diagnose(ctx, false)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "diagnosing"))
+	}
+	fixed, err := diagnose(ctx, true)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "fixing"))
+	}
+	after, err := diagnose(ctx, false)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "diagnosing after fixing"))
+	}
+
+	// test
+	expected := map[string]int{
+		"search index":         1,
+		"notes without a book": 1,
+		"book names":           2,
+		"deleted notes":        1,
+		"unsynced changes":     2,
+	}
+	assert.DeepEqual(t, countProblems(found), expected, "problems mismatch")
+	// renaming b3 marks it dirty before its usn is checked
+	expected["unsynced changes"] = 1
+	assert.DeepEqual(t, countProblems(fixed), expected, "problems found while fixing mismatch")
+	assert.DeepEqual(t, countProblems(after), map[string]int{}, "problems after fixing mismatch")
+
+	var n2BookLabel string
+	var n2Dirty bool
+	database.MustScan(t, "getting n2", db.QueryRow(`SELECT books.label, notes.dirty FROM notes
+		INNER JOIN books ON books.uuid = notes.book_uuid WHERE notes.uuid = ?`, "n2-uuid"), &n2BookLabel, &n2Dirty)
+	assert.Equal(t, n2BookLabel, recoveredBookLabel, "n2 book mismatch")
+	assert.Equal(t, n2Dirty, true, "n2 dirty mismatch")
+
+	var b2Label, b3Label string
+	var b3Dirty bool
+	database.MustScan(t, "getting b2", db.QueryRow("SELECT label FROM books WHERE uuid = ?", "b2-uuid"), &b2Label)
+	database.MustScan(t, "getting b3", db.QueryRow("SELECT label, dirty FROM books WHERE uuid = ?", "b3-uuid"), &b3Label, &b3Dirty)
+	assert.Equal(t, b2Label, "foo-bar", "b2 label mismatch")
+	assert.Equal(t, b3Label, "book-123", "b3 label mismatch")
+	assert.Equal(t, b3Dirty, true, "b3 dirty mismatch")
+
+	var n3Body string
+	database.MustScan(t, "getting n3", db.QueryRow("SELECT body FROM notes WHERE uuid = ?", "n3-uuid"), &n3Body)
+	assert.Equal(t, n3Body, "", "n3 body mismatch")
+
+	var n5Dirty bool
+	database.MustScan(t, "getting n5", db.QueryRow("SELECT dirty FROM notes WHERE uuid = ?", "n5-uuid"), &n5Dirty)
+	assert.Equal(t, n5Dirty, false, "a note in a local-only book should not be marked")
+
+	var matchCount int
+	database.MustScan(t, "searching n1", db.QueryRow("SELECT count(*) FROM note_fts WHERE note_fts MATCH ?", "n1"), &matchCount)
+	assert.Equal(t, matchCount, 1, "n1 should be searchable")
+}
+
+func TestFindStaleIndex_error(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	database.MustExec(t, "dropping the index", ctx.DB, "DROP TABLE note_fts")
+
+	// execute
+	problems, err := findStaleIndex(ctx)
+
+	// test
+	assert.NotEqual(t, err, nil, "an error other than corruption should be returned")
+	assert.Equal(t, len(problems), 0, "problems mismatch")
+}
+
+func TestGetValidLabel(t *testing.T) {
+	assert.Equal(t, getValidLabel("foo  bar"), "foo-bar", "spaces mismatch")
+	assert.Equal(t, getValidLabel(" "), "untitled", "empty mismatch")
+	assert.Equal(t, getValidLabel("123"), "book-123", "numeric mismatch")
+	assert.Equal(t, getValidLabel("trash"), "book-trash", "reserved mismatch")
+}
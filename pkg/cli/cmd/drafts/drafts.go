@@ -0,0 +1,192 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package drafts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dnote/dnote/pkg/cli/cmd/add"
+	"github.com/dnote/dnote/pkg/cli/cmd/edit"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/draft"
+	"github.com/dnote/dnote/pkg/cli/infra"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/ui"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var yesFlag bool
+
+var example = `
+  * See the drafts that have not been saved
+  dnote drafts list
+
+  * Open a draft in an editor and save it
+  dnote drafts resume 1a2b3c4d
+
+  * Remove a draft
+  dnote drafts discard 1a2b3c4d`
+
+// NewCmd returns a new drafts command
+func NewCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "drafts",
+		Short:   "Manage the content written in an editor that has not been saved",
+		Example: example,
+	}
+
+	cmd.AddCommand(newListCmd(ctx))
+	cmd.AddCommand(newResumeCmd(ctx))
+	cmd.AddCommand(newDiscardCmd(ctx))
+
+	return cmd
+}
+
+func newListCmd(ctx context.DnoteCtx) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the drafts",
+		Args:  cobra.NoArgs,
+		RunE:  newListRun(ctx),
+	}
+}
+
+func newResumeCmd(ctx context.DnoteCtx) *cobra.Command {
+	return &cobra.Command{
+		Use:   "resume <id>",
+		Short: "Open a draft in an editor and save it",
+		Args:  cobra.ExactArgs(1),
+		RunE:  newResumeRun(ctx),
+	}
+}
+
+func newDiscardCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "discard <id>",
+		Short: "Remove a draft",
+		Args:  cobra.ExactArgs(1),
+		RunE:  newDiscardRun(ctx),
+	}
+
+	f := cmd.Flags()
+	f.BoolVarP(&yesFlag, "yes", "y", false, "Assume yes to the prompts and run in non-interactive mode")
+
+	return cmd
+}
+
+func maybeConfirm(message string, defaultValue bool) (bool, error) {
+	if yesFlag {
+		return true, nil
+	}
+
+	return ui.Confirm(message, defaultValue)
+}
+
+// getPreview returns the first line of the content of the draft
+func getPreview(d draft.Draft) string {
+	content, err := d.Content()
+	if err != nil {
+		log.Debug("reading the draft %s: %s\n", d.ID, err)
+		return ""
+	}
+
+	lines := strings.SplitN(strings.TrimSpace(content), "\n", 2)
+	if len(lines[0]) > 50 {
+		return lines[0][:50] + "..."
+	}
+
+	return lines[0]
+}
+
+func newListRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		drafts, err := draft.List(ctx)
+		if err != nil {
+			return errors.Wrap(err, "listing the drafts")
+		}
+
+		if len(drafts) == 0 {
+			log.Plain("no drafts\n")
+			return nil
+		}
+
+		for _, d := range drafts {
+			createdAt := time.Unix(0, d.CreatedAt).Format("Jan 2, 2006 3:04pm")
+
+			var status string
+			if d.InUse() {
+				status = " (in use)"
+			}
+
+			log.Plainf("%s  %s  %-4s  %s%s  %s\n", log.ColorYellow.Sprint(d.ID), createdAt, d.Kind, d.BookLabel, status, getPreview(d))
+		}
+
+		return nil
+	}
+}
+
+func newResumeRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		d, err := draft.Find(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		if d.InUse() {
+			return errors.Errorf("draft %s is being edited by another process", d.ID)
+		}
+
+		switch d.Kind {
+		case draft.KindAdd:
+			return add.ResumeDraft(ctx, d)
+		case draft.KindEdit, draft.KindEditMeta, draft.KindEditBook:
+			return edit.ResumeDraft(ctx, d)
+		default:
+			return errors.Errorf("unknown kind of draft '%s'", d.Kind)
+		}
+	}
+}
+
+func newDiscardRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		d, err := draft.Find(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		ok, err := maybeConfirm(fmt.Sprintf("remove the draft %s for %s?", d.ID, d.BookLabel), false)
+		if err != nil {
+			return errors.Wrap(err, "getting confirmation")
+		}
+		if !ok {
+			log.Warnf("aborted by user\n")
+			return nil
+		}
+
+		if err := d.Discard(); err != nil {
+			return errors.Wrap(err, "removing the draft")
+		}
+
+		log.Successf("discarded %s\n", d.ID)
+
+		return nil
+	}
+}
@@ -0,0 +1,493 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package edit
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/draft"
+	"github.com/dnote/dnote/pkg/cli/hooks"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/operations"
+	"github.com/dnote/dnote/pkg/cli/snapshot"
+	"github.com/dnote/dnote/pkg/cli/ui"
+	"github.com/pkg/errors"
+)
+
+// sectionDelimiterRegex matches the line at which a note starts in the document
+// of a book. The uuid is empty for new notes.
+var sectionDelimiterRegex = regexp.MustCompile(`^=== note(?: (\S+))? ===$`)
+
+const bookDocumentHeader = `# Edit the notes in the book '%s'.
+# Each note starts with a line of '=== note <uuid> ==='. Remove the section of
+# a note to remove it, and add a section starting with '=== note ===' to add a note.
+# The lines above the first note are ignored.
+`
+
+// bookNote is a note in the document of a book
+type bookNote struct {
+	RowID   int
+	UUID    string
+	Body    string
+	AddedOn int64
+}
+
+// section is the content of a note in the document of a book
+type section struct {
+	uuid string
+	body string
+}
+
+// bookChanges is the changes made to the notes in the document of a book
+type bookChanges struct {
+	updated []bookNote
+	removed []bookNote
+	added   []string
+}
+
+func (c bookChanges) empty() bool {
+	return len(c.updated) == 0 && len(c.removed) == 0 && len(c.added) == 0
+}
+
+func getBookNotes(db *database.DB, bookUUID string) ([]bookNote, error) {
+	rows, err := db.Query("SELECT rowid, uuid, body, added_on FROM notes WHERE book_uuid = ? AND deleted = ? ORDER BY added_on ASC", bookUUID, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying notes")
+	}
+	defer rows.Close()
+
+	var ret []bookNote
+	for rows.Next() {
+		var n bookNote
+		if err := rows.Scan(&n.RowID, &n.UUID, &n.Body, &n.AddedOn); err != nil {
+			return nil, errors.Wrap(err, "scanning a row")
+		}
+
+		ret = append(ret, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating rows")
+	}
+
+	return ret, nil
+}
+
+// normalizeBody removes the line breaks at the end of the content of a section
+func normalizeBody(body string) string {
+	return strings.TrimRight(body, "\r\n")
+}
+
+// renderBook returns a document containing all notes in a book
+func renderBook(bookLabel string, notes []bookNote) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, bookDocumentHeader, bookLabel)
+
+	for _, n := range notes {
+		fmt.Fprintf(&b, "\n=== note %s ===\n", n.UUID)
+		b.WriteString(normalizeBody(n.Body))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// parseBook parses the document of a book into sections
+func parseBook(content string) []section {
+	var ret []section
+	var cur *section
+	var lines []string
+
+	flush := func() {
+		if cur != nil {
+			cur.body = normalizeBody(strings.Join(lines, "\n"))
+			ret = append(ret, *cur)
+		}
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		match := sectionDelimiterRegex.FindStringSubmatch(strings.TrimSuffix(line, "\r"))
+		if match == nil {
+			lines = append(lines, line)
+			continue
+		}
+
+		flush()
+		cur = &section{uuid: match[1]}
+		lines = nil
+	}
+	flush()
+
+	return ret
+}
+
+// diffBook returns the changes made to the notes in the given sections. The section of
+// an existing note without content is regarded as removed.
+func diffBook(notes []bookNote, sections []section) (bookChanges, error) {
+	byUUID := map[string]bookNote{}
+	for _, n := range notes {
+		byUUID[n.UUID] = n
+	}
+
+	var ret bookChanges
+	seen := map[string]bool{}
+	for _, s := range sections {
+		empty := strings.TrimSpace(s.body) == ""
+
+		if s.uuid == "" {
+			if !empty {
+				ret.added = append(ret.added, s.body)
+			}
+
+			continue
+		}
+
+		n, ok := byUUID[s.uuid]
+		if !ok {
+			return bookChanges{}, errors.Errorf("note %s is not in the book", s.uuid)
+		}
+		if seen[s.uuid] {
+			return bookChanges{}, errors.Errorf("note %s appears more than once", s.uuid)
+		}
+		seen[s.uuid] = true
+
+		if empty {
+			ret.removed = append(ret.removed, n)
+		} else if s.body != normalizeBody(n.Body) {
+			n.Body = s.body
+			ret.updated = append(ret.updated, n)
+		}
+	}
+
+	for _, n := range notes {
+		if !seen[n.UUID] {
+			ret.removed = append(ret.removed, n)
+		}
+	}
+
+	return ret, nil
+}
+
+// getTitle returns the first line of the content to be used in the summary
+func getTitle(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if utf8.RuneCountInString(line) > 50 {
+			line = string([]rune(line)[:50]) + "..."
+		}
+
+		return line
+	}
+
+	return ""
+}
+
+func pluralize(count int, singular string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, singular)
+	}
+
+	return fmt.Sprintf("%d %ss", count, singular)
+}
+
+// getSummary returns a question confirming the changes
+func (c bookChanges) getSummary() string {
+	var parts []string
+	if len(c.updated) > 0 {
+		parts = append(parts, "update "+pluralize(len(c.updated), "note"))
+	}
+	if len(c.removed) > 0 {
+		parts = append(parts, "remove "+pluralize(len(c.removed), "note"))
+	}
+	if len(c.added) > 0 {
+		parts = append(parts, "add "+pluralize(len(c.added), "note"))
+	}
+
+	ret := parts[0]
+	if len(parts) > 1 {
+		ret = strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+	}
+
+	return ret + "?"
+}
+
+func (c bookChanges) print() {
+	for _, n := range c.updated {
+		log.Plainf("  %s %s\n", log.ColorYellow.Sprint("update"), getTitle(n.Body))
+	}
+	for _, n := range c.removed {
+		log.Plainf("  %s %s\n", log.ColorRed.Sprint("remove"), getTitle(n.Body))
+	}
+	for _, body := range c.added {
+		log.Plainf("  %s %s\n", log.ColorGreen.Sprint("add"), getTitle(body))
+	}
+}
+
+// runBookPreEdit runs the pre-edit hook with the updated and added notes, and returns
+// the changes with the content rewritten by the hook
+func runBookPreEdit(ctx context.DnoteCtx, bookLabel string, c bookChanges) (bookChanges, error) {
+	var notes []hooks.Note
+	for _, n := range c.updated {
+		notes = append(notes, hooks.Note{UUID: n.UUID, RowID: n.RowID, BookLabel: bookLabel, Content: n.Body})
+	}
+	for _, body := range c.added {
+		notes = append(notes, hooks.Note{BookLabel: bookLabel, Content: body})
+	}
+	if len(notes) == 0 {
+		return c, nil
+	}
+
+	notes, err := hooks.Pre(ctx, hooks.PreEdit, notes)
+	if err != nil {
+		return c, err
+	}
+
+	ret := bookChanges{removed: c.removed}
+	for i, n := range c.updated {
+		n.Body = notes[i].Content
+		ret.updated = append(ret.updated, n)
+	}
+	for i := range c.added {
+		ret.added = append(ret.added, notes[len(c.updated)+i].Content)
+	}
+
+	return ret, nil
+}
+
+// runBookPostHooks runs the post-edit hook with the updated and added notes, and the
+// post-remove hook with the removed notes
+func runBookPostHooks(ctx context.DnoteCtx, bookLabel string, c bookChanges, addedUUIDs []string) {
+	var edited, removed []hooks.Note
+	for _, n := range c.updated {
+		edited = append(edited, hooks.Note{UUID: n.UUID, RowID: n.RowID, BookLabel: bookLabel, Content: n.Body})
+	}
+	for i, body := range c.added {
+		edited = append(edited, hooks.Note{UUID: addedUUIDs[i], BookLabel: bookLabel, Content: body})
+	}
+	for _, n := range c.removed {
+		removed = append(removed, hooks.Note{UUID: n.UUID, RowID: n.RowID, BookLabel: bookLabel, Content: n.Body})
+	}
+
+	if len(edited) > 0 {
+		hooks.Post(ctx, hooks.PostEdit, hooks.Payload{Notes: edited})
+	}
+	if len(removed) > 0 {
+		hooks.Post(ctx, hooks.PostRemove, hooks.Payload{Notes: removed})
+	}
+}
+
+// applyBookChanges applies the changes to the notes in a book in a transaction. It
+// returns the uuids of the added notes.
+func applyBookChanges(ctx context.DnoteCtx, bookUUID string, c bookChanges) ([]string, error) {
+	tx, err := ctx.DB.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "beginning a transaction")
+	}
+
+	for _, n := range c.updated {
+		if err := database.UpdateNoteContent(tx, ctx.Clock, n.RowID, n.Body); err != nil {
+			tx.Rollback()
+			return nil, errors.Wrapf(err, "updating the note %s", n.UUID)
+		}
+	}
+
+	for _, n := range c.removed {
+		if err := operations.RemoveNote(tx, n.UUID); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	var addedUUIDs []string
+	ts := ctx.Clock.Now().UnixNano()
+	for _, body := range c.added {
+		uuid, err := operations.InsertNote(tx, bookUUID, body, ts, 0, 0)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		addedUUIDs = append(addedUUIDs, uuid)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, errors.Wrap(err, "committing a transaction")
+	}
+
+	return addedUUIDs, nil
+}
+
+// getDraftNotes returns the notes in the book that were in the document when the draft
+// was created. The notes added since then are not in the document, and must not be
+// regarded as removed.
+func getDraftNotes(d draft.Draft, notes []bookNote) []bookNote {
+	inDraft := map[string]bool{}
+	for _, uuid := range d.NoteUUIDs {
+		inDraft[uuid] = true
+	}
+
+	var ret []bookNote
+	for _, n := range notes {
+		// the drafts created by an older version do not have the uuids
+		if d.NoteUUIDs == nil && n.AddedOn <= d.CreatedAt || inDraft[n.UUID] {
+			ret = append(ret, n)
+		}
+	}
+
+	return ret
+}
+
+// getUUIDs returns the uuids of the notes
+func getUUIDs(notes []bookNote) []string {
+	var ret []string
+	for _, n := range notes {
+		ret = append(ret, n.UUID)
+	}
+
+	return ret
+}
+
+// editBookDraft opens the document of a book in an editor and applies the changes after
+// a confirmation. If the document is invalid, the editor is opened again on the same draft.
+// The document is compared only to the notes it was created from.
+func editBookDraft(ctx context.DnoteCtx, d draft.Draft, bookUUID string, all []bookNote) error {
+	notes := getDraftNotes(d, all)
+
+	var c bookChanges
+	for {
+		content, err := d.Edit(ctx)
+		if err != nil {
+			d.Keep()
+			return errors.Wrap(err, "getting content from editor")
+		}
+
+		c, err = diffBook(notes, parseBook(content))
+		if err == nil {
+			break
+		}
+
+		log.Errorf("%s\n", err)
+
+		ok, confirmErr := ui.Confirm("edit again?", true)
+		if confirmErr != nil {
+			d.Keep()
+			return errors.Wrap(confirmErr, "getting confirmation")
+		}
+		if !ok {
+			d.Keep()
+			return err
+		}
+	}
+
+	if c.empty() {
+		if err := d.Discard(); err != nil {
+			log.Debug("discarding the draft: %s\n", err)
+		}
+
+		return errors.New("Nothing changed")
+	}
+
+	c, err := runBookPreEdit(ctx, d.BookLabel, c)
+	if err != nil {
+		d.Keep()
+		return err
+	}
+
+	c.print()
+	ok, err := ui.Confirm(c.getSummary(), true)
+	if err != nil {
+		d.Keep()
+		return errors.Wrap(err, "getting confirmation")
+	}
+	if !ok {
+		log.Warnf("aborted by user\n")
+		d.Keep()
+		return nil
+	}
+
+	if err := snapshot.Auto(ctx, snapshot.ReasonBulk); err != nil {
+		d.Keep()
+		return errors.Wrap(err, "backing up the database")
+	}
+
+	addedUUIDs, err := applyBookChanges(ctx, bookUUID, c)
+	if err != nil {
+		d.Keep()
+		return err
+	}
+
+	if err := d.Discard(); err != nil {
+		log.Error(errors.Wrap(err, "discarding the draft").Error())
+	}
+
+	log.Successf("edited the book %s\n", d.BookLabel)
+
+	runBookPostHooks(ctx, d.BookLabel, c, addedUUIDs)
+	sync.AfterWrite(ctx)
+
+	return nil
+}
+
+// resumeBookDraft opens the draft of a book in an editor and applies the changes
+func resumeBookDraft(ctx context.DnoteCtx, d draft.Draft) error {
+	bookUUID, err := database.GetBookUUID(ctx.DB, d.BookLabel)
+	if err != nil {
+		return err
+	}
+
+	notes, err := getBookNotes(ctx.DB, bookUUID)
+	if err != nil {
+		return errors.Wrap(err, "getting notes")
+	}
+
+	return editBookDraft(ctx, d, bookUUID, notes)
+}
+
+func runBookAll(ctx context.DnoteCtx, bookName string) error {
+	if nameFlag != "" {
+		return errors.New("--name cannot be used with --all")
+	}
+
+	bookUUID, err := database.GetBookUUID(ctx.DB, bookName)
+	if err != nil {
+		return errors.Wrap(err, "getting book uuid")
+	}
+
+	notes, err := getBookNotes(ctx.DB, bookUUID)
+	if err != nil {
+		return errors.Wrap(err, "getting notes")
+	}
+
+	d, err := draft.GetBook(ctx, bookName, getUUIDs(notes), renderBook(bookName, notes))
+	if err != nil {
+		return errors.Wrap(err, "getting a draft")
+	}
+
+	return editBookDraft(ctx, d, bookUUID, notes)
+}
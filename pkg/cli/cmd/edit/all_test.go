@@ -0,0 +1,274 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package edit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/draft"
+	"github.com/dnote/dnote/pkg/clock"
+	"github.com/pkg/errors"
+)
+
+var paths context.Paths = context.Paths{
+	Home:   "../../tmp",
+	Cache:  "../../tmp",
+	Config: "../../tmp",
+	Data:   "../../tmp",
+}
+
+var testBookNotes = []bookNote{
+	{RowID: 1, UUID: "n1-uuid", Body: "n1 body\n"},
+	{RowID: 2, UUID: "n2-uuid", Body: "# n2 title\n\nn2 body"},
+}
+
+func TestRenderBook(t *testing.T) {
+	result := renderBook("js", testBookNotes)
+
+	expected := `# Edit the notes in the book 'js'.
+# Each note starts with a line of '=== note <uuid> ==='. Remove the section of
+# a note to remove it, and add a section starting with '=== note ===' to add a note.
+# The lines above the first note are ignored.
+
+=== note n1-uuid ===
+n1 body
+
+=== note n2-uuid ===
+# n2 title
+
+n2 body
+`
+	assert.Equal(t, result, expected, "result mismatch")
+
+	c, err := diffBook(testBookNotes, parseBook(result))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "diffing"))
+	}
+	assert.Equal(t, c.empty(), true, "the rendered document should have no changes")
+}
+
+func TestDiffBook(t *testing.T) {
+	testCases := []struct {
+		content  string
+		updated  []string
+		removed  []string
+		added    []string
+		expected string
+	}{
+		{
+			content: `=== note n1-uuid ===
+n1 body updated
+=== note n2-uuid ===
+# n2 title
+
+n2 body
+`,
+			updated: []string{"n1-uuid"},
+		},
+		{
+			content: `=== note n2-uuid ===
+# n2 title
+
+n2 body
+`,
+			removed: []string{"n1-uuid"},
+		},
+		{
+			content: `=== note n1-uuid ===
+
+=== note n2-uuid ===
+n2 body updated
+=== note ===
+n3 body
+
+=== note ===
+
+`,
+			updated: []string{"n2-uuid"},
+			removed: []string{"n1-uuid"},
+			added:   []string{"n3 body"},
+		},
+		{
+			content:  "=== note n3-uuid ===\nn3 body\n",
+			expected: "note n3-uuid is not in the book",
+		},
+		{
+			content:  "=== note n1-uuid ===\nn1 body\n=== note n1-uuid ===\nn1 body\n",
+			expected: "note n1-uuid appears more than once",
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			c, err := diffBook(testBookNotes, parseBook(tc.content))
+			if tc.expected != "" {
+				if err == nil {
+					t.Fatal("no error")
+				}
+
+				assert.Equal(t, err.Error(), tc.expected, "error mismatch")
+				return
+			}
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "diffing"))
+			}
+
+			assert.DeepEqual(t, getUUIDs(c.updated), tc.updated, "updated mismatch")
+			assert.DeepEqual(t, getUUIDs(c.removed), tc.removed, "removed mismatch")
+			assert.DeepEqual(t, c.added, tc.added, "added mismatch")
+		})
+	}
+}
+
+func TestApplyBookChanges(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	c := clock.NewMock()
+	now := time.Date(2020, time.October, 18, 15, 30, 0, 0, time.UTC)
+	c.SetNow(now)
+	ctx.Clock = c
+
+	db := ctx.DB
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn) VALUES (?, ?, ?)", "b1-uuid", "js", 1)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn) VALUES (?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1 body", 1541108743, 1)
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn) VALUES (?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", "n2 body", 1541108744, 2)
+	database.MustExec(t, "inserting n3", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn) VALUES (?, ?, ?, ?, ?)", "n3-uuid", "b1-uuid", "n3 body", 1541108745, 3)
+
+	notes, err := getBookNotes(db, "b1-uuid")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting notes"))
+	}
+	assert.DeepEqual(t, getUUIDs(notes), []string{"n1-uuid", "n2-uuid", "n3-uuid"}, "notes mismatch")
+
+	content := "=== note n1-uuid ===\nn1 body updated\n=== note n3-uuid ===\nn3 body\n=== note ===\nn4 body\n"
+	changes, err := diffBook(notes, parseBook(content))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "diffing"))
+	}
+
+	// execute
+	if _, err := applyBookChanges(ctx, "b1-uuid", changes); err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	// test
+	var noteCount, deletedCount int
+	database.MustScan(t, "counting notes", db.QueryRow("SELECT count(*) FROM notes WHERE deleted = false"), &noteCount)
+	database.MustScan(t, "counting deleted notes", db.QueryRow("SELECT count(*) FROM notes WHERE deleted = true"), &deletedCount)
+	assert.Equal(t, noteCount, 3, "note count mismatch")
+	assert.Equal(t, deletedCount, 1, "deleted note count mismatch")
+
+	var n1Body string
+	var n1Dirty bool
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT body, dirty FROM notes WHERE uuid = ?", "n1-uuid"), &n1Body, &n1Dirty)
+	assert.Equal(t, n1Body, "n1 body updated", "n1 body mismatch")
+	assert.Equal(t, n1Dirty, true, "n1 dirty mismatch")
+
+	var n2Body string
+	var n2Deleted, n2Dirty bool
+	database.MustScan(t, "getting n2", db.QueryRow("SELECT body, deleted, dirty FROM notes WHERE uuid = ?", "n2-uuid"), &n2Body, &n2Deleted, &n2Dirty)
+	assert.Equal(t, n2Body, "", "n2 body mismatch")
+	assert.Equal(t, n2Deleted, true, "n2 deleted mismatch")
+	assert.Equal(t, n2Dirty, true, "n2 dirty mismatch")
+
+	var n3Dirty bool
+	database.MustScan(t, "getting n3", db.QueryRow("SELECT dirty FROM notes WHERE uuid = ?", "n3-uuid"), &n3Dirty)
+	assert.Equal(t, n3Dirty, false, "n3 dirty mismatch")
+
+	var n4BookUUID string
+	var n4AddedOn int64
+	var n4USN int
+	var n4Dirty bool
+	database.MustScan(t, "getting n4", db.QueryRow("SELECT book_uuid, added_on, usn, dirty FROM notes WHERE body = ?", "n4 body"), &n4BookUUID, &n4AddedOn, &n4USN, &n4Dirty)
+	assert.Equal(t, n4BookUUID, "b1-uuid", "n4 book_uuid mismatch")
+	assert.Equal(t, n4AddedOn, now.UnixNano(), "n4 added_on mismatch")
+	assert.Equal(t, n4USN, 0, "n4 usn mismatch")
+	assert.Equal(t, n4Dirty, true, "n4 dirty mismatch")
+}
+
+func TestGetDraftNotes_staleDraft(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	c := clock.NewMock()
+	c.SetNow(time.Date(2020, time.October, 18, 15, 30, 0, 0, time.UTC))
+	ctx.Clock = c
+
+	db := ctx.DB
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn) VALUES (?, ?, ?)", "b1-uuid", "js", 1)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn) VALUES (?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1 body", 1541108743, 1)
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn) VALUES (?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", "n2 body", 1541108744, 2)
+
+	notes, err := getBookNotes(db, "b1-uuid")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting notes"))
+	}
+	d, err := draft.GetBook(ctx, "js", getUUIDs(notes), renderBook("js", notes))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating a draft"))
+	}
+	d.Keep()
+
+	// a note added after the draft was created, with an older timestamp as if it was
+	// synced from another machine
+	database.MustExec(t, "inserting n3", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn) VALUES (?, ?, ?, ?, ?)", "n3-uuid", "b1-uuid", "n3 body", 1541108745, 3)
+
+	// execute
+	resumed, err := draft.Find(ctx, d.ID)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "finding the draft"))
+	}
+	all, err := getBookNotes(db, "b1-uuid")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting notes after adding"))
+	}
+	result := getDraftNotes(resumed, all)
+
+	// test
+	assert.DeepEqual(t, getUUIDs(result), []string{"n1-uuid", "n2-uuid"}, "notes mismatch")
+
+	content, err := resumed.Content()
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "reading the draft"))
+	}
+	changes, err := diffBook(result, parseBook(content))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "diffing"))
+	}
+	assert.Equal(t, changes.empty(), true, "the note added after the draft should not be removed")
+}
+
+func TestGetDraftNotes_legacyDraft(t *testing.T) {
+	notes := []bookNote{
+		{UUID: "n1-uuid", AddedOn: 1},
+		{UUID: "n2-uuid", AddedOn: 3},
+	}
+
+	result := getDraftNotes(draft.Draft{Kind: draft.KindEditBook, CreatedAt: 2}, notes)
+
+	assert.DeepEqual(t, getUUIDs(result), []string{"n1-uuid"}, "notes mismatch")
+}
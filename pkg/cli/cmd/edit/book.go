@@ -21,6 +21,7 @@ package edit
 import (
 	"strings"
 
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
 	"github.com/dnote/dnote/pkg/cli/context"
 	"github.com/dnote/dnote/pkg/cli/database"
 	"github.com/dnote/dnote/pkg/cli/log"
@@ -37,6 +38,9 @@ func validateRunBookFlags() error {
 	if bookFlag != "" {
 		return errors.New("--book is invalid for editing a book")
 	}
+	if metaFlag {
+		return errors.New("--meta is invalid for editing a book")
+	}
 
 	return nil
 }
@@ -78,6 +82,10 @@ func runBook(ctx context.DnoteCtx, bookName string) error {
 		return errors.Wrap(err, "validating flags.")
 	}
 
+	if allFlag {
+		return runBookAll(ctx, bookName)
+	}
+
 	db := ctx.DB
 	uuid, err := database.GetBookUUID(db, bookName)
 	if err != nil {
@@ -120,5 +128,7 @@ func runBook(ctx context.DnoteCtx, bookName string) error {
 	log.Success("edited the book\n")
 	output.BookInfo(bookInfo)
 
+	sync.AfterWrite(ctx)
+
 	return nil
 }
@@ -30,6 +30,10 @@ import (
 var contentFlag string
 var bookFlag string
 var nameFlag string
+var metaFlag bool
+var allFlag bool
+var dueFlag string
+var remindFlag string
 
 var example = `
   * Edit a note by id
@@ -41,11 +45,20 @@ var example = `
   * Move a note to another book
   dnote edit 3 -b javascript
 
+  * Change the due date of a note and remove its reminder
+  dnote edit 3 --due "next friday" --remind none
+
+  * Edit a note along with its book and visibility in a front matter
+  dnote edit 3 --meta
+
   * Rename a book
   dnote edit javascript
 
   * Rename a book without launching an editor
   dnote edit javascript -n js
+
+  * Edit all notes in a book in a single document
+  dnote edit javascript --all
 `
 
 // NewCmd returns a new edit command
@@ -63,6 +76,10 @@ func NewCmd(ctx context.DnoteCtx) *cobra.Command {
 	f.StringVarP(&contentFlag, "content", "c", "", "a new content for the note")
 	f.StringVarP(&bookFlag, "book", "b", "", "the name of the book to move the note to")
 	f.StringVarP(&nameFlag, "name", "n", "", "a new name for a book")
+	f.BoolVarP(&allFlag, "all", "a", false, "edit all notes in the book in a single document")
+	f.StringVarP(&dueFlag, "due", "", "", "a new due date for the note, or 'none' to remove it")
+	f.StringVarP(&remindFlag, "remind", "r", "", "a new reminder for the note, or 'none' to remove it")
+	f.BoolVarP(&metaFlag, "meta", "m", false, "edit the book and visibility of the note in a front matter along with the content")
 
 	return cmd
 }
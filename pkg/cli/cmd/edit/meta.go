@@ -0,0 +1,164 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package edit
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/validate"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const frontMatterDelimiter = "---"
+
+// noteMeta is the metadata of a note in the front matter
+type noteMeta struct {
+	Book   string `yaml:"book"`
+	Public *bool  `yaml:"public"`
+
+	// read-only
+	UUID     string `yaml:"uuid"`
+	AddedOn  string `yaml:"added_on"`
+	EditedOn string `yaml:"edited_on"`
+}
+
+func formatTimestamp(ts int64) string {
+	if ts == 0 {
+		return ""
+	}
+
+	return time.Unix(0, ts).UTC().Format(time.RFC3339)
+}
+
+func getNoteMeta(note database.Note, bookLabel string) noteMeta {
+	public := note.Public
+
+	return noteMeta{
+		Book:     bookLabel,
+		Public:   &public,
+		UUID:     note.UUID,
+		AddedOn:  formatTimestamp(note.AddedOn),
+		EditedOn: formatTimestamp(note.EditedOn),
+	}
+}
+
+// editableMeta is the part of the front matter that can be changed
+type editableMeta struct {
+	Book   string `yaml:"book"`
+	Public bool   `yaml:"public"`
+}
+
+// readOnlyMeta is the part of the front matter that cannot be changed
+type readOnlyMeta struct {
+	UUID     string `yaml:"uuid"`
+	AddedOn  string `yaml:"added_on"`
+	EditedOn string `yaml:"edited_on,omitempty"`
+}
+
+// renderMeta returns the content of the note with its metadata in a front matter.
+// The values are marshalled so that a book name such as 'null' or 'yes' is quoted.
+func renderMeta(note database.Note, bookLabel string) (string, error) {
+	m := getNoteMeta(note, bookLabel)
+
+	editable, err := yaml.Marshal(editableMeta{Book: m.Book, Public: *m.Public})
+	if err != nil {
+		return "", errors.Wrap(err, "marshalling the front matter")
+	}
+	readOnly, err := yaml.Marshal(readOnlyMeta{UUID: m.UUID, AddedOn: m.AddedOn, EditedOn: m.EditedOn})
+	if err != nil {
+		return "", errors.Wrap(err, "marshalling the read-only fields")
+	}
+
+	var b strings.Builder
+	b.WriteString(frontMatterDelimiter + "\n")
+	b.Write(editable)
+	b.WriteString("# the fields below are read-only\n")
+	b.Write(readOnly)
+	b.WriteString(frontMatterDelimiter + "\n")
+	b.WriteString(note.Body)
+
+	return b.String(), nil
+}
+
+// splitFrontMatter splits the content into the front matter and the body
+func splitFrontMatter(content string) (string, string, error) {
+	lines := strings.SplitAfter(content, "\n")
+	if len(lines) == 0 || strings.TrimRight(lines[0], "\r\n") != frontMatterDelimiter {
+		return "", "", errors.Errorf("the content must start with a line of '%s'", frontMatterDelimiter)
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], "\r\n") == frontMatterDelimiter {
+			return strings.Join(lines[1:i], ""), strings.Join(lines[i+1:], ""), nil
+		}
+	}
+
+	return "", "", errors.Errorf("the front matter must end with a line of '%s'", frontMatterDelimiter)
+}
+
+// parseMeta parses the content with a front matter and returns the changes made to the note
+func parseMeta(content string, note database.Note, bookLabel string) (noteChanges, error) {
+	fm, body, err := splitFrontMatter(content)
+	if err != nil {
+		return noteChanges{}, err
+	}
+
+	var m noteMeta
+	if err := yaml.Unmarshal([]byte(fm), &m); err != nil {
+		return noteChanges{}, errors.Wrap(err, "invalid front matter")
+	}
+
+	prev := getNoteMeta(note, bookLabel)
+	if m.UUID != "" && m.UUID != prev.UUID {
+		return noteChanges{}, errors.New("uuid is read-only")
+	}
+	if m.AddedOn != "" && m.AddedOn != prev.AddedOn {
+		return noteChanges{}, errors.New("added_on is read-only")
+	}
+	if m.EditedOn != "" && m.EditedOn != prev.EditedOn {
+		return noteChanges{}, errors.New("edited_on is read-only")
+	}
+
+	var ret noteChanges
+
+	if m.Book == "" {
+		return noteChanges{}, errors.New("book is required")
+	}
+	if m.Book != bookLabel {
+		if err := validate.BookName(m.Book); err != nil {
+			return noteChanges{}, errors.Wrap(err, "invalid book name")
+		}
+
+		ret.bookName = m.Book
+	}
+	if m.Public != nil && *m.Public != note.Public {
+		ret.public = m.Public
+	}
+	if strings.TrimSpace(body) == "" {
+		return noteChanges{}, errors.New("Empty content")
+	}
+	if body != note.Body {
+		ret.content = body
+	}
+
+	return ret, nil
+}
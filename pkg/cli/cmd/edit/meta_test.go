@@ -0,0 +1,189 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package edit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/database"
+)
+
+var testNote = database.Note{
+	UUID:     "n1-uuid",
+	BookUUID: "b1-uuid",
+	Body:     "n1 body\n",
+	AddedOn:  time.Date(2020, time.October, 18, 15, 30, 0, 0, time.UTC).UnixNano(),
+	EditedOn: time.Date(2020, time.October, 19, 9, 0, 0, 0, time.UTC).UnixNano(),
+	Public:   false,
+}
+
+func TestRenderMeta(t *testing.T) {
+	result, err := renderMeta(testNote, "js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `---
+book: js
+public: false
+# the fields below are read-only
+uuid: n1-uuid
+added_on: "2020-10-18T15:30:00Z"
+edited_on: "2020-10-19T09:00:00Z"
+---
+n1 body
+`
+	assert.Equal(t, result, expected, "result mismatch")
+}
+
+func TestRenderMeta_roundTrip(t *testing.T) {
+	testCases := []string{"c# notes", "null", "~", "yes", "1.0", "true", "a: b", "'quoted'"}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			content, err := renderMeta(testNote, tc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := parseMeta(content, testNote, tc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, result.bookName, "", "bookName mismatch")
+			assert.Equal(t, result.empty(), true, "the rendered content should have no changes")
+		})
+	}
+}
+
+func TestParseMeta(t *testing.T) {
+	public := true
+
+	rendered, err := renderMeta(testNote, "js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		content  string
+		expected noteChanges
+	}{
+		{
+			content:  rendered,
+			expected: noteChanges{},
+		},
+		{
+			content: `---
+book: css
+public: true
+# the fields below are read-only
+uuid: n1-uuid
+added_on: 2020-10-18T15:30:00Z
+edited_on: 2020-10-19T09:00:00Z
+---
+n1 body updated
+`,
+			expected: noteChanges{
+				bookName: "css",
+				public:   &public,
+				content:  "n1 body updated\n",
+			},
+		},
+		{
+			// read-only fields can be omitted
+			content:  "---\r\nbook: js\r\n---\r\nn1 body\n",
+			expected: noteChanges{},
+		},
+		{
+			// the body can contain the delimiter
+			content: "---\nbook: js\n---\n---\nn1 body\n",
+			expected: noteChanges{
+				content: "---\nn1 body\n",
+			},
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			result, err := parseMeta(tc.content, testNote, "js")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, result.bookName, tc.expected.bookName, "bookName mismatch")
+			assert.DeepEqual(t, result.public, tc.expected.public, "public mismatch")
+			assert.Equal(t, result.content, tc.expected.content, "content mismatch")
+		})
+	}
+}
+
+func TestParseMeta_invalid(t *testing.T) {
+	testCases := []struct {
+		content  string
+		expected string
+	}{
+		{
+			content:  "n1 body\n",
+			expected: "the content must start with a line of '---'",
+		},
+		{
+			content:  "---\nbook: js\nn1 body\n",
+			expected: "the front matter must end with a line of '---'",
+		},
+		{
+			content:  "---\npublic: true\n---\nn1 body\n",
+			expected: "book is required",
+		},
+		{
+			content:  "---\nbook: js\nuuid: n2-uuid\n---\nn1 body\n",
+			expected: "uuid is read-only",
+		},
+		{
+			content:  "---\nbook: js\nadded_on: 2020-10-18T15:31:00Z\n---\nn1 body\n",
+			expected: "added_on is read-only",
+		},
+		{
+			content:  "---\nbook: trash\n---\nn1 body\n",
+			expected: "invalid book name: The book name is reserved",
+		},
+		{
+			content:  "---\nbook: js\n---\n",
+			expected: "Empty content",
+		},
+		{
+			content:  "---\nbook: js\n---\n  \n",
+			expected: "Empty content",
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			_, err := parseMeta(tc.content, testNote, "js")
+			if err == nil {
+				t.Fatal("no error")
+			}
+
+			assert.Equal(t, err.Error(), tc.expected, "error mismatch")
+		})
+	}
+}
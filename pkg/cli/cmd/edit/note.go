@@ -20,12 +20,14 @@ package edit
 
 import (
 	"database/sql"
-	"io/ioutil"
 	"strconv"
 
 	"github.com/dnote/dnote/pkg/cli/context"
 	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/dates"
+	"github.com/dnote/dnote/pkg/cli/draft"
 	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/operations"
 	"github.com/dnote/dnote/pkg/cli/output"
 	"github.com/dnote/dnote/pkg/cli/ui"
 	"github.com/pkg/errors"
@@ -35,83 +37,194 @@ func validateRunNoteFlags() error {
 	if nameFlag != "" {
 		return errors.New("--name is invalid for editing a book")
 	}
+	if allFlag {
+		return errors.New("--all is invalid for editing a note")
+	}
+	if metaFlag && (bookFlag != "" || contentFlag != "" || dueFlag != "" || remindFlag != "") {
+		return errors.New("--meta cannot be used with --book, --content, --due or --remind")
+	}
 
 	return nil
 }
 
-func waitEditorNoteContent(ctx context.DnoteCtx, note database.Note) (string, error) {
-	fpath, err := ui.GetTmpContentPath(ctx)
-	if err != nil {
-		return "", errors.Wrap(err, "getting temporarily content file path")
+// noteChanges is the changes to apply to a note. Empty fields are left unchanged.
+type noteChanges struct {
+	bookName string
+	public   *bool
+	content  string
+	dueOn    *int64
+	remindOn *int64
+}
+
+// empty returns true if nothing is changed
+func (c noteChanges) empty() bool {
+	return c.bookName == "" && c.public == nil && c.content == "" && c.dueOn == nil && c.remindOn == nil
+}
+
+// parseScheduleFlag parses the value of --due or --remind into unix nanoseconds.
+// It returns nil if the flag is not given, and 0 if the flag is 'none'.
+func parseScheduleFlag(ctx context.DnoteCtx, val string) (*int64, error) {
+	if val == "" {
+		return nil, nil
 	}
 
-	if err := ioutil.WriteFile(fpath, []byte(note.Body), 0644); err != nil {
-		return "", errors.Wrap(err, "preparing tmp content file")
+	var ret int64
+	if val != "none" {
+		t, err := dates.Parse(val, ctx.Clock.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		ret = t.UnixNano()
 	}
 
-	c, err := ui.GetEditorInput(ctx, fpath)
-	if err != nil {
-		return "", errors.Wrap(err, "getting editor input")
+	return &ret, nil
+}
+
+// getNoteParams returns the params to apply the changes with
+func (c noteChanges) getNoteParams() operations.NoteParams {
+	var ret operations.NoteParams
+	if c.bookName != "" {
+		ret.Book = &c.bookName
+	}
+	if c.content != "" {
+		ret.Content = &c.content
 	}
+	ret.Public = c.public
+	ret.DueOn = c.dueOn
+	ret.RemindOn = c.remindOn
 
-	return c, nil
+	return ret
 }
 
-func getContent(ctx context.DnoteCtx, note database.Note) (string, error) {
-	if contentFlag != "" {
-		return contentFlag, nil
+// saveNote applies the changes to the note and prints the result
+func saveNote(ctx context.DnoteCtx, note database.Note, c noteChanges) error {
+	_, err := operations.UpdateNote(ctx, note.RowID, c.getNoteParams())
+	if _, ok := errors.Cause(err).(operations.InvalidError); ok {
+		return err
+	} else if err != nil {
+		return errors.Wrap(err, "updating note fields")
 	}
 
-	c, err := waitEditorNoteContent(ctx, note)
+	noteInfo, err := database.GetNoteInfo(ctx.DB, note.RowID)
 	if err != nil {
-		return "", errors.Wrap(err, "getting content from editor")
+		return errors.Wrap(err, "getting note info")
 	}
 
-	return c, nil
+	log.Success("edited the note\n")
+	output.NoteInfo(noteInfo)
+
+	return nil
 }
 
-func changeContent(ctx context.DnoteCtx, tx *database.DB, note database.Note, content string) error {
-	if note.Body == content {
-		return errors.New("Nothing changed")
+// getBookLabel returns the label of the book with the given uuid
+func getBookLabel(db *database.DB, uuid string) (string, error) {
+	var ret string
+	if err := db.QueryRow("SELECT label FROM books WHERE uuid = ?", uuid).Scan(&ret); err != nil {
+		return "", errors.Wrap(err, "finding the book")
 	}
 
-	if err := database.UpdateNoteContent(tx, ctx.Clock, note.RowID, content); err != nil {
-		return errors.Wrap(err, "updating the note")
+	return ret, nil
+}
+
+// getChanges returns the changes made to the note in the content of the draft
+func getChanges(ctx context.DnoteCtx, d draft.Draft, note database.Note, bookLabel, content string) (noteChanges, error) {
+	if d.Kind != draft.KindEditMeta {
+		if content == note.Body {
+			return noteChanges{}, nil
+		}
+
+		return noteChanges{content: content}, nil
 	}
 
-	return nil
+	c, err := parseMeta(content, note, bookLabel)
+	if err != nil {
+		return noteChanges{}, err
+	}
+	if c.bookName != "" {
+		if _, err := database.GetBookUUID(ctx.DB, c.bookName); err != nil {
+			return noteChanges{}, err
+		}
+	}
+
+	return c, nil
 }
 
-func moveBook(ctx context.DnoteCtx, tx *database.DB, note database.Note, bookName string) error {
-	targetBookUUID, err := database.GetBookUUID(tx, bookName)
+// editDraft opens the draft of the note in an editor and saves the changes. If the
+// changes are invalid, the editor is opened again on the same draft.
+func editDraft(ctx context.DnoteCtx, d draft.Draft, note database.Note) error {
+	bookLabel, err := getBookLabel(ctx.DB, note.BookUUID)
 	if err != nil {
-		return errors.Wrap(err, "finding book uuid")
+		return err
+	}
+
+	var c noteChanges
+	for {
+		content, err := d.Edit(ctx)
+		if err != nil {
+			d.Keep()
+			return errors.Wrap(err, "getting content from editor")
+		}
+
+		c, err = getChanges(ctx, d, note, bookLabel, content)
+		if err == nil {
+			break
+		}
+
+		log.Errorf("%s\n", err)
+
+		ok, confirmErr := ui.Confirm("edit again?", true)
+		if confirmErr != nil {
+			d.Keep()
+			return errors.Wrap(confirmErr, "getting confirmation")
+		}
+		if !ok {
+			d.Keep()
+			return err
+		}
 	}
 
-	if note.BookUUID == targetBookUUID {
-		return errors.New("book has not changed")
+	if c.empty() {
+		if err := d.Discard(); err != nil {
+			log.Debug("discarding the draft: %s\n", err)
+		}
+
+		return errors.New("Nothing changed")
+	}
+
+	if err := saveNote(ctx, note, c); err != nil {
+		d.Keep()
+		return err
 	}
 
-	if err := database.UpdateNoteBook(tx, ctx.Clock, note.RowID, targetBookUUID); err != nil {
-		return errors.Wrap(err, "moving book")
+	if err := d.Discard(); err != nil {
+		log.Error(errors.Wrap(err, "discarding the draft").Error())
 	}
 
 	return nil
 }
 
-func updateNote(ctx context.DnoteCtx, tx *database.DB, note database.Note, bookName, content string) error {
-	if bookName != "" {
-		if err := moveBook(ctx, tx, note, bookName); err != nil {
-			return errors.Wrap(err, "moving book")
-		}
+// ResumeDraft opens the draft in an editor and updates the note, or the notes in
+// the book, with the content
+func ResumeDraft(ctx context.DnoteCtx, d draft.Draft) error {
+	if d.Kind == draft.KindEditBook {
+		return resumeBookDraft(ctx, d)
 	}
-	if content != "" {
-		if err := changeContent(ctx, tx, note, content); err != nil {
-			return errors.Wrap(err, "changing content")
-		}
+
+	var rowID int
+	err := ctx.DB.QueryRow("SELECT rowid FROM notes WHERE uuid = ? AND deleted = false", d.NoteUUID).Scan(&rowID)
+	if err == sql.ErrNoRows {
+		return errors.Errorf("the note of the draft %s no longer exists", d.ID)
+	} else if err != nil {
+		return errors.Wrap(err, "finding the note")
 	}
 
-	return nil
+	note, err := database.GetActiveNote(ctx.DB, rowID)
+	if err != nil {
+		return errors.Wrap(err, "getting the note")
+	}
+
+	return editDraft(ctx, d, note)
 }
 
 func runNote(ctx context.DnoteCtx, rowIDArg string) error {
@@ -133,43 +246,43 @@ func runNote(ctx context.DnoteCtx, rowIDArg string) error {
 		return errors.Wrap(err, "querying the book")
 	}
 
-	content := contentFlag
+	dueOn, err := parseScheduleFlag(ctx, dueFlag)
+	if err != nil {
+		return errors.Wrap(err, "invalid due date")
+	}
+	remindOn, err := parseScheduleFlag(ctx, remindFlag)
+	if err != nil {
+		return errors.Wrap(err, "invalid reminder")
+	}
 
 	// If no flag was provided, launch an editor to get the content
-	if bookFlag == "" && contentFlag == "" {
-		c, err := getContent(ctx, note)
+	if bookFlag == "" && contentFlag == "" && dueOn == nil && remindOn == nil {
+		bookLabel, err := getBookLabel(db, note.BookUUID)
 		if err != nil {
-			return errors.Wrap(err, "getting content from editor")
+			return err
 		}
 
-		content = c
-	}
-
-	tx, err := ctx.DB.Begin()
-	if err != nil {
-		return errors.Wrap(err, "beginning a transaction")
-	}
-
-	err = updateNote(ctx, tx, note, bookFlag, content)
-	if err != nil {
-		tx.Rollback()
-		return errors.Wrap(err, "updating note fields")
-	}
+		kind, content := draft.KindEdit, note.Body
+		if metaFlag {
+			kind = draft.KindEditMeta
+			content, err = renderMeta(note, bookLabel)
+			if err != nil {
+				return errors.Wrap(err, "rendering the metadata")
+			}
+		}
 
-	noteInfo, err := database.GetNoteInfo(tx, rowID)
-	if err != nil {
-		tx.Rollback()
-		return errors.Wrap(err, "getting note info")
-	}
+		d, err := draft.Get(ctx, kind, bookLabel, note.UUID, content)
+		if err != nil {
+			return errors.Wrap(err, "getting a draft")
+		}
 
-	err = tx.Commit()
-	if err != nil {
-		tx.Rollback()
-		return errors.Wrap(err, "committing a transaction")
+		return editDraft(ctx, d, note)
 	}
 
-	log.Success("edited the note\n")
-	output.NoteInfo(noteInfo)
-
-	return nil
+	return saveNote(ctx, note, noteChanges{
+		bookName: bookFlag,
+		content:  contentFlag,
+		dueOn:    dueOn,
+		remindOn: remindOn,
+	})
 }
@@ -42,6 +42,7 @@ var example = `
 	`
 
 var bookName string
+var archived bool
 
 func preRun(cmd *cobra.Command, args []string) error {
 	if len(args) != 1 {
@@ -64,6 +65,7 @@ func NewCmd(ctx context.DnoteCtx) *cobra.Command {
 
 	f := cmd.Flags()
 	f.StringVarP(&bookName, "book", "b", "", "book name to find notes in")
+	f.BoolVarP(&archived, "archived", "", false, "include archived notes and books")
 
 	return cmd
 }
@@ -130,7 +132,7 @@ func escapePhrase(s string) (string, error) {
 	return b.String(), nil
 }
 
-func doQuery(ctx context.DnoteCtx, query, bookName string) (*sql.Rows, error) {
+func doQuery(ctx context.DnoteCtx, query, bookName string, archived bool) (*sql.Rows, error) {
 	db := ctx.DB
 
 	sql := `SELECT
@@ -147,6 +149,9 @@ func doQuery(ctx context.DnoteCtx, query, bookName string) (*sql.Rows, error) {
 		sql = fmt.Sprintf("%s AND books.label = ?", sql)
 		args = append(args, bookName)
 	}
+	if !archived {
+		sql = fmt.Sprintf("%s AND notes.archived = false AND books.archived = false", sql)
+	}
 
 	rows, err := db.Query(sql, args...)
 
@@ -160,7 +165,7 @@ func newRun(ctx context.DnoteCtx) infra.RunEFunc {
 			return errors.Wrap(err, "escaping phrase")
 		}
 
-		rows, err := doQuery(ctx, phrase, bookName)
+		rows, err := doQuery(ctx, phrase, bookName, archived)
 		if err != nil {
 			return errors.Wrap(err, "querying notes")
 		}
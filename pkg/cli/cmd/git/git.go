@@ -0,0 +1,315 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package git
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/config"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/infra"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/snapshot"
+	"github.com/dnote/dnote/pkg/cli/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// NewCmd returns a new git command
+func NewCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "git",
+		Short: "Mirror the notes into a git repository",
+	}
+
+	cmd.AddCommand(newInitCmd(ctx))
+	cmd.AddCommand(newPushCmd(ctx))
+	cmd.AddCommand(newPullCmd(ctx))
+
+	return cmd
+}
+
+var initExample = `
+  * Mirror the notes into a new repository
+  dnote git init ~/notes`
+
+func newInitCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "init <path>",
+		Short:   "Set up a git repository to mirror the notes",
+		Example: initExample,
+		Args:    cobra.ExactArgs(1),
+		RunE:    newInitRun(ctx),
+	}
+
+	return cmd
+}
+
+func newPushCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "push",
+		Short: "Commit the local changes to the repository and push them to its remote",
+		Args:  cobra.NoArgs,
+		RunE:  newPushRun(ctx),
+	}
+
+	return cmd
+}
+
+func newPullCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pull",
+		Short: "Import the changes in the repository and its remote",
+		Args:  cobra.NoArgs,
+		RunE:  newPullRun(ctx),
+	}
+
+	return cmd
+}
+
+func getDir(ctx context.DnoteCtx) (string, error) {
+	if ctx.GitDir == "" {
+		return "", errNotConfigured
+	}
+
+	ok, err := utils.FileExists(filepath.Join(ctx.GitDir, ".git"))
+	if err != nil {
+		return "", errors.Wrap(err, "checking the repository")
+	}
+	if !ok {
+		return "", errors.Errorf("%s is not a git repository. Run `dnote git init <path>` to set it up again", ctx.GitDir)
+	}
+
+	return ctx.GitDir, nil
+}
+
+// initRepo creates a git repository at the given path if it does not exist and
+// configures it as the mirror of the local data. The notes are committed if the
+// repository is empty. Otherwise, the notes in the repository need to be pulled first.
+func initRepo(ctx context.DnoteCtx, path string) (string, bool, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", false, errors.Wrap(err, "getting the absolute path")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", false, errors.Wrap(err, "creating the directory")
+	}
+
+	ok, err := utils.FileExists(filepath.Join(dir, ".git"))
+	if err != nil {
+		return "", false, errors.Wrap(err, "checking the repository")
+	}
+	if !ok {
+		if _, err := runGit(dir, "init", "-q"); err != nil {
+			return "", false, errors.Wrap(err, "initializing the repository")
+		}
+	}
+
+	cf, err := config.Read(ctx)
+	if err != nil {
+		return "", false, errors.Wrap(err, "reading the config")
+	}
+	cf.GitDir = dir
+	if err := config.Write(ctx, cf); err != nil {
+		return "", false, errors.Wrap(err, "writing the config")
+	}
+
+	if err := database.DeleteSystem(ctx.DB, consts.SystemGitCommit); err != nil {
+		return "", false, errors.Wrap(err, "resetting the last commit")
+	}
+
+	head, err := getHead(dir)
+	if err != nil {
+		return "", false, errors.Wrap(err, "getting HEAD")
+	}
+	if head != "" {
+		return dir, false, nil
+	}
+
+	if _, err := mirror(ctx, dir); err != nil {
+		return "", false, errors.Wrap(err, "committing the notes")
+	}
+
+	return dir, true, nil
+}
+
+// push commits the local data and pushes it to the remote, if any. It returns
+// whether a commit was made and the remote to which the commits were pushed.
+func push(ctx context.DnoteCtx, dir string) (bool, string, error) {
+	if err := checkImported(ctx, dir); err != nil {
+		return false, "", err
+	}
+
+	committed, err := mirror(ctx, dir)
+	if err != nil {
+		return false, "", errors.Wrap(err, "committing the notes")
+	}
+
+	remote, err := getRemote(dir)
+	if err != nil {
+		return false, "", err
+	}
+	head, err := getHead(dir)
+	if err != nil {
+		return false, "", errors.Wrap(err, "getting HEAD")
+	}
+	if remote == "" || head == "" {
+		return committed, "", nil
+	}
+
+	if _, err := runGit(dir, "push", "-q", "-u", remote, "HEAD"); err != nil {
+		return false, "", errors.Wrapf(err, "pushing to %s", remote)
+	}
+
+	return committed, remote, nil
+}
+
+// mergeRemote merges the branch of the remote with the same name as the current branch
+func mergeRemote(dir, remote string) error {
+	if _, err := runGit(dir, "fetch", "-q", remote); err != nil {
+		return errors.Wrapf(err, "fetching %s", remote)
+	}
+
+	branch, err := getBranch(dir)
+	if err != nil {
+		return err
+	}
+
+	ref := remote + "/" + branch
+	if _, err := runGit(dir, "rev-parse", "--verify", "-q", ref); err != nil {
+		log.Debug("%s does not exist\n", ref)
+		return nil
+	}
+
+	if _, err := runGit(dir, "merge", "-q", "--no-edit", "--allow-unrelated-histories", ref); err != nil {
+		return errors.Wrapf(err, "merging %s. Resolve the conflicts with git, commit them and run `dnote git pull` again", ref)
+	}
+
+	return nil
+}
+
+// pull merges the remote, if any, imports the changes in the repository and commits
+// the local data so that the repository matches it
+func pull(ctx context.DnoteCtx, dir string) (importReport, error) {
+	last, err := getLastCommit(ctx.DB)
+	if err != nil {
+		return importReport{}, err
+	}
+	if last != "" {
+		if _, err := runGit(dir, "cat-file", "-e", last+"^{commit}"); err != nil {
+			log.Debug("the last commit %s does not exist\n", last)
+			last = ""
+		}
+	}
+
+	remote, err := getRemote(dir)
+	if err != nil {
+		return importReport{}, err
+	}
+	if remote != "" {
+		if err := mergeRemote(dir, remote); err != nil {
+			return importReport{}, err
+		}
+	}
+
+	if err := snapshot.Auto(ctx, snapshot.ReasonBulk); err != nil {
+		return importReport{}, errors.Wrap(err, "backing up the database")
+	}
+
+	r, err := importTree(ctx, dir, last)
+	if err != nil {
+		return r, errors.Wrap(err, "importing the changes")
+	}
+
+	if _, err := mirror(ctx, dir); err != nil {
+		return r, errors.Wrap(err, "committing the notes")
+	}
+
+	return r, nil
+}
+
+func newInitRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		dir, committed, err := initRepo(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		log.Successf("mirroring the notes into %s\n", dir)
+		if !committed {
+			log.Plain("The repository already has commits. Run `dnote git pull` to import them.\n")
+		}
+
+		return nil
+	}
+}
+
+func newPushRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		dir, err := getDir(ctx)
+		if err != nil {
+			return err
+		}
+
+		committed, remote, err := push(ctx, dir)
+		if err != nil {
+			return err
+		}
+
+		if committed {
+			log.Success("committed the changes\n")
+		} else {
+			log.Plain("nothing to commit\n")
+		}
+		if remote != "" {
+			log.Successf("pushed to %s\n", remote)
+		}
+
+		return nil
+	}
+}
+
+func newPullRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		dir, err := getDir(ctx)
+		if err != nil {
+			return err
+		}
+
+		r, err := pull(ctx, dir)
+		if err != nil {
+			return err
+		}
+
+		log.Successf("imported %d added, %d updated, %d deleted notes\n", r.Added, r.Updated, r.Deleted)
+		if r.Conflicted > 0 {
+			log.Warnf("%d notes were changed in both places. Please edit them to resolve the conflicts.\n", r.Conflicted)
+		}
+
+		if r.changed() {
+			sync.AfterWrite(ctx)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,45 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package git
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/cli/context"
+)
+
+var testDir = "../../tmp"
+
+var paths context.Paths = context.Paths{
+	Home:   testDir,
+	Cache:  testDir,
+	Config: testDir,
+	Data:   testDir,
+}
+
+func TestMain(m *testing.M) {
+	// commit with a fixed identity regardless of the git configuration of the machine
+	os.Setenv("GIT_AUTHOR_NAME", "dnote")
+	os.Setenv("GIT_AUTHOR_EMAIL", "dnote@example.com")
+	os.Setenv("GIT_COMMITTER_NAME", "dnote")
+	os.Setenv("GIT_COMMITTER_EMAIL", "dnote@example.com")
+
+	os.Exit(m.Run())
+}
@@ -0,0 +1,659 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package git
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/utils"
+	"github.com/dnote/dnote/pkg/cli/validate"
+	"github.com/pkg/errors"
+)
+
+var (
+	// errNotConfigured is an error for running a git command without a repository
+	errNotConfigured = errors.New("git is not set up. Run `dnote git init <path>` first")
+	// errNotImported is an error for committing while the repository has changes
+	// that have not been imported into the local data
+	errNotImported = errors.New("the repository has changes that are not imported. Run `dnote git pull` first")
+)
+
+// isNotePath returns true if the given path, relative to the repository and separated
+// by slashes, is a note file in a book directory
+func isNotePath(p string) bool {
+	parts := strings.Split(p, "/")
+
+	return len(parts) == 2 && !strings.HasPrefix(parts[0], ".") && strings.HasSuffix(parts[1], ".md")
+}
+
+// readTree returns the contents of the note files in the working tree
+func readTree(dir string) (map[string]string, error) {
+	ret := map[string]string{}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the repository")
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading the directory %s", entry.Name())
+		}
+
+		for _, file := range files {
+			p := entry.Name() + "/" + file.Name()
+			if file.IsDir() || !isNotePath(p) {
+				continue
+			}
+
+			b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(p)))
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading %s", p)
+			}
+
+			ret[p] = string(b)
+		}
+	}
+
+	return ret, nil
+}
+
+// readCommit returns the contents of the note files in the given commit
+func readCommit(dir, commit string) (map[string]string, error) {
+	files, err := listFiles(dir, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, p := range files {
+		if isNotePath(p) {
+			paths = append(paths, p)
+		}
+	}
+
+	return readFiles(dir, commit, paths)
+}
+
+// getNotes returns the notes to be written in the repository
+func getNotes(db *database.DB) ([]noteFile, error) {
+	rows, err := db.Query(`SELECT notes.uuid, books.label, notes.body, notes.added_on, notes.edited_on, notes.public
+		FROM notes
+		INNER JOIN books ON books.uuid = notes.book_uuid
+		WHERE NOT notes.deleted AND NOT books.deleted`)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting notes")
+	}
+	defer rows.Close()
+
+	var ret []noteFile
+	for rows.Next() {
+		var n noteFile
+		if err := rows.Scan(&n.UUID, &n.BookLabel, &n.Body, &n.AddedOn, &n.EditedOn, &n.Public); err != nil {
+			return nil, errors.Wrap(err, "scanning a note")
+		}
+
+		ret = append(ret, n)
+	}
+
+	return ret, rows.Err()
+}
+
+// export writes the notes in the working tree, removing the files of the notes
+// that no longer exist
+func export(dir string, notes []noteFile) error {
+	existing, err := readTree(dir)
+	if err != nil {
+		return errors.Wrap(err, "reading the working tree")
+	}
+
+	desired := map[string]string{}
+	for _, n := range notes {
+		content, err := n.render()
+		if err != nil {
+			return errors.Wrapf(err, "rendering the note %s", n.UUID)
+		}
+
+		desired[filepath.ToSlash(n.path())] = content
+	}
+
+	for p := range existing {
+		if _, ok := desired[p]; ok {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(dir, filepath.FromSlash(p))); err != nil {
+			return errors.Wrapf(err, "removing %s", p)
+		}
+	}
+
+	for p, content := range desired {
+		if existing[p] == content {
+			continue
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return errors.Wrapf(err, "creating the directory for %s", p)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			return errors.Wrapf(err, "writing %s", p)
+		}
+	}
+
+	// remove the directories of the books without notes
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return errors.Wrap(err, "reading the repository")
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			return errors.Wrapf(err, "reading the directory %s", entry.Name())
+		}
+		if len(files) == 0 {
+			if err := os.Remove(path); err != nil {
+				return errors.Wrapf(err, "removing the directory %s", entry.Name())
+			}
+		}
+	}
+
+	return nil
+}
+
+// getTitle returns the first line of the note in the given file, to be used in a commit message
+func getTitle(content string) string {
+	n, err := parseNoteFile(content)
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(n.Body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if utf8.RuneCountInString(line) > 50 {
+			line = string([]rune(line)[:50]) + "..."
+		}
+
+		return line
+	}
+
+	return ""
+}
+
+func pluralize(count int, singular string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, singular)
+	}
+
+	return fmt.Sprintf("%d %ss", count, singular)
+}
+
+// getCommitMessage returns the subject and the body of the commit message for the given changes
+func getCommitMessage(dir string, changes []change) (string, string, error) {
+	head, err := getHead(dir)
+	if err != nil {
+		return "", "", errors.Wrap(err, "getting HEAD")
+	}
+
+	var deleted []string
+	for _, c := range changes {
+		if c.status == "D" && isNotePath(c.path) {
+			deleted = append(deleted, c.path)
+		}
+	}
+	var prev map[string]string
+	if head != "" {
+		prev, err = readFiles(dir, head, deleted)
+		if err != nil {
+			return "", "", errors.Wrap(err, "reading the deleted notes")
+		}
+	}
+
+	var added, updated, removed int
+	var lines []string
+	for _, c := range changes {
+		if !isNotePath(c.path) {
+			continue
+		}
+
+		var verb, content string
+		switch c.status {
+		case "A":
+			added++
+			verb = "add"
+		case "D":
+			removed++
+			verb = "remove"
+			content = prev[c.path]
+		default:
+			updated++
+			verb = "update"
+		}
+		if c.status != "D" {
+			b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(c.path)))
+			if err != nil {
+				return "", "", errors.Wrapf(err, "reading %s", c.path)
+			}
+			content = string(b)
+		}
+
+		label, err := bookLabel(strings.Split(c.path, "/")[0])
+		if err != nil {
+			return "", "", err
+		}
+
+		line := fmt.Sprintf("%s %s", verb, label)
+		if title := getTitle(content); title != "" {
+			line = fmt.Sprintf("%s: %s", line, title)
+		}
+		lines = append(lines, line)
+	}
+
+	var parts []string
+	if added > 0 {
+		parts = append(parts, fmt.Sprintf("add %s", pluralize(added, "note")))
+	}
+	if updated > 0 {
+		parts = append(parts, fmt.Sprintf("update %s", pluralize(updated, "note")))
+	}
+	if removed > 0 {
+		parts = append(parts, fmt.Sprintf("remove %s", pluralize(removed, "note")))
+	}
+
+	var subject string
+	switch len(parts) {
+	case 0:
+		return "Update the repository", "", nil
+	case 1:
+		subject = parts[0]
+	default:
+		subject = strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+	}
+
+	sort.Strings(lines)
+
+	return strings.ToUpper(subject[:1]) + subject[1:], strings.Join(lines, "\n"), nil
+}
+
+// checkImported returns an error if the repository has changes that have not been
+// imported into the local data
+func checkImported(ctx context.DnoteCtx, dir string) error {
+	head, err := getHead(dir)
+	if err != nil {
+		return errors.Wrap(err, "getting HEAD")
+	}
+
+	last, err := getLastCommit(ctx.DB)
+	if err != nil {
+		return err
+	}
+	if head != last {
+		return errNotImported
+	}
+
+	changed, err := hasChanges(dir)
+	if err != nil {
+		return err
+	}
+	if changed {
+		return errNotImported
+	}
+
+	return nil
+}
+
+func getLastCommit(db *database.DB) (string, error) {
+	var ret string
+	err := db.QueryRow("SELECT value FROM system WHERE key = ?", consts.SystemGitCommit).Scan(&ret)
+	if err != nil && err != sql.ErrNoRows {
+		return "", errors.Wrap(err, "getting the last commit")
+	}
+
+	return ret, nil
+}
+
+// mirror writes the local data into the repository and commits the changes. It
+// returns true if a commit was made.
+func mirror(ctx context.DnoteCtx, dir string) (bool, error) {
+	notes, err := getNotes(ctx.DB)
+	if err != nil {
+		return false, errors.Wrap(err, "getting the notes")
+	}
+	if err := export(dir, notes); err != nil {
+		return false, errors.Wrap(err, "writing the notes")
+	}
+
+	if _, err := runGit(dir, "add", "-A"); err != nil {
+		return false, errors.Wrap(err, "staging the changes")
+	}
+
+	changes, err := getStagedChanges(dir)
+	if err != nil {
+		return false, err
+	}
+
+	committed := false
+	if len(changes) > 0 {
+		subject, body, err := getCommitMessage(dir, changes)
+		if err != nil {
+			return false, errors.Wrap(err, "getting the commit message")
+		}
+
+		args := []string{"commit", "-q", "-m", subject}
+		if body != "" {
+			args = append(args, "-m", body)
+		}
+		if _, err := runGit(dir, args...); err != nil {
+			return false, errors.Wrap(err, "committing")
+		}
+
+		committed = true
+	}
+
+	head, err := getHead(dir)
+	if err != nil {
+		return false, errors.Wrap(err, "getting HEAD")
+	}
+	if err := database.UpsertSystem(ctx.DB, consts.SystemGitCommit, head); err != nil {
+		return false, errors.Wrap(err, "saving the last commit")
+	}
+
+	return committed, nil
+}
+
+// importReport is the result of importing the changes in the repository
+type importReport struct {
+	Added      int
+	Updated    int
+	Deleted    int
+	Conflicted int
+}
+
+func (r importReport) changed() bool {
+	return r.Added+r.Updated+r.Deleted+r.Conflicted > 0
+}
+
+// localNote is a note in the local data, with the label of its book
+type localNote struct {
+	noteFile
+	Deleted bool
+}
+
+func getLocalNote(tx *database.DB, uuid string) (localNote, bool, error) {
+	var ret localNote
+	ret.UUID = uuid
+
+	err := tx.QueryRow(`SELECT books.label, notes.body, notes.added_on, notes.edited_on, notes.public, notes.deleted
+		FROM notes
+		INNER JOIN books ON books.uuid = notes.book_uuid
+		WHERE notes.uuid = ?`, uuid).Scan(&ret.BookLabel, &ret.Body, &ret.AddedOn, &ret.EditedOn, &ret.Public, &ret.Deleted)
+	if err == sql.ErrNoRows {
+		return ret, false, nil
+	} else if err != nil {
+		return ret, false, errors.Wrapf(err, "finding the note %s", uuid)
+	}
+
+	return ret, true, nil
+}
+
+// bookResolver finds the books for the directories in the repository, creating
+// the books that do not exist
+type bookResolver struct {
+	tx    *database.DB
+	uuids map[string]string
+}
+
+func (r *bookResolver) get(label string) (string, error) {
+	if uuid, ok := r.uuids[label]; ok {
+		return uuid, nil
+	}
+
+	var uuid string
+	err := r.tx.QueryRow("SELECT uuid FROM books WHERE label = ? AND NOT deleted", label).Scan(&uuid)
+	if err == sql.ErrNoRows {
+		uuid, err = utils.GenerateUUID()
+		if err != nil {
+			return "", errors.Wrap(err, "generating uuid")
+		}
+
+		b := database.NewBook(uuid, label, 0, false, true)
+		if err := b.Insert(r.tx); err != nil {
+			return "", errors.Wrapf(err, "creating the book %s", label)
+		}
+	} else if err != nil {
+		return "", errors.Wrapf(err, "finding the book %s", label)
+	}
+
+	r.uuids[label] = uuid
+
+	return uuid, nil
+}
+
+// importNote imports a note file in the working tree into the local data. The note is
+// compared to its file in the last commit to tell which side changed it.
+func importNote(ctx context.DnoteCtx, tx *database.DB, books *bookResolver, f noteFile, content string, prev map[string]string, r *importReport) error {
+	bookUUID, err := books.get(f.BookLabel)
+	if err != nil {
+		return err
+	}
+
+	var local localNote
+	var ok bool
+	if f.UUID != "" {
+		local, ok, err = getLocalNote(tx, f.UUID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !ok {
+		uuid := f.UUID
+		if uuid == "" {
+			uuid, err = utils.GenerateUUID()
+			if err != nil {
+				return errors.Wrap(err, "generating uuid")
+			}
+		}
+		addedOn := f.AddedOn
+		if addedOn == 0 {
+			addedOn = ctx.Clock.Now().UnixNano()
+		}
+
+		n := database.NewNote(uuid, bookUUID, f.Body, addedOn, f.EditedOn, 0, f.Public, false, true)
+		if err := n.Insert(tx); err != nil {
+			return errors.Wrapf(err, "inserting the note %s", uuid)
+		}
+
+		r.Added++
+		return nil
+	}
+
+	prevContent, hadPrev := prev[f.UUID]
+	if hadPrev && prevContent == content {
+		return nil
+	}
+
+	localContent, err := local.render()
+	if err != nil {
+		return errors.Wrapf(err, "rendering the note %s", f.UUID)
+	}
+	localChanged := !hadPrev || localContent != prevContent
+
+	body := f.Body
+	conflicted := false
+	if !local.Deleted && local.Body != f.Body && localChanged {
+		body = sync.ReportBodyConflict(local.Body, f.Body)
+		conflicted = true
+	} else if !local.Deleted && local.Body == f.Body && local.BookLabel == f.BookLabel && local.Public == f.Public {
+		return nil
+	}
+
+	if _, err := tx.Exec("UPDATE notes SET book_uuid = ?, body = ?, public = ?, edited_on = ?, deleted = ?, dirty = ? WHERE uuid = ?",
+		bookUUID, body, f.Public, ctx.Clock.Now().UnixNano(), false, true, f.UUID); err != nil {
+		return errors.Wrapf(err, "updating the note %s", f.UUID)
+	}
+
+	if conflicted {
+		r.Conflicted++
+	} else {
+		r.Updated++
+	}
+
+	return nil
+}
+
+// importTree imports the changes made in the working tree since the given commit
+// into the local data
+func importTree(ctx context.DnoteCtx, dir, last string) (importReport, error) {
+	var ret importReport
+
+	current, err := readTree(dir)
+	if err != nil {
+		return ret, errors.Wrap(err, "reading the working tree")
+	}
+
+	// the files at the last commit, by the uuids of the notes
+	prev := map[string]string{}
+	if last != "" {
+		files, err := readCommit(dir, last)
+		if err != nil {
+			return ret, errors.Wrap(err, "reading the last commit")
+		}
+
+		for _, content := range files {
+			f, err := parseNoteFile(content)
+			if err != nil || f.UUID == "" {
+				continue
+			}
+
+			prev[f.UUID] = content
+		}
+	}
+
+	var paths []string
+	for p := range current {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	tx, err := ctx.DB.Begin()
+	if err != nil {
+		return ret, errors.Wrap(err, "beginning a transaction")
+	}
+
+	books := &bookResolver{tx: tx, uuids: map[string]string{}}
+	seen := map[string]bool{}
+	for _, p := range paths {
+		content := current[p]
+
+		f, err := parseNoteFile(content)
+		if err != nil {
+			log.Warnf("skipping %s: %s\n", p, err)
+			continue
+		}
+
+		f.BookLabel, err = bookLabel(strings.Split(p, "/")[0])
+		if err == nil {
+			err = validate.BookName(f.BookLabel)
+		}
+		if err != nil {
+			log.Warnf("skipping %s: %s\n", p, err)
+			continue
+		}
+
+		// a copied file becomes a new note
+		if seen[f.UUID] {
+			f.UUID = ""
+		}
+
+		if err := importNote(ctx, tx, books, f, content, prev, &ret); err != nil {
+			tx.Rollback()
+			return ret, errors.Wrapf(err, "importing %s", p)
+		}
+
+		if f.UUID != "" {
+			seen[f.UUID] = true
+		}
+	}
+
+	// delete the notes whose files were removed, unless they were changed locally
+	for uuid, content := range prev {
+		if seen[uuid] {
+			continue
+		}
+
+		local, ok, err := getLocalNote(tx, uuid)
+		if err != nil {
+			tx.Rollback()
+			return ret, err
+		}
+		if !ok || local.Deleted {
+			continue
+		}
+
+		localContent, err := local.render()
+		if err != nil {
+			tx.Rollback()
+			return ret, errors.Wrapf(err, "rendering the note %s", uuid)
+		}
+		if localContent != content {
+			continue
+		}
+
+		if _, err := tx.Exec("UPDATE notes SET deleted = ?, dirty = ?, body = ? WHERE uuid = ?", true, true, "", uuid); err != nil {
+			tx.Rollback()
+			return ret, errors.Wrapf(err, "deleting the note %s", uuid)
+		}
+
+		ret.Deleted++
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return ret, errors.Wrap(err, "committing a transaction")
+	}
+
+	return ret, nil
+}
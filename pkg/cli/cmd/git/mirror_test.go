@@ -0,0 +1,207 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/config"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/pkg/errors"
+)
+
+func initGitTestCtx(t *testing.T, p context.Paths) context.DnoteCtx {
+	ctx := context.InitTestCtx(t, p, nil)
+	if err := config.Write(ctx, config.Config{}); err != nil {
+		t.Fatal(errors.Wrap(err, "writing the config"))
+	}
+
+	return ctx
+}
+
+func mustRunGit(t *testing.T, dir string, args ...string) string {
+	out, err := runGit(dir, args...)
+	if err != nil {
+		t.Fatal(errors.Wrapf(err, "running git %s", args[0]))
+	}
+
+	return strings.TrimSpace(out)
+}
+
+func mustInit(t *testing.T, ctx *context.DnoteCtx, dir string) {
+	gitDir, _, err := initRepo(*ctx, dir)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "initializing the repository"))
+	}
+
+	ctx.GitDir = gitDir
+}
+
+func mustPull(t *testing.T, ctx context.DnoteCtx) importReport {
+	r, err := pull(ctx, ctx.GitDir)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "pulling"))
+	}
+
+	return r
+}
+
+func mustPush(t *testing.T, ctx context.DnoteCtx) {
+	if _, _, err := push(ctx, ctx.GitDir); err != nil {
+		t.Fatal(errors.Wrap(err, "pushing"))
+	}
+}
+
+func writeNoteFile(t *testing.T, dir, path string, n noteFile) {
+	content, err := n.render()
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "rendering the note"))
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, path), []byte(content), 0644); err != nil {
+		t.Fatal(errors.Wrap(err, "writing the note"))
+	}
+}
+
+func getNote(t *testing.T, db *database.DB, uuid string) database.Note {
+	var ret database.Note
+	database.MustScan(t, "getting the note", db.QueryRow("SELECT uuid, book_uuid, body, deleted, dirty FROM notes WHERE uuid = ?", uuid),
+		&ret.UUID, &ret.BookUUID, &ret.Body, &ret.Deleted, &ret.Dirty)
+
+	return ret
+}
+
+func TestInitRepo(t *testing.T) {
+	// set up
+	tmpDir, err := ioutil.TempDir("", "dnote-git")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating a temporary directory"))
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := initGitTestCtx(t, paths)
+	defer context.TeardownTestCtx(t, ctx)
+
+	database.MustExec(t, "inserting b1", ctx.DB, "INSERT INTO books (uuid, label) VALUES (?, ?)", "b1-uuid", "js")
+	database.MustExec(t, "inserting b2", ctx.DB, "INSERT INTO books (uuid, label, deleted) VALUES (?, ?, ?)", "b2-uuid", "go", true)
+	database.MustExec(t, "inserting n1", ctx.DB, "INSERT INTO notes (uuid, book_uuid, body, added_on) VALUES (?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1 body", 1541108743)
+	database.MustExec(t, "inserting n2", ctx.DB, "INSERT INTO notes (uuid, book_uuid, body, added_on, deleted) VALUES (?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", "", 1541108743, true)
+	database.MustExec(t, "inserting n3", ctx.DB, "INSERT INTO notes (uuid, book_uuid, body, added_on) VALUES (?, ?, ?, ?)", "n3-uuid", "b2-uuid", "n3 body", 1541108743)
+
+	// execute
+	dir, committed, err := initRepo(ctx, filepath.Join(tmpDir, "notes"))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	// test
+	assert.Equal(t, committed, true, "committed mismatch")
+
+	cf, err := config.Read(ctx)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "reading the config"))
+	}
+	assert.Equal(t, cf.GitDir, dir, "config gitDir mismatch")
+
+	assert.Equal(t, mustRunGit(t, dir, "ls-files"), "js/n1-uuid.md", "files mismatch")
+	assert.Equal(t, mustRunGit(t, dir, "log", "--format=%B"), "Add 1 note\n\nadd js: n1 body", "commit message mismatch")
+
+	lastCommit, err := getLastCommit(ctx.DB)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the last commit"))
+	}
+	assert.Equal(t, lastCommit, mustRunGit(t, dir, "rev-parse", "HEAD"), "last commit mismatch")
+}
+
+func TestPushPull(t *testing.T) {
+	// set up
+	tmpDir, err := ioutil.TempDir("", "dnote-git")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating a temporary directory"))
+	}
+	defer os.RemoveAll(tmpDir)
+
+	remote := filepath.Join(tmpDir, "remote.git")
+	mustRunGit(t, tmpDir, "init", "-q", "--bare", remote)
+
+	ctxA := initGitTestCtx(t, paths)
+	defer context.TeardownTestCtx(t, ctxA)
+	pathsB := context.Paths{Home: testDir + "/b", Cache: testDir + "/b", Config: testDir + "/b", Data: testDir + "/b"}
+	ctxB := initGitTestCtx(t, pathsB)
+	defer context.TeardownTestCtx(t, ctxB)
+
+	database.MustExec(t, "inserting b1", ctxA.DB, "INSERT INTO books (uuid, label) VALUES (?, ?)", "b1-uuid", "js")
+	database.MustExec(t, "inserting n1", ctxA.DB, "INSERT INTO notes (uuid, book_uuid, body, added_on) VALUES (?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1 body", 1541108743)
+	database.MustExec(t, "inserting n2", ctxA.DB, "INSERT INTO notes (uuid, book_uuid, body, added_on) VALUES (?, ?, ?, ?)", "n2-uuid", "b1-uuid", "n2 body", 1541108744)
+
+	mustInit(t, &ctxA, filepath.Join(tmpDir, "a"))
+	mustRunGit(t, ctxA.GitDir, "remote", "add", "origin", remote)
+	mustPush(t, ctxA)
+
+	// the other machine imports the notes from the remote
+	mustInit(t, &ctxB, filepath.Join(tmpDir, "b"))
+	mustRunGit(t, ctxB.GitDir, "remote", "add", "origin", remote)
+	assert.Equal(t, mustPull(t, ctxB), importReport{Added: 2}, "first pull report mismatch")
+	assert.Equal(t, getNote(t, ctxB.DB, "n1-uuid").Body, "n1 body", "imported n1 body mismatch")
+
+	// edit the files directly
+	writeNoteFile(t, ctxB.GitDir, "js/n1-uuid.md", noteFile{UUID: "n1-uuid", Body: "n1 edited", AddedOn: 1541108743})
+	if err := os.Remove(filepath.Join(ctxB.GitDir, "js", "n2-uuid.md")); err != nil {
+		t.Fatal(errors.Wrap(err, "removing n2"))
+	}
+	if err := os.MkdirAll(filepath.Join(ctxB.GitDir, "go"), 0755); err != nil {
+		t.Fatal(errors.Wrap(err, "creating a book directory"))
+	}
+	if err := ioutil.WriteFile(filepath.Join(ctxB.GitDir, "go", "new.md"), []byte("a new note\n"), 0644); err != nil {
+		t.Fatal(errors.Wrap(err, "writing a new note"))
+	}
+
+	_, _, err = push(ctxB, ctxB.GitDir)
+	assert.Equal(t, err, errNotImported, "pushing without pulling should fail")
+
+	assert.Equal(t, mustPull(t, ctxB), importReport{Added: 1, Updated: 1, Deleted: 1}, "second pull report mismatch")
+	n1 := getNote(t, ctxB.DB, "n1-uuid")
+	assert.Equal(t, n1.Body, "n1 edited", "edited n1 body mismatch")
+	assert.Equal(t, n1.Dirty, true, "edited n1 dirty mismatch")
+	assert.Equal(t, getNote(t, ctxB.DB, "n2-uuid").Deleted, true, "n2 should be deleted")
+
+	var newUUID string
+	database.MustScan(t, "getting the new note", ctxB.DB.QueryRow(`SELECT notes.uuid FROM notes
+		INNER JOIN books ON books.uuid = notes.book_uuid
+		WHERE books.label = ? AND notes.body = ?`, "go", "a new note"), &newUUID)
+	assert.Equal(t, mustRunGit(t, ctxB.GitDir, "status", "--porcelain"), "", "working tree should be clean")
+	assert.Equal(t, mustRunGit(t, ctxB.GitDir, "ls-files"), "go/"+newUUID+".md\njs/n1-uuid.md", "files mismatch")
+	assert.Equal(t, mustRunGit(t, ctxB.GitDir, "log", "-1", "--format=%s"), "Add 1 note, update 1 note and remove 1 note", "commit subject mismatch")
+
+	mustPush(t, ctxB)
+
+	// a note edited on both sides keeps both versions
+	database.MustExec(t, "editing n1", ctxA.DB, "UPDATE notes SET body = ? WHERE uuid = ?", "n1 local", "n1-uuid")
+	assert.Equal(t, mustPull(t, ctxA), importReport{Added: 1, Deleted: 1, Conflicted: 1}, "third pull report mismatch")
+	assert.Equal(t, getNote(t, ctxA.DB, "n1-uuid").Body, sync.ReportBodyConflict("n1 local", "n1 edited"), "conflicted n1 body mismatch")
+	assert.Equal(t, getNote(t, ctxA.DB, "n2-uuid").Deleted, true, "n2 should be deleted on the other machine")
+	assert.Equal(t, getNote(t, ctxA.DB, newUUID).Body, "a new note", "new note body mismatch")
+}
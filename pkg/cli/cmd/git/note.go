@@ -0,0 +1,156 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package git
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const frontMatterDelimiter = "---\n"
+
+// frontMatter is the metadata of a note written at the top of its file
+type frontMatter struct {
+	UUID     string `yaml:"uuid"`
+	AddedOn  string `yaml:"added_on,omitempty"`
+	EditedOn string `yaml:"edited_on,omitempty"`
+	Public   bool   `yaml:"public,omitempty"`
+}
+
+// noteFile is a note in the repository
+type noteFile struct {
+	UUID      string
+	BookLabel string
+	Body      string
+	AddedOn   int64
+	EditedOn  int64
+	Public    bool
+}
+
+func formatTime(ts int64) string {
+	if ts == 0 {
+		return ""
+	}
+
+	return time.Unix(0, ts).UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return 0, errors.Wrapf(err, "parsing the time %s", s)
+	}
+
+	return t.UnixNano(), nil
+}
+
+// render returns the content of the file for the note
+func (n noteFile) render() (string, error) {
+	fm := frontMatter{
+		UUID:     n.UUID,
+		AddedOn:  formatTime(n.AddedOn),
+		EditedOn: formatTime(n.EditedOn),
+		Public:   n.Public,
+	}
+
+	b, err := yaml.Marshal(fm)
+	if err != nil {
+		return "", errors.Wrap(err, "marshalling the front matter")
+	}
+
+	return frontMatterDelimiter + string(b) + frontMatterDelimiter + n.Body + "\n", nil
+}
+
+// path returns the path of the file for the note relative to the repository
+func (n noteFile) path() string {
+	return filepath.Join(bookDirName(n.BookLabel), n.UUID+".md")
+}
+
+// parseNoteFile parses the content of a note file. A file without the front matter,
+// such as a file added by hand, is parsed as a note without a uuid.
+func parseNoteFile(content string) (noteFile, error) {
+	var ret noteFile
+
+	body := content
+	if strings.HasPrefix(content, frontMatterDelimiter) {
+		rest := content[len(frontMatterDelimiter):]
+
+		var meta string
+		if strings.HasPrefix(rest, frontMatterDelimiter) {
+			body = rest[len(frontMatterDelimiter):]
+		} else if idx := strings.Index(rest, "\n"+frontMatterDelimiter); idx != -1 {
+			meta = rest[:idx+1]
+			body = rest[idx+1+len(frontMatterDelimiter):]
+		}
+
+		var fm frontMatter
+		if err := yaml.Unmarshal([]byte(meta), &fm); err != nil {
+			return ret, errors.Wrap(err, "unmarshalling the front matter")
+		}
+
+		addedOn, err := parseTime(fm.AddedOn)
+		if err != nil {
+			return ret, errors.Wrap(err, "parsing added_on")
+		}
+		editedOn, err := parseTime(fm.EditedOn)
+		if err != nil {
+			return ret, errors.Wrap(err, "parsing edited_on")
+		}
+
+		ret.UUID = fm.UUID
+		ret.AddedOn = addedOn
+		ret.EditedOn = editedOn
+		ret.Public = fm.Public
+	}
+
+	ret.Body = strings.TrimSuffix(body, "\n")
+
+	return ret, nil
+}
+
+// bookDirName returns the name of the directory for the book with the given label.
+// Book names can contain slashes and begin with a dot, which are escaped so that
+// every book is a single, visible directory.
+func bookDirName(label string) string {
+	ret := url.PathEscape(label)
+	if strings.HasPrefix(ret, ".") {
+		ret = "%2E" + ret[1:]
+	}
+
+	return ret
+}
+
+// bookLabel returns the label of the book for the given directory name
+func bookLabel(dirName string) (string, error) {
+	ret, err := url.PathUnescape(dirName)
+	if err != nil {
+		return "", errors.Wrapf(err, "unescaping the directory name %s", dirName)
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,136 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package git
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/pkg/errors"
+)
+
+func TestNoteFile(t *testing.T) {
+	testCases := []noteFile{
+		{
+			UUID:     "n1-uuid",
+			Body:     "n1 body",
+			AddedOn:  1541108743000000000,
+			EditedOn: 1541108745000000000,
+		},
+		{
+			UUID:    "n2-uuid",
+			Body:    "line 1\n\n---\nline 2\n",
+			AddedOn: 1541108743123456789,
+			Public:  true,
+		},
+		{
+			UUID: "n3-uuid",
+			Body: "",
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			content, err := tc.render()
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "rendering"))
+			}
+
+			got, err := parseNoteFile(content)
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "parsing"))
+			}
+
+			assert.DeepEqual(t, got, tc, "note mismatch")
+		})
+	}
+}
+
+func TestParseNoteFile(t *testing.T) {
+	testCases := []struct {
+		content  string
+		expected noteFile
+	}{
+		{
+			content:  "a note added by hand\n",
+			expected: noteFile{Body: "a note added by hand"},
+		},
+		{
+			content:  "---\nuuid: n1-uuid\n---\nn1 body",
+			expected: noteFile{UUID: "n1-uuid", Body: "n1 body"},
+		},
+		{
+			content:  "---\n---\nn1 body\n",
+			expected: noteFile{Body: "n1 body"},
+		},
+		{
+			content:  "---\nuuid: n1-uuid\nadded_on: 2018-11-01T21:45:43Z\n---\n",
+			expected: noteFile{UUID: "n1-uuid", AddedOn: 1541108743000000000},
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			got, err := parseNoteFile(tc.content)
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "executing"))
+			}
+
+			assert.DeepEqual(t, got, tc.expected, "note mismatch")
+		})
+	}
+}
+
+func TestBookDirName(t *testing.T) {
+	testCases := []struct {
+		label    string
+		expected string
+	}{
+		{
+			label:    "js",
+			expected: "js",
+		},
+		{
+			label:    "node.js",
+			expected: "node.js",
+		},
+		{
+			label:    "a/b",
+			expected: "a%2Fb",
+		},
+		{
+			label:    ".git",
+			expected: "%2Egit",
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			got := bookDirName(tc.label)
+			assert.Equal(t, got, tc.expected, "dir name mismatch")
+
+			label, err := bookLabel(got)
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "getting the label"))
+			}
+			assert.Equal(t, label, tc.label, "label mismatch")
+		})
+	}
+}
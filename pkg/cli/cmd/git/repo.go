@@ -0,0 +1,188 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// runGit runs a git command in the given directory and returns its output
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(string(out))
+		}
+
+		return "", errors.Wrapf(err, "running git %s: %s", args[0], msg)
+	}
+
+	return string(out), nil
+}
+
+// getHead returns the commit at HEAD, or an empty string if there is no commit yet
+func getHead(dir string) (string, error) {
+	out, err := runGit(dir, "rev-parse", "--verify", "-q", "HEAD")
+	if err != nil {
+		if _, ok := errors.Cause(err).(*exec.ExitError); ok {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+// getBranch returns the name of the current branch
+func getBranch(dir string) (string, error) {
+	out, err := runGit(dir, "symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return "", errors.Wrap(err, "getting the current branch")
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+// getRemote returns the first remote of the repository, or an empty string if there is none
+func getRemote(dir string) (string, error) {
+	out, err := runGit(dir, "remote")
+	if err != nil {
+		return "", errors.Wrap(err, "getting the remotes")
+	}
+
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return "", nil
+	}
+
+	return fields[0], nil
+}
+
+// hasChanges returns true if the working tree has changes that are not committed
+func hasChanges(dir string) (bool, error) {
+	out, err := runGit(dir, "status", "--porcelain")
+	if err != nil {
+		return false, errors.Wrap(err, "getting the status")
+	}
+
+	return strings.TrimSpace(out) != "", nil
+}
+
+// change is a file added, modified or deleted in a commit
+type change struct {
+	status string
+	path   string
+}
+
+// getStagedChanges returns the changes staged for the next commit
+func getStagedChanges(dir string) ([]change, error) {
+	out, err := runGit(dir, "diff", "--cached", "--name-status", "--no-renames", "-z")
+	if err != nil {
+		return nil, errors.Wrap(err, "getting the staged changes")
+	}
+
+	var ret []change
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		ret = append(ret, change{status: fields[i], path: fields[i+1]})
+	}
+
+	return ret, nil
+}
+
+// listFiles returns the paths of the files in the given commit
+func listFiles(dir, commit string) ([]string, error) {
+	out, err := runGit(dir, "ls-tree", "-r", "-z", "--name-only", commit)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing the files")
+	}
+
+	var ret []string
+	for _, p := range strings.Split(out, "\x00") {
+		if p != "" {
+			ret = append(ret, p)
+		}
+	}
+
+	return ret, nil
+}
+
+// readFiles returns the contents of the given files in the given commit
+func readFiles(dir, commit string, paths []string) (map[string]string, error) {
+	ret := map[string]string{}
+	if len(paths) == 0 {
+		return ret, nil
+	}
+
+	var stdin bytes.Buffer
+	for _, p := range paths {
+		fmt.Fprintf(&stdin, "%s:%s\n", commit, p)
+	}
+
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Dir = dir
+	cmd.Stdin = &stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "running git cat-file")
+	}
+
+	r := bufio.NewReader(bytes.NewReader(out))
+	for _, p := range paths {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading the header for %s", p)
+		}
+
+		// the header is "<object> <type> <size>", or "<object> missing"
+		parts := strings.Fields(header)
+		if len(parts) != 3 {
+			continue
+		}
+		size, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing the size of %s", p)
+		}
+
+		content := make([]byte, size+1)
+		if _, err := io.ReadFull(r, content); err != nil {
+			return nil, errors.Wrapf(err, "reading %s", p)
+		}
+
+		ret[p] = string(content[:size])
+	}
+
+	return ret, nil
+}
@@ -151,6 +151,10 @@ func getGreeting(ctx context.DnoteCtx) string {
 
 func newRun(ctx context.DnoteCtx) infra.RunEFunc {
 	return func(cmd *cobra.Command, args []string) error {
+		if client.IsFileEndpoint(ctx.APIEndpoint) {
+			return errors.Errorf("no login is needed to sync with the directory %s", ctx.APIEndpoint)
+		}
+
 		greeting := getGreeting(ctx)
 		log.Plain(greeting)
 
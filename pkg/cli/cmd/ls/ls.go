@@ -58,7 +58,7 @@ func NewCmd(ctx context.DnoteCtx) *cobra.Command {
 		Aliases:    []string{"l", "notes"},
 		Short:      "List all notes",
 		Example:    example,
-		RunE:       NewRun(ctx, false),
+		RunE:       NewRun(ctx, false, false),
 		PreRunE:    preRun,
 		Deprecated: deprecationWarning,
 	}
@@ -66,11 +66,12 @@ func NewCmd(ctx context.DnoteCtx) *cobra.Command {
 	return cmd
 }
 
-// NewRun returns a new run function for ls
-func NewRun(ctx context.DnoteCtx, nameOnly bool) infra.RunEFunc {
+// NewRun returns a new run function for ls. Archived books and notes are
+// listed only if archived is true.
+func NewRun(ctx context.DnoteCtx, nameOnly, archived bool) infra.RunEFunc {
 	return func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
-			if err := printBooks(ctx, nameOnly); err != nil {
+			if err := printBooks(ctx, nameOnly, archived); err != nil {
 				return errors.Wrap(err, "viewing books")
 			}
 
@@ -78,7 +79,7 @@ func NewRun(ctx context.DnoteCtx, nameOnly bool) infra.RunEFunc {
 		}
 
 		bookName := args[0]
-		if err := printNotes(ctx, bookName); err != nil {
+		if err := printNotes(ctx, bookName, archived); err != nil {
 			return errors.Wrapf(err, "viewing book '%s'", bookName)
 		}
 
@@ -90,12 +91,16 @@ func NewRun(ctx context.DnoteCtx, nameOnly bool) infra.RunEFunc {
 type bookInfo struct {
 	BookLabel string
 	NoteCount int
+	Pinned    bool
+	Archived  bool
 }
 
 // noteInfo is an information about the note to be printed on screen
 type noteInfo struct {
-	RowID int
-	Body  string
+	RowID    int
+	Body     string
+	Pinned   bool
+	Archived bool
 }
 
 // getNewlineIdx returns the index of newline character in a string
@@ -126,23 +131,38 @@ func formatBody(noteBody string) (string, bool) {
 	return strings.Trim(trimmed, " "), false
 }
 
+// formatStates returns the labels for the pinned and archived states to be
+// printed after a book or a note
+func formatStates(pinned, archived bool) string {
+	var ret string
+
+	if pinned {
+		ret += " " + log.ColorBlue.Sprint("[pinned]")
+	}
+	if archived {
+		ret += " " + log.ColorGray.Sprint("[archived]")
+	}
+
+	return ret
+}
+
 func printBookLine(info bookInfo, nameOnly bool) {
 	if nameOnly {
 		fmt.Println(info.BookLabel)
 	} else {
-		log.Printf("%s %s\n", info.BookLabel, log.ColorYellow.Sprintf("(%d)", info.NoteCount))
+		log.Printf("%s %s%s\n", info.BookLabel, log.ColorYellow.Sprintf("(%d)", info.NoteCount), formatStates(info.Pinned, info.Archived))
 	}
 }
 
-func printBooks(ctx context.DnoteCtx, nameOnly bool) error {
+func printBooks(ctx context.DnoteCtx, nameOnly, archived bool) error {
 	db := ctx.DB
 
-	rows, err := db.Query(`SELECT books.label, count(notes.uuid) note_count
+	rows, err := db.Query(`SELECT books.label, count(notes.uuid) note_count, books.pinned, books.archived
 	FROM books
-	LEFT JOIN notes ON notes.book_uuid = books.uuid AND notes.deleted = false
-	WHERE books.deleted = false
+	LEFT JOIN notes ON notes.book_uuid = books.uuid AND notes.deleted = false AND (? OR notes.archived = false)
+	WHERE books.deleted = false AND (? OR books.archived = false)
 	GROUP BY books.uuid
-	ORDER BY books.label ASC;`)
+	ORDER BY books.pinned DESC, books.label ASC;`, archived, archived)
 	if err != nil {
 		return errors.Wrap(err, "querying books")
 	}
@@ -151,7 +171,7 @@ func printBooks(ctx context.DnoteCtx, nameOnly bool) error {
 	infos := []bookInfo{}
 	for rows.Next() {
 		var info bookInfo
-		err = rows.Scan(&info.BookLabel, &info.NoteCount)
+		err = rows.Scan(&info.BookLabel, &info.NoteCount, &info.Pinned, &info.Archived)
 		if err != nil {
 			return errors.Wrap(err, "scanning a row")
 		}
@@ -166,7 +186,7 @@ func printBooks(ctx context.DnoteCtx, nameOnly bool) error {
 	return nil
 }
 
-func printNotes(ctx context.DnoteCtx, bookName string) error {
+func printNotes(ctx context.DnoteCtx, bookName string, archived bool) error {
 	db := ctx.DB
 
 	var bookUUID string
@@ -177,7 +197,9 @@ func printNotes(ctx context.DnoteCtx, bookName string) error {
 		return errors.Wrap(err, "querying the book")
 	}
 
-	rows, err := db.Query(`SELECT rowid, body FROM notes WHERE book_uuid = ? AND deleted = ? ORDER BY added_on ASC;`, bookUUID, false)
+	rows, err := db.Query(`SELECT rowid, body, pinned, archived FROM notes
+	WHERE book_uuid = ? AND deleted = ? AND (? OR archived = false)
+	ORDER BY pinned DESC, added_on ASC;`, bookUUID, false, archived)
 	if err != nil {
 		return errors.Wrap(err, "querying notes")
 	}
@@ -186,7 +208,7 @@ func printNotes(ctx context.DnoteCtx, bookName string) error {
 	infos := []noteInfo{}
 	for rows.Next() {
 		var info noteInfo
-		err = rows.Scan(&info.RowID, &info.Body)
+		err = rows.Scan(&info.RowID, &info.Body, &info.Pinned, &info.Archived)
 		if err != nil {
 			return errors.Wrap(err, "scanning a row")
 		}
@@ -204,7 +226,7 @@ func printNotes(ctx context.DnoteCtx, bookName string) error {
 			body = fmt.Sprintf("%s %s", body, log.ColorYellow.Sprintf("[---More---]"))
 		}
 
-		log.Plainf("%s %s\n", rowid, body)
+		log.Plainf("%s %s%s\n", rowid, body, formatStates(info.Pinned, info.Archived))
 	}
 
 	return nil
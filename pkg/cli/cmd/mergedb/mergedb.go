@@ -0,0 +1,438 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package mergedb
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/config"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/infra"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/migrate"
+	"github.com/dnote/dnote/pkg/cli/snapshot"
+	"github.com/dnote/dnote/pkg/cli/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var example = `
+  * Merge the notes from the database of another machine
+  dnote merge-db ~/backup/dnote.db`
+
+// NewCmd returns a new merge-db command
+func NewCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "merge-db <path>",
+		Short:   "Merge the books and notes from another database",
+		Example: example,
+		PreRunE: preRun,
+		RunE:    newRun(ctx),
+	}
+
+	return cmd
+}
+
+func preRun(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Incorrect number of argument")
+	}
+
+	return nil
+}
+
+// mergeReport is the result of merging another database
+type mergeReport struct {
+	// BooksAdded is the number of books that did not exist
+	BooksAdded int
+	// BooksMatched is the number of books merged into an existing book with the same name
+	BooksMatched int
+	// BooksRenamed is the number of added books renamed because of a duplicate name
+	BooksRenamed int
+	// NotesAdded is the number of notes that did not exist
+	NotesAdded int
+	// NotesRestored is the number of notes that were deleted in this database
+	NotesRestored int
+	// NotesMoved is the number of notes with the same body that were moved to another book
+	// in the other database more recently
+	NotesMoved int
+	// NotesUpdated is the number of notes with the same body whose dates or states were
+	// changed in the other database more recently
+	NotesUpdated int
+	// NotesConflicted is the number of notes with different bodies in the two databases
+	NotesConflicted int
+	// NotesUnchanged is the number of notes that are the same in both databases
+	NotesUnchanged int
+}
+
+// openOther opens a copy of the database at the given path, upgraded to the current
+// schema. The copy and the configuration used while upgrading it are kept in a temporary
+// directory so that neither the given database nor the current configuration is modified.
+// The returned function closes and removes the copy.
+func openOther(ctx context.DnoteCtx, path string) (*database.DB, func(), error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, errors.Wrapf(err, "checking the database %s", path)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "getting the absolute path")
+	}
+	currentPath, err := filepath.Abs(ctx.DB.Filepath)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "getting the absolute path of the current database")
+	}
+	if absPath == currentPath {
+		return nil, nil, errors.New("cannot merge the current database into itself")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "dnote-merge")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "creating a temporary directory")
+	}
+	cleanup := func() { os.RemoveAll(tmpDir) }
+
+	dnoteDir := filepath.Join(tmpDir, consts.DnoteDirName)
+	if err := os.MkdirAll(dnoteDir, 0755); err != nil {
+		cleanup()
+		return nil, nil, errors.Wrap(err, "creating the dnote directory")
+	}
+
+	dbPath := filepath.Join(dnoteDir, consts.DnoteDBFileName)
+	if err := copyDatabase(absPath, dbPath); err != nil {
+		cleanup()
+		return nil, nil, errors.Wrap(err, "copying the database")
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		cleanup()
+		return nil, nil, errors.Wrap(err, "opening the database")
+	}
+	closeAndCleanup := func() {
+		db.Close()
+		cleanup()
+	}
+
+	tmpCtx := context.DnoteCtx{
+		Paths: context.Paths{
+			Home:   tmpDir,
+			Config: tmpDir,
+			Data:   tmpDir,
+			Cache:  tmpDir,
+		},
+		DB:    db,
+		Clock: ctx.Clock,
+	}
+	if err := config.Write(tmpCtx, config.Config{}); err != nil {
+		closeAndCleanup()
+		return nil, nil, errors.Wrap(err, "writing a temporary configuration")
+	}
+	if err := migrate.Run(tmpCtx, migrate.LocalSequence, migrate.LocalMode); err != nil {
+		closeAndCleanup()
+		return nil, nil, errors.Wrap(err, "upgrading the database")
+	}
+
+	return db, closeAndCleanup, nil
+}
+
+// copyDatabase copies the database at src into a new database file at dest. It uses the
+// online backup of SQLite rather than copying the file so that the changes that are only
+// in the write-ahead log of src are included. src is opened read-only and is not modified.
+func copyDatabase(src, dest string) error {
+	// a Windows path such as C:\dnote.db needs a leading slash in the uri
+	uriPath := filepath.ToSlash(src)
+	if !strings.HasPrefix(uriPath, "/") {
+		uriPath = "/" + uriPath
+	}
+	uri := url.URL{Scheme: "file", Path: uriPath, RawQuery: "mode=ro"}
+
+	db, err := database.Open(uri.String())
+	if err != nil {
+		return errors.Wrapf(err, "opening %s", src)
+	}
+	defer db.Close()
+
+	if err := db.Backup(dest); err != nil {
+		return errors.Wrapf(err, "backing up %s", src)
+	}
+
+	return nil
+}
+
+type otherBook struct {
+	uuid      string
+	label     string
+	localOnly bool
+	pinned    bool
+	archived  bool
+}
+
+// mergeBooks merges the books in the other database by name. It returns a map from the
+// uuids of the books in the other database to the uuids of the books in this database.
+// The added books are pinned and archived as in the other database, and the matched
+// books are kept as they are in this database.
+func mergeBooks(tx, other *database.DB, r *mergeReport) (map[string]string, error) {
+	rows, err := other.Query("SELECT uuid, label, local_only, pinned, archived FROM books WHERE NOT deleted")
+	if err != nil {
+		return nil, errors.Wrap(err, "getting the books")
+	}
+	defer rows.Close()
+
+	var books []otherBook
+	for rows.Next() {
+		var b otherBook
+		if err := rows.Scan(&b.uuid, &b.label, &b.localOnly, &b.pinned, &b.archived); err != nil {
+			return nil, errors.Wrap(err, "scanning a book")
+		}
+
+		books = append(books, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating the books")
+	}
+
+	ret := map[string]string{}
+	for _, b := range books {
+		var uuid string
+		err := tx.QueryRow("SELECT uuid FROM books WHERE label = ? AND NOT deleted", b.label).Scan(&uuid)
+		if err == nil {
+			ret[b.uuid] = uuid
+			r.BooksMatched++
+			continue
+		} else if err != sql.ErrNoRows {
+			return nil, errors.Wrapf(err, "finding the book '%s'", b.label)
+		}
+
+		// a deleted book that has not been synced yet can still hold the name
+		label := b.label
+		var labelCount int
+		if err := tx.QueryRow("SELECT count(*) FROM books WHERE label = ?", label).Scan(&labelCount); err != nil {
+			return nil, errors.Wrapf(err, "checking for books with a duplicate label %s", label)
+		}
+		if labelCount > 0 {
+			label, err = sync.ResolveLabel(tx, label)
+			if err != nil {
+				return nil, errors.Wrap(err, "getting a new book label")
+			}
+
+			r.BooksRenamed++
+		}
+
+		uuid = b.uuid
+		var uuidCount int
+		if err := tx.QueryRow("SELECT count(*) FROM books WHERE uuid = ?", uuid).Scan(&uuidCount); err != nil {
+			return nil, errors.Wrapf(err, "checking for books with the uuid %s", uuid)
+		}
+		if uuidCount > 0 {
+			uuid, err = utils.GenerateUUID()
+			if err != nil {
+				return nil, errors.Wrap(err, "generating a uuid")
+			}
+		}
+
+		book := database.NewBook(uuid, label, 0, false, true)
+		book.Pinned = b.pinned
+		book.Archived = b.archived
+		if err := book.Insert(tx); err != nil {
+			return nil, errors.Wrapf(err, "inserting the book '%s'", label)
+		}
+		if b.localOnly {
+			if _, err := tx.Exec("UPDATE books SET local_only = ? WHERE uuid = ?", true, uuid); err != nil {
+				return nil, errors.Wrapf(err, "marking the book '%s' local-only", label)
+			}
+		}
+
+		ret[b.uuid] = uuid
+		r.BooksAdded++
+	}
+
+	return ret, nil
+}
+
+// mergeNotes merges the notes in the other database by uuid. The notes that differ
+// are marked dirty and, if both databases have a different body, the conflict is
+// reported in the body. The book, dates and states of the more recently edited copy are kept.
+func mergeNotes(tx, other *database.DB, bookUUIDs map[string]string, r *mergeReport) error {
+	rows, err := other.Query("SELECT uuid, book_uuid, body, added_on, edited_on, public, due_on, remind_on, pinned, archived FROM notes WHERE NOT deleted")
+	if err != nil {
+		return errors.Wrap(err, "getting the notes")
+	}
+	defer rows.Close()
+
+	var notes []database.Note
+	for rows.Next() {
+		var n database.Note
+		if err := rows.Scan(&n.UUID, &n.BookUUID, &n.Body, &n.AddedOn, &n.EditedOn, &n.Public, &n.DueOn, &n.RemindOn, &n.Pinned, &n.Archived); err != nil {
+			return errors.Wrap(err, "scanning a note")
+		}
+
+		notes = append(notes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "iterating the notes")
+	}
+
+	for _, n := range notes {
+		bookUUID, ok := bookUUIDs[n.BookUUID]
+		if !ok {
+			log.Debug("skipping note %s whose book is deleted\n", n.UUID)
+			continue
+		}
+
+		var local database.Note
+		err := tx.QueryRow("SELECT book_uuid, body, edited_on, deleted, due_on, remind_on, pinned, archived FROM notes WHERE uuid = ?", n.UUID).
+			Scan(&local.BookUUID, &local.Body, &local.EditedOn, &local.Deleted, &local.DueOn, &local.RemindOn, &local.Pinned, &local.Archived)
+		if err == sql.ErrNoRows {
+			note := database.NewNote(n.UUID, bookUUID, n.Body, n.AddedOn, n.EditedOn, 0, n.Public, false, true)
+			note.DueOn = n.DueOn
+			note.RemindOn = n.RemindOn
+			note.Pinned = n.Pinned
+			note.Archived = n.Archived
+			if err := note.Insert(tx); err != nil {
+				return errors.Wrapf(err, "inserting the note %s", n.UUID)
+			}
+
+			r.NotesAdded++
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "finding the note %s", n.UUID)
+		}
+
+		if local.Deleted {
+			if _, err := tx.Exec("UPDATE notes SET book_uuid = ?, body = ?, edited_on = ?, public = ?, due_on = ?, remind_on = ?, pinned = ?, archived = ?, deleted = ?, dirty = ? WHERE uuid = ?",
+				bookUUID, n.Body, n.EditedOn, n.Public, n.DueOn, n.RemindOn, n.Pinned, n.Archived, false, true, n.UUID); err != nil {
+				return errors.Wrapf(err, "restoring the note %s", n.UUID)
+			}
+
+			r.NotesRestored++
+			continue
+		}
+
+		merged := local
+		if n.EditedOn > local.EditedOn {
+			merged.BookUUID = bookUUID
+			merged.EditedOn = n.EditedOn
+			merged.DueOn = n.DueOn
+			merged.RemindOn = n.RemindOn
+			merged.Pinned = n.Pinned
+			merged.Archived = n.Archived
+		}
+
+		if local.Body == n.Body {
+			if merged == local {
+				r.NotesUnchanged++
+				continue
+			}
+
+			if _, err := tx.Exec("UPDATE notes SET book_uuid = ?, edited_on = ?, due_on = ?, remind_on = ?, pinned = ?, archived = ?, dirty = ? WHERE uuid = ?",
+				merged.BookUUID, merged.EditedOn, merged.DueOn, merged.RemindOn, merged.Pinned, merged.Archived, true, n.UUID); err != nil {
+				return errors.Wrapf(err, "updating the note %s", n.UUID)
+			}
+
+			if merged.BookUUID != local.BookUUID {
+				r.NotesMoved++
+			} else {
+				r.NotesUpdated++
+			}
+			continue
+		}
+
+		body := sync.ReportBodyConflict(local.Body, n.Body)
+		if _, err := tx.Exec("UPDATE notes SET book_uuid = ?, body = ?, edited_on = ?, due_on = ?, remind_on = ?, pinned = ?, archived = ?, dirty = ? WHERE uuid = ?",
+			merged.BookUUID, body, merged.EditedOn, merged.DueOn, merged.RemindOn, merged.Pinned, merged.Archived, true, n.UUID); err != nil {
+			return errors.Wrapf(err, "updating the note %s", n.UUID)
+		}
+
+		r.NotesConflicted++
+	}
+
+	return nil
+}
+
+// merge merges the books and notes in the other database into this database
+func merge(tx, other *database.DB) (mergeReport, error) {
+	var ret mergeReport
+
+	bookUUIDs, err := mergeBooks(tx, other, &ret)
+	if err != nil {
+		return ret, errors.Wrap(err, "merging books")
+	}
+	if err := mergeNotes(tx, other, bookUUIDs, &ret); err != nil {
+		return ret, errors.Wrap(err, "merging notes")
+	}
+
+	return ret, nil
+}
+
+func printReport(r mergeReport) {
+	log.Plainf("books: %d added, %d merged by name, %d renamed because of a duplicate name\n", r.BooksAdded, r.BooksMatched, r.BooksRenamed)
+	log.Plainf("notes: %d added, %d restored, %d moved, %d updated, %d with conflicts, %d unchanged\n", r.NotesAdded, r.NotesRestored, r.NotesMoved, r.NotesUpdated, r.NotesConflicted, r.NotesUnchanged)
+
+	if r.NotesConflicted > 0 {
+		log.Warnf("%d notes were changed in both databases. Please edit them to resolve the conflicts.\n", r.NotesConflicted)
+	}
+}
+
+func newRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		path := args[0]
+
+		other, cleanup, err := openOther(ctx, path)
+		if err != nil {
+			return errors.Wrap(err, "opening the other database")
+		}
+		defer cleanup()
+
+		if err := snapshot.Auto(ctx, snapshot.ReasonBulk); err != nil {
+			return errors.Wrap(err, "backing up the database")
+		}
+
+		tx, err := ctx.DB.Begin()
+		if err != nil {
+			return errors.Wrap(err, "beginning a transaction")
+		}
+
+		r, err := merge(tx, other)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "committing a transaction")
+		}
+
+		log.Successf("merged %s\n", path)
+		printReport(r)
+
+		sync.AfterWrite(ctx)
+
+		return nil
+	}
+}
@@ -0,0 +1,259 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package mergedb
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/pkg/errors"
+)
+
+func TestMerge(t *testing.T) {
+	// set up
+	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+	defer database.TeardownTestDB(t, db)
+	other := database.InitTestDB(t, "../../tmp/other/.dnote", nil)
+	defer database.TeardownTestDB(t, other)
+
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "js", 1, false, false)
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b2-uuid", "go", 2, true, true)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1 body", 1, 0, 3, false, false)
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", "n2 body", 2, 0, 4, false, false)
+	database.MustExec(t, "inserting n3", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", "n3-uuid", "b1-uuid", "", 3, 0, 5, true, true)
+	database.MustExec(t, "inserting n6", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", "n6-uuid", "b1-uuid", "n6 body", 6, 0, 6, false, false)
+	database.MustExec(t, "inserting n7", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, deleted, dirty, pinned) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", "n7-uuid", "b1-uuid", "n7 body", 7, 70, 7, false, false, true)
+	database.MustExec(t, "inserting n8", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, deleted, dirty, due_on, remind_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", "n8-uuid", "b1-uuid", "n8 body", 8, 0, 8, false, false, 100, 90)
+
+	// a book with the same name, a book whose name is held by a deleted book, and a new book
+	database.MustExec(t, "inserting other b1", other, "INSERT INTO books (uuid, label, usn, deleted, dirty, pinned) VALUES (?, ?, ?, ?, ?, ?)", "other-b1-uuid", "js", 7, false, false, true)
+	database.MustExec(t, "inserting other b2", other, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "other-b2-uuid", "go", 8, false, false)
+	database.MustExec(t, "inserting other b3", other, "INSERT INTO books (uuid, label, usn, deleted, dirty, local_only, pinned, archived) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", "b1-uuid", "css", 0, false, true, true, true, true)
+	database.MustExec(t, "inserting other b4", other, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "other-b4-uuid", "deleted", 9, true, false)
+	// an unchanged note, a conflicting note, a note deleted in this database, and new notes
+	database.MustExec(t, "inserting other n1", other, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "other-b1-uuid", "n1 body", 1, 0, 3, false, false)
+	database.MustExec(t, "inserting other n2", other, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "other-b1-uuid", "n2 body edited", 2, 20, 4, false, false)
+	database.MustExec(t, "setting the schedule of other n2", other, "UPDATE notes SET due_on = ?, remind_on = ?, pinned = ? WHERE uuid = ?", 200, 190, true, "n2-uuid")
+	database.MustExec(t, "inserting other n3", other, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", "n3-uuid", "other-b2-uuid", "n3 body", 3, 30, 5, false, false)
+	database.MustExec(t, "inserting other n4", other, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, deleted, dirty, public, due_on, remind_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", "n4-uuid", "b1-uuid", "n4 body", 4, 40, 0, false, true, true, 400, 390)
+	database.MustExec(t, "archiving other n4", other, "UPDATE notes SET pinned = ?, archived = ? WHERE uuid = ?", true, true, "n4-uuid")
+	database.MustExec(t, "inserting other n5", other, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", "n5-uuid", "other-b4-uuid", "n5 body", 5, 0, 9, false, false)
+	// a note moved to another book in the other database, and a note moved in the other database before it was edited in this database
+	database.MustExec(t, "inserting other n6", other, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", "n6-uuid", "other-b2-uuid", "n6 body", 6, 60, 6, false, true)
+	database.MustExec(t, "inserting other n7", other, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", "n7-uuid", "other-b2-uuid", "n7 body", 7, 50, 7, false, true)
+	// a note rescheduled and archived in the other database
+	database.MustExec(t, "inserting other n8", other, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, deleted, dirty, due_on, remind_on, archived) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", "n8-uuid", "other-b1-uuid", "n8 body", 8, 80, 8, false, true, 800, 0, true)
+	// a note pinned in this database more recently than it was archived in the other database
+	database.MustExec(t, "inserting other n7 state", other, "UPDATE notes SET archived = ? WHERE uuid = ?", true, "n7-uuid")
+
+	// execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "beginning a transaction"))
+	}
+	got, err := merge(tx, other)
+	if err != nil {
+		tx.Rollback()
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+	tx.Commit()
+
+	// test
+	assert.Equal(t, got, mergeReport{
+		BooksAdded:      2,
+		BooksMatched:    1,
+		BooksRenamed:    1,
+		NotesAdded:      1,
+		NotesRestored:   1,
+		NotesMoved:      1,
+		NotesUpdated:    1,
+		NotesConflicted: 1,
+		NotesUnchanged:  2,
+	}, "report mismatch")
+
+	var bookCount, noteCount int
+	database.MustScan(t, "counting books", db.QueryRow("SELECT count(*) FROM books"), &bookCount)
+	database.MustScan(t, "counting notes", db.QueryRow("SELECT count(*) FROM notes"), &noteCount)
+	assert.Equal(t, bookCount, 4, "book count mismatch")
+	assert.Equal(t, noteCount, 7, "note count mismatch")
+
+	var goBookUUID string
+	var goBookUSN int
+	var goBookDirty bool
+	database.MustScan(t, "getting the renamed book", db.QueryRow("SELECT uuid, usn, dirty FROM books WHERE label = ?", "go_2"), &goBookUUID, &goBookUSN, &goBookDirty)
+	assert.Equal(t, goBookUUID, "other-b2-uuid", "renamed book uuid mismatch")
+	assert.Equal(t, goBookUSN, 0, "renamed book usn mismatch")
+	assert.Equal(t, goBookDirty, true, "renamed book dirty mismatch")
+
+	var cssBookUUID string
+	var cssLocalOnly, cssPinned, cssArchived bool
+	database.MustScan(t, "getting the new book", db.QueryRow("SELECT uuid, local_only, pinned, archived FROM books WHERE label = ?", "css"), &cssBookUUID, &cssLocalOnly, &cssPinned, &cssArchived)
+	assert.NotEqual(t, cssBookUUID, "b1-uuid", "new book should get a new uuid if the uuid is taken")
+	assert.Equal(t, cssLocalOnly, true, "new book local_only mismatch")
+	assert.Equal(t, cssPinned, true, "new book pinned mismatch")
+	assert.Equal(t, cssArchived, true, "new book archived mismatch")
+
+	var jsPinned bool
+	database.MustScan(t, "getting the matched book", db.QueryRow("SELECT pinned FROM books WHERE uuid = ?", "b1-uuid"), &jsPinned)
+	assert.Equal(t, jsPinned, false, "matched book should be kept as it is")
+
+	var n1 database.Note
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT body, usn, dirty FROM notes WHERE uuid = ?", "n1-uuid"), &n1.Body, &n1.USN, &n1.Dirty)
+	assert.Equal(t, n1.Body, "n1 body", "n1 body mismatch")
+	assert.Equal(t, n1.USN, 3, "n1 usn mismatch")
+	assert.Equal(t, n1.Dirty, false, "n1 dirty mismatch")
+
+	var n2 database.Note
+	database.MustScan(t, "getting n2", db.QueryRow("SELECT book_uuid, body, added_on, edited_on, due_on, remind_on, pinned, dirty FROM notes WHERE uuid = ?", "n2-uuid"), &n2.BookUUID, &n2.Body, &n2.AddedOn, &n2.EditedOn, &n2.DueOn, &n2.RemindOn, &n2.Pinned, &n2.Dirty)
+	assert.Equal(t, n2.BookUUID, "b1-uuid", "n2 book_uuid mismatch")
+	assert.Equal(t, n2.Body, sync.ReportBodyConflict("n2 body", "n2 body edited"), "n2 body mismatch")
+	assert.Equal(t, n2.AddedOn, int64(2), "n2 added_on mismatch")
+	assert.Equal(t, n2.EditedOn, int64(20), "n2 edited_on mismatch")
+	assert.Equal(t, n2.DueOn, int64(200), "n2 due_on mismatch")
+	assert.Equal(t, n2.RemindOn, int64(190), "n2 remind_on mismatch")
+	assert.Equal(t, n2.Pinned, true, "n2 pinned mismatch")
+	assert.Equal(t, n2.Dirty, true, "n2 dirty mismatch")
+
+	var n3 database.Note
+	database.MustScan(t, "getting n3", db.QueryRow("SELECT book_uuid, body, deleted, dirty FROM notes WHERE uuid = ?", "n3-uuid"), &n3.BookUUID, &n3.Body, &n3.Deleted, &n3.Dirty)
+	assert.Equal(t, n3.BookUUID, "other-b2-uuid", "n3 book_uuid mismatch")
+	assert.Equal(t, n3.Body, "n3 body", "n3 body mismatch")
+	assert.Equal(t, n3.Deleted, false, "n3 deleted mismatch")
+	assert.Equal(t, n3.Dirty, true, "n3 dirty mismatch")
+
+	var n4 database.Note
+	database.MustScan(t, "getting n4", db.QueryRow("SELECT book_uuid, body, added_on, edited_on, usn, public, due_on, remind_on, pinned, archived, dirty FROM notes WHERE uuid = ?", "n4-uuid"), &n4.BookUUID, &n4.Body, &n4.AddedOn, &n4.EditedOn, &n4.USN, &n4.Public, &n4.DueOn, &n4.RemindOn, &n4.Pinned, &n4.Archived, &n4.Dirty)
+	assert.Equal(t, n4.BookUUID, cssBookUUID, "n4 book_uuid mismatch")
+	assert.Equal(t, n4.Body, "n4 body", "n4 body mismatch")
+	assert.Equal(t, n4.AddedOn, int64(4), "n4 added_on mismatch")
+	assert.Equal(t, n4.EditedOn, int64(40), "n4 edited_on mismatch")
+	assert.Equal(t, n4.USN, 0, "n4 usn mismatch")
+	assert.Equal(t, n4.Public, true, "n4 public mismatch")
+	assert.Equal(t, n4.DueOn, int64(400), "n4 due_on mismatch")
+	assert.Equal(t, n4.RemindOn, int64(390), "n4 remind_on mismatch")
+	assert.Equal(t, n4.Pinned, true, "n4 pinned mismatch")
+	assert.Equal(t, n4.Archived, true, "n4 archived mismatch")
+	assert.Equal(t, n4.Dirty, true, "n4 dirty mismatch")
+
+	var n6 database.Note
+	database.MustScan(t, "getting n6", db.QueryRow("SELECT book_uuid, body, edited_on, dirty FROM notes WHERE uuid = ?", "n6-uuid"), &n6.BookUUID, &n6.Body, &n6.EditedOn, &n6.Dirty)
+	assert.Equal(t, n6.BookUUID, "other-b2-uuid", "n6 book_uuid mismatch")
+	assert.Equal(t, n6.Body, "n6 body", "n6 body mismatch")
+	assert.Equal(t, n6.EditedOn, int64(60), "n6 edited_on mismatch")
+	assert.Equal(t, n6.Dirty, true, "n6 dirty mismatch")
+
+	var n7 database.Note
+	database.MustScan(t, "getting n7", db.QueryRow("SELECT book_uuid, edited_on, pinned, archived, dirty FROM notes WHERE uuid = ?", "n7-uuid"), &n7.BookUUID, &n7.EditedOn, &n7.Pinned, &n7.Archived, &n7.Dirty)
+	assert.Equal(t, n7.BookUUID, "b1-uuid", "n7 book_uuid mismatch")
+	assert.Equal(t, n7.EditedOn, int64(70), "n7 edited_on mismatch")
+	assert.Equal(t, n7.Pinned, true, "n7 pinned mismatch")
+	assert.Equal(t, n7.Archived, false, "n7 archived mismatch")
+	assert.Equal(t, n7.Dirty, false, "n7 dirty mismatch")
+
+	var n8 database.Note
+	database.MustScan(t, "getting n8", db.QueryRow("SELECT book_uuid, edited_on, due_on, remind_on, archived, dirty FROM notes WHERE uuid = ?", "n8-uuid"), &n8.BookUUID, &n8.EditedOn, &n8.DueOn, &n8.RemindOn, &n8.Archived, &n8.Dirty)
+	assert.Equal(t, n8.BookUUID, "b1-uuid", "n8 book_uuid mismatch")
+	assert.Equal(t, n8.EditedOn, int64(80), "n8 edited_on mismatch")
+	assert.Equal(t, n8.DueOn, int64(800), "n8 due_on mismatch")
+	assert.Equal(t, n8.RemindOn, int64(0), "n8 remind_on mismatch")
+	assert.Equal(t, n8.Archived, true, "n8 archived mismatch")
+	assert.Equal(t, n8.Dirty, true, "n8 dirty mismatch")
+}
+
+func TestOpenOther_current(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, context.Paths{
+		Home:   "../../tmp",
+		Config: "../../tmp/config",
+		Data:   "../../tmp/data",
+		Cache:  "../../tmp/cache",
+	}, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	// execute
+	_, _, err := openOther(ctx, ctx.DB.Filepath)
+
+	// test
+	assert.NotEqual(t, err, nil, "merging the current database should fail")
+}
+
+func TestOpenOther(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, context.Paths{
+		Home:   "../../tmp",
+		Config: "../../tmp/config",
+		Data:   "../../tmp/data",
+		Cache:  "../../tmp/cache",
+	}, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	src := database.InitTestDB(t, "../../tmp/other/.dnote", nil)
+	defer database.TeardownTestDB(t, src)
+	database.MustExec(t, "inserting b1", src, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "js", 1, false, false)
+
+	// execute
+	other, cleanup, err := openOther(ctx, src.Filepath)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	// test
+	var label string
+	database.MustScan(t, "getting the book", other.QueryRow("SELECT label FROM books WHERE uuid = ?", "b1-uuid"), &label)
+	assert.Equal(t, label, "js", "label mismatch")
+	assert.NotEqual(t, other.Filepath, src.Filepath, "the database should be copied")
+
+	cleanup()
+	_, err = os.Stat(other.Filepath)
+	assert.Equal(t, os.IsNotExist(err), true, "the copy should be removed")
+}
+
+func TestOpenOther_wal(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, context.Paths{
+		Home:   "../../tmp",
+		Config: "../../tmp/config",
+		Data:   "../../tmp/data",
+		Cache:  "../../tmp/cache",
+	}, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	src := database.InitTestDB(t, "../../tmp/other/.dnote", nil)
+	defer database.TeardownTestDB(t, src)
+	database.MustExec(t, "enabling the write-ahead log", src, "PRAGMA journal_mode=WAL")
+	database.MustExec(t, "disabling checkpoints", src, "PRAGMA wal_autocheckpoint=0")
+	// the book is only in the write-ahead log while src is open
+	database.MustExec(t, "inserting b1", src, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "js", 1, false, false)
+
+	// execute
+	other, cleanup, err := openOther(ctx, src.Filepath)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+	defer cleanup()
+
+	// test
+	var label string
+	database.MustScan(t, "getting the book", other.QueryRow("SELECT label FROM books WHERE uuid = ?", "b1-uuid"), &label)
+	assert.Equal(t, label, "js", "label mismatch")
+}
@@ -22,11 +22,15 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
 	"github.com/dnote/dnote/pkg/cli/context"
 	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/hooks"
 	"github.com/dnote/dnote/pkg/cli/infra"
 	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/operations"
 	"github.com/dnote/dnote/pkg/cli/output"
+	"github.com/dnote/dnote/pkg/cli/snapshot"
 	"github.com/dnote/dnote/pkg/cli/ui"
 	"github.com/dnote/dnote/pkg/cli/utils"
 	"github.com/pkg/errors"
@@ -143,25 +147,37 @@ func runNote(ctx context.DnoteCtx, rowIDArg string) error {
 		return nil
 	}
 
-	tx, err := db.Begin()
-	if err != nil {
-		return errors.Wrap(err, "beginning a transaction")
-	}
-
-	if _, err = tx.Exec("UPDATE notes SET deleted = ?, dirty = ?, body = ? WHERE uuid = ?", true, true, "", noteInfo.UUID); err != nil {
-		tx.Rollback()
+	if _, err := operations.DeleteNote(ctx, noteRowID); err != nil {
 		return errors.Wrap(err, "removing the note")
 	}
 
-	err = tx.Commit()
+	log.Successf("removed from %s\n", noteInfo.BookLabel)
+
+	return nil
+}
+
+// getBookNotes returns the notes in the book to describe to the hooks
+func getBookNotes(db *database.DB, bookUUID, bookLabel string) ([]hooks.Note, error) {
+	rows, err := db.Query("SELECT rowid, uuid, body FROM notes WHERE book_uuid = ? AND deleted = ? ORDER BY added_on ASC", bookUUID, false)
 	if err != nil {
-		tx.Rollback()
-		return errors.Wrap(err, "comitting transaction")
+		return nil, errors.Wrap(err, "querying notes")
 	}
+	defer rows.Close()
 
-	log.Successf("removed from %s\n", noteInfo.BookLabel)
+	var ret []hooks.Note
+	for rows.Next() {
+		n := hooks.Note{BookLabel: bookLabel}
+		if err := rows.Scan(&n.RowID, &n.UUID, &n.Content); err != nil {
+			return nil, errors.Wrap(err, "scanning a row")
+		}
 
-	return nil
+		ret = append(ret, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating rows")
+	}
+
+	return ret, nil
 }
 
 func runBook(ctx context.DnoteCtx, bookLabel string) error {
@@ -181,6 +197,15 @@ func runBook(ctx context.DnoteCtx, bookLabel string) error {
 		return nil
 	}
 
+	notes, err := getBookNotes(db, bookUUID, bookLabel)
+	if err != nil {
+		return errors.Wrap(err, "getting the notes in the book")
+	}
+
+	if err := snapshot.Auto(ctx, snapshot.ReasonBulk); err != nil {
+		return errors.Wrap(err, "backing up the database")
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
 		return errors.Wrap(err, "beginning a transaction")
@@ -210,5 +235,8 @@ func runBook(ctx context.DnoteCtx, bookLabel string) error {
 
 	log.Success("removed book\n")
 
+	hooks.Post(ctx, hooks.PostRemove, hooks.Payload{Notes: notes})
+	sync.AfterWrite(ctx)
+
 	return nil
 }
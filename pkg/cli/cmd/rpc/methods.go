@@ -0,0 +1,169 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpc
+
+import (
+	"encoding/json"
+
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/operations"
+)
+
+// method handles a request with the given params and returns the result
+type method func(s *server, params json.RawMessage) (interface{}, error)
+
+var methods = map[string]method{
+	"notes.search": searchNotes,
+	"notes.read":   readNote,
+	"notes.create": createNote,
+	"notes.update": updateNote,
+	"notes.delete": deleteNote,
+	"notes.move":   moveNote,
+	"books.list":   listBooks,
+	"sync":         syncNotes,
+}
+
+func decodeParams(params json.RawMessage, dest interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(params, dest); err != nil {
+		return invalidParamsf("invalid params: %s", err)
+	}
+
+	return nil
+}
+
+type searchParams struct {
+	Query    string `json:"query"`
+	Book     string `json:"book"`
+	Archived bool   `json:"archived"`
+	Limit    int    `json:"limit"`
+}
+
+func searchNotes(s *server, params json.RawMessage) (interface{}, error) {
+	var p searchParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Limit < 0 {
+		return nil, invalidParamsf("invalid limit: %d", p.Limit)
+	}
+
+	return operations.ListNotes(s.ctx.DB, operations.NotesFilter{
+		Query:    p.Query,
+		Book:     p.Book,
+		Archived: p.Archived,
+		Limit:    p.Limit,
+	})
+}
+
+type noteIDParams struct {
+	ID int `json:"id"`
+}
+
+func readNote(s *server, params json.RawMessage) (interface{}, error) {
+	var p noteIDParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	return operations.GetNote(s.ctx.DB, p.ID)
+}
+
+type createParams struct {
+	Book    string `json:"book"`
+	Content string `json:"content"`
+}
+
+func createNote(s *server, params json.RawMessage) (interface{}, error) {
+	var p createParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	return operations.CreateNote(s.ctx, operations.NewNoteParams{Book: p.Book, Content: p.Content})
+}
+
+type updateParams struct {
+	ID int `json:"id"`
+	operations.NoteParams
+}
+
+func updateNote(s *server, params json.RawMessage) (interface{}, error) {
+	var p updateParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	return operations.UpdateNote(s.ctx, p.ID, p.NoteParams)
+}
+
+func deleteNote(s *server, params json.RawMessage) (interface{}, error) {
+	var p noteIDParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	return operations.DeleteNote(s.ctx, p.ID)
+}
+
+type moveParams struct {
+	ID   int    `json:"id"`
+	Book string `json:"book"`
+}
+
+func moveNote(s *server, params json.RawMessage) (interface{}, error) {
+	var p moveParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Book == "" {
+		return nil, invalidParamsf("book is required")
+	}
+
+	return operations.UpdateNote(s.ctx, p.ID, operations.NoteParams{Book: &p.Book})
+}
+
+type listBooksParams struct {
+	Archived bool `json:"archived"`
+}
+
+func listBooks(s *server, params json.RawMessage) (interface{}, error) {
+	var p listBooksParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	return operations.ListBooks(s.ctx.DB, p.Archived)
+}
+
+func syncNotes(s *server, params json.RawMessage) (interface{}, error) {
+	summary, err := sync.Run(s.ctx)
+
+	// notify the changes even if the sync failed partway
+	s.notifySyncs()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,180 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/operations"
+	"github.com/pkg/errors"
+)
+
+const version = "2.0"
+
+// notificationChanged is sent when a sync changes the local books or notes
+const notificationChanged = "notes.changed"
+
+// error codes defined by JSON-RPC 2.0
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+	// codeNotFound is returned when the note or the book does not exist
+	codeNotFound = -32001
+)
+
+var nullID = json.RawMessage("null")
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// response is the reply to a request. Result is always set when Error is nil
+// because every method returns a value.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+func invalidParamsf(msg string, v ...interface{}) *rpcError {
+	return &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf(msg, v...)}
+}
+
+// toRPCError returns the error to send for the error returned by a method
+func toRPCError(err error) *rpcError {
+	cause := errors.Cause(err)
+
+	if e, ok := cause.(*rpcError); ok {
+		return e
+	}
+	if cause == operations.ErrNotFound {
+		return &rpcError{Code: codeNotFound, Message: "not found"}
+	}
+	if e, ok := cause.(operations.InvalidError); ok {
+		return &rpcError{Code: codeInvalidParams, Message: e.Message}
+	}
+
+	log.Debug("handling a request: %s\n", err)
+
+	return &rpcError{Code: codeInternalError, Message: err.Error()}
+}
+
+func errorResponse(id json.RawMessage, err *rpcError) *response {
+	return &response{JSONRPC: version, ID: id, Error: err}
+}
+
+// handle processes a single request or a batch, and returns what to reply, if anything
+func (s *server) handle(msg []byte) interface{} {
+	msg = bytes.TrimSpace(msg)
+
+	if len(msg) == 0 || msg[0] != '[' {
+		if res := s.handleRequest(msg); res != nil {
+			return res
+		}
+
+		return nil
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(msg, &batch); err != nil {
+		return errorResponse(nullID, &rpcError{Code: codeParseError, Message: "parse error"})
+	}
+	if len(batch) == 0 {
+		return errorResponse(nullID, &rpcError{Code: codeInvalidRequest, Message: "invalid request"})
+	}
+
+	var ret []*response
+	for _, m := range batch {
+		if res := s.handleRequest(m); res != nil {
+			ret = append(ret, res)
+		}
+	}
+
+	// a batch of notifications is not replied to
+	if len(ret) == 0 {
+		return nil
+	}
+
+	return ret
+}
+
+// handleRequest calls the method of a request. It returns nil for a notification.
+func (s *server) handleRequest(msg json.RawMessage) *response {
+	var req request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		if !json.Valid(msg) {
+			return errorResponse(nullID, &rpcError{Code: codeParseError, Message: "parse error"})
+		}
+
+		return errorResponse(nullID, &rpcError{Code: codeInvalidRequest, Message: "invalid request"})
+	}
+
+	id := req.ID
+	if id == nil {
+		id = nullID
+	}
+
+	if req.JSONRPC != version || req.Method == "" {
+		return errorResponse(id, &rpcError{Code: codeInvalidRequest, Message: "invalid request"})
+	}
+
+	m, ok := methods[req.Method]
+	if !ok {
+		if req.ID == nil {
+			return nil
+		}
+
+		return errorResponse(id, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method '%s' not found", req.Method)})
+	}
+
+	result, err := m(s, req.Params)
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		return errorResponse(id, toRPCError(err))
+	}
+
+	return &response{JSONRPC: version, ID: id, Result: result}
+}
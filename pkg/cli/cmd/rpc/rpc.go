@@ -0,0 +1,165 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dnote/color"
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/infra"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var example = `
+ * Start a JSON-RPC session on the standard input and output
+ dnote rpc
+
+ * Search the notes
+ echo '{"jsonrpc": "2.0", "id": 1, "method": "notes.search", "params": {"query": "closure"}}' | dnote rpc`
+
+// syncPollInterval is how often the sync log is checked for the syncs made by other processes
+var syncPollInterval = 5 * time.Second
+
+// NewCmd returns a new rpc command
+func NewCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "rpc",
+		Short:   "Serve JSON-RPC on the standard input and output for editor integrations",
+		Example: example,
+		RunE:    newRun(ctx),
+	}
+
+	return cmd
+}
+
+// server reads the requests and writes the responses and notifications, one JSON
+// document per line. It keeps using the same context and database for the whole session.
+type server struct {
+	ctx context.DnoteCtx
+	enc *json.Encoder
+
+	// lastSync is when the last sync that has been notified finished
+	lastSync int64
+}
+
+func newServer(ctx context.DnoteCtx, out io.Writer) *server {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+
+	s := &server{ctx: ctx, enc: enc}
+
+	entries, err := sync.ReadSyncLog(ctx)
+	if err != nil {
+		log.Debug("reading the sync log: %s\n", err)
+	}
+	if len(entries) > 0 {
+		s.lastSync = entries[len(entries)-1].FinishedAt
+	}
+
+	return s
+}
+
+func (s *server) write(v interface{}) {
+	if err := s.enc.Encode(v); err != nil {
+		log.Errorf("writing the output: %s\n", err)
+	}
+}
+
+// notifySyncs sends a notification for each sync that changed the local data since
+// the last check, including the ones made by other processes.
+func (s *server) notifySyncs() {
+	entries, err := sync.ReadSyncLog(s.ctx)
+	if err != nil {
+		log.Debug("reading the sync log: %s\n", err)
+		return
+	}
+
+	for _, e := range entries {
+		if e.FinishedAt <= s.lastSync {
+			continue
+		}
+
+		s.lastSync = e.FinishedAt
+		if e.ChangedLocal() {
+			s.write(notification{JSONRPC: version, Method: notificationChanged, Params: e})
+		}
+	}
+}
+
+// serve handles the requests from the input until it is closed
+func (s *server) serve(in io.Reader) error {
+	lines := make(chan []byte)
+	errs := make(chan error, 1)
+
+	go func() {
+		r := bufio.NewReader(in)
+
+		for {
+			line, err := r.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				lines <- line
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(syncPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case line := <-lines:
+			if res := s.handle(line); res != nil {
+				s.write(res)
+			}
+		case <-ticker.C:
+			s.notifySyncs()
+		case err := <-errs:
+			if err == io.EOF {
+				return nil
+			}
+
+			return errors.Wrap(err, "reading the input")
+		}
+	}
+}
+
+func newRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		out := os.Stdout
+
+		// keep the standard output for the protocol and print the logs to the standard error
+		os.Stdout = os.Stderr
+		color.Output = os.Stderr
+
+		return newServer(ctx, out).serve(os.Stdin)
+	}
+}
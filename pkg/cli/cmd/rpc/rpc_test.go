@@ -0,0 +1,157 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/pkg/errors"
+)
+
+var paths context.Paths = context.Paths{
+	Home:   "../../tmp",
+	Cache:  "../../tmp",
+	Config: "../../tmp",
+	Data:   "../../tmp",
+}
+
+// run serves the given input and returns the output lines
+func run(t *testing.T, ctx context.DnoteCtx, input string) []string {
+	var out bytes.Buffer
+	if err := newServer(ctx, &out).serve(strings.NewReader(input)); err != nil {
+		t.Fatal(errors.Wrap(err, "serving"))
+	}
+
+	return strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+}
+
+func TestServe_Errors(t *testing.T) {
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    `{"jsonrpc": "2.0", "id": 1, "method": "notes.search"`,
+			expected: `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`,
+		},
+		{
+			input:    `{"jsonrpc": "1.0", "id": 1, "method": "notes.search"}`,
+			expected: `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"invalid request"}}`,
+		},
+		{
+			input:    `[]`,
+			expected: `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid request"}}`,
+		},
+		{
+			input:    `{"jsonrpc": "2.0", "id": "a", "method": "foo"}`,
+			expected: `{"jsonrpc":"2.0","id":"a","error":{"code":-32601,"message":"method 'foo' not found"}}`,
+		},
+		{
+			input:    `{"jsonrpc": "2.0", "id": 2, "method": "notes.read", "params": {"id": 100}}`,
+			expected: `{"jsonrpc":"2.0","id":2,"error":{"code":-32001,"message":"not found"}}`,
+		},
+		{
+			input:    `{"jsonrpc": "2.0", "id": 3, "method": "notes.create", "params": {"book": "js", "content": ""}}`,
+			expected: `{"jsonrpc":"2.0","id":3,"error":{"code":-32602,"message":"content is empty"}}`,
+		},
+		{
+			input:    `{"jsonrpc": "2.0", "id": 4, "method": "notes.move", "params": {"id": "1"}}`,
+			expected: `{"jsonrpc":"2.0","id":4,"error":{"code":-32602,"message":"invalid params: json: cannot unmarshal string into Go struct field moveParams.id of type int"}}`,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			result := run(t, ctx, tc.input)
+
+			assert.DeepEqual(t, result, []string{tc.expected}, "output mismatch")
+		})
+	}
+}
+
+func TestServe_Notes(t *testing.T) {
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "js", 1, false, false)
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b2-uuid", "css", 2, false, false)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "closures in js", 1, 5, false, false)
+
+	var rowID int
+	database.MustScan(t, "getting n1 rowid", db.QueryRow("SELECT rowid FROM notes WHERE uuid = ?", "n1-uuid"), &rowID)
+
+	input := fmt.Sprintf(`{"jsonrpc": "2.0", "id": 1, "method": "notes.search", "params": {"query": "closures"}}
+{"jsonrpc": "2.0", "method": "notes.update", "params": {"id": %[1]d, "content": "closures edited"}}
+[{"jsonrpc": "2.0", "id": 2, "method": "notes.move", "params": {"id": %[1]d, "book": "css"}}, {"jsonrpc": "2.0", "id": 3, "method": "books.list"}]
+{"jsonrpc": "2.0", "id": 4, "method": "notes.delete", "params": {"id": %[1]d}}
+`, rowID)
+
+	result := run(t, ctx, input)
+	assert.Equal(t, len(result), 3, "output length mismatch")
+
+	var search struct {
+		ID     int `json:"id"`
+		Result []struct {
+			UUID string `json:"uuid"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(result[0]), &search); err != nil {
+		t.Fatal(errors.Wrap(err, "unmarshalling the search response"))
+	}
+	assert.Equal(t, search.ID, 1, "search id mismatch")
+	assert.Equal(t, len(search.Result), 1, "search result length mismatch")
+	assert.Equal(t, search.Result[0].UUID, "n1-uuid", "search result mismatch")
+
+	var batch []struct {
+		ID     int             `json:"id"`
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(result[1]), &batch); err != nil {
+		t.Fatal(errors.Wrap(err, "unmarshalling the batch response"))
+	}
+	assert.Equal(t, len(batch), 2, "batch length mismatch")
+	assert.Equal(t, batch[0].ID, 2, "move id mismatch")
+	assert.Equal(t, batch[1].ID, 3, "list books id mismatch")
+
+	var moved struct {
+		BookLabel string `json:"book"`
+		Content   string `json:"content"`
+	}
+	if err := json.Unmarshal(batch[0].Result, &moved); err != nil {
+		t.Fatal(errors.Wrap(err, "unmarshalling the moved note"))
+	}
+	assert.Equal(t, moved.BookLabel, "css", "moved book mismatch")
+	assert.Equal(t, moved.Content, "closures edited", "moved content mismatch")
+
+	var deleted, dirty bool
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT deleted, dirty FROM notes WHERE uuid = ?", "n1-uuid"), &deleted, &dirty)
+	assert.Equal(t, deleted, true, "deleted mismatch")
+	assert.Equal(t, dirty, true, "dirty mismatch")
+}
@@ -0,0 +1,311 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package serve
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	gosync "sync"
+
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/operations"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// server handles the requests to the local API
+type server struct {
+	ctx   context.DnoteCtx
+	token string
+
+	// mu serializes the requests so that they do not contend for the database
+	mu gosync.Mutex
+}
+
+func respondJSON(w http.ResponseWriter, statusCode int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		log.Debug("encoding the response: %s\n", err)
+	}
+}
+
+// respondError responds with the status code matching the error
+func respondError(w http.ResponseWriter, err error) {
+	cause := errors.Cause(err)
+
+	if cause == operations.ErrNotFound {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if e, ok := cause.(operations.InvalidError); ok {
+		http.Error(w, e.Message, http.StatusBadRequest)
+		return
+	}
+
+	log.Errorf("%s\n", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// authenticate checks the bearer token of the request and runs the handler one request at a time
+func (s *server) authenticate(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		credential := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(credential), []byte(s.token)) != 1 {
+			w.Header().Add("WWW-Authenticate", `Bearer realm="Dnote", charset="UTF-8"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		h(w, r)
+	}
+}
+
+func decodeBody(r *http.Request, dest interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(dest); err != nil {
+		return operations.InvalidError{Message: "invalid request body: " + err.Error()}
+	}
+
+	return nil
+}
+
+func getNoteID(r *http.Request) (int, error) {
+	ret, err := strconv.Atoi(mux.Vars(r)["noteID"])
+	if err != nil {
+		return 0, operations.ErrNotFound
+	}
+
+	return ret, nil
+}
+
+func (s *server) getNotes(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	f := operations.NotesFilter{
+		Book:     q.Get("book"),
+		Query:    q.Get("q"),
+		Archived: q.Get("archived") == "true",
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+
+		f.Limit = n
+	}
+
+	notes, err := operations.ListNotes(s.ctx.DB, f)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, notes)
+}
+
+type createNotePayload struct {
+	Book    string `json:"book"`
+	Content string `json:"content"`
+}
+
+func (s *server) createNote(w http.ResponseWriter, r *http.Request) {
+	var p createNotePayload
+	if err := decodeBody(r, &p); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	note, err := operations.CreateNote(s.ctx, operations.NewNoteParams{Book: p.Book, Content: p.Content})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, note)
+}
+
+func (s *server) getNote(w http.ResponseWriter, r *http.Request) {
+	id, err := getNoteID(r)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	note, err := operations.GetNote(s.ctx.DB, id)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, note)
+}
+
+func (s *server) updateNote(w http.ResponseWriter, r *http.Request) {
+	id, err := getNoteID(r)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	var p operations.NoteParams
+	if err := decodeBody(r, &p); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	note, err := operations.UpdateNote(s.ctx, id, p)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, note)
+}
+
+func (s *server) deleteNote(w http.ResponseWriter, r *http.Request) {
+	id, err := getNoteID(r)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	note, err := operations.DeleteNote(s.ctx, id)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, note)
+}
+
+func (s *server) getBooks(w http.ResponseWriter, r *http.Request) {
+	books, err := operations.ListBooks(s.ctx.DB, r.URL.Query().Get("archived") == "true")
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, books)
+}
+
+type createBookPayload struct {
+	Name string `json:"name"`
+}
+
+func (s *server) createBook(w http.ResponseWriter, r *http.Request) {
+	var p createBookPayload
+	if err := decodeBody(r, &p); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	book, err := operations.CreateBook(s.ctx, p.Name)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, book)
+}
+
+func (s *server) getBook(w http.ResponseWriter, r *http.Request) {
+	book, err := operations.GetBook(s.ctx.DB, mux.Vars(r)["bookName"])
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, book)
+}
+
+func (s *server) updateBook(w http.ResponseWriter, r *http.Request) {
+	var p operations.BookParams
+	if err := decodeBody(r, &p); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	book, err := operations.UpdateBook(s.ctx, mux.Vars(r)["bookName"], p)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, book)
+}
+
+func (s *server) deleteBook(w http.ResponseWriter, r *http.Request) {
+	if err := operations.DeleteBook(s.ctx, mux.Vars(r)["bookName"]); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) sync(w http.ResponseWriter, r *http.Request) {
+	summary, err := sync.Run(s.ctx)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, summary)
+}
+
+// newRouter returns the router of the local API that accepts the requests with the token
+func newRouter(ctx context.DnoteCtx, token string) *mux.Router {
+	s := &server{ctx: ctx, token: token}
+
+	routes := []struct {
+		method  string
+		pattern string
+		handler http.HandlerFunc
+	}{
+		{method: "GET", pattern: "/notes", handler: s.getNotes},
+		{method: "POST", pattern: "/notes", handler: s.createNote},
+		{method: "GET", pattern: "/notes/{noteID}", handler: s.getNote},
+		{method: "PATCH", pattern: "/notes/{noteID}", handler: s.updateNote},
+		{method: "DELETE", pattern: "/notes/{noteID}", handler: s.deleteNote},
+		{method: "GET", pattern: "/books", handler: s.getBooks},
+		{method: "POST", pattern: "/books", handler: s.createBook},
+		{method: "GET", pattern: "/books/{bookName}", handler: s.getBook},
+		{method: "PATCH", pattern: "/books/{bookName}", handler: s.updateBook},
+		{method: "DELETE", pattern: "/books/{bookName}", handler: s.deleteBook},
+		{method: "POST", pattern: "/sync", handler: s.sync},
+	}
+
+	router := mux.NewRouter().StrictSlash(true)
+	for _, route := range routes {
+		router.Methods(route.method).Path(route.pattern).Handler(s.authenticate(route.handler))
+	}
+
+	return router
+}
@@ -0,0 +1,196 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/operations"
+	"github.com/pkg/errors"
+)
+
+var paths context.Paths = context.Paths{
+	Home:   "../../tmp",
+	Cache:  "../../tmp",
+	Config: "../../tmp",
+	Data:   "../../tmp",
+}
+
+const testToken = "test-token"
+
+func doRequest(t *testing.T, ctx context.DnoteCtx, method, path, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	newRouter(ctx, testToken).ServeHTTP(w, req)
+
+	return w
+}
+
+func decodeResponse(t *testing.T, w *httptest.ResponseRecorder, dest interface{}) {
+	if err := json.NewDecoder(w.Body).Decode(dest); err != nil {
+		t.Fatal(errors.Wrap(err, "decoding the response"))
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	testCases := []struct {
+		token    string
+		expected int
+	}{
+		{
+			token:    "",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			token:    "wrong-token",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			token:    testToken,
+			expected: http.StatusOK,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			w := doRequest(t, ctx, "GET", "/notes", "", tc.token)
+
+			assert.Equal(t, w.Code, tc.expected, "status code mismatch")
+		})
+	}
+}
+
+func TestNotes(t *testing.T) {
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	// create
+	w := doRequest(t, ctx, "POST", "/notes", `{"book": "js", "content": "closures"}`, testToken)
+	assert.Equal(t, w.Code, http.StatusCreated, "create status code mismatch")
+
+	var created operations.Note
+	decodeResponse(t, w, &created)
+	assert.Equal(t, created.BookLabel, "js", "created book mismatch")
+	assert.Equal(t, created.Content, "closures", "created content mismatch")
+
+	w = doRequest(t, ctx, "POST", "/notes", `{"book": "js"}`, testToken)
+	assert.Equal(t, w.Code, http.StatusBadRequest, "invalid create status code mismatch")
+
+	// list
+	w = doRequest(t, ctx, "GET", "/notes?q=closures", "", testToken)
+	assert.Equal(t, w.Code, http.StatusOK, "list status code mismatch")
+
+	var notes []operations.Note
+	decodeResponse(t, w, &notes)
+	assert.Equal(t, len(notes), 1, "list length mismatch")
+	assert.Equal(t, notes[0].UUID, created.UUID, "listed uuid mismatch")
+
+	// update
+	path := fmt.Sprintf("/notes/%d", created.RowID)
+	w = doRequest(t, ctx, "PATCH", path, `{"content": "closures edited"}`, testToken)
+	assert.Equal(t, w.Code, http.StatusOK, "update status code mismatch")
+
+	var body string
+	database.MustScan(t, "getting the note", ctx.DB.QueryRow("SELECT body FROM notes WHERE uuid = ?", created.UUID), &body)
+	assert.Equal(t, body, "closures edited", "updated content mismatch")
+
+	// delete
+	w = doRequest(t, ctx, "DELETE", path, "", testToken)
+	assert.Equal(t, w.Code, http.StatusOK, "delete status code mismatch")
+
+	w = doRequest(t, ctx, "GET", path, "", testToken)
+	assert.Equal(t, w.Code, http.StatusNotFound, "get status code mismatch")
+}
+
+func TestBooks(t *testing.T) {
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	// create
+	w := doRequest(t, ctx, "POST", "/books", `{"name": "js"}`, testToken)
+	assert.Equal(t, w.Code, http.StatusCreated, "create status code mismatch")
+
+	w = doRequest(t, ctx, "POST", "/books", `{"name": "js"}`, testToken)
+	assert.Equal(t, w.Code, http.StatusBadRequest, "duplicate create status code mismatch")
+
+	// update
+	w = doRequest(t, ctx, "PATCH", "/books/js", `{"name": "javascript", "pinned": true}`, testToken)
+	assert.Equal(t, w.Code, http.StatusOK, "update status code mismatch")
+
+	var book operations.Book
+	decodeResponse(t, w, &book)
+	assert.Equal(t, book.Name, "javascript", "updated name mismatch")
+	assert.Equal(t, book.Pinned, true, "updated pinned mismatch")
+
+	// list
+	w = doRequest(t, ctx, "GET", "/books", "", testToken)
+	assert.Equal(t, w.Code, http.StatusOK, "list status code mismatch")
+
+	var books []operations.Book
+	decodeResponse(t, w, &books)
+	assert.Equal(t, len(books), 1, "list length mismatch")
+	assert.Equal(t, books[0].Name, "javascript", "listed name mismatch")
+
+	// delete
+	w = doRequest(t, ctx, "DELETE", "/books/javascript", "", testToken)
+	assert.Equal(t, w.Code, http.StatusNoContent, "delete status code mismatch")
+
+	w = doRequest(t, ctx, "GET", "/books/javascript", "", testToken)
+	assert.Equal(t, w.Code, http.StatusNotFound, "get status code mismatch")
+}
+
+func TestIsLocal(t *testing.T) {
+	testCases := []struct {
+		addr     string
+		expected bool
+	}{
+		{addr: "127.0.0.1:3030", expected: true},
+		{addr: "localhost:3030", expected: true},
+		{addr: "[::1]:3030", expected: true},
+		{addr: "0.0.0.0:3030", expected: false},
+		{addr: ":3030", expected: false},
+		{addr: "192.168.0.2:3030", expected: false},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			result, err := isLocal(tc.addr)
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "executing"))
+			}
+
+			assert.Equal(t, result, tc.expected, "result mismatch")
+		})
+	}
+}
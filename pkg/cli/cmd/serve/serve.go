@@ -0,0 +1,167 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package serve
+
+import (
+	gocontext "context"
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/infra"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var example = `
+ * Serve the local notes on the default address
+ dnote serve
+
+ * Serve on another port
+ dnote serve --addr 127.0.0.1:4000`
+
+var addrFlag string
+var allowRemoteFlag bool
+
+// NewCmd returns a new serve command
+func NewCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "serve",
+		Short:   "Serve the local notes over an HTTP API",
+		Example: example,
+		RunE:    newRun(ctx),
+	}
+
+	f := cmd.Flags()
+	f.StringVarP(&addrFlag, "addr", "", "127.0.0.1:3030", "The address to listen on")
+	f.BoolVarP(&allowRemoteFlag, "allow-remote", "", false, "Allow listening on an address other than localhost")
+
+	return cmd
+}
+
+// getTokenPath returns the path to the file containing the token
+func getTokenPath(ctx context.DnoteCtx) string {
+	return filepath.Join(ctx.Paths.Config, consts.DnoteDirName, consts.ServeTokenFilename)
+}
+
+// getToken returns the token that the clients need to send, generating one if it
+// does not exist yet
+func getToken(ctx context.DnoteCtx) (string, error) {
+	path := getTokenPath(ctx)
+
+	b, err := ioutil.ReadFile(path)
+	if err == nil {
+		if token := strings.TrimSpace(string(b)); token != "" {
+			return token, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", errors.Wrap(err, "reading the token")
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.Wrap(err, "generating a token")
+	}
+	token := hex.EncodeToString(buf)
+
+	if err := ioutil.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", errors.Wrap(err, "writing the token")
+	}
+
+	return token, nil
+}
+
+// isLocal returns true if the address listens only on the loopback interface
+func isLocal(addr string) (bool, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false, errors.Wrap(err, "parsing the address")
+	}
+
+	if host == "localhost" {
+		return true, nil
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback(), nil
+}
+
+func newRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		local, err := isLocal(addrFlag)
+		if err != nil {
+			return err
+		}
+		if !local && !allowRemoteFlag {
+			return errors.Errorf("%s is not a localhost address. Use --allow-remote to listen on it", addrFlag)
+		}
+
+		token, err := getToken(ctx)
+		if err != nil {
+			return err
+		}
+
+		srv := &http.Server{
+			Addr:    addrFlag,
+			Handler: newRouter(ctx, token),
+		}
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- srv.ListenAndServe()
+		}()
+
+		log.Infof("listening on http://%s\n", addrFlag)
+		log.Infof("token: %s\n", getTokenPath(ctx))
+
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(sigs)
+
+		select {
+		case err := <-errCh:
+			return errors.Wrap(err, "serving")
+		case <-sigs:
+		}
+
+		c, cancel := gocontext.WithTimeout(gocontext.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := srv.Shutdown(c); err != nil {
+			return errors.Wrap(err, "shutting down the server")
+		}
+
+		log.Plain("\n")
+		log.Info("stopped serving\n")
+
+		return nil
+	}
+}
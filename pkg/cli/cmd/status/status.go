@@ -0,0 +1,319 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package status
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/dnote/dnote/pkg/cli/client"
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/infra"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var example = `
+  * Show the changes that have not been synced yet
+  dnote status
+
+  * Also check how far behind the server the local data is
+  dnote status --remote`
+
+var remoteFlag bool
+
+// NewCmd returns a new status command
+func NewCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "status",
+		Short:   "Show unsynced changes and the sync status",
+		Example: example,
+		RunE:    newRun(ctx),
+	}
+
+	f := cmd.Flags()
+	f.BoolVarP(&remoteFlag, "remote", "r", false, "check the state of the server.")
+
+	return cmd
+}
+
+const (
+	changeNew      = "new"
+	changeModified = "modified"
+	changeDeleted  = "deleted"
+)
+
+var timeFormat = "Jan 2, 2006 3:04pm (MST)"
+
+// getChangeKind returns the kind of the unsynced change to a resource
+func getChangeKind(usn int, deleted bool) string {
+	if deleted {
+		return changeDeleted
+	}
+	if usn == 0 {
+		return changeNew
+	}
+
+	return changeModified
+}
+
+// pendingBook is a book with changes that have not been synced
+type pendingBook struct {
+	Label string
+	Kind  string
+}
+
+// pendingNote is a note with changes that have not been synced
+type pendingNote struct {
+	RowID     int
+	BookLabel string
+	Body      string
+	Kind      string
+}
+
+func getPendingBooks(db *database.DB) ([]pendingBook, error) {
+	rows, err := db.Query("SELECT label, usn, deleted FROM books WHERE dirty AND NOT local_only ORDER BY label ASC")
+	if err != nil {
+		return nil, errors.Wrap(err, "querying books")
+	}
+	defer rows.Close()
+
+	ret := []pendingBook{}
+	for rows.Next() {
+		var b pendingBook
+		var usn int
+		var deleted bool
+		if err := rows.Scan(&b.Label, &usn, &deleted); err != nil {
+			return nil, errors.Wrap(err, "scanning a row")
+		}
+
+		b.Kind = getChangeKind(usn, deleted)
+		ret = append(ret, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating books")
+	}
+
+	return ret, nil
+}
+
+func getPendingNotes(db *database.DB) ([]pendingNote, error) {
+	rows, err := db.Query(`SELECT notes.rowid, books.label, notes.body, notes.usn, notes.deleted
+	FROM notes
+	LEFT JOIN books ON books.uuid = notes.book_uuid
+	WHERE notes.dirty AND NOT coalesce(books.local_only, false)
+	ORDER BY notes.added_on ASC`)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying notes")
+	}
+	defer rows.Close()
+
+	ret := []pendingNote{}
+	for rows.Next() {
+		var n pendingNote
+		var label sql.NullString
+		var usn int
+		var deleted bool
+		if err := rows.Scan(&n.RowID, &label, &n.Body, &usn, &deleted); err != nil {
+			return nil, errors.Wrap(err, "scanning a row")
+		}
+
+		n.BookLabel = label.String
+		n.Kind = getChangeKind(usn, deleted)
+		ret = append(ret, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating notes")
+	}
+
+	return ret, nil
+}
+
+// getFirstLine returns the first non-empty line of the given text
+func getFirstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			return trimmed
+		}
+	}
+
+	return ""
+}
+
+// syncInfo is the local record of the last sync
+type syncInfo struct {
+	LastSyncAt int64
+	LastMaxUSN int
+}
+
+func getSyncInfo(db *database.DB) (syncInfo, error) {
+	var ret syncInfo
+
+	if err := database.GetSystem(db, consts.SystemLastSyncAt, &ret.LastSyncAt); err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return ret, errors.Wrap(err, "getting the last sync time")
+	}
+	if err := database.GetSystem(db, consts.SystemLastMaxUSN, &ret.LastMaxUSN); err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return ret, errors.Wrap(err, "getting the last max_usn")
+	}
+
+	return ret, nil
+}
+
+func printSession(ctx context.DnoteCtx) {
+	log.Infof("endpoint: %s\n", ctx.APIEndpoint)
+
+	if client.IsFileEndpoint(ctx.APIEndpoint) {
+		log.Infof("session: not required for a directory\n")
+		return
+	}
+	if ctx.SessionKey == "" {
+		log.Infof("session: not logged in\n")
+		return
+	}
+
+	expiry := time.Unix(ctx.SessionKeyExpiry, 0)
+	if ctx.SessionKeyExpiry < ctx.Clock.Now().Unix() {
+		log.Warnf("session: expired at %s\n", expiry.Format(timeFormat))
+	} else {
+		log.Infof("session: expires at %s\n", expiry.Format(timeFormat))
+	}
+}
+
+func printSyncInfo(ctx context.DnoteCtx, info syncInfo) {
+	if info.LastSyncAt == 0 {
+		log.Infof("last sync: never\n")
+	} else {
+		log.Infof("last sync: %s (max usn %d)\n", time.Unix(info.LastSyncAt, 0).Format(timeFormat), info.LastMaxUSN)
+	}
+
+	s, err := sync.ReadStatus(ctx)
+	if err != nil {
+		log.Debug("reading the sync status: %s\n", err)
+		return
+	}
+	if s.Watching {
+		log.Infof("watching for changes (pid %d)\n", s.PID)
+	}
+	if s.Failures > 0 {
+		log.Warnf("the last %d sync attempts failed: %s\n", s.Failures, s.LastError)
+	}
+}
+
+func printRemote(ctx context.DnoteCtx, info syncInfo) error {
+	if !client.IsAuthorized(ctx) {
+		return errors.New("not logged in")
+	}
+
+	state, err := client.GetSyncState(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting the sync state from the server")
+	}
+
+	behind := state.MaxUSN - info.LastMaxUSN
+	if behind > 0 {
+		log.Infof("server: %d changes behind (max usn %d)\n", behind, state.MaxUSN)
+	} else {
+		log.Infof("server: up to date (max usn %d)\n", state.MaxUSN)
+	}
+
+	if info.LastSyncAt < int64(state.FullSyncBefore) {
+		log.Warnf("a full sync will be performed on the next sync\n")
+	}
+
+	return nil
+}
+
+func printPending(books []pendingBook, notes []pendingNote) {
+	if len(books) == 0 && len(notes) == 0 {
+		log.Plain("\n")
+		log.Plain("nothing to sync\n")
+		return
+	}
+
+	if len(books) > 0 {
+		log.Plain("\n")
+		log.Plain("books to be synced:\n")
+		for _, b := range books {
+			log.Printf("%-9s %s\n", b.Kind+":", b.Label)
+		}
+	}
+
+	if len(notes) > 0 {
+		log.Plain("\n")
+		log.Plain("notes to be synced:\n")
+		for _, n := range notes {
+			log.Printf("%-9s (%s) %s %s\n", n.Kind+":", n.BookLabel, log.ColorYellow.Sprintf("(%d)", n.RowID), getFirstLine(n.Body))
+		}
+	}
+}
+
+func printOutbox(items []sync.OutboxItem) {
+	if len(items) == 0 {
+		return
+	}
+
+	log.Plain("\n")
+	log.Warnf("changes that failed to be sent (run `dnote sync --retry-failed` to retry):\n")
+	for _, item := range items {
+		log.Printf("%s %s (%d attempts): %s\n", item.Type, item.UUID, item.Attempts, item.Error)
+	}
+}
+
+func newRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		info, err := getSyncInfo(ctx.DB)
+		if err != nil {
+			return errors.Wrap(err, "getting the sync information")
+		}
+
+		printSession(ctx)
+		printSyncInfo(ctx, info)
+
+		if remoteFlag {
+			if err := printRemote(ctx, info); err != nil {
+				return errors.Wrap(err, "checking the server")
+			}
+		}
+
+		books, err := getPendingBooks(ctx.DB)
+		if err != nil {
+			return errors.Wrap(err, "getting unsynced books")
+		}
+		notes, err := getPendingNotes(ctx.DB)
+		if err != nil {
+			return errors.Wrap(err, "getting unsynced notes")
+		}
+
+		printPending(books, notes)
+
+		outbox, err := sync.GetOutbox(ctx.DB)
+		if err != nil {
+			return errors.Wrap(err, "getting the outbox")
+		}
+
+		printOutbox(outbox)
+
+		return nil
+	}
+}
@@ -0,0 +1,123 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package status
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/pkg/errors"
+)
+
+func TestGetChangeKind(t *testing.T) {
+	testCases := []struct {
+		usn      int
+		deleted  bool
+		expected string
+	}{
+		{
+			usn:      0,
+			deleted:  false,
+			expected: changeNew,
+		},
+		{
+			usn:      3,
+			deleted:  false,
+			expected: changeModified,
+		},
+		{
+			usn:      3,
+			deleted:  true,
+			expected: changeDeleted,
+		},
+		{
+			usn:      0,
+			deleted:  true,
+			expected: changeDeleted,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			assert.Equal(t, getChangeKind(tc.usn, tc.deleted), tc.expected, "result mismatch")
+		})
+	}
+}
+
+func TestGetPending(t *testing.T) {
+	// set up
+	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+	defer database.TeardownTestDB(t, db)
+
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "b1-label", 0, false, true)
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b2-uuid", "b2-label", 5, false, false)
+	database.MustExec(t, "inserting b3", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b3-uuid", "b3-label", 6, true, true)
+	database.MustExec(t, "inserting b4", db, "INSERT INTO books (uuid, label, usn, deleted, dirty, local_only) VALUES (?, ?, ?, ?, ?, ?)", "b4-uuid", "b4-label", 0, false, true, true)
+
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "\nn1 title\nn1 body", 1, 0, false, true)
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "b2-uuid", "n2 body", 2, 7, false, true)
+	database.MustExec(t, "inserting n3", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n3-uuid", "b2-uuid", "n3 body", 3, 8, false, false)
+	database.MustExec(t, "inserting n4", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n4-uuid", "b2-uuid", "", 4, 9, true, true)
+	database.MustExec(t, "inserting n5", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n5-uuid", "b4-uuid", "n5 body", 5, 0, false, true)
+
+	// execute
+	books, err := getPendingBooks(db)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting pending books"))
+	}
+	notes, err := getPendingNotes(db)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting pending notes"))
+	}
+
+	// test
+	assert.DeepEqual(t, books, []pendingBook{
+		{Label: "b1-label", Kind: changeNew},
+		{Label: "b3-label", Kind: changeDeleted},
+	}, "books mismatch")
+
+	assert.Equal(t, len(notes), 3, "note count mismatch")
+	assert.Equal(t, notes[0].BookLabel, "b1-label", "n1 BookLabel mismatch")
+	assert.Equal(t, notes[0].Kind, changeNew, "n1 Kind mismatch")
+	assert.Equal(t, getFirstLine(notes[0].Body), "n1 title", "n1 first line mismatch")
+	assert.Equal(t, notes[1].BookLabel, "b2-label", "n2 BookLabel mismatch")
+	assert.Equal(t, notes[1].Kind, changeModified, "n2 Kind mismatch")
+	assert.Equal(t, notes[2].Kind, changeDeleted, "n4 Kind mismatch")
+}
+
+func TestGetSyncInfo(t *testing.T) {
+	// set up
+	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+	defer database.TeardownTestDB(t, db)
+
+	database.MustExec(t, "inserting last sync at", db, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastSyncAt, 1541108743)
+	database.MustExec(t, "inserting last max usn", db, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastMaxUSN, 12)
+
+	// execute
+	got, err := getSyncInfo(db)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	// test
+	assert.Equal(t, got, syncInfo{LastSyncAt: 1541108743, LastMaxUSN: 12}, "result mismatch")
+}
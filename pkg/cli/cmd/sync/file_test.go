@@ -0,0 +1,95 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sync
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/pkg/errors"
+)
+
+// initFileSyncCtx initializes a machine that syncs with the given directory
+func initFileSyncCtx(t *testing.T, p context.Paths, dir string) context.DnoteCtx {
+	ctx := context.InitTestCtx(t, p, nil)
+	ctx.APIEndpoint = "file://" + dir
+
+	database.MustExec(t, "inserting last max usn", ctx.DB, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastMaxUSN, 0)
+	database.MustExec(t, "inserting last sync at", ctx.DB, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastSyncAt, 0)
+
+	return ctx
+}
+
+func mustSync(t *testing.T, ctx context.DnoteCtx, message string) {
+	if err := doSync(ctx, syncOptions{}, &syncReport{}); err != nil {
+		t.Fatal(errors.Wrap(err, message))
+	}
+}
+
+func getNoteBodyInBook(t *testing.T, db *database.DB, label string) string {
+	var ret string
+	database.MustScan(t, "getting the note body", db.QueryRow(`SELECT notes.body FROM notes
+		INNER JOIN books ON books.uuid = notes.book_uuid
+		WHERE books.label = ?`, label), &ret)
+
+	return ret
+}
+
+func TestDoSync_fileBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dnote-sync")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating the sync directory"))
+	}
+	defer os.RemoveAll(dir)
+
+	ctxA := initFileSyncCtx(t, paths, dir)
+	defer context.TeardownTestCtx(t, ctxA)
+	pathsB := context.Paths{Home: testDir + "/b", Cache: testDir + "/b", Config: testDir + "/b", Data: testDir + "/b"}
+	ctxB := initFileSyncCtx(t, pathsB, dir)
+	defer context.TeardownTestCtx(t, ctxB)
+
+	database.MustExec(t, "inserting b1", ctxA.DB, "INSERT INTO books (uuid, label, usn, dirty) VALUES (?, ?, ?, ?)", "b1-uuid", "js", 0, true)
+	database.MustExec(t, "inserting n1", ctxA.DB, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, dirty) VALUES (?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "line 1\nline 2\nline 3\n", 1541108743, 0, true)
+
+	mustSync(t, ctxA, "syncing a")
+	mustSync(t, ctxB, "syncing b")
+
+	assert.Equal(t, getNoteBodyInBook(t, ctxB.DB, "js"), "line 1\nline 2\nline 3\n", "synced body mismatch")
+
+	// edit different lines of the note on both machines
+	database.MustExec(t, "editing the note in a", ctxA.DB, "UPDATE notes SET body = ?, dirty = ?", "line 1 by a\nline 2\nline 3\n", true)
+	database.MustExec(t, "editing the note in b", ctxB.DB, "UPDATE notes SET body = ?, dirty = ?", "line 1\nline 2\nline 3 by b\n", true)
+
+	mustSync(t, ctxA, "syncing the edit in a")
+	mustSync(t, ctxB, "syncing the edit in b")
+	mustSync(t, ctxA, "syncing the merged note in a")
+
+	expected := "line 1 by a\nline 2\nline 3 by b\n"
+	assert.Equal(t, getNoteBodyInBook(t, ctxB.DB, "js"), expected, "merged body in b mismatch")
+	assert.Equal(t, getNoteBodyInBook(t, ctxA.DB, "js"), expected, "merged body in a mismatch")
+
+	var dirtyCount int
+	database.MustScan(t, "counting dirty notes", ctxA.DB.QueryRow("SELECT count(*) FROM notes WHERE dirty"), &dirtyCount)
+	assert.Equal(t, dirtyCount, 0, "dirty count mismatch")
+}
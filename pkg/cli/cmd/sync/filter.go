@@ -0,0 +1,209 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sync
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dnote/dnote/pkg/cli/client"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/pkg/errors"
+)
+
+// bookFilter decides which of the books in the server are synced to this machine.
+// Notes are synced if their books are.
+type bookFilter struct {
+	include map[string]bool
+	exclude map[string]bool
+	// books maps the uuids of the server books to whether they are synced
+	books map[string]bool
+}
+
+func newBookFilter(include, exclude []string) *bookFilter {
+	ret := &bookFilter{
+		include: map[string]bool{},
+		exclude: map[string]bool{},
+		books:   map[string]bool{},
+	}
+
+	for _, label := range include {
+		ret.include[label] = true
+	}
+	for _, label := range exclude {
+		ret.exclude[label] = true
+	}
+
+	return ret
+}
+
+// isActive returns true if the filter leaves out any books
+func (f *bookFilter) isActive() bool {
+	return len(f.include) > 0 || len(f.exclude) > 0
+}
+
+// matchLabel returns true if the book with the given label should be synced
+func (f *bookFilter) matchLabel(label string) bool {
+	if len(f.include) > 0 && !f.include[label] {
+		return false
+	}
+
+	return !f.exclude[label]
+}
+
+func getSortedKeys(m map[string]bool) []string {
+	ret := []string{}
+	for key := range m {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+// String returns a canonical representation of the filter, which is empty if the
+// filter is not active
+func (f *bookFilter) String() string {
+	if !f.isActive() {
+		return ""
+	}
+
+	include := strings.Join(getSortedKeys(f.include), ",")
+	exclude := strings.Join(getSortedKeys(f.exclude), ",")
+
+	return fmt.Sprintf("include=%s;exclude=%s", include, exclude)
+}
+
+// load fetches the books in the server in order to tell which book the notes
+// in the sync fragments belong to
+func (f *bookFilter) load(ctx context.DnoteCtx) error {
+	if !f.isActive() {
+		return nil
+	}
+
+	books, err := client.GetBooks(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting books from the server")
+	}
+
+	for _, book := range books {
+		f.books[book.UUID] = f.matchLabel(book.Label)
+	}
+
+	return nil
+}
+
+// apply removes from the given sync list the books that are not synced and their notes.
+// Notes that were moved into such books are marked as expunged, so that the local copies
+// are removed unless they have changes that are yet to be uploaded.
+func (f *bookFilter) apply(list *syncList) {
+	if !f.isActive() {
+		return
+	}
+
+	for uuid, book := range list.Books {
+		ok := f.matchLabel(book.Label)
+		f.books[uuid] = ok
+
+		if !ok {
+			delete(list.Books, uuid)
+		}
+	}
+
+	for uuid, note := range list.Notes {
+		if f.books[note.BookUUID] {
+			continue
+		}
+
+		delete(list.Notes, uuid)
+		list.ExpungedNotes[uuid] = true
+	}
+}
+
+// getLastSyncFilter returns the representation of the book filter used at the last sync
+func getLastSyncFilter(tx *database.DB) (string, error) {
+	var ret string
+
+	err := database.GetSystem(tx, consts.SystemSyncFilter, &ret)
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return ret, errors.Wrap(err, "querying the last sync filter")
+	}
+
+	return ret, nil
+}
+
+func saveSyncFilter(tx *database.DB, f *bookFilter) error {
+	if err := database.UpsertSystem(tx, consts.SystemSyncFilter, f.String()); err != nil {
+		return errors.Wrap(err, "saving the sync filter")
+	}
+
+	return nil
+}
+
+// cleanFilteredBooks removes from this machine the books that are no longer synced
+// and their notes, unless they have changes that are yet to be uploaded.
+func cleanFilteredBooks(tx *database.DB, f *bookFilter) error {
+	if !f.isActive() {
+		return nil
+	}
+
+	rows, err := tx.Query("SELECT uuid, label FROM books WHERE usn > 0 AND NOT dirty AND NOT local_only")
+	if err != nil {
+		return errors.Wrap(err, "getting local books")
+	}
+	defer rows.Close()
+
+	var uuids []string
+	for rows.Next() {
+		var uuid, label string
+		if err := rows.Scan(&uuid, &label); err != nil {
+			return errors.Wrap(err, "scanning a row for local book")
+		}
+
+		if !f.matchLabel(label) {
+			uuids = append(uuids, uuid)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "iterating local books")
+	}
+
+	for _, uuid := range uuids {
+		ok, err := checkNotesPristine(tx, uuid)
+		if err != nil {
+			return errors.Wrap(err, "checking if any notes are dirty in book")
+		}
+		if !ok {
+			continue
+		}
+
+		if _, err := tx.Exec("DELETE FROM notes WHERE book_uuid = ?", uuid); err != nil {
+			return errors.Wrapf(err, "deleting local notes of the book %s", uuid)
+		}
+		if _, err := tx.Exec("DELETE FROM books WHERE uuid = ?", uuid); err != nil {
+			return errors.Wrapf(err, "deleting local book %s", uuid)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sync
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/client"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/testutils"
+	"github.com/pkg/errors"
+)
+
+func TestBookFilterMatchLabel(t *testing.T) {
+	testCases := []struct {
+		include  []string
+		exclude  []string
+		label    string
+		expected bool
+	}{
+		{
+			include:  nil,
+			exclude:  nil,
+			label:    "js",
+			expected: true,
+		},
+		{
+			include:  []string{"js", "linux"},
+			exclude:  nil,
+			label:    "js",
+			expected: true,
+		},
+		{
+			include:  []string{"js", "linux"},
+			exclude:  nil,
+			label:    "go",
+			expected: false,
+		},
+		{
+			include:  nil,
+			exclude:  []string{"js"},
+			label:    "js",
+			expected: false,
+		},
+		{
+			include:  nil,
+			exclude:  []string{"js"},
+			label:    "go",
+			expected: true,
+		},
+		{
+			include:  []string{"js", "linux"},
+			exclude:  []string{"js"},
+			label:    "js",
+			expected: false,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			f := newBookFilter(tc.include, tc.exclude)
+			assert.Equal(t, f.matchLabel(tc.label), tc.expected, "result mismatch")
+		})
+	}
+}
+
+func TestBookFilterString(t *testing.T) {
+	assert.Equal(t, newBookFilter(nil, nil).String(), "", "inactive filter mismatch")
+	assert.Equal(t, newBookFilter([]string{"linux", "js"}, nil).String(), "include=js,linux;exclude=", "include mismatch")
+	assert.Equal(t, newBookFilter([]string{"js", "linux"}, []string{"go"}).String(), "include=js,linux;exclude=go", "include and exclude mismatch")
+}
+
+func TestBookFilterApply(t *testing.T) {
+	f := newBookFilter([]string{"js"}, nil)
+	f.books["b1-uuid"] = true
+	f.books["b2-uuid"] = false
+
+	list := syncList{
+		Notes: map[string]client.SyncFragNote{
+			"n1-uuid": {UUID: "n1-uuid", BookUUID: "b1-uuid"},
+			"n2-uuid": {UUID: "n2-uuid", BookUUID: "b2-uuid"},
+			"n3-uuid": {UUID: "n3-uuid", BookUUID: "b3-uuid"},
+			"n4-uuid": {UUID: "n4-uuid", BookUUID: "b4-uuid"},
+		},
+		Books: map[string]client.SyncFragBook{
+			// renamed into an included book
+			"b3-uuid": {UUID: "b3-uuid", Label: "js"},
+			"b4-uuid": {UUID: "b4-uuid", Label: "go"},
+		},
+		ExpungedNotes: map[string]bool{},
+		ExpungedBooks: map[string]bool{
+			"b5-uuid": true,
+		},
+	}
+
+	// execute
+	f.apply(&list)
+
+	// test
+	assert.Equal(t, len(list.Books), 1, "books length mismatch")
+	assert.Equal(t, list.Books["b3-uuid"].Label, "js", "b3 mismatch")
+	assert.Equal(t, len(list.Notes), 2, "notes length mismatch")
+	assert.Equal(t, list.Notes["n1-uuid"].UUID, "n1-uuid", "n1 mismatch")
+	assert.Equal(t, list.Notes["n3-uuid"].UUID, "n3-uuid", "n3 mismatch")
+	assert.DeepEqual(t, list.ExpungedNotes, map[string]bool{"n2-uuid": true, "n4-uuid": true}, "expunged notes mismatch")
+	assert.DeepEqual(t, list.ExpungedBooks, map[string]bool{"b5-uuid": true}, "expunged books mismatch")
+}
+
+func TestGetLastSyncFilter(t *testing.T) {
+	// set up
+	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+	defer database.TeardownTestDB(t, db)
+
+	got, err := getLastSyncFilter(db)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the filter before saving"))
+	}
+	assert.Equal(t, got, "", "initial filter mismatch")
+
+	// execute
+	if err := saveSyncFilter(db, newBookFilter([]string{"js"}, nil)); err != nil {
+		t.Fatal(errors.Wrap(err, "saving the filter"))
+	}
+
+	// test
+	got, err = getLastSyncFilter(db)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the filter after saving"))
+	}
+	assert.Equal(t, got, "include=js;exclude=", "saved filter mismatch")
+}
+
+func TestFullSync_filter(t *testing.T) {
+	ts := newFragmentServer(t, map[string]client.SyncFragment{
+		"0": {
+			FragMaxUSN:  8,
+			UserMaxUSN:  8,
+			CurrentTime: 1550436136,
+			Notes: []client.SyncFragNote{
+				{UUID: "n1-uuid", BookUUID: "b1-uuid", USN: 3, Body: "n1 body", AddedOn: 1541108743},
+				{UUID: "n2-uuid", BookUUID: "b2-uuid", USN: 4, Body: "n2 body", AddedOn: 1541108743},
+				{UUID: "n3-uuid", BookUUID: "b3-uuid", USN: 6, Body: "n3 body", AddedOn: 1541108743},
+				{UUID: "n4-uuid", BookUUID: "b4-uuid", USN: 8, Body: "n4 body", AddedOn: 1541108743},
+			},
+			Books: []client.SyncFragBook{
+				{UUID: "b1-uuid", USN: 1, Label: "js"},
+				{UUID: "b2-uuid", USN: 2, Label: "go"},
+				{UUID: "b3-uuid", USN: 5, Label: "linux"},
+				{UUID: "b4-uuid", USN: 7, Label: "css"},
+			},
+		},
+		"8": {
+			FragMaxUSN:  0,
+			UserMaxUSN:  8,
+			CurrentTime: 1550436137,
+		},
+	})
+	defer ts.Close()
+
+	ctx := context.InitTestCtx(t, paths, nil)
+	ctx.APIEndpoint = ts.URL
+	defer context.TeardownTestCtx(t, ctx)
+	testutils.Login(t, &ctx)
+
+	db := ctx.DB
+	database.MustExec(t, "inserting last max usn", db, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastMaxUSN, 0)
+	database.MustExec(t, "inserting last sync at", db, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastSyncAt, 0)
+
+	// synced before the filter was set up
+	database.MustExec(t, "inserting b3", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b3-uuid", "linux", 5, false, false)
+	database.MustExec(t, "inserting n3", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n3-uuid", "b3-uuid", 6, "n3 body", 1541108743, false, false)
+	database.MustExec(t, "inserting b4", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b4-uuid", "css", 7, false, false)
+	database.MustExec(t, "inserting n4", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n4-uuid", "b4-uuid", 8, "n4 body edited", 1541108743, false, true)
+
+	filter := newBookFilter([]string{"js"}, nil)
+
+	// execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "beginning a transaction"))
+	}
+	if err := fullSync(ctx, tx, filter, &syncReport{}); err != nil {
+		tx.Rollback()
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+	tx.Commit()
+
+	// test
+	var bookLabels []string
+	rows, err := db.Query("SELECT label FROM books ORDER BY label ASC")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting books"))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var label string
+		if err := rows.Scan(&label); err != nil {
+			t.Fatal(errors.Wrap(err, "scanning a book"))
+		}
+		bookLabels = append(bookLabels, label)
+	}
+
+	// b3 is removed because it is not synced, and b4 is kept because it has unsynced changes
+	assert.DeepEqual(t, bookLabels, []string{"css", "js"}, "book labels mismatch")
+
+	var noteCount int
+	database.MustScan(t, "counting notes", db.QueryRow("SELECT count(*) FROM notes"), &noteCount)
+	assert.Equal(t, noteCount, 2, "note count mismatch")
+
+	var n1Body string
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT body FROM notes WHERE uuid = ?", "n1-uuid"), &n1Body)
+	assert.Equal(t, n1Body, "n1 body", "n1 body mismatch")
+}
@@ -0,0 +1,70 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sync
+
+import (
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/hooks"
+	"github.com/pkg/errors"
+)
+
+// getUnsyncedNotes returns the notes with the local changes to be sent, except the
+// removed ones
+func getUnsyncedNotes(db *database.DB) ([]hooks.Note, error) {
+	rows, err := db.Query(`SELECT notes.rowid, notes.uuid, books.label, notes.body
+	FROM notes
+	INNER JOIN books ON books.uuid = notes.book_uuid
+	WHERE notes.dirty AND NOT notes.deleted AND NOT books.local_only
+	ORDER BY notes.added_on ASC`)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying notes")
+	}
+	defer rows.Close()
+
+	var ret []hooks.Note
+	for rows.Next() {
+		var n hooks.Note
+		if err := rows.Scan(&n.RowID, &n.UUID, &n.BookLabel, &n.Content); err != nil {
+			return nil, errors.Wrap(err, "scanning a row")
+		}
+
+		ret = append(ret, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating rows")
+	}
+
+	return ret, nil
+}
+
+// runPreSync runs the pre-sync hook with the unsynced notes. It returns an error
+// if the hook rejects the sync.
+func runPreSync(ctx context.DnoteCtx) error {
+	notes, err := getUnsyncedNotes(ctx.DB)
+	if err != nil {
+		return errors.Wrap(err, "getting the unsynced notes")
+	}
+
+	if _, err := hooks.Pre(ctx, hooks.PreSync, notes); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sync
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/utils"
+	"github.com/pkg/errors"
+)
+
+var (
+	// lockRefreshInterval is how often a running sync refreshes the lock it holds
+	lockRefreshInterval = 30 * time.Second
+	// lockStaleAfter is the duration after which the lock held on another machine is
+	// considered to be left behind if it has not been refreshed. The liveness of the
+	// holder can only be checked on the same machine.
+	lockStaleAfter = 2 * time.Minute
+)
+
+// errSyncInProgress is an error for the sync lock being held by another process
+var errSyncInProgress = errors.New("another sync is in progress")
+
+// lock is a lock file that prevents multiple processes from syncing at the same time
+type lock struct {
+	path string
+	done chan struct{}
+}
+
+// getSyncDir returns the path to the directory containing the files used to
+// coordinate syncs, creating it if it does not exist
+func getSyncDir(ctx context.DnoteCtx) (string, error) {
+	dir := filepath.Join(ctx.Paths.Cache, consts.DnoteDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrapf(err, "creating the directory %s", dir)
+	}
+
+	return dir, nil
+}
+
+// lockOwner identifies the process holding the sync lock
+type lockOwner struct {
+	Hostname string `json:"hostname"`
+	PID      int    `json:"pid"`
+}
+
+func getLockOwner() lockOwner {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Debug("getting the hostname: %s\n", err)
+	}
+
+	return lockOwner{Hostname: hostname, PID: os.Getpid()}
+}
+
+// createLockFile exclusively creates the lock file and writes the owner into it
+func createLockFile(path string, owner lockOwner) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := json.NewEncoder(f).Encode(owner); err != nil {
+		return errors.Wrap(err, "writing the owner")
+	}
+
+	return nil
+}
+
+// isLockAbandoned returns true if the lock was left behind by a process that did not
+// exit cleanly. A lock held on the same machine is abandoned if its holder is no longer
+// running, and one held on another machine if it has not been refreshed for a while.
+func isLockAbandoned(path string, self lockOwner) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, errors.Wrap(err, "checking the existing lock")
+	}
+
+	var owner lockOwner
+	if data, err := ioutil.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &owner); err != nil {
+			log.Debug("unmarshalling the sync lock: %s\n", err)
+		}
+	}
+
+	if owner.Hostname != "" && owner.Hostname == self.Hostname {
+		log.Debug("sync lock is held by pid %d\n", owner.PID)
+
+		return !utils.ProcessExists(owner.PID), nil
+	}
+
+	return time.Since(info.ModTime()) > lockStaleAfter, nil
+}
+
+// acquireLock acquires the sync lock. If the lock is held by another process, it
+// returns errSyncInProgress. A lock left behind by a process that is gone is taken over.
+func acquireLock(ctx context.DnoteCtx) (*lock, error) {
+	dir, err := getSyncDir(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting the sync directory")
+	}
+	path := filepath.Join(dir, consts.SyncLockFilename)
+	owner := getLockOwner()
+
+	err = createLockFile(path, owner)
+	if os.IsExist(err) {
+		abandoned, checkErr := isLockAbandoned(path, owner)
+		if checkErr != nil {
+			return nil, checkErr
+		}
+		if !abandoned {
+			return nil, errSyncInProgress
+		}
+
+		log.Debug("taking over an abandoned sync lock\n")
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrap(err, "removing the abandoned lock")
+		}
+
+		err = createLockFile(path, owner)
+	}
+	if os.IsExist(err) {
+		return nil, errSyncInProgress
+	} else if err != nil {
+		return nil, errors.Wrap(err, "creating the lock file")
+	}
+
+	l := &lock{
+		path: path,
+		done: make(chan struct{}),
+	}
+	go l.keepAlive()
+
+	return l, nil
+}
+
+// keepAlive periodically refreshes the modification time of the lock file so
+// that the processes on other machines do not consider it abandoned
+func (l *lock) keepAlive() {
+	ticker := time.NewTicker(lockRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if err := os.Chtimes(l.path, now, now); err != nil {
+				log.Debug("refreshing the sync lock: %s\n", err)
+			}
+		}
+	}
+}
+
+// release releases the lock
+func (l *lock) release() {
+	close(l.done)
+
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		log.Debug("removing the sync lock: %s\n", err)
+	}
+}
@@ -52,9 +52,9 @@ func sanitize(s string) string {
 	return textBuilder.String()
 }
 
-// reportBodyConflict returns a conflict report of the local and the remote version
+// ReportBodyConflict returns a conflict report of the local and the remote version
 // of a body
-func reportBodyConflict(localBody, remoteBody string) string {
+func ReportBodyConflict(localBody, remoteBody string) string {
 	diffs := diff.Do(localBody, remoteBody)
 
 	var ret strings.Builder
@@ -101,6 +101,45 @@ func reportBodyConflict(localBody, remoteBody string) string {
 	return ret.String()
 }
 
+// reportBodyMerge returns the result of a three-way merge of the local and the remote
+// version of a body, given the body last synced with the server. Changes made on only
+// one side are merged and conflicts are reported only for regions changed on both sides.
+func reportBodyMerge(baseBody, localBody, remoteBody string) string {
+	var ret strings.Builder
+
+	for _, h := range diff.Merge(baseBody, localBody, remoteBody) {
+		if !h.Conflict {
+			ret.WriteString(h.Text)
+			continue
+		}
+
+		ret.WriteString(conflictLabelLocal)
+		if h.Local != "" {
+			ret.WriteString(sanitize(h.Local))
+		}
+		ret.WriteString(conflictLabelDivide)
+		if h.Remote != "" {
+			ret.WriteString(sanitize(h.Remote))
+		}
+		ret.WriteString(conflictLabelServer)
+	}
+
+	return ret.String()
+}
+
+// getNoteBaseBody returns the body of the note as of the last sync. The result is
+// not valid if the note has not been synced since the base body started to be recorded.
+func getNoteBaseBody(tx *database.DB, noteUUID string) (sql.NullString, error) {
+	var ret sql.NullString
+
+	err := tx.QueryRow("SELECT base_body FROM notes WHERE uuid = ?", noteUUID).Scan(&ret)
+	if err != nil && err != sql.ErrNoRows {
+		return ret, errors.Wrapf(err, "getting base body for note %s", noteUUID)
+	}
+
+	return ret, nil
+}
+
 func maxInt64(a, b int64) int64 {
 	if a > b {
 		return a
@@ -160,6 +199,10 @@ type noteMergeReport struct {
 	body     string
 	bookUUID string
 	editedOn int64
+	dueOn    int64
+	remindOn int64
+	pinned   bool
+	archived bool
 }
 
 // mergeNoteFields  performs a field-by-field merge between the local and the server copy. It returns a merge report
@@ -170,10 +213,24 @@ func mergeNoteFields(tx *database.DB, localNote database.Note, serverNote client
 			body:     serverNote.Body,
 			bookUUID: serverNote.BookUUID,
 			editedOn: serverNote.EditedOn,
+			dueOn:    serverNote.DueOn,
+			remindOn: serverNote.RemindOn,
+			pinned:   serverNote.Pinned,
+			archived: serverNote.Archived,
 		}, nil
 	}
 
-	body := reportBodyConflict(localNote.Body, serverNote.Body)
+	baseBody, err := getNoteBaseBody(tx, serverNote.UUID)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting the base body")
+	}
+
+	var body string
+	if baseBody.Valid {
+		body = reportBodyMerge(baseBody.String, localNote.Body, serverNote.Body)
+	} else {
+		body = ReportBodyConflict(localNote.Body, serverNote.Body)
+	}
 
 	var bookUUID string
 	if serverNote.BookUUID != localNote.BookUUID {
@@ -198,6 +255,11 @@ func mergeNoteFields(tx *database.DB, localNote database.Note, serverNote client
 		body:     body,
 		bookUUID: bookUUID,
 		editedOn: maxInt64(localNote.EditedOn, serverNote.EditedOn),
+		// the local dates and states are sent to the server along with the merged body
+		dueOn:    localNote.DueOn,
+		remindOn: localNote.RemindOn,
+		pinned:   localNote.Pinned,
+		archived: localNote.Archived,
 	}
 
 	return &ret, nil
@@ -142,7 +142,62 @@ bar
 	}
 
 	for idx, tc := range testCases {
-		result := reportBodyConflict(tc.local, tc.server)
+		result := ReportBodyConflict(tc.local, tc.server)
+
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			assert.DeepEqual(t, result, tc.expected, "result mismatch")
+		})
+	}
+}
+
+func TestReportBodyMerge(t *testing.T) {
+	testCases := []struct {
+		base     string
+		local    string
+		server   string
+		expected string
+	}{
+		{
+			base:     "foo\nbar\n",
+			local:    "foo\nbar\n",
+			server:   "foo\nbar\n",
+			expected: "foo\nbar\n",
+		},
+		{
+			base:     "foo\n\nbar\n",
+			local:    "foo-local\n\nbar\n",
+			server:   "foo\n\nbar-server\n",
+			expected: "foo-local\n\nbar-server\n",
+		},
+		{
+			base:   "foo\n\nbar",
+			local:  "foo\n\nbar-local",
+			server: "foo\n\nbar-server",
+			expected: `foo
+
+<<<<<<< Local
+bar-local
+=======
+bar-server
+>>>>>>> Server
+`,
+		},
+		{
+			base:   "foo\nbar\nbaz\n",
+			local:  "foo\nbaz\n",
+			server: "foo\nbar-server\nbaz\n",
+			expected: `foo
+<<<<<<< Local
+=======
+bar-server
+>>>>>>> Server
+baz
+`,
+		},
+	}
+
+	for idx, tc := range testCases {
+		result := reportBodyMerge(tc.base, tc.local, tc.server)
 
 		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
 			assert.DeepEqual(t, result, tc.expected, "result mismatch")
@@ -0,0 +1,117 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sync
+
+import (
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/pkg/errors"
+)
+
+// errServerAhead is recorded in the outbox for the changes held back because the server
+// has newer changes that have not been pulled
+var errServerAhead = errors.New("server has newer changes")
+
+// OutboxItem is a local change that could not be sent to the server
+type OutboxItem struct {
+	Type          string
+	UUID          string
+	Error         string
+	Attempts      int
+	LastAttemptAt int64
+}
+
+// recordFailure records in the outbox that the resource could not be sent to the server
+func recordFailure(tx *database.DB, now int64, resourceType, uuid string, sendErr error) error {
+	res, err := tx.Exec("UPDATE outbox SET error = ?, attempts = attempts + 1, last_attempt_at = ? WHERE type = ? AND uuid = ?",
+		sendErr.Error(), now, resourceType, uuid)
+	if err != nil {
+		return errors.Wrapf(err, "updating the outbox for %s %s", resourceType, uuid)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "counting affected rows")
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec("INSERT INTO outbox (type, uuid, error, attempts, last_attempt_at) VALUES (?, ?, ?, ?, ?)",
+		resourceType, uuid, sendErr.Error(), 1, now); err != nil {
+		return errors.Wrapf(err, "inserting into the outbox for %s %s", resourceType, uuid)
+	}
+
+	return nil
+}
+
+// pruneOutbox removes from the outbox the resources that no longer have changes to be sent,
+// either because they have been sent or because they no longer exist.
+func pruneOutbox(tx *database.DB) error {
+	_, err := tx.Exec(`DELETE FROM outbox
+		WHERE (type = ? AND uuid NOT IN (SELECT uuid FROM books WHERE dirty))
+		OR (type = ? AND uuid NOT IN (SELECT uuid FROM notes WHERE dirty))`, resourceTypeBook, resourceTypeNote)
+	if err != nil {
+		return errors.Wrap(err, "pruning the outbox")
+	}
+
+	return nil
+}
+
+// GetOutbox returns the local changes that could not be sent to the server
+func GetOutbox(db *database.DB) ([]OutboxItem, error) {
+	rows, err := db.Query("SELECT type, uuid, error, attempts, last_attempt_at FROM outbox ORDER BY last_attempt_at ASC")
+	if err != nil {
+		return nil, errors.Wrap(err, "querying the outbox")
+	}
+	defer rows.Close()
+
+	ret := []OutboxItem{}
+	for rows.Next() {
+		var item OutboxItem
+		if err := rows.Scan(&item.Type, &item.UUID, &item.Error, &item.Attempts, &item.LastAttemptAt); err != nil {
+			return nil, errors.Wrap(err, "scanning a row")
+		}
+
+		ret = append(ret, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating the outbox")
+	}
+
+	return ret, nil
+}
+
+// countOutbox returns the number of the local changes that could not be sent
+func countOutbox(db *database.DB) (int, error) {
+	var count int
+	if err := db.QueryRow("SELECT count(*) FROM outbox").Scan(&count); err != nil {
+		return count, errors.Wrap(err, "counting the outbox")
+	}
+
+	return count, nil
+}
+
+// checkOutbox returns an error if the sync left any changes that could not be sent
+func checkOutbox(s Summary) error {
+	if s.Outbox > 0 {
+		return errors.Errorf("failed to send %d changes. Please run `dnote status` to see why and `dnote sync` to try again", s.Outbox)
+	}
+
+	return nil
+}
@@ -0,0 +1,246 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sync
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/client"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/testutils"
+	"github.com/pkg/errors"
+)
+
+func TestRecordFailure(t *testing.T) {
+	// set up
+	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+	defer database.TeardownTestDB(t, db)
+
+	// execute
+	if err := recordFailure(db, 1541108743, resourceTypeNote, "n1-uuid", errors.New("network error")); err != nil {
+		t.Fatal(errors.Wrap(err, "recording the first failure"))
+	}
+	if err := recordFailure(db, 1541108800, resourceTypeNote, "n1-uuid", errors.New("server error")); err != nil {
+		t.Fatal(errors.Wrap(err, "recording the second failure"))
+	}
+	if err := recordFailure(db, 1541108900, resourceTypeBook, "b1-uuid", errors.New("conflict")); err != nil {
+		t.Fatal(errors.Wrap(err, "recording the book failure"))
+	}
+
+	// test
+	items, err := GetOutbox(db)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the outbox"))
+	}
+
+	assert.DeepEqual(t, items, []OutboxItem{
+		{Type: resourceTypeNote, UUID: "n1-uuid", Error: "server error", Attempts: 2, LastAttemptAt: 1541108800},
+		{Type: resourceTypeBook, UUID: "b1-uuid", Error: "conflict", Attempts: 1, LastAttemptAt: 1541108900},
+	}, "outbox mismatch")
+}
+
+func TestPruneOutbox(t *testing.T) {
+	// set up
+	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+	defer database.TeardownTestDB(t, db)
+
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "b1-label", 1, false, true)
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b2-uuid", "b2-label", 2, false, false)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", 0, "n1 body", 1541108743, false, true)
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", 3, "n2 body", 1541108743, false, false)
+
+	for _, item := range []OutboxItem{
+		{Type: resourceTypeBook, UUID: "b1-uuid"},
+		{Type: resourceTypeBook, UUID: "b2-uuid"},
+		{Type: resourceTypeNote, UUID: "n1-uuid"},
+		{Type: resourceTypeNote, UUID: "n2-uuid"},
+		{Type: resourceTypeNote, UUID: "n3-uuid"},
+	} {
+		if err := recordFailure(db, 1541108743, item.Type, item.UUID, errors.New("network error")); err != nil {
+			t.Fatal(errors.Wrap(err, "preparing the outbox"))
+		}
+	}
+
+	// execute
+	if err := pruneOutbox(db); err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	// test
+	items, err := GetOutbox(db)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the outbox"))
+	}
+
+	assert.Equal(t, len(items), 2, "outbox length mismatch")
+	assert.Equal(t, items[0].UUID, "b1-uuid", "b1 mismatch")
+	assert.Equal(t, items[1].UUID, "n1-uuid", "n1 mismatch")
+
+	count, err := countOutbox(db)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "counting the outbox"))
+	}
+	assert.Equal(t, count, 2, "outbox count mismatch")
+}
+
+func TestCheckOutbox(t *testing.T) {
+	assert.Equal(t, checkOutbox(Summary{Failed: 1}), nil, "an empty outbox should not be an error")
+	assert.NotEqual(t, checkOutbox(Summary{Outbox: 1}), nil, "a non-empty outbox should be an error")
+}
+
+// newFlakyNoteServer returns a server that is up to date with the client and fails to
+// create the notes whose body contains the word "fail"
+func newFlakyNoteServer(t *testing.T, createdBodies *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v3/sync/state" && r.Method == "GET" {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(client.GetSyncStateResp{}); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		if r.URL.Path != "/v3/notes" || r.Method != "POST" {
+			t.Fatalf("unrecognized endpoint reached Method: %s Path: %s", r.Method, r.URL.Path)
+		}
+
+		var payload client.CreateNotePayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatal(errors.Wrap(err, "decoding payload in the test server"))
+		}
+
+		if strings.Contains(payload.Body, "fail") {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		*createdBodies = append(*createdBodies, payload.Body)
+
+		resp := client.CreateNoteResp{
+			Result: client.RespNote{
+				UUID: "server-" + payload.Body,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}))
+}
+
+func TestSendNotes_failure(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+	testutils.Login(t, &ctx)
+
+	db := ctx.DB
+
+	database.MustExec(t, "inserting last max usn", db, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastMaxUSN, 0)
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "b1-label", 1, false, false)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", 0, "n1 fail", 1541108743, false, true)
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", 0, "n2 body", 1541108743, false, true)
+
+	var createdBodies []string
+	ts := newFlakyNoteServer(t, &createdBodies)
+	defer ts.Close()
+
+	ctx.APIEndpoint = ts.URL
+
+	// execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "beginning a transaction"))
+	}
+	r := syncReport{}
+	if _, err := sendChanges(ctx, tx, false, false, &r); err != nil {
+		tx.Rollback()
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+	tx.Commit()
+
+	// test
+	assert.DeepEqual(t, createdBodies, []string{"n2 body"}, "created bodies mismatch")
+	assert.Equal(t, r.PushedNotes, Counts{Created: 1}, "PushedNotes mismatch")
+	assert.Equal(t, r.Failed, 1, "Failed mismatch")
+	assert.Equal(t, r.sent, 2, "sent mismatch")
+
+	items, err := GetOutbox(db)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the outbox"))
+	}
+	assert.Equal(t, len(items), 1, "outbox length mismatch")
+	assert.Equal(t, items[0].UUID, "n1-uuid", "outbox uuid mismatch")
+	assert.Equal(t, items[0].Attempts, 1, "outbox attempts mismatch")
+
+	var n1Dirty, n2Dirty bool
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT dirty FROM notes WHERE uuid = ?", "n1-uuid"), &n1Dirty)
+	database.MustScan(t, "getting n2", db.QueryRow("SELECT dirty FROM notes WHERE uuid = ?", "server-n2 body"), &n2Dirty)
+	assert.Equal(t, n1Dirty, true, "n1 dirty mismatch")
+	assert.Equal(t, n2Dirty, false, "n2 dirty mismatch")
+}
+
+func TestDoSync_retryFailed(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+	testutils.Login(t, &ctx)
+
+	db := ctx.DB
+
+	database.MustExec(t, "inserting last max usn", db, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastMaxUSN, 0)
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "b1-label", 1, false, false)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", 0, "n1 body", 1541108743, false, true)
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", 0, "n2 body", 1541108743, false, true)
+	if err := recordFailure(db, 1541108743, resourceTypeNote, "n1-uuid", errors.New("network error")); err != nil {
+		t.Fatal(errors.Wrap(err, "preparing the outbox"))
+	}
+
+	// the server does not serve the sync fragments, so the sync fails if it tries to pull
+	var createdBodies []string
+	ts := newFlakyNoteServer(t, &createdBodies)
+	defer ts.Close()
+
+	ctx.APIEndpoint = ts.URL
+
+	// execute
+	if err := doSync(ctx, syncOptions{PushOnly: true, OnlyFailed: true}, &syncReport{}); err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	// test
+	assert.DeepEqual(t, createdBodies, []string{"n1 body"}, "created bodies mismatch")
+
+	items, err := GetOutbox(db)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the outbox"))
+	}
+	assert.Equal(t, len(items), 0, "outbox length mismatch")
+
+	var n2Dirty bool
+	database.MustScan(t, "getting n2", db.QueryRow("SELECT dirty FROM notes WHERE uuid = ?", "n2-uuid"), &n2Dirty)
+	assert.Equal(t, n2Dirty, true, "n2 should not have been sent")
+}
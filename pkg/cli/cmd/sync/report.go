@@ -0,0 +1,325 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dnote/dnote/pkg/cli/client"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/pkg/errors"
+)
+
+// maxSyncLogEntries is the number of the most recent sync runs kept in the sync log
+const maxSyncLogEntries = 20
+
+// Counts is the number of resources changed in one direction of a sync
+type Counts struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+}
+
+func (c Counts) total() int {
+	return c.Created + c.Updated + c.Deleted
+}
+
+func (c Counts) String() string {
+	return fmt.Sprintf("%d created, %d updated, %d deleted", c.Created, c.Updated, c.Deleted)
+}
+
+// Summary describes what happened in a sync run. Timestamps are unix timestamps in seconds.
+type Summary struct {
+	StartedAt  int64 `json:"started_at"`
+	FinishedAt int64 `json:"finished_at"`
+	FullSync   bool  `json:"full_sync"`
+	// PulledBooks and PulledNotes are the changes from the server applied locally
+	PulledBooks Counts `json:"pulled_books"`
+	PulledNotes Counts `json:"pulled_notes"`
+	// PushedBooks and PushedNotes are the local changes sent to the server
+	PushedBooks Counts `json:"pushed_books"`
+	PushedNotes Counts `json:"pushed_notes"`
+	// RenamedBooks is the number of local books renamed because of a label conflict
+	RenamedBooks int `json:"renamed_books"`
+	// ConflictedNotes is the number of notes moved to the conflicts book
+	ConflictedNotes int `json:"conflicted_notes"`
+	// CleanedBooks and CleanedNotes are the local resources removed during a full sync
+	// because they were in an invalid state
+	CleanedBooks int `json:"cleaned_books"`
+	CleanedNotes int `json:"cleaned_notes"`
+	// Failed is the number of local changes that failed to be sent
+	Failed int `json:"failed"`
+	// Outbox is the number of local changes left in the outbox after the sync
+	Outbox int    `json:"outbox"`
+	Error  string `json:"error,omitempty"`
+}
+
+// lines returns the human readable description of the summary
+func (s Summary) lines() []string {
+	var ret []string
+
+	if s.PulledBooks.total() > 0 {
+		ret = append(ret, fmt.Sprintf("books from the server: %s", s.PulledBooks))
+	}
+	if s.PulledNotes.total() > 0 {
+		ret = append(ret, fmt.Sprintf("notes from the server: %s", s.PulledNotes))
+	}
+	if s.PushedBooks.total() > 0 {
+		ret = append(ret, fmt.Sprintf("books sent: %s", s.PushedBooks))
+	}
+	if s.PushedNotes.total() > 0 {
+		ret = append(ret, fmt.Sprintf("notes sent: %s", s.PushedNotes))
+	}
+	if s.RenamedBooks > 0 {
+		ret = append(ret, fmt.Sprintf("books renamed because of a duplicate name: %d", s.RenamedBooks))
+	}
+	if s.ConflictedNotes > 0 {
+		ret = append(ret, fmt.Sprintf("notes moved to the book 'conflicts': %d", s.ConflictedNotes))
+	}
+	if s.CleanedBooks > 0 || s.CleanedNotes > 0 {
+		ret = append(ret, fmt.Sprintf("cleaned up: %d books, %d notes", s.CleanedBooks, s.CleanedNotes))
+	}
+	if s.Failed > 0 {
+		ret = append(ret, fmt.Sprintf("failed to send: %d", s.Failed))
+	}
+	if s.Outbox > 0 {
+		ret = append(ret, fmt.Sprintf("waiting in the outbox: %d", s.Outbox))
+	}
+
+	if len(ret) == 0 {
+		ret = append(ret, "already up to date")
+	}
+
+	return ret
+}
+
+// ChangedLocal returns true if the sync changed the local books or notes
+func (s Summary) ChangedLocal() bool {
+	return s.PulledBooks.total() > 0 || s.PulledNotes.total() > 0 || s.RenamedBooks > 0 ||
+		s.ConflictedNotes > 0 || s.CleanedBooks > 0 || s.CleanedNotes > 0
+}
+
+// progress displays the progress of a sync step. On a terminal, it redraws the
+// current line as the step advances. Otherwise, it only prints the result of each step.
+type progress struct {
+	out   io.Writer
+	tty   bool
+	label string
+}
+
+func newProgress(out io.Writer, tty bool) *progress {
+	return &progress{
+		out: out,
+		tty: tty,
+	}
+}
+
+func (p *progress) line() string {
+	return fmt.Sprintf("  %s %s", log.ColorBlue.Sprint("•"), p.label)
+}
+
+// start begins a new step with the given label
+func (p *progress) start(label string) {
+	if p == nil {
+		return
+	}
+
+	p.label = label
+	fmt.Fprint(p.out, p.line())
+}
+
+// update shows the progress of the current step
+func (p *progress) update(msg string, v ...interface{}) {
+	if p == nil || !p.tty {
+		return
+	}
+
+	// clear the rest of the line in case the previous message was longer
+	fmt.Fprintf(p.out, "\r%s %s\033[K", p.line(), fmt.Sprintf(msg, v...))
+}
+
+// finish ends the current step with the given result
+func (p *progress) finish(msg string, v ...interface{}) {
+	if p == nil {
+		return
+	}
+
+	if p.tty {
+		fmt.Fprintf(p.out, "\r%s %s\033[K\n", p.line(), fmt.Sprintf(msg, v...))
+	} else {
+		fmt.Fprintf(p.out, " %s\n", fmt.Sprintf(msg, v...))
+	}
+}
+
+// syncReport accumulates the summary of a sync run as it progresses
+type syncReport struct {
+	Summary
+
+	// progress is nil if the progress should not be displayed
+	progress *progress
+	// sendTotal and sent are the number of the local changes to send and already sent
+	sendTotal int
+	sent      int
+}
+
+// fragmentReceived shows the progress of downloading the sync fragments
+func (r *syncReport) fragmentReceived(afterUSN int, frag client.SyncFragment) {
+	if frag.UserMaxUSN <= afterUSN {
+		return
+	}
+
+	pct := (frag.FragMaxUSN - afterUSN) * 100 / (frag.UserMaxUSN - afterUSN)
+	if frag.FragMaxUSN == 0 || pct > 100 {
+		pct = 100
+	}
+
+	r.progress.update("%d%%", pct)
+}
+
+// itemSent records that a local change was processed and shows the progress of sending changes
+func (r *syncReport) itemSent() {
+	r.sent++
+	r.progress.update("%d/%d", r.sent, r.sendTotal)
+}
+
+func getSyncLogPath(ctx context.DnoteCtx) (string, error) {
+	dir, err := getSyncDir(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "getting the sync directory")
+	}
+
+	return filepath.Join(dir, consts.SyncLogFilename), nil
+}
+
+// ReadSyncLog reads the summaries of the most recent sync runs, oldest first
+func ReadSyncLog(ctx context.DnoteCtx) ([]Summary, error) {
+	var ret []Summary
+
+	path, err := getSyncLogPath(ctx)
+	if err != nil {
+		return ret, errors.Wrap(err, "getting the sync log path")
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ret, nil
+	} else if err != nil {
+		return ret, errors.Wrap(err, "reading the sync log")
+	}
+
+	if err := json.Unmarshal(b, &ret); err != nil {
+		return ret, errors.Wrap(err, "unmarshalling the sync log")
+	}
+
+	return ret, nil
+}
+
+// appendSyncLog adds the given summary to the sync log, keeping only the most recent entries
+func appendSyncLog(ctx context.DnoteCtx, s Summary) error {
+	entries, err := ReadSyncLog(ctx)
+	if err != nil {
+		return errors.Wrap(err, "reading the sync log")
+	}
+
+	entries = append(entries, s)
+	if len(entries) > maxSyncLogEntries {
+		entries = entries[len(entries)-maxSyncLogEntries:]
+	}
+
+	path, err := getSyncLogPath(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting the sync log path")
+	}
+
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return errors.Wrap(err, "marshalling the sync log")
+	}
+
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, b, 0644); err != nil {
+		return errors.Wrap(err, "writing the temporary sync log")
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return errors.Wrap(err, "renaming the temporary sync log")
+	}
+
+	return nil
+}
+
+// printSummary prints the human readable summary of a sync run
+func printSummary(s Summary) {
+	for _, l := range s.lines() {
+		log.Plainf("%s\n", l)
+	}
+}
+
+// printJSON prints the given value as indented JSON
+func printJSON(v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshalling")
+	}
+
+	fmt.Println(string(b))
+
+	return nil
+}
+
+// describeRun returns a one-line description of a sync run for the sync log
+func describeRun(s Summary) string {
+	var parts []string
+
+	if s.FullSync {
+		parts = append(parts, "full")
+	}
+
+	pulled := s.PulledBooks.total() + s.PulledNotes.total()
+	pushed := s.PushedBooks.total() + s.PushedNotes.total()
+	parts = append(parts, fmt.Sprintf("received %d, sent %d", pulled, pushed))
+
+	if s.RenamedBooks > 0 {
+		parts = append(parts, fmt.Sprintf("renamed %d", s.RenamedBooks))
+	}
+	if s.ConflictedNotes > 0 {
+		parts = append(parts, fmt.Sprintf("conflicts %d", s.ConflictedNotes))
+	}
+	if s.CleanedBooks > 0 || s.CleanedNotes > 0 {
+		parts = append(parts, fmt.Sprintf("cleaned %d", s.CleanedBooks+s.CleanedNotes))
+	}
+	if s.Failed > 0 {
+		parts = append(parts, fmt.Sprintf("failed %d", s.Failed))
+	}
+	if s.Outbox > 0 {
+		parts = append(parts, fmt.Sprintf("outbox %d", s.Outbox))
+	}
+	if s.Error != "" {
+		parts = append(parts, fmt.Sprintf("error: %s", s.Error))
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,278 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/client"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/testutils"
+	"github.com/pkg/errors"
+)
+
+func TestSummaryLines(t *testing.T) {
+	testCases := []struct {
+		summary  Summary
+		expected []string
+	}{
+		{
+			summary:  Summary{},
+			expected: []string{"already up to date"},
+		},
+		{
+			summary: Summary{
+				PulledNotes: Counts{Created: 2, Deleted: 1},
+				PushedBooks: Counts{Updated: 1},
+			},
+			expected: []string{
+				"notes from the server: 2 created, 0 updated, 1 deleted",
+				"books sent: 0 created, 1 updated, 0 deleted",
+			},
+		},
+		{
+			summary: Summary{
+				RenamedBooks:    1,
+				ConflictedNotes: 2,
+				CleanedNotes:    3,
+				Failed:          4,
+				Outbox:          5,
+			},
+			expected: []string{
+				"books renamed because of a duplicate name: 1",
+				"notes moved to the book 'conflicts': 2",
+				"cleaned up: 0 books, 3 notes",
+				"failed to send: 4",
+				"waiting in the outbox: 5",
+			},
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			assert.DeepEqual(t, tc.summary.lines(), tc.expected, "lines mismatch")
+		})
+	}
+}
+
+func TestSummaryChangedLocal(t *testing.T) {
+	testCases := []struct {
+		summary  Summary
+		expected bool
+	}{
+		{
+			summary:  Summary{},
+			expected: false,
+		},
+		{
+			summary:  Summary{PushedNotes: Counts{Created: 1}, Failed: 1},
+			expected: false,
+		},
+		{
+			summary:  Summary{PulledNotes: Counts{Deleted: 1}},
+			expected: true,
+		},
+		{
+			summary:  Summary{PulledBooks: Counts{Updated: 1}},
+			expected: true,
+		},
+		{
+			summary:  Summary{ConflictedNotes: 1},
+			expected: true,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			assert.Equal(t, tc.summary.ChangedLocal(), tc.expected, "result mismatch")
+		})
+	}
+}
+
+func TestProgress(t *testing.T) {
+	t.Run("terminal", func(t *testing.T) {
+		var buf bytes.Buffer
+		r := syncReport{progress: newProgress(&buf, true)}
+
+		r.progress.start("resolving delta.")
+		r.fragmentReceived(10, client.SyncFragment{FragMaxUSN: 15, UserMaxUSN: 20})
+		r.fragmentReceived(10, client.SyncFragment{FragMaxUSN: 0, UserMaxUSN: 20})
+		r.progress.finish("(total %d). done.", 3)
+
+		got := buf.String()
+		assert.Equal(t, strings.Contains(got, "resolving delta. 50%"), true, "first fragment progress mismatch")
+		assert.Equal(t, strings.Contains(got, "resolving delta. 100%"), true, "last fragment progress mismatch")
+		assert.Equal(t, strings.HasSuffix(got, "resolving delta. (total 3). done.\033[K\n"), true, "result mismatch")
+	})
+
+	t.Run("not terminal", func(t *testing.T) {
+		var buf bytes.Buffer
+		r := syncReport{progress: newProgress(&buf, false), sendTotal: 2}
+
+		r.progress.start("sending changes.")
+		r.itemSent()
+		r.itemSent()
+		r.progress.finish("(total %d). done.", 2)
+
+		got := buf.String()
+		assert.Equal(t, strings.Contains(got, "/2"), false, "progress should not be shown")
+		assert.Equal(t, strings.HasSuffix(got, "sending changes. (total 2). done.\n"), true, "result mismatch")
+		assert.Equal(t, r.sent, 2, "sent mismatch")
+	})
+
+	t.Run("hidden", func(t *testing.T) {
+		r := syncReport{sendTotal: 1}
+
+		// does not panic without a progress
+		r.progress.start("sending changes.")
+		r.itemSent()
+		r.progress.finish("done.")
+
+		assert.Equal(t, r.sent, 1, "sent mismatch")
+	})
+}
+
+func TestAppendSyncLog(t *testing.T) {
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	entries, err := ReadSyncLog(ctx)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "reading an empty log"))
+	}
+	assert.Equal(t, len(entries), 0, "initial length mismatch")
+
+	for i := 1; i <= maxSyncLogEntries+5; i++ {
+		if err := appendSyncLog(ctx, Summary{StartedAt: int64(i)}); err != nil {
+			t.Fatal(errors.Wrapf(err, "appending entry %d", i))
+		}
+	}
+
+	entries, err = ReadSyncLog(ctx)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "reading the log"))
+	}
+
+	assert.Equal(t, len(entries), maxSyncLogEntries, "length mismatch")
+	assert.Equal(t, entries[0].StartedAt, int64(6), "oldest entry mismatch")
+	assert.Equal(t, entries[len(entries)-1].StartedAt, int64(maxSyncLogEntries+5), "newest entry mismatch")
+}
+
+func TestDescribeRun(t *testing.T) {
+	testCases := []struct {
+		summary  Summary
+		expected string
+	}{
+		{
+			summary:  Summary{},
+			expected: "received 0, sent 0",
+		},
+		{
+			summary: Summary{
+				FullSync:     true,
+				PulledBooks:  Counts{Created: 1},
+				PulledNotes:  Counts{Created: 2, Updated: 1},
+				PushedNotes:  Counts{Deleted: 1},
+				CleanedBooks: 1,
+				CleanedNotes: 1,
+			},
+			expected: "full, received 4, sent 1, cleaned 2",
+		},
+		{
+			summary: Summary{
+				RenamedBooks:    1,
+				ConflictedNotes: 1,
+				Failed:          2,
+				Error:           "network error",
+			},
+			expected: "received 0, sent 0, renamed 1, conflicts 1, failed 2, error: network error",
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			assert.Equal(t, describeRun(tc.summary), tc.expected, "description mismatch")
+		})
+	}
+}
+
+func TestFullSync_report(t *testing.T) {
+	ts := newFragmentServer(t, map[string]client.SyncFragment{
+		"0": {
+			FragMaxUSN:  4,
+			UserMaxUSN:  4,
+			CurrentTime: 1550436136,
+			Notes: []client.SyncFragNote{
+				{UUID: "n1-uuid", BookUUID: "b1-uuid", USN: 3, Body: "n1 body", AddedOn: 1541108743},
+				{UUID: "n2-uuid", BookUUID: "b1-uuid", USN: 4, Body: "n2 body edited", AddedOn: 1541108743},
+			},
+			Books: []client.SyncFragBook{
+				{UUID: "b1-uuid", USN: 1, Label: "js"},
+			},
+		},
+		"4": {
+			FragMaxUSN:  0,
+			UserMaxUSN:  4,
+			CurrentTime: 1550436137,
+		},
+	})
+	defer ts.Close()
+
+	ctx := context.InitTestCtx(t, paths, nil)
+	ctx.APIEndpoint = ts.URL
+	defer context.TeardownTestCtx(t, ctx)
+	testutils.Login(t, &ctx)
+
+	db := ctx.DB
+	database.MustExec(t, "inserting last max usn", db, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastMaxUSN, 0)
+	database.MustExec(t, "inserting last sync at", db, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastSyncAt, 0)
+
+	// a local book with the same label as a book in the server
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b2-uuid", "js", 0, false, true)
+	// a synced note that is no longer in the server
+	database.MustExec(t, "inserting n3", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n3-uuid", "b2-uuid", 2, "n3 body", 1541108743, false, false)
+	// a note edited in the server
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", 2, "n2 body", 1541108743, false, false)
+
+	// execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "beginning a transaction"))
+	}
+	r := syncReport{}
+	if err := fullSync(ctx, tx, newBookFilter(nil, nil), &r); err != nil {
+		tx.Rollback()
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+	tx.Commit()
+
+	// test
+	assert.Equal(t, r.FullSync, true, "FullSync mismatch")
+	assert.Equal(t, r.PulledBooks, Counts{Created: 1}, "PulledBooks mismatch")
+	assert.Equal(t, r.PulledNotes, Counts{Created: 1, Updated: 1}, "PulledNotes mismatch")
+	assert.Equal(t, r.RenamedBooks, 1, "RenamedBooks mismatch")
+	assert.Equal(t, r.CleanedNotes, 1, "CleanedNotes mismatch")
+	assert.Equal(t, r.CleanedBooks, 0, "CleanedBooks mismatch")
+}
@@ -0,0 +1,167 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sync
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/hooks"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/pkg/errors"
+)
+
+// Status is the status of the sync, persisted so that other processes can
+// inspect it. Timestamps are unix timestamps in seconds.
+type Status struct {
+	PID int `json:"pid"`
+	// Watching is true if a sync is running in the watch mode
+	Watching bool `json:"watching"`
+	// Syncing is true while a sync is in progress
+	Syncing       bool   `json:"syncing"`
+	LastAttemptAt int64  `json:"last_attempt_at"`
+	LastSuccessAt int64  `json:"last_success_at"`
+	LastError     string `json:"last_error"`
+	// Failures is the number of consecutive failed syncs
+	Failures int `json:"failures"`
+	// NextAttemptAt is the time of the next retry after a failure
+	NextAttemptAt int64 `json:"next_attempt_at"`
+}
+
+func getStatusPath(ctx context.DnoteCtx) (string, error) {
+	dir, err := getSyncDir(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "getting the sync directory")
+	}
+
+	return filepath.Join(dir, consts.SyncStatusFilename), nil
+}
+
+// ReadStatus reads the status of the sync. It returns a zero value if no sync
+// has been recorded yet.
+func ReadStatus(ctx context.DnoteCtx) (Status, error) {
+	var ret Status
+
+	path, err := getStatusPath(ctx)
+	if err != nil {
+		return ret, errors.Wrap(err, "getting the status path")
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ret, nil
+	} else if err != nil {
+		return ret, errors.Wrap(err, "reading the status file")
+	}
+
+	if err := json.Unmarshal(b, &ret); err != nil {
+		return ret, errors.Wrap(err, "unmarshalling the status")
+	}
+
+	return ret, nil
+}
+
+// writeStatus persists the status. It writes to a temporary file first so that
+// readers never see a partially written status.
+func writeStatus(ctx context.DnoteCtx, s Status) error {
+	path, err := getStatusPath(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting the status path")
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "marshalling the status")
+	}
+
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, b, 0644); err != nil {
+		return errors.Wrap(err, "writing the temporary status file")
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return errors.Wrap(err, "renaming the temporary status file")
+	}
+
+	return nil
+}
+
+// recordResult updates the status with the result of a sync attempted at the given time
+func recordResult(s *Status, now int64, syncErr error) {
+	s.Syncing = false
+	s.LastAttemptAt = now
+
+	if syncErr != nil {
+		s.LastError = syncErr.Error()
+		s.Failures++
+		return
+	}
+
+	s.LastSuccessAt = now
+	s.LastError = ""
+	s.Failures = 0
+	s.NextAttemptAt = 0
+}
+
+// runSync performs a single sync between the pre-sync and post-sync hooks, and
+// records its summary in the sync log
+func runSync(ctx context.DnoteCtx, opts syncOptions, r *syncReport) error {
+	r.StartedAt = ctx.Clock.Now().Unix()
+
+	if err := runPreSync(ctx); err != nil {
+		return err
+	}
+
+	syncErr := doSync(ctx, opts, r)
+
+	r.FinishedAt = ctx.Clock.Now().Unix()
+	if syncErr != nil {
+		r.Error = syncErr.Error()
+	}
+	if err := appendSyncLog(ctx, r.Summary); err != nil {
+		log.Debug("writing the sync log: %s\n", err)
+	}
+
+	hooks.Post(ctx, hooks.PostSync, hooks.Payload{Summary: r.Summary})
+
+	return syncErr
+}
+
+// syncAndReport performs a single sync and records the result in the status. The
+// caller must hold the sync lock.
+func syncAndReport(ctx context.DnoteCtx, opts syncOptions, r *syncReport) error {
+	s, err := ReadStatus(ctx)
+	if err != nil {
+		log.Debug("reading the sync status: %s\n", err)
+	}
+	s.PID = os.Getpid()
+	s.Watching = false
+
+	syncErr := runSync(ctx, opts, r)
+
+	recordResult(&s, ctx.Clock.Now().Unix(), syncErr)
+	if err := writeStatus(ctx, s); err != nil {
+		log.Debug("writing the sync status: %s\n", err)
+	}
+
+	return syncErr
+}
@@ -21,7 +21,10 @@ package sync
 import (
 	"database/sql"
 	"fmt"
+	"os"
+	"time"
 
+	"github.com/dnote/color"
 	"github.com/dnote/dnote/pkg/cli/client"
 	"github.com/dnote/dnote/pkg/cli/consts"
 	"github.com/dnote/dnote/pkg/cli/context"
@@ -29,9 +32,11 @@ import (
 	"github.com/dnote/dnote/pkg/cli/infra"
 	"github.com/dnote/dnote/pkg/cli/log"
 	"github.com/dnote/dnote/pkg/cli/migrate"
+	"github.com/dnote/dnote/pkg/cli/snapshot"
 	"github.com/dnote/dnote/pkg/cli/upgrade"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 const (
@@ -39,10 +44,43 @@ const (
 	modeUpdate
 )
 
+const (
+	resourceTypeNote = "note"
+	resourceTypeBook = "book"
+)
+
 var example = `
-  dnote sync`
+  * Sync data with the server
+  dnote sync
+
+  * Keep syncing in the background, pushing local changes as they are made
+  dnote sync --watch
+
+  * Sync only the specified books to this machine
+  dnote sync --books js,linux
+
+  * Send the local changes without getting the changes from the server
+  dnote sync --push-only
+
+  * Retry sending the changes that failed to be sent
+  dnote sync --retry-failed
+
+  * Print the summary of the sync as JSON
+  dnote sync --format json
+
+  * Show the recent syncs
+  dnote sync log`
 
 var isFullSync bool
+var watchFlag bool
+var watchIntervalFlag time.Duration
+var booksFlag []string
+var pullOnlyFlag bool
+var pushOnlyFlag bool
+var retryFailedFlag bool
+var formatFlag string
+
+const formatJSON = "json"
 
 // NewCmd returns a new sync command
 func NewCmd(ctx context.DnoteCtx) *cobra.Command {
@@ -51,15 +89,45 @@ func NewCmd(ctx context.DnoteCtx) *cobra.Command {
 		Aliases: []string{"s"},
 		Short:   "Sync data with the server",
 		Example: example,
+		PreRunE: preRun,
 		RunE:    newRun(ctx),
 	}
 
 	f := cmd.Flags()
 	f.BoolVarP(&isFullSync, "full", "f", false, "perform a full sync instead of incrementally syncing only the changed data.")
+	f.BoolVarP(&watchFlag, "watch", "w", false, "keep running and sync whenever the server or the local data changes.")
+	f.DurationVarP(&watchIntervalFlag, "interval", "", defaultWatchInterval, "how often to check the server for changes in the watch mode.")
+	f.StringSliceVarP(&booksFlag, "books", "b", nil, "sync only the books with the given names to this machine. Overrides the configuration.")
+	f.BoolVarP(&pullOnlyFlag, "pull-only", "", false, "only get the changes from the server.")
+	f.BoolVarP(&pushOnlyFlag, "push-only", "", false, "only send the local changes to the server.")
+	f.BoolVarP(&retryFailedFlag, "retry-failed", "", false, "only send the local changes that previously failed to be sent.")
+	f.StringVarP(&formatFlag, "format", "", "", "print the summary in the given format. Currently only 'json' is supported.")
+
+	cmd.AddCommand(newLogCmd(ctx))
 
 	return cmd
 }
 
+func preRun(cmd *cobra.Command, args []string) error {
+	if pullOnlyFlag && (pushOnlyFlag || retryFailedFlag) {
+		return errors.New("--pull-only cannot be used with --push-only or --retry-failed")
+	}
+	if isFullSync && (pushOnlyFlag || retryFailedFlag) {
+		return errors.New("--full cannot be used with --push-only or --retry-failed")
+	}
+	if watchFlag && (pullOnlyFlag || pushOnlyFlag || retryFailedFlag) {
+		return errors.New("--watch cannot be used with --pull-only, --push-only or --retry-failed")
+	}
+	if formatFlag != "" && formatFlag != formatJSON {
+		return errors.Errorf("unknown format '%s'", formatFlag)
+	}
+	if watchFlag && formatFlag != "" {
+		return errors.New("--watch cannot be used with --format")
+	}
+
+	return nil
+}
+
 func getLastSyncAt(tx *database.DB) (int, error) {
 	var ret int
 
@@ -138,37 +206,48 @@ func processFragments(fragments []client.SyncFragment) (syncList, error) {
 	return sl, nil
 }
 
-// getSyncList gets a list of all sync fragments after the specified usn
-// and aggregates them into a syncList data structure
-func getSyncList(ctx context.DnoteCtx, afterUSN int) (syncList, error) {
-	fragments, err := getSyncFragments(ctx, afterUSN)
-	if err != nil {
-		return syncList{}, errors.Wrap(err, "getting sync fragments")
-	}
-
-	ret, err := processFragments(fragments)
-	if err != nil {
-		return syncList{}, errors.Wrap(err, "making sync list")
-	}
-
-	return ret, nil
+// syncResult is the aggregate of all sync fragments received in a sync
+type syncResult struct {
+	Total          int
+	MaxUSN         int
+	MaxCurrentTime int64
 }
 
-// getSyncFragments repeatedly gets all sync fragments after the specified usn until there is no more new data
-// remaining and returns the buffered list
-func getSyncFragments(ctx context.DnoteCtx, afterUSN int) ([]client.SyncFragment, error) {
-	var buf []client.SyncFragment
+// streamSyncFragments repeatedly gets the sync fragments after the specified usn until
+// there is no more new data remaining. It calls the handler with the resources in each
+// fragment as it arrives, so that only one fragment is held in memory at a time.
+func streamSyncFragments(ctx context.DnoteCtx, afterUSN int, r *syncReport, handle func(list *syncList) error) (syncResult, error) {
+	var ret syncResult
 
 	nextAfterUSN := afterUSN
 
 	for {
 		resp, err := client.GetSyncFragment(ctx, nextAfterUSN)
 		if err != nil {
-			return buf, errors.Wrap(err, "getting sync fragment")
+			return ret, errors.Wrap(err, "getting sync fragment")
 		}
 
 		frag := resp.Fragment
-		buf = append(buf, frag)
+		log.Debug("received a sync fragment: %+v\n", frag)
+
+		list, err := processFragments([]client.SyncFragment{frag})
+		if err != nil {
+			return ret, errors.Wrap(err, "making sync list")
+		}
+
+		if err := handle(&list); err != nil {
+			return ret, errors.Wrapf(err, "processing the fragment after usn %d", nextAfterUSN)
+		}
+
+		r.fragmentReceived(afterUSN, frag)
+
+		ret.Total += list.getLength()
+		if list.MaxUSN > ret.MaxUSN {
+			ret.MaxUSN = list.MaxUSN
+		}
+		if list.MaxCurrentTime > ret.MaxCurrentTime {
+			ret.MaxCurrentTime = list.MaxCurrentTime
+		}
 
 		nextAfterUSN = frag.FragMaxUSN
 
@@ -178,14 +257,41 @@ func getSyncFragments(ctx context.DnoteCtx, afterUSN int) ([]client.SyncFragment
 		}
 	}
 
-	log.Debug("received sync fragments: %+v\n", buf)
+	return ret, nil
+}
+
+// applySyncList applies the resources in the given sync list to the local database
+// using the given functions. Books are applied before notes so that notes can refer to
+// them, and expunged resources are applied last.
+func applySyncList(tx *database.DB, list *syncList, r *syncReport, syncBook func(*database.DB, client.SyncFragBook, *syncReport) error, syncNote func(*database.DB, client.SyncFragNote, *syncReport) error) error {
+	for _, book := range list.Books {
+		if err := syncBook(tx, book, r); err != nil {
+			return errors.Wrap(err, "merging book")
+		}
+	}
+	for _, note := range list.Notes {
+		if err := syncNote(tx, note, r); err != nil {
+			return errors.Wrap(err, "merging note")
+		}
+	}
+
+	for noteUUID := range list.ExpungedNotes {
+		if err := syncDeleteNote(tx, noteUUID, r); err != nil {
+			return errors.Wrap(err, "deleting note")
+		}
+	}
+	for bookUUID := range list.ExpungedBooks {
+		if err := syncDeleteBook(tx, bookUUID, r); err != nil {
+			return errors.Wrap(err, "deleting book")
+		}
+	}
 
-	return buf, nil
+	return nil
 }
 
-// resolveLabel resolves a book label conflict by repeatedly appending an increasing integer
+// ResolveLabel resolves a book label conflict by repeatedly appending an increasing integer
 // to the label until it finds a unique label. It returns the first non-conflicting label.
-func resolveLabel(tx *database.DB, label string) (string, error) {
+func ResolveLabel(tx *database.DB, label string) (string, error) {
 	var ret string
 
 	for i := 2; ; i++ {
@@ -206,7 +312,7 @@ func resolveLabel(tx *database.DB, label string) (string, error) {
 
 // mergeBook inserts or updates the given book in the local database.
 // If a book with a duplicate label exists locally, it renames the duplicate by appending a number.
-func mergeBook(tx *database.DB, b client.SyncFragBook, mode int) error {
+func mergeBook(tx *database.DB, b client.SyncFragBook, mode int, r *syncReport) error {
 	var count int
 	if err := tx.QueryRow("SELECT count(*) FROM books WHERE label = ?", b.Label).Scan(&count); err != nil {
 		return errors.Wrapf(err, "checking for books with a duplicate label %s", b.Label)
@@ -214,7 +320,7 @@ func mergeBook(tx *database.DB, b client.SyncFragBook, mode int) error {
 
 	// if duplicate exists locally, rename it and mark it dirty
 	if count > 0 {
-		newLabel, err := resolveLabel(tx, b.Label)
+		newLabel, err := ResolveLabel(tx, b.Label)
 		if err != nil {
 			return errors.Wrap(err, "getting a new book label for conflict resolution")
 		}
@@ -222,25 +328,33 @@ func mergeBook(tx *database.DB, b client.SyncFragBook, mode int) error {
 		if _, err := tx.Exec("UPDATE books SET label = ?, dirty = ? WHERE label = ?", newLabel, true, b.Label); err != nil {
 			return errors.Wrap(err, "resolving duplicate book label")
 		}
+
+		r.RenamedBooks++
 	}
 
 	if mode == modeInsert {
 		book := database.NewBook(b.UUID, b.Label, b.USN, false, false)
+		book.Pinned = b.Pinned
+		book.Archived = b.Archived
 		if err := book.Insert(tx); err != nil {
 			return errors.Wrapf(err, "inserting note with uuid %s", b.UUID)
 		}
+
+		r.PulledBooks.Created++
 	} else if mode == modeUpdate {
 		// The state from the server overwrites the local state. In other words, the server change always wins.
-		if _, err := tx.Exec("UPDATE books SET usn = ?, uuid = ?, label = ?, deleted = ? WHERE uuid = ?",
-			b.USN, b.UUID, b.Label, b.Deleted, b.UUID); err != nil {
+		if _, err := tx.Exec("UPDATE books SET usn = ?, uuid = ?, label = ?, deleted = ?, pinned = ?, archived = ? WHERE uuid = ?",
+			b.USN, b.UUID, b.Label, b.Deleted, b.Pinned, b.Archived, b.UUID); err != nil {
 			return errors.Wrapf(err, "updating local book %s", b.UUID)
 		}
+
+		r.PulledBooks.Updated++
 	}
 
 	return nil
 }
 
-func stepSyncBook(tx *database.DB, b client.SyncFragBook) error {
+func stepSyncBook(tx *database.DB, b client.SyncFragBook, r *syncReport) error {
 	var localUSN int
 	var dirty bool
 	err := tx.QueryRow("SELECT usn, dirty FROM books WHERE uuid = ?", b.UUID).Scan(&localUSN, &dirty)
@@ -250,21 +364,21 @@ func stepSyncBook(tx *database.DB, b client.SyncFragBook) error {
 
 	// if book exists in the server and does not exist in the client
 	if err == sql.ErrNoRows {
-		if e := mergeBook(tx, b, modeInsert); e != nil {
+		if e := mergeBook(tx, b, modeInsert, r); e != nil {
 			return errors.Wrapf(e, "resolving book")
 		}
 
 		return nil
 	}
 
-	if e := mergeBook(tx, b, modeUpdate); e != nil {
+	if e := mergeBook(tx, b, modeUpdate, r); e != nil {
 		return errors.Wrapf(e, "resolving book")
 	}
 
 	return nil
 }
 
-func mergeNote(tx *database.DB, serverNote client.SyncFragNote, localNote database.Note) error {
+func mergeNote(tx *database.DB, serverNote client.SyncFragNote, localNote database.Note, r *syncReport) error {
 	var bookDeleted bool
 	err := tx.QueryRow("SELECT deleted FROM books WHERE uuid = ?", localNote.BookUUID).Scan(&bookDeleted)
 	if err != nil {
@@ -278,11 +392,13 @@ func mergeNote(tx *database.DB, serverNote client.SyncFragNote, localNote databa
 
 	// if the local copy is deleted, and it was edited on the server, override with server values and mark it not dirty.
 	if localNote.Deleted {
-		if _, err := tx.Exec("UPDATE notes SET usn = ?, book_uuid = ?, body = ?, edited_on = ?, deleted = ?, public = ?, dirty = ? WHERE uuid = ?",
-			serverNote.USN, serverNote.BookUUID, serverNote.Body, serverNote.EditedOn, serverNote.Deleted, serverNote.Public, false, serverNote.UUID); err != nil {
+		if _, err := tx.Exec("UPDATE notes SET usn = ?, book_uuid = ?, body = ?, base_body = ?, edited_on = ?, deleted = ?, public = ?, due_on = ?, remind_on = ?, pinned = ?, archived = ?, dirty = ? WHERE uuid = ?",
+			serverNote.USN, serverNote.BookUUID, serverNote.Body, serverNote.Body, serverNote.EditedOn, serverNote.Deleted, serverNote.Public, serverNote.DueOn, serverNote.RemindOn, serverNote.Pinned, serverNote.Archived, false, serverNote.UUID); err != nil {
 			return errors.Wrapf(err, "updating local note %s", serverNote.UUID)
 		}
 
+		r.PulledNotes.Updated++
+
 		return nil
 	}
 
@@ -291,18 +407,35 @@ func mergeNote(tx *database.DB, serverNote client.SyncFragNote, localNote databa
 		return errors.Wrapf(err, "reporting note conflict for note %s", localNote.UUID)
 	}
 
-	if _, err := tx.Exec("UPDATE notes SET usn = ?, book_uuid = ?, body = ?, edited_on = ?, deleted = ?  WHERE uuid = ?",
-		serverNote.USN, mr.bookUUID, mr.body, mr.editedOn, serverNote.Deleted, serverNote.UUID); err != nil {
+	// The server copy becomes the base for the next merge, whether or not the merged
+	// result is still dirty.
+	if _, err := tx.Exec("UPDATE notes SET usn = ?, book_uuid = ?, body = ?, base_body = ?, edited_on = ?, deleted = ?, due_on = ?, remind_on = ?, pinned = ?, archived = ? WHERE uuid = ?",
+		serverNote.USN, mr.bookUUID, mr.body, serverNote.Body, mr.editedOn, serverNote.Deleted, mr.dueOn, mr.remindOn, mr.pinned, mr.archived, serverNote.UUID); err != nil {
 		return errors.Wrapf(err, "updating local note %s", serverNote.UUID)
 	}
 
+	r.PulledNotes.Updated++
+	if mr.bookUUID != serverNote.BookUUID {
+		r.ConflictedNotes++
+	}
+
 	return nil
 }
 
-func stepSyncNote(tx *database.DB, n client.SyncFragNote) error {
+// updateNoteBaseBody records the given body as the last version of the note agreed
+// with the server. It is used as the common ancestor when merging the note later.
+func updateNoteBaseBody(tx *database.DB, noteUUID, body string) error {
+	if _, err := tx.Exec("UPDATE notes SET base_body = ? WHERE uuid = ?", body, noteUUID); err != nil {
+		return errors.Wrapf(err, "updating base body of note %s", noteUUID)
+	}
+
+	return nil
+}
+
+func stepSyncNote(tx *database.DB, n client.SyncFragNote, r *syncReport) error {
 	var localNote database.Note
-	err := tx.QueryRow("SELECT body, usn, book_uuid, dirty, deleted FROM notes WHERE uuid = ?", n.UUID).
-		Scan(&localNote.Body, &localNote.USN, &localNote.BookUUID, &localNote.Dirty, &localNote.Deleted)
+	err := tx.QueryRow("SELECT body, usn, book_uuid, dirty, deleted, due_on, remind_on, pinned, archived FROM notes WHERE uuid = ?", n.UUID).
+		Scan(&localNote.Body, &localNote.USN, &localNote.BookUUID, &localNote.Dirty, &localNote.Deleted, &localNote.DueOn, &localNote.RemindOn, &localNote.Pinned, &localNote.Archived)
 	if err != nil && err != sql.ErrNoRows {
 		return errors.Wrapf(err, "getting local note %s", n.UUID)
 	}
@@ -310,12 +443,21 @@ func stepSyncNote(tx *database.DB, n client.SyncFragNote) error {
 	// if note exists in the server and does not exist in the client, insert the note.
 	if err == sql.ErrNoRows {
 		note := database.NewNote(n.UUID, n.BookUUID, n.Body, n.AddedOn, n.EditedOn, n.USN, n.Public, n.Deleted, false)
+		note.DueOn = n.DueOn
+		note.RemindOn = n.RemindOn
+		note.Pinned = n.Pinned
+		note.Archived = n.Archived
 
 		if err := note.Insert(tx); err != nil {
 			return errors.Wrapf(err, "inserting note with uuid %s", n.UUID)
 		}
+		if err := updateNoteBaseBody(tx, n.UUID, n.Body); err != nil {
+			return errors.Wrapf(err, "saving base body for note %s", n.UUID)
+		}
+
+		r.PulledNotes.Created++
 	} else {
-		if err := mergeNote(tx, n, localNote); err != nil {
+		if err := mergeNote(tx, n, localNote, r); err != nil {
 			return errors.Wrap(err, "merging local note")
 		}
 	}
@@ -323,10 +465,10 @@ func stepSyncNote(tx *database.DB, n client.SyncFragNote) error {
 	return nil
 }
 
-func fullSyncNote(tx *database.DB, n client.SyncFragNote) error {
+func fullSyncNote(tx *database.DB, n client.SyncFragNote, r *syncReport) error {
 	var localNote database.Note
-	err := tx.QueryRow("SELECT body, usn, book_uuid, dirty, deleted FROM notes WHERE uuid = ?", n.UUID).
-		Scan(&localNote.Body, &localNote.USN, &localNote.BookUUID, &localNote.Dirty, &localNote.Deleted)
+	err := tx.QueryRow("SELECT body, usn, book_uuid, dirty, deleted, due_on, remind_on, pinned, archived FROM notes WHERE uuid = ?", n.UUID).
+		Scan(&localNote.Body, &localNote.USN, &localNote.BookUUID, &localNote.Dirty, &localNote.Deleted, &localNote.DueOn, &localNote.RemindOn, &localNote.Pinned, &localNote.Archived)
 	if err != nil && err != sql.ErrNoRows {
 		return errors.Wrapf(err, "getting local note %s", n.UUID)
 	}
@@ -334,12 +476,21 @@ func fullSyncNote(tx *database.DB, n client.SyncFragNote) error {
 	// if note exists in the server and does not exist in the client, insert the note.
 	if err == sql.ErrNoRows {
 		note := database.NewNote(n.UUID, n.BookUUID, n.Body, n.AddedOn, n.EditedOn, n.USN, n.Public, n.Deleted, false)
+		note.DueOn = n.DueOn
+		note.RemindOn = n.RemindOn
+		note.Pinned = n.Pinned
+		note.Archived = n.Archived
 
 		if err := note.Insert(tx); err != nil {
 			return errors.Wrapf(err, "inserting note with uuid %s", n.UUID)
 		}
+		if err := updateNoteBaseBody(tx, n.UUID, n.Body); err != nil {
+			return errors.Wrapf(err, "saving base body for note %s", n.UUID)
+		}
+
+		r.PulledNotes.Created++
 	} else if n.USN > localNote.USN {
-		if err := mergeNote(tx, n, localNote); err != nil {
+		if err := mergeNote(tx, n, localNote, r); err != nil {
 			return errors.Wrap(err, "merging local note")
 		}
 	}
@@ -347,10 +498,13 @@ func fullSyncNote(tx *database.DB, n client.SyncFragNote) error {
 	return nil
 }
 
-func syncDeleteNote(tx *database.DB, noteUUID string) error {
+func syncDeleteNote(tx *database.DB, noteUUID string, r *syncReport) error {
 	var localUSN int
-	var dirty bool
-	err := tx.QueryRow("SELECT usn, dirty FROM notes WHERE uuid = ?", noteUUID).Scan(&localUSN, &dirty)
+	var dirty, localOnly bool
+	err := tx.QueryRow(`SELECT notes.usn, notes.dirty, coalesce(books.local_only, false)
+		FROM notes
+		LEFT JOIN books ON books.uuid = notes.book_uuid
+		WHERE notes.uuid = ?`, noteUUID).Scan(&localUSN, &dirty, &localOnly)
 	if err != nil && err != sql.ErrNoRows {
 		return errors.Wrapf(err, "getting local note %s", noteUUID)
 	}
@@ -360,12 +514,19 @@ func syncDeleteNote(tx *database.DB, noteUUID string) error {
 		return nil
 	}
 
+	// notes in local-only books are no longer tracked by the server
+	if localOnly {
+		return nil
+	}
+
 	// if local copy is not dirty, delete
 	if !dirty {
 		_, err = tx.Exec("DELETE FROM notes WHERE uuid = ?", noteUUID)
 		if err != nil {
 			return errors.Wrapf(err, "deleting local note %s", noteUUID)
 		}
+
+		r.PulledNotes.Deleted++
 	}
 
 	return nil
@@ -385,10 +546,10 @@ func checkNotesPristine(tx *database.DB, bookUUID string) (bool, error) {
 	return true, nil
 }
 
-func syncDeleteBook(tx *database.DB, bookUUID string) error {
+func syncDeleteBook(tx *database.DB, bookUUID string, r *syncReport) error {
 	var localUSN int
-	var dirty bool
-	err := tx.QueryRow("SELECT usn, dirty FROM books WHERE uuid = ?", bookUUID).Scan(&localUSN, &dirty)
+	var dirty, localOnly bool
+	err := tx.QueryRow("SELECT usn, dirty, local_only FROM books WHERE uuid = ?", bookUUID).Scan(&localUSN, &dirty, &localOnly)
 	if err != nil && err != sql.ErrNoRows {
 		return errors.Wrapf(err, "getting local book %s", bookUUID)
 	}
@@ -398,6 +559,11 @@ func syncDeleteBook(tx *database.DB, bookUUID string) error {
 		return nil
 	}
 
+	// local-only books are no longer tracked by the server
+	if localOnly {
+		return nil
+	}
+
 	// if local copy is dirty, noop. it will be uploaded to the server later
 	if dirty {
 		return nil
@@ -428,10 +594,12 @@ func syncDeleteBook(tx *database.DB, bookUUID string) error {
 		return errors.Wrapf(err, "deleting local book %s", bookUUID)
 	}
 
+	r.PulledBooks.Deleted++
+
 	return nil
 }
 
-func fullSyncBook(tx *database.DB, b client.SyncFragBook) error {
+func fullSyncBook(tx *database.DB, b client.SyncFragBook, r *syncReport) error {
 	var localUSN int
 	var dirty bool
 	err := tx.QueryRow("SELECT usn, dirty FROM books WHERE uuid = ?", b.UUID).Scan(&localUSN, &dirty)
@@ -441,11 +609,11 @@ func fullSyncBook(tx *database.DB, b client.SyncFragBook) error {
 
 	// if book exists in the server and does not exist in the client
 	if err == sql.ErrNoRows {
-		if e := mergeBook(tx, b, modeInsert); e != nil {
+		if e := mergeBook(tx, b, modeInsert, r); e != nil {
 			return errors.Wrapf(e, "resolving book")
 		}
 	} else if b.USN > localUSN {
-		if e := mergeBook(tx, b, modeUpdate); e != nil {
+		if e := mergeBook(tx, b, modeUpdate, r); e != nil {
 			return errors.Wrapf(e, "resolving book")
 		}
 	}
@@ -453,186 +621,230 @@ func fullSyncBook(tx *database.DB, b client.SyncFragBook) error {
 	return nil
 }
 
-// checkNoteInList checks if the given syncList contains the note with the given uuid
-func checkNoteInList(uuid string, list *syncList) bool {
-	if _, ok := list.Notes[uuid]; ok {
-		return true
+// createServerResourcesTable creates a temporary table to record the uuids of all resources
+// present in the server during a full sync, so that they need not be held in memory
+func createServerResourcesTable(tx *database.DB) error {
+	if _, err := tx.Exec(`CREATE TEMP TABLE IF NOT EXISTS server_resources
+		(
+			uuid text NOT NULL,
+			type text NOT NULL,
+			PRIMARY KEY (uuid, type)
+		)`); err != nil {
+		return errors.Wrap(err, "creating the table")
+	}
+	if _, err := tx.Exec("DELETE FROM server_resources"); err != nil {
+		return errors.Wrap(err, "clearing the table")
 	}
 
-	if _, ok := list.ExpungedNotes[uuid]; ok {
-		return true
+	return nil
+}
+
+// dropServerResourcesTable drops the table created by createServerResourcesTable
+func dropServerResourcesTable(tx *database.DB) error {
+	if _, err := tx.Exec("DROP TABLE IF EXISTS server_resources"); err != nil {
+		return errors.Wrap(err, "dropping the table")
 	}
 
-	return false
+	return nil
 }
 
-// checkBookInList checks if the given syncList contains the book with the given uuid
-func checkBookInList(uuid string, list *syncList) bool {
-	if _, ok := list.Books[uuid]; ok {
-		return true
+func insertServerResource(tx *database.DB, uuid, resourceType string) error {
+	if _, err := tx.Exec("INSERT OR IGNORE INTO server_resources (uuid, type) VALUES (?, ?)", uuid, resourceType); err != nil {
+		return errors.Wrapf(err, "inserting %s %s", resourceType, uuid)
 	}
 
-	if _, ok := list.ExpungedBooks[uuid]; ok {
-		return true
+	return nil
+}
+
+// recordServerResources records the resources in the given sync list as present in the server
+func recordServerResources(tx *database.DB, list *syncList) error {
+	for uuid := range list.Notes {
+		if err := insertServerResource(tx, uuid, resourceTypeNote); err != nil {
+			return err
+		}
+	}
+	for uuid := range list.ExpungedNotes {
+		if err := insertServerResource(tx, uuid, resourceTypeNote); err != nil {
+			return err
+		}
+	}
+	for uuid := range list.Books {
+		if err := insertServerResource(tx, uuid, resourceTypeBook); err != nil {
+			return err
+		}
+	}
+	for uuid := range list.ExpungedBooks {
+		if err := insertServerResource(tx, uuid, resourceTypeBook); err != nil {
+			return err
+		}
 	}
 
-	return false
+	return nil
 }
 
 // cleanLocalNotes deletes from the local database any notes that are in invalid state
-// judging by the full list of resources in the server. Concretely, the only acceptable
+// judging by the resources recorded in the server during a full sync. Concretely, the only acceptable
 // situation in which a local note is not present in the server is if it is new and has not been
 // uploaded (i.e. dirty and usn is 0). Otherwise, it is a result of some kind of error and should be cleaned.
-func cleanLocalNotes(tx *database.DB, fullList *syncList) error {
-	rows, err := tx.Query("SELECT uuid, usn, dirty FROM notes")
+// Notes in local-only books are never present in the server and are left untouched.
+func cleanLocalNotes(tx *database.DB, r *syncReport) error {
+	rows, err := tx.Query(`SELECT uuid, usn, dirty FROM notes
+		WHERE uuid NOT IN (SELECT uuid FROM server_resources WHERE type = ?)
+		AND book_uuid NOT IN (SELECT uuid FROM books WHERE local_only)`, resourceTypeNote)
 	if err != nil {
 		return errors.Wrap(err, "getting local notes")
 	}
 	defer rows.Close()
 
+	var notes []database.Note
 	for rows.Next() {
 		var note database.Note
 		if err := rows.Scan(&note.UUID, &note.USN, &note.Dirty); err != nil {
 			return errors.Wrap(err, "scanning a row for local note")
 		}
 
-		ok := checkNoteInList(note.UUID, fullList)
-		if !ok && (!note.Dirty || note.USN != 0) {
-			err = note.Expunge(tx)
-			if err != nil {
-				return errors.Wrap(err, "expunging a note")
-			}
+		if !note.Dirty || note.USN != 0 {
+			notes = append(notes, note)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "iterating local notes")
+	}
+
+	for _, note := range notes {
+		if err := note.Expunge(tx); err != nil {
+			return errors.Wrap(err, "expunging a note")
 		}
 	}
 
+	r.CleanedNotes += len(notes)
+
 	return nil
 }
 
-// cleanLocalBooks deletes from the local database any books that are in invalid state
-func cleanLocalBooks(tx *database.DB, fullList *syncList) error {
-	rows, err := tx.Query("SELECT uuid, usn, dirty FROM books")
+// cleanLocalBooks deletes from the local database any books that are in invalid state.
+// Local-only books are never present in the server and are left untouched.
+func cleanLocalBooks(tx *database.DB, r *syncReport) error {
+	rows, err := tx.Query(`SELECT uuid, usn, dirty FROM books
+		WHERE uuid NOT IN (SELECT uuid FROM server_resources WHERE type = ?)
+		AND NOT local_only`, resourceTypeBook)
 	if err != nil {
 		return errors.Wrap(err, "getting local books")
 	}
 	defer rows.Close()
 
+	var books []database.Book
 	for rows.Next() {
 		var book database.Book
 		if err := rows.Scan(&book.UUID, &book.USN, &book.Dirty); err != nil {
 			return errors.Wrap(err, "scanning a row for local book")
 		}
 
-		ok := checkBookInList(book.UUID, fullList)
-		if !ok && (!book.Dirty || book.USN != 0) {
-			err = book.Expunge(tx)
-			if err != nil {
-				return errors.Wrap(err, "expunging a book")
-			}
+		if !book.Dirty || book.USN != 0 {
+			books = append(books, book)
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "iterating local books")
+	}
+
+	for _, book := range books {
+		if err := book.Expunge(tx); err != nil {
+			return errors.Wrap(err, "expunging a book")
+		}
+	}
+
+	r.CleanedBooks += len(books)
 
 	return nil
 }
 
-func fullSync(ctx context.DnoteCtx, tx *database.DB) error {
+func fullSync(ctx context.DnoteCtx, tx *database.DB, filter *bookFilter, r *syncReport) error {
 	log.Debug("performing a full sync\n")
-	log.Info("resolving delta.")
+	r.FullSync = true
+	r.progress.start("resolving delta.")
 
-	list, err := getSyncList(ctx, 0)
-	if err != nil {
-		return errors.Wrap(err, "getting sync list")
+	if err := createServerResourcesTable(tx); err != nil {
+		return errors.Wrap(err, "preparing to record the server resources")
 	}
 
-	fmt.Printf(" (total %d).", list.getLength())
+	result, err := streamSyncFragments(ctx, 0, r, func(list *syncList) error {
+		// record all resources so that the ones in the filtered books are not cleaned
+		if err := recordServerResources(tx, list); err != nil {
+			return errors.Wrap(err, "recording the server resources")
+		}
+
+		filter.apply(list)
+
+		return applySyncList(tx, list, r, fullSyncBook, fullSyncNote)
+	})
+	if err != nil {
+		return errors.Wrap(err, "syncing fragments")
+	}
 
 	// clean resources that are in erroneous states
-	if err := cleanLocalNotes(tx, &list); err != nil {
+	if err := cleanLocalNotes(tx, r); err != nil {
 		return errors.Wrap(err, "cleaning up local notes")
 	}
-	if err := cleanLocalBooks(tx, &list); err != nil {
+	if err := cleanLocalBooks(tx, r); err != nil {
 		return errors.Wrap(err, "cleaning up local books")
 	}
-
-	for _, note := range list.Notes {
-		if err := fullSyncNote(tx, note); err != nil {
-			return errors.Wrap(err, "merging note")
-		}
-	}
-	for _, book := range list.Books {
-		if err := fullSyncBook(tx, book); err != nil {
-			return errors.Wrap(err, "merging book")
-		}
+	if err := cleanFilteredBooks(tx, filter); err != nil {
+		return errors.Wrap(err, "cleaning up the books that are not synced")
 	}
 
-	for noteUUID := range list.ExpungedNotes {
-		if err := syncDeleteNote(tx, noteUUID); err != nil {
-			return errors.Wrap(err, "deleting note")
-		}
-	}
-	for bookUUID := range list.ExpungedBooks {
-		if err := syncDeleteBook(tx, bookUUID); err != nil {
-			return errors.Wrap(err, "deleting book")
-		}
+	if err := dropServerResourcesTable(tx); err != nil {
+		return errors.Wrap(err, "cleaning up the server resources")
 	}
 
-	err = saveSyncState(tx, list.MaxCurrentTime, list.MaxUSN)
+	err = saveSyncState(tx, result.MaxCurrentTime, result.MaxUSN)
 	if err != nil {
 		return errors.Wrap(err, "saving sync state")
 	}
 
-	fmt.Println(" done.")
+	r.progress.finish("(total %d). done.", result.Total)
 
 	return nil
 }
 
-func stepSync(ctx context.DnoteCtx, tx *database.DB, afterUSN int) error {
+func stepSync(ctx context.DnoteCtx, tx *database.DB, afterUSN int, filter *bookFilter, r *syncReport) error {
 	log.Debug("performing a step sync\n")
 
-	log.Info("resolving delta.")
+	r.progress.start("resolving delta.")
 
-	list, err := getSyncList(ctx, afterUSN)
-	if err != nil {
-		return errors.Wrap(err, "getting sync list")
-	}
+	result, err := streamSyncFragments(ctx, afterUSN, r, func(list *syncList) error {
+		filter.apply(list)
 
-	fmt.Printf(" (total %d).", list.getLength())
-
-	for _, note := range list.Notes {
-		if err := stepSyncNote(tx, note); err != nil {
-			return errors.Wrap(err, "merging note")
-		}
-	}
-	for _, book := range list.Books {
-		if err := stepSyncBook(tx, book); err != nil {
-			return errors.Wrap(err, "merging book")
-		}
-	}
-
-	for noteUUID := range list.ExpungedNotes {
-		if err := syncDeleteNote(tx, noteUUID); err != nil {
-			return errors.Wrap(err, "deleting note")
-		}
-	}
-	for bookUUID := range list.ExpungedBooks {
-		if err := syncDeleteBook(tx, bookUUID); err != nil {
-			return errors.Wrap(err, "deleting book")
-		}
+		return applySyncList(tx, list, r, stepSyncBook, stepSyncNote)
+	})
+	if err != nil {
+		return errors.Wrap(err, "syncing fragments")
 	}
 
-	err = saveSyncState(tx, list.MaxCurrentTime, list.MaxUSN)
+	err = saveSyncState(tx, result.MaxCurrentTime, result.MaxUSN)
 	if err != nil {
 		return errors.Wrap(err, "saving sync state")
 	}
 
-	fmt.Println(" done.")
+	r.progress.finish("(total %d). done.", result.Total)
 
 	return nil
 }
 
-func sendBooks(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
+// sendBooks sends the changed books to the server. If onlyFailed is true, it only sends the
+// books that previously failed to be sent. If serverAhead is true, only the new books are sent
+// and the rest are held back. A book that is not sent is recorded in the outbox.
+func sendBooks(ctx context.DnoteCtx, tx *database.DB, onlyFailed, serverAhead bool, r *syncReport) (bool, error) {
 	isBehind := false
 
-	rows, err := tx.Query("SELECT uuid, label, usn, deleted FROM books WHERE dirty")
+	query := "SELECT uuid, label, usn, deleted, pinned, archived FROM books WHERE dirty AND NOT local_only"
+	var args []interface{}
+	if onlyFailed {
+		query += " AND uuid IN (SELECT uuid FROM outbox WHERE type = ?)"
+		args = append(args, resourceTypeBook)
+	}
+
+	rows, err := tx.Query(query, args...)
 	if err != nil {
 		return isBehind, errors.Wrap(err, "getting syncable books")
 	}
@@ -641,11 +853,12 @@ func sendBooks(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
 	for rows.Next() {
 		var book database.Book
 
-		if err = rows.Scan(&book.UUID, &book.Label, &book.USN, &book.Deleted); err != nil {
+		if err = rows.Scan(&book.UUID, &book.Label, &book.USN, &book.Deleted, &book.Pinned, &book.Archived); err != nil {
 			return isBehind, errors.Wrap(err, "scanning a syncable book")
 		}
 
 		log.Debug("sending book %s\n", book.UUID)
+		r.itemSent()
 
 		var respUSN int
 
@@ -659,9 +872,14 @@ func sendBooks(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
 
 				continue
 			} else {
-				resp, err := client.CreateBook(ctx, book.Label)
+				resp, err := client.CreateBook(ctx, book.Label, book.Pinned, book.Archived)
 				if err != nil {
-					return isBehind, errors.Wrap(err, "creating a book")
+					if err := recordFailure(tx, ctx.Clock.Now().Unix(), resourceTypeBook, book.UUID, errors.Wrap(err, "creating a book")); err != nil {
+						return isBehind, errors.Wrap(err, "recording the failure")
+					}
+					r.Failed++
+
+					continue
 				}
 
 				_, err = tx.Exec("UPDATE notes SET book_uuid = ? WHERE book_uuid = ?", resp.Book.UUID, book.UUID)
@@ -682,12 +900,25 @@ func sendBooks(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
 				}
 
 				respUSN = resp.Book.USN
+				r.PushedBooks.Created++
 			}
+		} else if serverAhead {
+			if err := recordFailure(tx, ctx.Clock.Now().Unix(), resourceTypeBook, book.UUID, errServerAhead); err != nil {
+				return isBehind, errors.Wrap(err, "recording the failure")
+			}
+			r.Failed++
+
+			continue
 		} else {
 			if book.Deleted {
 				resp, err := client.DeleteBook(ctx, book.UUID)
 				if err != nil {
-					return isBehind, errors.Wrap(err, "deleting a book")
+					if err := recordFailure(tx, ctx.Clock.Now().Unix(), resourceTypeBook, book.UUID, errors.Wrap(err, "deleting a book")); err != nil {
+						return isBehind, errors.Wrap(err, "recording the failure")
+					}
+					r.Failed++
+
+					continue
 				}
 
 				err = book.Expunge(tx)
@@ -696,10 +927,16 @@ func sendBooks(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
 				}
 
 				respUSN = resp.Book.USN
+				r.PushedBooks.Deleted++
 			} else {
-				resp, err := client.UpdateBook(ctx, book.Label, book.UUID)
+				resp, err := client.UpdateBook(ctx, book.Label, book.UUID, book.Pinned, book.Archived)
 				if err != nil {
-					return isBehind, errors.Wrap(err, "updating a book")
+					if err := recordFailure(tx, ctx.Clock.Now().Unix(), resourceTypeBook, book.UUID, errors.Wrap(err, "updating a book")); err != nil {
+						return isBehind, errors.Wrap(err, "recording the failure")
+					}
+					r.Failed++
+
+					continue
 				}
 
 				book.Dirty = false
@@ -710,6 +947,7 @@ func sendBooks(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
 				}
 
 				respUSN = resp.Book.USN
+				r.PushedBooks.Updated++
 			}
 		}
 
@@ -733,10 +971,21 @@ func sendBooks(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
 	return isBehind, nil
 }
 
-func sendNotes(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
+// sendNotes sends the changed notes to the server. If onlyFailed is true, it only sends the
+// notes that previously failed to be sent. If serverAhead is true, only the new notes are sent
+// and the rest are held back. A note that is not sent is recorded in the outbox.
+func sendNotes(ctx context.DnoteCtx, tx *database.DB, onlyFailed, serverAhead bool, r *syncReport) (bool, error) {
 	isBehind := false
 
-	rows, err := tx.Query("SELECT uuid, book_uuid, body, public, deleted, usn, added_on FROM notes WHERE dirty")
+	query := `SELECT uuid, book_uuid, body, public, deleted, usn, added_on, edited_on, due_on, remind_on, pinned, archived FROM notes
+		WHERE dirty AND book_uuid NOT IN (SELECT uuid FROM books WHERE local_only)`
+	var args []interface{}
+	if onlyFailed {
+		query += " AND uuid IN (SELECT uuid FROM outbox WHERE type = ?)"
+		args = append(args, resourceTypeNote)
+	}
+
+	rows, err := tx.Query(query, args...)
 	if err != nil {
 		return isBehind, errors.Wrap(err, "getting syncable notes")
 	}
@@ -745,11 +994,12 @@ func sendNotes(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
 	for rows.Next() {
 		var note database.Note
 
-		if err = rows.Scan(&note.UUID, &note.BookUUID, &note.Body, &note.Public, &note.Deleted, &note.USN, &note.AddedOn); err != nil {
+		if err = rows.Scan(&note.UUID, &note.BookUUID, &note.Body, &note.Public, &note.Deleted, &note.USN, &note.AddedOn, &note.EditedOn, &note.DueOn, &note.RemindOn, &note.Pinned, &note.Archived); err != nil {
 			return isBehind, errors.Wrap(err, "scanning a syncable note")
 		}
 
 		log.Debug("sending note %s\n", note.UUID)
+		r.itemSent()
 
 		var respUSN int
 
@@ -764,9 +1014,14 @@ func sendNotes(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
 
 				continue
 			} else {
-				resp, err := client.CreateNote(ctx, note.BookUUID, note.Body)
+				resp, err := client.CreateNote(ctx, note.BookUUID, note.Body, note.DueOn, note.RemindOn, note.Pinned, note.Archived)
 				if err != nil {
-					return isBehind, errors.Wrap(err, "creating a note")
+					if err := recordFailure(tx, ctx.Clock.Now().Unix(), resourceTypeNote, note.UUID, errors.Wrap(err, "creating a note")); err != nil {
+						return isBehind, errors.Wrap(err, "recording the failure")
+					}
+					r.Failed++
+
+					continue
 				}
 
 				note.Dirty = false
@@ -781,13 +1036,31 @@ func sendNotes(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
 					return isBehind, errors.Wrap(err, "updating note uuid")
 				}
 
+				err = updateNoteBaseBody(tx, note.UUID, note.Body)
+				if err != nil {
+					return isBehind, errors.Wrap(err, "saving base body")
+				}
+
 				respUSN = resp.Result.USN
+				r.PushedNotes.Created++
 			}
+		} else if serverAhead {
+			if err := recordFailure(tx, ctx.Clock.Now().Unix(), resourceTypeNote, note.UUID, errServerAhead); err != nil {
+				return isBehind, errors.Wrap(err, "recording the failure")
+			}
+			r.Failed++
+
+			continue
 		} else {
 			if note.Deleted {
 				resp, err := client.DeleteNote(ctx, note.UUID)
 				if err != nil {
-					return isBehind, errors.Wrap(err, "deleting a note")
+					if err := recordFailure(tx, ctx.Clock.Now().Unix(), resourceTypeNote, note.UUID, errors.Wrap(err, "deleting a note")); err != nil {
+						return isBehind, errors.Wrap(err, "recording the failure")
+					}
+					r.Failed++
+
+					continue
 				}
 
 				err = note.Expunge(tx)
@@ -796,10 +1069,16 @@ func sendNotes(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
 				}
 
 				respUSN = resp.Result.USN
+				r.PushedNotes.Deleted++
 			} else {
-				resp, err := client.UpdateNote(ctx, note.UUID, note.BookUUID, note.Body, note.Public)
+				resp, err := client.UpdateNote(ctx, note.UUID, note.BookUUID, note.Body, note.Public, note.DueOn, note.RemindOn, note.Pinned, note.Archived)
 				if err != nil {
-					return isBehind, errors.Wrap(err, "updating a note")
+					if err := recordFailure(tx, ctx.Clock.Now().Unix(), resourceTypeNote, note.UUID, errors.Wrap(err, "updating a note")); err != nil {
+						return isBehind, errors.Wrap(err, "recording the failure")
+					}
+					r.Failed++
+
+					continue
 				}
 
 				note.Dirty = false
@@ -809,7 +1088,13 @@ func sendNotes(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
 					return isBehind, errors.Wrap(err, "marking note dirty")
 				}
 
+				err = updateNoteBaseBody(tx, note.UUID, note.Body)
+				if err != nil {
+					return isBehind, errors.Wrap(err, "saving base body")
+				}
+
 				respUSN = resp.Result.USN
+				r.PushedNotes.Updated++
 			}
 		}
 
@@ -833,27 +1118,123 @@ func sendNotes(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
 	return isBehind, nil
 }
 
-func sendChanges(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
-	log.Info("sending changes.")
+// settleLocalOnly handles the changes in local-only books, which are never uploaded.
+// Notes that were moved into a local-only book after having been synced are deleted
+// from the server, and the books and notes removed locally are expunged.
+func settleLocalOnly(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
+	isBehind := false
+
+	rows, err := tx.Query(`SELECT uuid, usn, deleted FROM notes
+		WHERE dirty AND (usn > 0 OR deleted)
+		AND book_uuid IN (SELECT uuid FROM books WHERE local_only)`)
+	if err != nil {
+		return isBehind, errors.Wrap(err, "getting notes in local-only books")
+	}
+	defer rows.Close()
+
+	var notes []database.Note
+	for rows.Next() {
+		var note database.Note
+		if err := rows.Scan(&note.UUID, &note.USN, &note.Deleted); err != nil {
+			return isBehind, errors.Wrap(err, "scanning a note in a local-only book")
+		}
+
+		notes = append(notes, note)
+	}
+	if err := rows.Err(); err != nil {
+		return isBehind, errors.Wrap(err, "iterating notes in local-only books")
+	}
+
+	for _, note := range notes {
+		if note.USN > 0 {
+			log.Debug("withdrawing note %s\n", note.UUID)
+
+			resp, err := client.DeleteNote(ctx, note.UUID)
+			if err != nil {
+				return isBehind, errors.Wrap(err, "deleting a note")
+			}
+
+			lastMaxUSN, err := getLastMaxUSN(tx)
+			if err != nil {
+				return isBehind, errors.Wrap(err, "getting last max usn")
+			}
+
+			if resp.Result.USN == lastMaxUSN+1 {
+				if err := updateLastMaxUSN(tx, lastMaxUSN+1); err != nil {
+					return isBehind, errors.Wrap(err, "updating last max usn")
+				}
+			} else {
+				isBehind = true
+			}
+		}
+
+		if note.Deleted {
+			if err := note.Expunge(tx); err != nil {
+				return isBehind, errors.Wrap(err, "expunging a note locally")
+			}
+		} else {
+			if _, err := tx.Exec("UPDATE notes SET usn = ?, base_body = NULL WHERE uuid = ?", 0, note.UUID); err != nil {
+				return isBehind, errors.Wrap(err, "detaching a note from the server")
+			}
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM books WHERE local_only AND deleted"); err != nil {
+		return isBehind, errors.Wrap(err, "expunging local-only books")
+	}
+
+	return isBehind, nil
+}
+
+// sendChanges sends the local changes to the server. If onlyFailed is true, it only
+// sends the changes in the outbox. If serverAhead is true, the server has changes that
+// have not been pulled, and the changes to the resources already on the server are
+// recorded in the outbox instead of being sent so that they do not overwrite those.
+func sendChanges(ctx context.DnoteCtx, tx *database.DB, onlyFailed, serverAhead bool, r *syncReport) (bool, error) {
+	r.progress.start("sending changes.")
 
 	var delta int
-	err := tx.QueryRow("SELECT (SELECT count(*) FROM notes WHERE dirty) + (SELECT count(*) FROM books WHERE dirty)").Scan(&delta)
+	var err error
+	if onlyFailed {
+		err = tx.QueryRow("SELECT count(*) FROM outbox").Scan(&delta)
+	} else {
+		err = tx.QueryRow(`SELECT
+		(SELECT count(*) FROM notes WHERE dirty AND book_uuid NOT IN (SELECT uuid FROM books WHERE local_only)) +
+		(SELECT count(*) FROM books WHERE dirty AND NOT local_only)`).Scan(&delta)
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "counting the changes to send")
+	}
+
+	r.sendTotal = delta
 
-	fmt.Printf(" (total %d).", delta)
+	// withdrawing the notes in local-only books deletes them from the server, which
+	// waits until the changes on the server have been pulled
+	var behind1 bool
+	if !serverAhead {
+		behind1, err = settleLocalOnly(ctx, tx)
+		if err != nil {
+			return behind1, errors.Wrap(err, "settling local-only books")
+		}
+	}
 
-	behind1, err := sendBooks(ctx, tx)
+	behind2, err := sendBooks(ctx, tx, onlyFailed, serverAhead, r)
 	if err != nil {
-		return behind1, errors.Wrap(err, "sending books")
+		return behind2, errors.Wrap(err, "sending books")
 	}
 
-	behind2, err := sendNotes(ctx, tx)
+	behind3, err := sendNotes(ctx, tx, onlyFailed, serverAhead, r)
 	if err != nil {
-		return behind2, errors.Wrap(err, "sending notes")
+		return behind3, errors.Wrap(err, "sending notes")
+	}
+
+	if err := pruneOutbox(tx); err != nil {
+		return false, errors.Wrap(err, "pruning the outbox")
 	}
 
-	fmt.Println(" done.")
+	r.progress.finish("(total %d). done.", delta)
 
-	isBehind := behind1 || behind2
+	isBehind := behind1 || behind2 || behind3
 
 	return isBehind, nil
 }
@@ -885,61 +1266,124 @@ func saveSyncState(tx *database.DB, serverTime int64, serverMaxUSN int) error {
 	return nil
 }
 
-func newRun(ctx context.DnoteCtx) infra.RunEFunc {
-	return func(cmd *cobra.Command, args []string) error {
-		if ctx.SessionKey == "" {
-			return errors.New("not logged in")
-		}
+// syncOptions specifies how a sync is performed
+type syncOptions struct {
+	// Full makes the sync download all data from the server
+	Full bool
+	// PullOnly skips sending the local changes to the server
+	PullOnly bool
+	// PushOnly skips getting the changes from the server
+	PushOnly bool
+	// OnlyFailed sends only the changes that previously failed to be sent
+	OnlyFailed bool
+}
 
-		if err := migrate.Run(ctx, migrate.RemoteSequence, migrate.RemoteMode); err != nil {
-			return errors.Wrap(err, "running remote migrations")
-		}
+// pull gets the changes from the server and applies them to the local database
+func pull(ctx context.DnoteCtx, tx *database.DB, full bool, filter *bookFilter, r *syncReport) error {
+	syncState, err := client.GetSyncState(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting the sync state from the server")
+	}
+	lastSyncAt, err := getLastSyncAt(tx)
+	if err != nil {
+		return errors.Wrap(err, "getting the last sync time")
+	}
+	lastMaxUSN, err := getLastMaxUSN(tx)
+	if err != nil {
+		return errors.Wrap(err, "getting the last max_usn")
+	}
 
-		tx, err := ctx.DB.Begin()
-		if err != nil {
-			return errors.Wrap(err, "beginning a transaction")
+	log.Debug("lastSyncAt: %d, lastMaxUSN: %d, syncState: %+v\n", lastSyncAt, lastMaxUSN, syncState)
+
+	if err := filter.load(ctx); err != nil {
+		return errors.Wrap(err, "loading the book filter")
+	}
+	lastFilter, err := getLastSyncFilter(tx)
+	if err != nil {
+		return errors.Wrap(err, "getting the last sync filter")
+	}
+
+	// if the filter changed, the books that were previously left out need to be synced
+	if full || lastSyncAt < syncState.FullSyncBefore || filter.String() != lastFilter {
+		// a full sync can remove local data that the server does not have
+		if err := snapshot.Auto(ctx, snapshot.ReasonFullSync); err != nil {
+			return errors.Wrap(err, "backing up the database before a full sync")
 		}
 
-		syncState, err := client.GetSyncState(ctx)
-		if err != nil {
-			return errors.Wrap(err, "getting the sync state from the server")
+		if err := fullSync(ctx, tx, filter, r); err != nil {
+			return errors.Wrap(err, "performing a full sync")
 		}
-		lastSyncAt, err := getLastSyncAt(tx)
-		if err != nil {
-			return errors.Wrap(err, "getting the last sync time")
+	} else if lastMaxUSN != syncState.MaxUSN {
+		if err := stepSync(ctx, tx, lastMaxUSN, filter, r); err != nil {
+			return errors.Wrap(err, "performing a step sync")
 		}
-		lastMaxUSN, err := getLastMaxUSN(tx)
-		if err != nil {
-			return errors.Wrap(err, "getting the last max_usn")
+	} else {
+		// if no need to sync from the server, simply update the last sync timestamp and proceed to send changes
+		if err := updateLastSyncAt(tx, syncState.CurrentTime); err != nil {
+			return errors.Wrap(err, "updating last sync at")
 		}
+	}
 
-		log.Debug("lastSyncAt: %d, lastMaxUSN: %d, syncState: %+v\n", lastSyncAt, lastMaxUSN, syncState)
+	if err := saveSyncFilter(tx, filter); err != nil {
+		return errors.Wrap(err, "saving the sync filter")
+	}
 
-		var syncErr error
-		if isFullSync || lastSyncAt < syncState.FullSyncBefore {
-			syncErr = fullSync(ctx, tx)
-		} else if lastMaxUSN != syncState.MaxUSN {
-			syncErr = stepSync(ctx, tx, lastMaxUSN)
-		} else {
-			// if no need to sync from the server, simply update the last sync timestamp and proceed to send changes
-			err = updateLastSyncAt(tx, syncState.CurrentTime)
-			if err != nil {
-				return errors.Wrap(err, "updating last sync at")
-			}
+	return nil
+}
+
+// isServerAhead returns true if the server has changes that have not been pulled
+func isServerAhead(ctx context.DnoteCtx, tx *database.DB) (bool, error) {
+	syncState, err := client.GetSyncState(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "getting the sync state from the server")
+	}
+	lastMaxUSN, err := getLastMaxUSN(tx)
+	if err != nil {
+		return false, errors.Wrap(err, "getting the last max_usn")
+	}
+
+	return syncState.MaxUSN > lastMaxUSN, nil
+}
+
+// doSync performs a single sync with the server. Unless specified otherwise in the options,
+// it pulls the changes from the server and then sends the local changes. Local changes
+// that are not sent are recorded in the outbox while the rest of the changes are saved,
+// and the size of the outbox is reported. What happened in the sync is accumulated in the report.
+func doSync(ctx context.DnoteCtx, opts syncOptions, r *syncReport) error {
+	tx, err := ctx.DB.Begin()
+	if err != nil {
+		return errors.Wrap(err, "beginning a transaction")
+	}
+
+	filter := newBookFilter(ctx.SyncInclude, ctx.SyncExclude)
+
+	// sending the changes without pulling would overwrite the notes changed on the
+	// server since the last sync without merging them. Such changes are held back.
+	serverAhead := false
+	if opts.PushOnly {
+		serverAhead, err = isServerAhead(ctx, tx)
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "checking the server for unpulled changes")
 		}
-		if syncErr != nil {
+	}
+
+	if !opts.PushOnly {
+		if err := pull(ctx, tx, opts.Full, filter, r); err != nil {
 			tx.Rollback()
-			return errors.Wrap(syncErr, "syncing changes from the server")
+			return errors.Wrap(err, "syncing changes from the server")
 		}
+	}
 
-		isBehind, err := sendChanges(ctx, tx)
+	if !opts.PullOnly {
+		isBehind, err := sendChanges(ctx, tx, opts.OnlyFailed, serverAhead, r)
 		if err != nil {
 			tx.Rollback()
 			return errors.Wrap(err, "sending changes")
 		}
 
 		// if server state gets ahead of that of client during the sync, do an additional step sync
-		if isBehind {
+		if isBehind && !opts.PushOnly {
 			log.Debug("performing another step sync because client is behind\n")
 
 			updatedLastMaxUSN, err := getLastMaxUSN(tx)
@@ -948,21 +1392,88 @@ func newRun(ctx context.DnoteCtx) infra.RunEFunc {
 				return errors.Wrap(err, "getting the new last max_usn")
 			}
 
-			err = stepSync(ctx, tx, updatedLastMaxUSN)
+			err = stepSync(ctx, tx, updatedLastMaxUSN, filter, r)
 			if err != nil {
 				tx.Rollback()
 				return errors.Wrap(err, "performing the follow-up step sync")
 			}
 		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "committing a transaction")
+	}
+
+	outbox, err := countOutbox(ctx.DB)
+	if err != nil {
+		return errors.Wrap(err, "counting the outbox")
+	}
+	r.Outbox = outbox
+
+	return nil
+}
+
+func newRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		if !client.IsAuthorized(ctx) {
+			return errors.New("not logged in")
+		}
+
+		if err := migrate.Run(ctx, migrate.RemoteSequence, migrate.RemoteMode); err != nil {
+			return errors.Wrap(err, "running remote migrations")
+		}
+
+		if len(booksFlag) > 0 {
+			ctx.SyncInclude = booksFlag
+			ctx.SyncExclude = nil
+		}
+
+		if watchFlag {
+			if err := watch(ctx, watchIntervalFlag); err != nil {
+				return errors.Wrap(err, "watching for changes")
+			}
+
+			return nil
+		}
 
-		tx.Commit()
+		l, err := acquireLock(ctx)
+		if err != nil {
+			return errors.Wrap(err, "acquiring the sync lock")
+		}
+		defer l.release()
+
+		opts := syncOptions{
+			Full:       isFullSync,
+			PullOnly:   pullOnlyFlag,
+			PushOnly:   pushOnlyFlag || retryFailedFlag,
+			OnlyFailed: retryFailedFlag,
+		}
+		r := &syncReport{}
+		if formatFlag == formatJSON {
+			err := syncAndReport(ctx, opts, r)
+			if e := printJSON(r.Summary); e != nil {
+				return errors.Wrap(e, "printing the summary")
+			}
+			if err != nil {
+				return err
+			}
+
+			return checkOutbox(r.Summary)
+		}
+
+		r.progress = newProgress(color.Output, terminal.IsTerminal(int(os.Stdout.Fd())))
+		if err := syncAndReport(ctx, opts, r); err != nil {
+			return err
+		}
 
 		log.Success("success\n")
+		printSummary(r.Summary)
 
 		if err := upgrade.Check(ctx); err != nil {
 			log.Error(errors.Wrap(err, "automatically checking updates").Error())
 		}
 
-		return nil
+		return checkOutbox(r.Summary)
 	}
 }
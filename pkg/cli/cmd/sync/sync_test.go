@@ -19,6 +19,7 @@
 package sync
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -104,6 +105,160 @@ func TestProcessFragments(t *testing.T) {
 	assert.DeepEqual(t, sl, expected, "syncList mismatch")
 }
 
+// newFragmentServer returns a test server that serves the given sync fragments keyed by after_usn
+func newFragmentServer(t *testing.T, fragments map[string]client.SyncFragment) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/sync/fragment" {
+			t.Fatalf("unrecognized endpoint reached Method: %s Path: %s", r.Method, r.URL.Path)
+		}
+
+		frag, ok := fragments[r.URL.Query().Get("after_usn")]
+		if !ok {
+			t.Fatalf("unexpected after_usn %s", r.URL.Query().Get("after_usn"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(client.GetSyncFragmentResp{Fragment: frag}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}))
+}
+
+func TestStreamSyncFragments(t *testing.T) {
+	ts := newFragmentServer(t, map[string]client.SyncFragment{
+		"0": {
+			FragMaxUSN:  10,
+			UserMaxUSN:  12,
+			CurrentTime: 1550436136,
+			Notes: []client.SyncFragNote{
+				{UUID: "n1-uuid", USN: 5, Body: "n1 body"},
+			},
+			Books: []client.SyncFragBook{
+				{UUID: "b1-uuid", USN: 10},
+			},
+		},
+		"10": {
+			FragMaxUSN:    12,
+			UserMaxUSN:    12,
+			CurrentTime:   1550436137,
+			Notes:         []client.SyncFragNote{{UUID: "n1-uuid", USN: 11, Body: "n1 body edited"}},
+			ExpungedNotes: []string{"n2-uuid"},
+		},
+		"12": {
+			FragMaxUSN:  0,
+			UserMaxUSN:  12,
+			CurrentTime: 1550436137,
+		},
+	})
+	defer ts.Close()
+
+	ctx := context.InitTestCtx(t, paths, nil)
+	ctx.APIEndpoint = ts.URL
+	defer context.TeardownTestCtx(t, ctx)
+	testutils.Login(t, &ctx)
+
+	// execute
+	var lists []syncList
+	result, err := streamSyncFragments(ctx, 0, &syncReport{}, func(list *syncList) error {
+		lists = append(lists, *list)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	// test
+	assert.Equal(t, len(lists), 3, "fragment count mismatch")
+	assert.Equal(t, lists[0].Notes["n1-uuid"].Body, "n1 body", "first fragment note mismatch")
+	assert.Equal(t, lists[1].Notes["n1-uuid"].Body, "n1 body edited", "second fragment note mismatch")
+	assert.Equal(t, lists[1].ExpungedNotes["n2-uuid"], true, "second fragment expunged note mismatch")
+	assert.Equal(t, lists[2].getLength(), 0, "last fragment length mismatch")
+	assert.Equal(t, result, syncResult{
+		Total:          4,
+		MaxUSN:         12,
+		MaxCurrentTime: 1550436137,
+	}, "result mismatch")
+}
+
+func TestFullSync(t *testing.T) {
+	ts := newFragmentServer(t, map[string]client.SyncFragment{
+		"0": {
+			FragMaxUSN:  10,
+			UserMaxUSN:  11,
+			CurrentTime: 1550436136,
+			Notes: []client.SyncFragNote{
+				{UUID: "n1-uuid", BookUUID: "b1-uuid", USN: 5, Body: "n1 body", AddedOn: 1541108743},
+			},
+			Books: []client.SyncFragBook{
+				{UUID: "b1-uuid", USN: 10, Label: "b1-label"},
+			},
+		},
+		"10": {
+			FragMaxUSN:    11,
+			UserMaxUSN:    11,
+			CurrentTime:   1550436137,
+			Notes:         []client.SyncFragNote{{UUID: "n1-uuid", BookUUID: "b1-uuid", USN: 11, Body: "n1 body edited", AddedOn: 1541108743}},
+			ExpungedNotes: []string{"n2-uuid"},
+		},
+		"11": {
+			FragMaxUSN:  0,
+			UserMaxUSN:  11,
+			CurrentTime: 1550436137,
+		},
+	})
+	defer ts.Close()
+
+	ctx := context.InitTestCtx(t, paths, nil)
+	ctx.APIEndpoint = ts.URL
+	defer context.TeardownTestCtx(t, ctx)
+	testutils.Login(t, &ctx)
+
+	db := ctx.DB
+	database.MustExec(t, "inserting last max usn", db, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastMaxUSN, 0)
+	database.MustExec(t, "inserting last sync at", db, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastSyncAt, 0)
+
+	// expunged in the server
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", 3, "n2 body", 1541108743, false, false)
+	// non-existent in the server and in an invalid state
+	database.MustExec(t, "inserting n3", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n3-uuid", "b1-uuid", 4, "n3 body", 1541108743, false, false)
+	// created locally and not uploaded yet
+	database.MustExec(t, "inserting n4", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n4-uuid", "b1-uuid", 0, "n4 body", 1541108743, false, true)
+
+	// execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "beginning a transaction"))
+	}
+	if err := fullSync(ctx, tx, newBookFilter(nil, nil), &syncReport{}); err != nil {
+		tx.Rollback()
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+	tx.Commit()
+
+	// test
+	var noteCount, bookCount int
+	database.MustScan(t, "counting notes", db.QueryRow("SELECT count(*) FROM notes"), &noteCount)
+	database.MustScan(t, "counting books", db.QueryRow("SELECT count(*) FROM books"), &bookCount)
+	assert.Equal(t, noteCount, 2, "note count mismatch")
+	assert.Equal(t, bookCount, 1, "book count mismatch")
+
+	var n1 database.Note
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT body, usn FROM notes WHERE uuid = ?", "n1-uuid"), &n1.Body, &n1.USN)
+	assert.Equal(t, n1.Body, "n1 body edited", "n1 body mismatch")
+	assert.Equal(t, n1.USN, 11, "n1 usn mismatch")
+
+	var n4Count int
+	database.MustScan(t, "counting n4", db.QueryRow("SELECT count(*) FROM notes WHERE uuid = ?", "n4-uuid"), &n4Count)
+	assert.Equal(t, n4Count, 1, "n4 should not be cleaned")
+
+	var lastMaxUSN, lastSyncAt int
+	database.MustScan(t, "getting last max usn", db.QueryRow("SELECT value FROM system WHERE key = ?", consts.SystemLastMaxUSN), &lastMaxUSN)
+	database.MustScan(t, "getting last sync at", db.QueryRow("SELECT value FROM system WHERE key = ?", consts.SystemLastSyncAt), &lastSyncAt)
+	assert.Equal(t, lastMaxUSN, 11, "last max usn mismatch")
+	assert.Equal(t, lastSyncAt, 1550436137, "last sync at mismatch")
+}
+
 func TestGetLastSyncAt(t *testing.T) {
 	// set up
 	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
@@ -192,7 +347,7 @@ func TestResolveLabel(t *testing.T) {
 				t.Fatalf(errors.Wrap(err, fmt.Sprintf("beginning a transaction for test case %d", idx)).Error())
 			}
 
-			got, err := resolveLabel(tx, tc.input)
+			got, err := ResolveLabel(tx, tc.input)
 			if err != nil {
 				t.Fatalf(errors.Wrap(err, fmt.Sprintf("executing for test case %d", idx)).Error())
 			}
@@ -215,7 +370,7 @@ func TestSyncDeleteNote(t *testing.T) {
 			t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
 		}
 
-		if err := syncDeleteNote(tx, "nonexistent-note-uuid"); err != nil {
+		if err := syncDeleteNote(tx, "nonexistent-note-uuid", &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -257,7 +412,7 @@ func TestSyncDeleteNote(t *testing.T) {
 			t.Fatalf(errors.Wrap(err, "beginning a transaction for test case").Error())
 		}
 
-		if err := syncDeleteNote(tx, "n1-uuid"); err != nil {
+		if err := syncDeleteNote(tx, "n1-uuid", &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -327,7 +482,7 @@ func TestSyncDeleteNote(t *testing.T) {
 			t.Fatalf(errors.Wrap(err, "beginning a transaction for test case").Error())
 		}
 
-		if err := syncDeleteNote(tx, "n1-uuid"); err != nil {
+		if err := syncDeleteNote(tx, "n1-uuid", &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -376,7 +531,7 @@ func TestSyncDeleteBook(t *testing.T) {
 			t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
 		}
 
-		if err := syncDeleteBook(tx, "nonexistent-book-uuid"); err != nil {
+		if err := syncDeleteBook(tx, "nonexistent-book-uuid", &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -427,7 +582,7 @@ func TestSyncDeleteBook(t *testing.T) {
 			t.Fatalf(errors.Wrap(err, "beginning a transaction for test case").Error())
 		}
 
-		if err := syncDeleteBook(tx, b1UUID); err != nil {
+		if err := syncDeleteBook(tx, b1UUID, &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -495,7 +650,7 @@ func TestSyncDeleteBook(t *testing.T) {
 			t.Fatalf(errors.Wrap(err, "beginning a transaction for test case").Error())
 		}
 
-		if err := syncDeleteBook(tx, b1UUID); err != nil {
+		if err := syncDeleteBook(tx, b1UUID, &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -550,7 +705,7 @@ func TestSyncDeleteBook(t *testing.T) {
 			t.Fatalf(errors.Wrap(err, "beginning a transaction for test case").Error())
 		}
 
-		if err := syncDeleteBook(tx, b1UUID); err != nil {
+		if err := syncDeleteBook(tx, b1UUID, &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -612,7 +767,7 @@ func TestFullSyncNote(t *testing.T) {
 			Deleted:  false,
 		}
 
-		if err := fullSyncNote(tx, n); err != nil {
+		if err := fullSyncNote(tx, n, &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -848,7 +1003,7 @@ n1 body edited
 					Deleted:  tc.serverDeleted,
 				}
 
-				if err := fullSyncNote(tx, n); err != nil {
+				if err := fullSyncNote(tx, n, &syncReport{}); err != nil {
 					tx.Rollback()
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("executing for test case %d", idx)).Error())
 				}
@@ -905,7 +1060,7 @@ func TestFullSyncBook(t *testing.T) {
 			Deleted: false,
 		}
 
-		if err := fullSyncBook(tx, b); err != nil {
+		if err := fullSyncBook(tx, b, &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -1043,7 +1198,7 @@ func TestFullSyncBook(t *testing.T) {
 					Deleted: tc.serverDeleted,
 				}
 
-				if err := fullSyncBook(tx, b); err != nil {
+				if err := fullSyncBook(tx, b, &syncReport{}); err != nil {
 					tx.Rollback()
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("executing for test case %d", idx)).Error())
 				}
@@ -1098,7 +1253,7 @@ func TestStepSyncNote(t *testing.T) {
 			Deleted:  false,
 		}
 
-		if err := stepSyncNote(tx, n); err != nil {
+		if err := stepSyncNote(tx, n, &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -1260,7 +1415,7 @@ n1 body edited
 					Deleted:  tc.serverDeleted,
 				}
 
-				if err := stepSyncNote(tx, n); err != nil {
+				if err := stepSyncNote(tx, n, &syncReport{}); err != nil {
 					tx.Rollback()
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("executing for test case %d", idx)).Error())
 				}
@@ -1317,7 +1472,7 @@ func TestStepSyncBook(t *testing.T) {
 			Deleted: false,
 		}
 
-		if err := stepSyncBook(tx, b); err != nil {
+		if err := stepSyncBook(tx, b, &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -1441,7 +1596,7 @@ func TestStepSyncBook(t *testing.T) {
 					Deleted: tc.serverDeleted,
 				}
 
-				if err := fullSyncBook(tx, b); err != nil {
+				if err := fullSyncBook(tx, b, &syncReport{}); err != nil {
 					tx.Rollback()
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("executing for test case %d", idx)).Error())
 				}
@@ -1500,7 +1655,7 @@ func TestMergeBook(t *testing.T) {
 			Deleted: false,
 		}
 
-		if err := mergeBook(tx, b1, modeInsert); err != nil {
+		if err := mergeBook(tx, b1, modeInsert, &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -1545,7 +1700,7 @@ func TestMergeBook(t *testing.T) {
 			Deleted: false,
 		}
 
-		if err := mergeBook(tx, b, modeInsert); err != nil {
+		if err := mergeBook(tx, b, modeInsert, &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -1600,7 +1755,7 @@ func TestMergeBook(t *testing.T) {
 			Deleted: false,
 		}
 
-		if err := mergeBook(tx, b, modeInsert); err != nil {
+		if err := mergeBook(tx, b, modeInsert, &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -1668,7 +1823,7 @@ func TestMergeBook(t *testing.T) {
 			Deleted: false,
 		}
 
-		if err := mergeBook(tx, b1, modeUpdate); err != nil {
+		if err := mergeBook(tx, b1, modeUpdate, &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -1715,7 +1870,7 @@ func TestMergeBook(t *testing.T) {
 			Deleted: false,
 		}
 
-		if err := mergeBook(tx, b, modeUpdate); err != nil {
+		if err := mergeBook(tx, b, modeUpdate, &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -1771,7 +1926,7 @@ func TestMergeBook(t *testing.T) {
 			Deleted: false,
 		}
 
-		if err := mergeBook(tx, b, modeUpdate); err != nil {
+		if err := mergeBook(tx, b, modeUpdate, &syncReport{}); err != nil {
 			tx.Rollback()
 			t.Fatalf(errors.Wrap(err, "executing").Error())
 		}
@@ -1958,7 +2113,7 @@ func TestSendBooks(t *testing.T) {
 		t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
 	}
 
-	if _, err := sendBooks(ctx, tx); err != nil {
+	if _, err := sendBooks(ctx, tx, false, false, &syncReport{}); err != nil {
 		tx.Rollback()
 		t.Fatalf(errors.Wrap(err, "executing").Error())
 	}
@@ -2113,7 +2268,7 @@ func TestSendBooks_isBehind(t *testing.T) {
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("beginning a transaction for test case %d", idx)).Error())
 				}
 
-				isBehind, err := sendBooks(ctx, tx)
+				isBehind, err := sendBooks(ctx, tx, false, false, &syncReport{})
 				if err != nil {
 					tx.Rollback()
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("executing for test case %d", idx)).Error())
@@ -2161,7 +2316,7 @@ func TestSendBooks_isBehind(t *testing.T) {
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("beginning a transaction for test case %d", idx)).Error())
 				}
 
-				isBehind, err := sendBooks(ctx, tx)
+				isBehind, err := sendBooks(ctx, tx, false, false, &syncReport{})
 				if err != nil {
 					tx.Rollback()
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("executing for test case %d", idx)).Error())
@@ -2209,7 +2364,7 @@ func TestSendBooks_isBehind(t *testing.T) {
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("beginning a transaction for test case %d", idx)).Error())
 				}
 
-				isBehind, err := sendBooks(ctx, tx)
+				isBehind, err := sendBooks(ctx, tx, false, false, &syncReport{})
 				if err != nil {
 					tx.Rollback()
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("executing for test case %d", idx)).Error())
@@ -2322,7 +2477,7 @@ func TestSendNotes(t *testing.T) {
 		t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
 	}
 
-	if _, err := sendNotes(ctx, tx); err != nil {
+	if _, err := sendNotes(ctx, tx, false, false, &syncReport{}); err != nil {
 		tx.Rollback()
 		t.Fatalf(errors.Wrap(err, "executing").Error())
 	}
@@ -2377,6 +2532,13 @@ func TestSendNotes(t *testing.T) {
 	assert.Equal(t, n7.UUID, "n7-uuid", "n7 UUID mismatch")
 	assert.Equal(t, n8.UUID, "n8-uuid", "n8 UUID mismatch")
 	assert.Equal(t, n10.UUID, "server-n10-body-uuid", "n10 UUID mismatch")
+
+	// sent notes should record their body as the base for later merges
+	var n2Base, n3Base string
+	database.MustScan(t, "getting n2 base", db.QueryRow("SELECT base_body FROM notes WHERE body = ?", "n2-body"), &n2Base)
+	database.MustScan(t, "getting n3 base", db.QueryRow("SELECT base_body FROM notes WHERE body = ?", "n3-body"), &n3Base)
+	assert.Equal(t, n2Base, "n2-body", "n2 base_body mismatch")
+	assert.Equal(t, n3Base, "n3-body", "n3 base_body mismatch")
 }
 
 func TestSendNotes_addedOn(t *testing.T) {
@@ -2422,7 +2584,7 @@ func TestSendNotes_addedOn(t *testing.T) {
 		t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
 	}
 
-	if _, err := sendNotes(ctx, tx); err != nil {
+	if _, err := sendNotes(ctx, tx, false, false, &syncReport{}); err != nil {
 		tx.Rollback()
 		t.Fatalf(errors.Wrap(err, "executing").Error())
 	}
@@ -2435,6 +2597,90 @@ func TestSendNotes_addedOn(t *testing.T) {
 	assert.Equal(t, n1.AddedOn, int64(1541108743), "n1 AddedOn mismatch")
 }
 
+func TestSendNotes_dates(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+	testutils.Login(t, &ctx)
+
+	db := ctx.DB
+
+	database.MustExec(t, "inserting last max usn", db, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastMaxUSN, 0)
+
+	b1UUID := "b1-uuid"
+	// should be created
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty, due_on, remind_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", "n1-uuid", b1UUID, 0, "n1-body", 1541108743, false, true, 1541200000, 1541190000)
+	// should be updated
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty, due_on, remind_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", "n2-uuid", b1UUID, 3, "n2-body", 1541108743, false, true, 0, 1541190000)
+
+	var createPayload client.CreateNotePayload
+	var updatePayload map[string]interface{}
+
+	// fire up a test server. It decrypts the payload for test purposes.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp interface{}
+
+		if r.URL.String() == "/v3/notes" && r.Method == "POST" {
+			if err := json.NewDecoder(r.Body).Decode(&createPayload); err != nil {
+				t.Fatal(errors.Wrap(err, "decoding the create payload"))
+			}
+
+			resp = client.CreateNoteResp{
+				Result: client.RespNote{
+					UUID: testutils.MustGenerateUUID(t),
+					USN:  1,
+				},
+			}
+		} else if r.URL.String() == "/v3/notes/n2-uuid" && r.Method == "PATCH" {
+			if err := json.NewDecoder(r.Body).Decode(&updatePayload); err != nil {
+				t.Fatal(errors.Wrap(err, "decoding the update payload"))
+			}
+
+			resp = client.UpdateNoteResp{
+				Result: client.RespNote{
+					UUID: "n2-uuid",
+					USN:  2,
+				},
+			}
+		} else {
+			t.Fatalf("unrecognized endpoint reached Method: %s Path: %s", r.Method, r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}))
+	defer ts.Close()
+
+	ctx.APIEndpoint = ts.URL
+
+	// execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
+	}
+
+	if _, err := sendNotes(ctx, tx, false, false, &syncReport{}); err != nil {
+		tx.Rollback()
+		t.Fatalf(errors.Wrap(err, "executing").Error())
+	}
+
+	tx.Commit()
+
+	// test
+	assert.Equal(t, createPayload.DueOn, int64(1541200000), "create due_on mismatch")
+	assert.Equal(t, createPayload.RemindOn, int64(1541190000), "create remind_on mismatch")
+	assert.Equal(t, updatePayload["due_on"], float64(0), "update due_on mismatch")
+	assert.Equal(t, updatePayload["remind_on"], float64(1541190000), "update remind_on mismatch")
+
+	var n1DueOn, n1RemindOn int64
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT due_on, remind_on FROM notes WHERE body = ?", "n1-body"), &n1DueOn, &n1RemindOn)
+	assert.Equal(t, n1DueOn, int64(1541200000), "n1 due_on mismatch")
+	assert.Equal(t, n1RemindOn, int64(1541190000), "n1 remind_on mismatch")
+}
+
 func TestSendNotes_isBehind(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.String() == "/v3/notes" && r.Method == "POST" {
@@ -2530,7 +2776,7 @@ func TestSendNotes_isBehind(t *testing.T) {
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("beginning a transaction for test case %d", idx)).Error())
 				}
 
-				isBehind, err := sendNotes(ctx, tx)
+				isBehind, err := sendNotes(ctx, tx, false, false, &syncReport{})
 				if err != nil {
 					tx.Rollback()
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("executing for test case %d", idx)).Error())
@@ -2579,7 +2825,7 @@ func TestSendNotes_isBehind(t *testing.T) {
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("beginning a transaction for test case %d", idx)).Error())
 				}
 
-				isBehind, err := sendNotes(ctx, tx)
+				isBehind, err := sendNotes(ctx, tx, false, false, &syncReport{})
 				if err != nil {
 					tx.Rollback()
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("executing for test case %d", idx)).Error())
@@ -2628,7 +2874,7 @@ func TestSendNotes_isBehind(t *testing.T) {
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("beginning a transaction for test case %d", idx)).Error())
 				}
 
-				isBehind, err := sendNotes(ctx, tx)
+				isBehind, err := sendNotes(ctx, tx, false, false, &syncReport{})
 				if err != nil {
 					tx.Rollback()
 					t.Fatalf(errors.Wrap(err, fmt.Sprintf("executing for test case %d", idx)).Error())
@@ -2807,7 +3053,7 @@ n1 body edited
 				db.QueryRow("SELECT uuid, book_uuid, usn, added_on, edited_on, body, deleted, dirty FROM notes WHERE uuid = ?", n1UUID),
 				&localNote.UUID, &localNote.BookUUID, &localNote.USN, &localNote.AddedOn, &localNote.EditedOn, &localNote.Body, &localNote.Deleted, &localNote.Dirty)
 
-			if err := mergeNote(tx, fragNote, localNote); err != nil {
+			if err := mergeNote(tx, fragNote, localNote, &syncReport{}); err != nil {
 				tx.Rollback()
 				t.Fatalf(errors.Wrap(err, fmt.Sprintf("executing for test case %d", idx)).Error())
 			}
@@ -2857,6 +3103,305 @@ n1 body edited
 	}
 }
 
+func TestMergeNote_baseBody(t *testing.T) {
+	b1UUID := "b1-uuid"
+
+	testCases := []struct {
+		clientDirty      bool
+		clientBody       string
+		baseBody         sql.NullString
+		serverBody       string
+		expectedBody     string
+		expectedBaseBody string
+	}{
+		// local copy is not dirty
+		{
+			clientDirty:      false,
+			clientBody:       "foo\n\nbar\n",
+			baseBody:         sql.NullString{String: "foo\n\nbar\n", Valid: true},
+			serverBody:       "foo\n\nbar edited\n",
+			expectedBody:     "foo\n\nbar edited\n",
+			expectedBaseBody: "foo\n\nbar edited\n",
+		},
+		// local and server changed different paragraphs
+		{
+			clientDirty:      true,
+			clientBody:       "foo local\n\nbar\n",
+			baseBody:         sql.NullString{String: "foo\n\nbar\n", Valid: true},
+			serverBody:       "foo\n\nbar server\n",
+			expectedBody:     "foo local\n\nbar server\n",
+			expectedBaseBody: "foo\n\nbar server\n",
+		},
+		// local and server changed the same paragraph
+		{
+			clientDirty: true,
+			clientBody:  "foo local\n\nbar\n",
+			baseBody:    sql.NullString{String: "foo\n\nbar\n", Valid: true},
+			serverBody:  "foo server\n\nbar\n",
+			expectedBody: `<<<<<<< Local
+foo local
+=======
+foo server
+>>>>>>> Server
+
+bar
+`,
+			expectedBaseBody: "foo server\n\nbar\n",
+		},
+		// base body is unknown
+		{
+			clientDirty: true,
+			clientBody:  "foo local\n\nbar\n",
+			baseBody:    sql.NullString{},
+			serverBody:  "foo\n\nbar server\n",
+			expectedBody: `<<<<<<< Local
+foo local
+=======
+foo
+>>>>>>> Server
+
+<<<<<<< Local
+bar
+=======
+bar server
+>>>>>>> Server
+`,
+			expectedBaseBody: "foo\n\nbar server\n",
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			// set up
+			db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+			defer database.TeardownTestDB(t, db)
+
+			database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, dirty) VALUES (?, ?, ?, ?)", b1UUID, "b1-label", 5, false)
+			n1UUID := testutils.MustGenerateUUID(t)
+			database.MustExec(t, "inserting n1", db, `INSERT INTO notes (uuid, book_uuid, usn, added_on, edited_on, body, base_body, dirty)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, n1UUID, b1UUID, 1, 1541232118, 1541219320, tc.clientBody, tc.baseBody, tc.clientDirty)
+
+			// execute
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
+			}
+
+			fragNote := client.SyncFragNote{
+				UUID:     n1UUID,
+				BookUUID: b1UUID,
+				USN:      21,
+				AddedOn:  1541232118,
+				EditedOn: 1541219321,
+				Body:     tc.serverBody,
+			}
+			var localNote database.Note
+			database.MustScan(t, "getting localNote",
+				db.QueryRow("SELECT uuid, book_uuid, usn, body, deleted, dirty FROM notes WHERE uuid = ?", n1UUID),
+				&localNote.UUID, &localNote.BookUUID, &localNote.USN, &localNote.Body, &localNote.Deleted, &localNote.Dirty)
+
+			if err := mergeNote(tx, fragNote, localNote, &syncReport{}); err != nil {
+				tx.Rollback()
+				t.Fatalf(errors.Wrap(err, "executing").Error())
+			}
+
+			tx.Commit()
+
+			// test
+			var body, baseBody string
+			var dirty bool
+			database.MustScan(t, "getting n1Record",
+				db.QueryRow("SELECT body, base_body, dirty FROM notes WHERE uuid = ?", n1UUID),
+				&body, &baseBody, &dirty)
+
+			assert.Equal(t, body, tc.expectedBody, "n1 body mismatch")
+			assert.Equal(t, baseBody, tc.expectedBaseBody, "n1 base_body mismatch")
+			assert.Equal(t, dirty, tc.clientDirty, "n1 dirty mismatch")
+		})
+	}
+}
+
+func TestMergeNote_dates(t *testing.T) {
+	b1UUID := "b1-uuid"
+
+	testCases := []struct {
+		clientDirty      bool
+		expectedDueOn    int64
+		expectedRemindOn int64
+	}{
+		// the server dates overwrite the local copy that is not dirty
+		{
+			clientDirty:      false,
+			expectedDueOn:    1541300000,
+			expectedRemindOn: 1541290000,
+		},
+		// the local dates are kept to be sent to the server
+		{
+			clientDirty:      true,
+			expectedDueOn:    1541200000,
+			expectedRemindOn: 0,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			// set up
+			db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+			defer database.TeardownTestDB(t, db)
+
+			database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, dirty) VALUES (?, ?, ?, ?)", b1UUID, "b1-label", 5, false)
+			n1UUID := testutils.MustGenerateUUID(t)
+			database.MustExec(t, "inserting n1", db, `INSERT INTO notes (uuid, book_uuid, usn, added_on, edited_on, body, base_body, dirty, due_on, remind_on)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, n1UUID, b1UUID, 1, 1541232118, 1541219320, "n1 body", "n1 body", tc.clientDirty, 1541200000, 0)
+
+			// execute
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
+			}
+
+			fragNote := client.SyncFragNote{
+				UUID:     n1UUID,
+				BookUUID: b1UUID,
+				USN:      21,
+				AddedOn:  1541232118,
+				EditedOn: 1541219321,
+				Body:     "n1 body",
+				DueOn:    1541300000,
+				RemindOn: 1541290000,
+			}
+			var localNote database.Note
+			database.MustScan(t, "getting localNote",
+				db.QueryRow("SELECT uuid, book_uuid, usn, body, deleted, dirty, due_on, remind_on FROM notes WHERE uuid = ?", n1UUID),
+				&localNote.UUID, &localNote.BookUUID, &localNote.USN, &localNote.Body, &localNote.Deleted, &localNote.Dirty, &localNote.DueOn, &localNote.RemindOn)
+
+			if err := mergeNote(tx, fragNote, localNote, &syncReport{}); err != nil {
+				tx.Rollback()
+				t.Fatalf(errors.Wrap(err, "executing").Error())
+			}
+
+			tx.Commit()
+
+			// test
+			var dueOn, remindOn int64
+			database.MustScan(t, "getting n1Record",
+				db.QueryRow("SELECT due_on, remind_on FROM notes WHERE uuid = ?", n1UUID),
+				&dueOn, &remindOn)
+
+			assert.Equal(t, dueOn, tc.expectedDueOn, "n1 due_on mismatch")
+			assert.Equal(t, remindOn, tc.expectedRemindOn, "n1 remind_on mismatch")
+		})
+	}
+}
+
+func TestMergeNote_pinnedArchived(t *testing.T) {
+	b1UUID := "b1-uuid"
+
+	testCases := []struct {
+		clientDirty      bool
+		expectedPinned   bool
+		expectedArchived bool
+	}{
+		// the server states overwrite the local copy that is not dirty
+		{
+			clientDirty:      false,
+			expectedPinned:   false,
+			expectedArchived: true,
+		},
+		// the local states are kept to be sent to the server
+		{
+			clientDirty:      true,
+			expectedPinned:   true,
+			expectedArchived: false,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			// set up
+			db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+			defer database.TeardownTestDB(t, db)
+
+			database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, dirty) VALUES (?, ?, ?, ?)", b1UUID, "b1-label", 5, false)
+			n1UUID := testutils.MustGenerateUUID(t)
+			database.MustExec(t, "inserting n1", db, `INSERT INTO notes (uuid, book_uuid, usn, added_on, edited_on, body, base_body, dirty, pinned, archived)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, n1UUID, b1UUID, 1, 1541232118, 1541219320, "n1 body", "n1 body", tc.clientDirty, true, false)
+
+			// execute
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
+			}
+
+			fragNote := client.SyncFragNote{
+				UUID:     n1UUID,
+				BookUUID: b1UUID,
+				USN:      21,
+				AddedOn:  1541232118,
+				EditedOn: 1541219321,
+				Body:     "n1 body",
+				Pinned:   false,
+				Archived: true,
+			}
+			var localNote database.Note
+			database.MustScan(t, "getting localNote",
+				db.QueryRow("SELECT uuid, book_uuid, usn, body, deleted, dirty, pinned, archived FROM notes WHERE uuid = ?", n1UUID),
+				&localNote.UUID, &localNote.BookUUID, &localNote.USN, &localNote.Body, &localNote.Deleted, &localNote.Dirty, &localNote.Pinned, &localNote.Archived)
+
+			if err := mergeNote(tx, fragNote, localNote, &syncReport{}); err != nil {
+				tx.Rollback()
+				t.Fatalf(errors.Wrap(err, "executing").Error())
+			}
+
+			tx.Commit()
+
+			// test
+			var pinned, archived bool
+			database.MustScan(t, "getting n1Record",
+				db.QueryRow("SELECT pinned, archived FROM notes WHERE uuid = ?", n1UUID),
+				&pinned, &archived)
+
+			assert.Equal(t, pinned, tc.expectedPinned, "n1 pinned mismatch")
+			assert.Equal(t, archived, tc.expectedArchived, "n1 archived mismatch")
+		})
+	}
+}
+
+func TestMergeBook_pinnedArchived(t *testing.T) {
+	// set up
+	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+	defer database.TeardownTestDB(t, db)
+
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, dirty, pinned) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "b1-label", 5, false, true)
+
+	// execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
+	}
+
+	if err := mergeBook(tx, client.SyncFragBook{UUID: "b1-uuid", USN: 6, Label: "b1-label", Archived: true}, modeUpdate, &syncReport{}); err != nil {
+		tx.Rollback()
+		t.Fatalf(errors.Wrap(err, "merging b1").Error())
+	}
+	if err := mergeBook(tx, client.SyncFragBook{UUID: "b2-uuid", USN: 7, Label: "b2-label", Pinned: true}, modeInsert, &syncReport{}); err != nil {
+		tx.Rollback()
+		t.Fatalf(errors.Wrap(err, "merging b2").Error())
+	}
+
+	tx.Commit()
+
+	// test
+	var b1Pinned, b1Archived, b2Pinned, b2Archived bool
+	database.MustScan(t, "getting b1", db.QueryRow("SELECT pinned, archived FROM books WHERE uuid = ?", "b1-uuid"), &b1Pinned, &b1Archived)
+	database.MustScan(t, "getting b2", db.QueryRow("SELECT pinned, archived FROM books WHERE uuid = ?", "b2-uuid"), &b2Pinned, &b2Archived)
+
+	assert.Equal(t, b1Pinned, false, "b1 pinned mismatch")
+	assert.Equal(t, b1Archived, true, "b1 archived mismatch")
+	assert.Equal(t, b2Pinned, true, "b2 pinned mismatch")
+	assert.Equal(t, b2Archived, false, "b2 archived mismatch")
+}
+
 func TestCheckBookPristine(t *testing.T) {
 	// set up
 	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
@@ -2907,7 +3452,11 @@ func TestCheckBookPristine(t *testing.T) {
 	})
 }
 
-func TestCheckNoteInList(t *testing.T) {
+func TestRecordServerResources(t *testing.T) {
+	// set up
+	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+	defer database.TeardownTestDB(t, db)
+
 	list := syncList{
 		Notes: map[string]client.SyncFragNote{
 			"n1-uuid": {
@@ -2937,98 +3486,76 @@ func TestCheckNoteInList(t *testing.T) {
 		MaxCurrentTime: 2,
 	}
 
-	testCases := []struct {
-		uuid     string
-		expected bool
-	}{
-		{
-			uuid:     "n1-uuid",
-			expected: true,
-		},
-		{
-			uuid:     "n2-uuid",
-			expected: true,
-		},
-		{
-			uuid:     "n3-uuid",
-			expected: true,
-		},
-		{
-			uuid:     "n4-uuid",
-			expected: true,
-		},
-		{
-			uuid:     "nonexistent-note-uuid",
-			expected: false,
-		},
+	// execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
 	}
+	defer tx.Rollback()
 
-	for idx, tc := range testCases {
-		got := checkNoteInList(tc.uuid, &list)
-		assert.Equal(t, got, tc.expected, fmt.Sprintf("result mismatch for test case %d", idx))
+	if err := createServerResourcesTable(tx); err != nil {
+		t.Fatalf(errors.Wrap(err, "creating the table").Error())
 	}
-}
-
-func TestCheckBookInList(t *testing.T) {
-	list := syncList{
-		Notes: map[string]client.SyncFragNote{
-			"n1-uuid": {
-				UUID: "n1-uuid",
-			},
-			"n2-uuid": {
-				UUID: "n2-uuid",
-			},
-		},
-		Books: map[string]client.SyncFragBook{
-			"b1-uuid": {
-				UUID: "b1-uuid",
-			},
-			"b2-uuid": {
-				UUID: "b2-uuid",
-			},
-		},
-		ExpungedNotes: map[string]bool{
-			"n3-uuid": true,
-			"n4-uuid": true,
-		},
-		ExpungedBooks: map[string]bool{
-			"b3-uuid": true,
-			"b4-uuid": true,
-		},
-		MaxUSN:         1,
-		MaxCurrentTime: 2,
+	if err := recordServerResources(tx, &list); err != nil {
+		t.Fatalf(errors.Wrap(err, "executing").Error())
+	}
+	// recording the same resources again should be a noop
+	if err := recordServerResources(tx, &list); err != nil {
+		t.Fatalf(errors.Wrap(err, "executing again").Error())
 	}
 
+	// test
 	testCases := []struct {
-		uuid     string
-		expected bool
+		uuid         string
+		resourceType string
+		expected     bool
 	}{
 		{
-			uuid:     "b1-uuid",
-			expected: true,
+			uuid:         "n1-uuid",
+			resourceType: resourceTypeNote,
+			expected:     true,
 		},
 		{
-			uuid:     "b2-uuid",
-			expected: true,
+			uuid:         "n3-uuid",
+			resourceType: resourceTypeNote,
+			expected:     true,
 		},
 		{
-			uuid:     "b3-uuid",
-			expected: true,
+			uuid:         "n1-uuid",
+			resourceType: resourceTypeBook,
+			expected:     false,
 		},
 		{
-			uuid:     "b4-uuid",
-			expected: true,
+			uuid:         "nonexistent-note-uuid",
+			resourceType: resourceTypeNote,
+			expected:     false,
 		},
 		{
-			uuid:     "nonexistent-book-uuid",
-			expected: false,
+			uuid:         "b2-uuid",
+			resourceType: resourceTypeBook,
+			expected:     true,
+		},
+		{
+			uuid:         "b4-uuid",
+			resourceType: resourceTypeBook,
+			expected:     true,
+		},
+		{
+			uuid:         "nonexistent-book-uuid",
+			resourceType: resourceTypeBook,
+			expected:     false,
 		},
 	}
 
 	for idx, tc := range testCases {
-		got := checkBookInList(tc.uuid, &list)
-		assert.Equal(t, got, tc.expected, fmt.Sprintf("result mismatch for test case %d", idx))
+		var count int
+		database.MustScan(t, "counting the resource", tx.QueryRow("SELECT count(*) FROM server_resources WHERE uuid = ? AND type = ?", tc.uuid, tc.resourceType), &count)
+		assert.Equal(t, count == 1, tc.expected, fmt.Sprintf("result mismatch for test case %d", idx))
 	}
+
+	var total int
+	database.MustScan(t, "counting all resources", tx.QueryRow("SELECT count(*) FROM server_resources"), &total)
+	assert.Equal(t, total, 8, "total mismatch")
 }
 
 func TestCleanLocalNotes(t *testing.T) {
@@ -3078,6 +3605,10 @@ func TestCleanLocalNotes(t *testing.T) {
 	database.MustExec(t, "inserting n5", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n5-uuid", b1UUID, 7, "n5 body", 1541108743, true, true)
 	database.MustExec(t, "inserting n9", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n9-uuid", b1UUID, 17, "n9 body", 1541108743, true, false)
 	database.MustExec(t, "inserting n10", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n10-uuid", b1UUID, 0, "n10 body", 1541108743, false, false)
+	// non-existent in the list but in a local-only book
+	b2UUID := "b2-uuid"
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, usn, deleted, dirty, local_only) VALUES (?, ?, ?, ?, ?, ?)", b2UUID, "b2-label", 0, false, false, true)
+	database.MustExec(t, "inserting n11", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n11-uuid", b2UUID, 0, "n11 body", 1541108743, false, false)
 
 	// execute
 	tx, err := db.Begin()
@@ -3085,7 +3616,16 @@ func TestCleanLocalNotes(t *testing.T) {
 		t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
 	}
 
-	if err := cleanLocalNotes(tx, &list); err != nil {
+	if err := createServerResourcesTable(tx); err != nil {
+		tx.Rollback()
+		t.Fatalf(errors.Wrap(err, "creating the server resources table").Error())
+	}
+	if err := recordServerResources(tx, &list); err != nil {
+		tx.Rollback()
+		t.Fatalf(errors.Wrap(err, "recording the server resources").Error())
+	}
+
+	if err := cleanLocalNotes(tx, &syncReport{}); err != nil {
 		tx.Rollback()
 		t.Fatalf(errors.Wrap(err, "executing").Error())
 	}
@@ -3095,12 +3635,13 @@ func TestCleanLocalNotes(t *testing.T) {
 	// test
 	var noteCount int
 	database.MustScan(t, "counting notes", db.QueryRow("SELECT count(*) FROM notes"), &noteCount)
-	assert.Equal(t, noteCount, 3, "note count mismatch")
+	assert.Equal(t, noteCount, 4, "note count mismatch")
 
-	var n1, n2, n6 database.Note
+	var n1, n2, n6, n11 database.Note
 	database.MustScan(t, "getting n1", db.QueryRow("SELECT dirty FROM notes WHERE uuid = ?", "n1-uuid"), &n1.Dirty)
 	database.MustScan(t, "getting n2", db.QueryRow("SELECT dirty FROM notes WHERE uuid = ?", "n2-uuid"), &n2.Dirty)
 	database.MustScan(t, "getting n6", db.QueryRow("SELECT dirty FROM notes WHERE uuid = ?", "n6-uuid"), &n6.Dirty)
+	database.MustScan(t, "getting n11", db.QueryRow("SELECT dirty FROM notes WHERE uuid = ?", "n11-uuid"), &n11.Dirty)
 }
 
 func TestCleanLocalBooks(t *testing.T) {
@@ -3146,6 +3687,8 @@ func TestCleanLocalBooks(t *testing.T) {
 	database.MustExec(t, "inserting b6", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b6-uuid", "b6-label", 10, true, true)
 	database.MustExec(t, "inserting b7", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b7-uuid", "b7-label", 11, false, false)
 	database.MustExec(t, "inserting b8", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b8-uuid", "b8-label", 0, false, false)
+	// non-existent in the server but local-only
+	database.MustExec(t, "inserting b9", db, "INSERT INTO books (uuid, label, usn, deleted, dirty, local_only) VALUES (?, ?, ?, ?, ?, ?)", "b9-uuid", "b9-label", 0, false, false, true)
 
 	// execute
 	tx, err := db.Begin()
@@ -3153,7 +3696,16 @@ func TestCleanLocalBooks(t *testing.T) {
 		t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
 	}
 
-	if err := cleanLocalBooks(tx, &list); err != nil {
+	if err := createServerResourcesTable(tx); err != nil {
+		tx.Rollback()
+		t.Fatalf(errors.Wrap(err, "creating the server resources table").Error())
+	}
+	if err := recordServerResources(tx, &list); err != nil {
+		tx.Rollback()
+		t.Fatalf(errors.Wrap(err, "recording the server resources").Error())
+	}
+
+	if err := cleanLocalBooks(tx, &syncReport{}); err != nil {
 		tx.Rollback()
 		t.Fatalf(errors.Wrap(err, "executing").Error())
 	}
@@ -3163,10 +3715,190 @@ func TestCleanLocalBooks(t *testing.T) {
 	// test
 	var bookCount int
 	database.MustScan(t, "counting books", db.QueryRow("SELECT count(*) FROM books"), &bookCount)
-	assert.Equal(t, bookCount, 3, "note count mismatch")
+	assert.Equal(t, bookCount, 4, "note count mismatch")
 
-	var b1, b3, b5 database.Book
+	var b1, b3, b5, b9 database.Book
 	database.MustScan(t, "getting b1", db.QueryRow("SELECT label FROM books WHERE uuid = ?", "b1-uuid"), &b1.Label)
 	database.MustScan(t, "getting b3", db.QueryRow("SELECT label FROM books WHERE uuid = ?", "b3-uuid"), &b3.Label)
 	database.MustScan(t, "getting b5", db.QueryRow("SELECT label FROM books WHERE uuid = ?", "b5-uuid"), &b5.Label)
+	database.MustScan(t, "getting b9", db.QueryRow("SELECT label FROM books WHERE uuid = ?", "b9-uuid"), &b9.Label)
+}
+
+func TestSyncDelete_localOnly(t *testing.T) {
+	// set up
+	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+	defer database.TeardownTestDB(t, db)
+
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty, local_only) VALUES (?, ?, ?, ?, ?, ?)", "b1-uuid", "b1-label", 0, false, false, true)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", 0, "n1 body", 1541108743, false, false)
+
+	// execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
+	}
+
+	if err := syncDeleteNote(tx, "n1-uuid", &syncReport{}); err != nil {
+		tx.Rollback()
+		t.Fatalf(errors.Wrap(err, "deleting note").Error())
+	}
+	if err := syncDeleteBook(tx, "b1-uuid", &syncReport{}); err != nil {
+		tx.Rollback()
+		t.Fatalf(errors.Wrap(err, "deleting book").Error())
+	}
+
+	tx.Commit()
+
+	// test
+	var noteCount, bookCount int
+	database.MustScan(t, "counting notes", db.QueryRow("SELECT count(*) FROM notes"), &noteCount)
+	database.MustScan(t, "counting books", db.QueryRow("SELECT count(*) FROM books"), &bookCount)
+	assert.Equal(t, noteCount, 1, "note count mismatch")
+	assert.Equal(t, bookCount, 1, "book count mismatch")
+}
+
+func TestSettleLocalOnly(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+	testutils.Login(t, &ctx)
+
+	db := ctx.DB
+
+	database.MustExec(t, "inserting last max usn", db, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastMaxUSN, 0)
+
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "b1-label", 1, false, false)
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, usn, deleted, dirty, local_only) VALUES (?, ?, ?, ?, ?, ?)", "b2-uuid", "b2-label", 0, false, true, true)
+	database.MustExec(t, "inserting b3", db, "INSERT INTO books (uuid, label, usn, deleted, dirty, local_only) VALUES (?, ?, ?, ?, ?, ?)", "b3-uuid", "b3-label", 0, true, true, true)
+
+	// should be ignored because the book is synced
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", 0, "n1 body", 1541108743, true, true)
+	// should be ignored because it has never been uploaded
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "b2-uuid", 0, "n2 body", 1541108743, false, true)
+	// should be deleted from the server and kept locally
+	database.MustExec(t, "inserting n3", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty, base_body) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", "n3-uuid", "b2-uuid", 5, "n3 body", 1541108743, false, true, "n3 body")
+	// should be deleted from the server and expunged
+	database.MustExec(t, "inserting n4", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n4-uuid", "b2-uuid", 6, "", 1541108743, true, true)
+	// should be expunged without being sent
+	database.MustExec(t, "inserting n5", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n5-uuid", "b3-uuid", 0, "", 1541108743, true, true)
+
+	var deletedUUIDs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.Split(r.URL.Path, "/")
+		if len(p) == 4 && p[1] == "v3" && p[2] == "notes" && r.Method == "DELETE" {
+			deletedUUIDs = append(deletedUUIDs, p[3])
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("{}"))
+			return
+		}
+
+		t.Fatalf("unrecognized endpoint reached Method: %s Path: %s", r.Method, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	ctx.APIEndpoint = ts.URL
+
+	// execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf(errors.Wrap(err, "beginning a transaction").Error())
+	}
+
+	if _, err := settleLocalOnly(ctx, tx); err != nil {
+		tx.Rollback()
+		t.Fatalf(errors.Wrap(err, "executing").Error())
+	}
+
+	tx.Commit()
+
+	// test
+	sort.Strings(deletedUUIDs)
+	assert.DeepEqual(t, deletedUUIDs, []string{"n3-uuid", "n4-uuid"}, "deletedUUIDs mismatch")
+
+	var noteCount, bookCount int
+	database.MustScan(t, "counting notes", db.QueryRow("SELECT count(*) FROM notes"), &noteCount)
+	database.MustScan(t, "counting books", db.QueryRow("SELECT count(*) FROM books"), &bookCount)
+	assert.Equal(t, noteCount, 3, "note count mismatch")
+	assert.Equal(t, bookCount, 2, "book count mismatch")
+
+	var n3USN int
+	var n3Base sql.NullString
+	database.MustScan(t, "getting n3", db.QueryRow("SELECT usn, base_body FROM notes WHERE uuid = ?", "n3-uuid"), &n3USN, &n3Base)
+	assert.Equal(t, n3USN, 0, "n3 usn mismatch")
+	assert.Equal(t, n3Base.Valid, false, "n3 base_body should be null")
+}
+
+func TestDoSync_pushOnlyServerAhead(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+	testutils.Login(t, &ctx)
+
+	db := ctx.DB
+	database.MustExec(t, "inserting last max usn", db, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastMaxUSN, 1)
+	database.MustExec(t, "inserting last sync at", db, "INSERT INTO system (key, value) VALUES (?, ?)", consts.SystemLastSyncAt, 1550436136)
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "b1-label", 1, false, false)
+	database.MustExec(t, "inserting n1", db, `INSERT INTO notes (uuid, book_uuid, usn, added_on, edited_on, body, base_body, deleted, dirty)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, "n1-uuid", "b1-uuid", 1, 1541108743, 1541108744, "foo local\n\nbar\n", "foo\n\nbar\n", false, true)
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, usn, body, added_on, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", 0, "n2 body", 1541108745, false, true)
+
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+		w.Header().Set("Content-Type", "application/json")
+
+		var resp interface{}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v3/sync/state":
+			resp = client.GetSyncStateResp{MaxUSN: 2, CurrentTime: 1550436137}
+		case r.Method == "POST" && r.URL.Path == "/v3/notes":
+			resp = client.CreateNoteResp{Result: client.RespNote{UUID: "n2-server-uuid", USN: 3}}
+		default:
+			t.Fatalf("unrecognized endpoint reached Method: %s Path: %s", r.Method, r.URL.Path)
+		}
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	ctx.APIEndpoint = ts.URL
+
+	// execute
+	r := syncReport{}
+	if err := doSync(ctx, syncOptions{PushOnly: true}, &r); err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	// test
+	assert.DeepEqual(t, requests, []string{"GET /v3/sync/state", "POST /v3/notes"}, "requests mismatch")
+	assert.Equal(t, r.PushedNotes, Counts{Created: 1}, "PushedNotes mismatch")
+	assert.Equal(t, r.Failed, 1, "Failed mismatch")
+	assert.Equal(t, r.Outbox, 1, "Outbox mismatch")
+
+	var n1Body string
+	var n1USN int
+	var n1Dirty bool
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT body, usn, dirty FROM notes WHERE uuid = ?", "n1-uuid"), &n1Body, &n1USN, &n1Dirty)
+	assert.Equal(t, n1Body, "foo local\n\nbar\n", "n1 body mismatch")
+	assert.Equal(t, n1USN, 1, "n1 usn mismatch")
+	assert.Equal(t, n1Dirty, true, "n1 dirty mismatch")
+
+	var n2Dirty bool
+	database.MustScan(t, "getting n2", db.QueryRow("SELECT dirty FROM notes WHERE uuid = ?", "n2-server-uuid"), &n2Dirty)
+	assert.Equal(t, n2Dirty, false, "n2 dirty mismatch")
+
+	items, err := GetOutbox(db)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the outbox"))
+	}
+	assert.Equal(t, len(items), 1, "outbox length mismatch")
+	assert.Equal(t, items[0].UUID, "n1-uuid", "outbox uuid mismatch")
+	assert.Equal(t, items[0].Error, "server has newer changes", "outbox error mismatch")
+
+	var lastMaxUSN int
+	database.MustScan(t, "getting last max usn", db.QueryRow("SELECT value FROM system WHERE key = ?", consts.SystemLastMaxUSN), &lastMaxUSN)
+	assert.Equal(t, lastMaxUSN, 1, "last max usn should not advance past the unpulled changes")
 }
@@ -0,0 +1,94 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sync
+
+import (
+	"time"
+
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/infra"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var logExample = `
+  * Show the recent syncs
+  dnote sync log
+
+  * Print the recent syncs as JSON
+  dnote sync log --format json`
+
+var logFormatFlag string
+
+var logTimeFormat = "Jan 2, 2006 3:04pm (MST)"
+
+func newLogCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "log",
+		Short:   "Show the recent syncs",
+		Example: logExample,
+		RunE:    newLogRun(ctx),
+	}
+
+	f := cmd.Flags()
+	f.StringVarP(&logFormatFlag, "format", "", "", "print the log in the given format. Currently only 'json' is supported.")
+
+	return cmd
+}
+
+func newLogRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		if logFormatFlag != "" && logFormatFlag != formatJSON {
+			return errors.Errorf("unknown format '%s'", logFormatFlag)
+		}
+
+		entries, err := ReadSyncLog(ctx)
+		if err != nil {
+			return errors.Wrap(err, "reading the sync log")
+		}
+
+		if logFormatFlag == formatJSON {
+			if entries == nil {
+				entries = []Summary{}
+			}
+
+			return printJSON(entries)
+		}
+
+		if len(entries) == 0 {
+			log.Info("no syncs have been recorded yet\n")
+			return nil
+		}
+
+		// show the most recent sync first
+		for i := len(entries) - 1; i >= 0; i-- {
+			s := entries[i]
+			t := time.Unix(s.StartedAt, 0).Format(logTimeFormat)
+
+			if s.Error != "" {
+				log.Errorf("%s  %s\n", t, describeRun(s))
+			} else {
+				log.Successf("%s  %s\n", t, describeRun(s))
+			}
+		}
+
+		return nil
+	}
+}
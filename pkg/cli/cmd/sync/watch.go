@@ -0,0 +1,357 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sync
+
+import (
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/dnote/dnote/pkg/cli/client"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/migrate"
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultWatchInterval is the default interval at which the server is polled for changes
+	defaultWatchInterval = time.Minute
+	// watchTick is the interval at which the watcher checks whether to sync
+	watchTick = time.Second
+	// watchDebounce is how long the watcher waits after the last local change
+	// before pushing, so that a burst of writes results in a single sync
+	watchDebounce = 2 * time.Second
+	// backoffBase is the delay before retrying after the first failure
+	backoffBase = 5 * time.Second
+	// backoffMax is the maximum delay between retries
+	backoffMax = 10 * time.Minute
+)
+
+// getBackoff returns the delay before retrying after the given number of
+// consecutive failures. The delay doubles with each failure up to backoffMax.
+func getBackoff(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+
+	ret := backoffBase
+	for i := 1; i < failures; i++ {
+		ret = ret * 2
+
+		if ret >= backoffMax {
+			return backoffMax
+		}
+	}
+
+	return ret
+}
+
+func getTriggerPath(ctx context.DnoteCtx) (string, error) {
+	dir, err := getSyncDir(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "getting the sync directory")
+	}
+
+	return filepath.Join(dir, consts.SyncTriggerFilename), nil
+}
+
+// touchTrigger signals a running watcher that the local data has changed
+func touchTrigger(ctx context.DnoteCtx) error {
+	path, err := getTriggerPath(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting the trigger path")
+	}
+
+	now := time.Now()
+	if err := ioutil.WriteFile(path, []byte(strconv.FormatInt(now.UnixNano(), 10)), 0644); err != nil {
+		return errors.Wrap(err, "writing the trigger file")
+	}
+	// set the time explicitly in case the contents did not change
+	if err := os.Chtimes(path, now, now); err != nil {
+		return errors.Wrap(err, "updating the trigger file")
+	}
+
+	return nil
+}
+
+// readTrigger returns the last time the local data was changed, or a zero time if
+// no change was recorded
+func readTrigger(ctx context.DnoteCtx) (time.Time, error) {
+	path, err := getTriggerPath(ctx)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "getting the trigger path")
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, errors.Wrap(err, "reading the trigger file")
+	}
+
+	return info.ModTime(), nil
+}
+
+// countPendingChanges returns the number of the local changes that a sync would send.
+// The changes in the local-only books are never sent, and the ones in the outbox are
+// retried only when something else is synced or with --retry-failed.
+func countPendingChanges(db *database.DB) (int, error) {
+	var ret int
+	err := db.QueryRow(`SELECT
+		(SELECT count(*) FROM notes WHERE dirty AND book_uuid NOT IN (SELECT uuid FROM books WHERE local_only)
+			AND uuid NOT IN (SELECT uuid FROM outbox WHERE type = ?)) +
+		(SELECT count(*) FROM books WHERE dirty AND NOT local_only
+			AND uuid NOT IN (SELECT uuid FROM outbox WHERE type = ?))`, resourceTypeNote, resourceTypeBook).Scan(&ret)
+
+	return ret, err
+}
+
+// hasChanges checks if there is anything to sync, either on the server or locally
+func hasChanges(ctx context.DnoteCtx) (bool, error) {
+	dirtyCount, err := countPendingChanges(ctx.DB)
+	if err != nil {
+		return false, errors.Wrap(err, "counting the dirty records")
+	}
+	if dirtyCount > 0 {
+		return true, nil
+	}
+
+	syncState, err := client.GetSyncState(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "getting the sync state from the server")
+	}
+	lastSyncAt, err := getLastSyncAt(ctx.DB)
+	if err != nil {
+		return false, errors.Wrap(err, "getting the last sync time")
+	}
+	lastMaxUSN, err := getLastMaxUSN(ctx.DB)
+	if err != nil {
+		return false, errors.Wrap(err, "getting the last max_usn")
+	}
+
+	return lastSyncAt < syncState.FullSyncBefore || lastMaxUSN != syncState.MaxUSN, nil
+}
+
+// watcher decides when to sync in the watch mode
+type watcher struct {
+	ctx      context.DnoteCtx
+	interval time.Duration
+	status   Status
+	// pending is true if a sync is due regardless of the changes, such as on
+	// start up or after a failure
+	pending     bool
+	lastCheckAt time.Time
+	lastTrigger time.Time
+	// checkChanges reports whether there is anything to sync
+	checkChanges func(ctx context.DnoteCtx) (bool, error)
+}
+
+func newWatcher(ctx context.DnoteCtx, interval time.Duration) (*watcher, error) {
+	lastTrigger, err := readTrigger(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the trigger")
+	}
+
+	return &watcher{
+		ctx:      ctx,
+		interval: interval,
+		status: Status{
+			PID:      os.Getpid(),
+			Watching: true,
+		},
+		pending:      true,
+		lastTrigger:  lastTrigger,
+		checkChanges: hasChanges,
+	}, nil
+}
+
+// shouldSync reports whether a sync should be performed at the given time
+func (w *watcher) shouldSync(now time.Time) (bool, error) {
+	if w.status.NextAttemptAt != 0 && now.Unix() < w.status.NextAttemptAt {
+		return false, nil
+	}
+	if w.pending {
+		return true, nil
+	}
+
+	trigger, err := readTrigger(w.ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "reading the trigger")
+	}
+	if trigger.After(w.lastTrigger) && now.Sub(trigger) >= watchDebounce {
+		w.lastTrigger = trigger
+		return true, nil
+	}
+
+	if now.Sub(w.lastCheckAt) >= w.interval {
+		w.lastCheckAt = now
+
+		ok, err := w.checkChanges(w.ctx)
+		if err != nil {
+			return false, errors.Wrap(err, "checking for changes")
+		}
+
+		return ok, nil
+	}
+
+	return false, nil
+}
+
+// fail records a failure and schedules a retry
+func (w *watcher) fail(now time.Time, err error) {
+	recordResult(&w.status, now.Unix(), err)
+
+	backoff := getBackoff(w.status.Failures)
+	w.status.NextAttemptAt = now.Add(backoff).Unix()
+	w.pending = true
+
+	log.Errorf("sync failed: %s. retrying in %s\n", err, backoff)
+}
+
+// tick syncs if needed and persists the status
+func (w *watcher) tick(now time.Time) {
+	ok, err := w.shouldSync(now)
+	if err != nil {
+		w.fail(now, err)
+	} else if ok {
+		w.status.Syncing = true
+		if err := writeStatus(w.ctx, w.status); err != nil {
+			log.Debug("writing the sync status: %s\n", err)
+		}
+
+		w.lastCheckAt = now
+		if err := runSync(w.ctx, syncOptions{}, &syncReport{}); err != nil {
+			w.fail(now, err)
+		} else {
+			recordResult(&w.status, now.Unix(), nil)
+			w.pending = false
+
+			log.Successf("synced at %s\n", now.Format("15:04:05"))
+		}
+	} else {
+		return
+	}
+
+	if err := writeStatus(w.ctx, w.status); err != nil {
+		log.Debug("writing the sync status: %s\n", err)
+	}
+}
+
+// watch keeps syncing until interrupted. It pushes local changes shortly after they
+// are made and polls the server for changes at the given interval.
+func watch(ctx context.DnoteCtx, interval time.Duration) error {
+	l, err := acquireLock(ctx)
+	if err != nil {
+		return errors.Wrap(err, "acquiring the sync lock")
+	}
+	defer l.release()
+
+	w, err := newWatcher(ctx, interval)
+	if err != nil {
+		return errors.Wrap(err, "initializing the watcher")
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	ticker := time.NewTicker(watchTick)
+	defer ticker.Stop()
+
+	log.Infof("watching for changes. syncing every %s\n", interval)
+
+	w.tick(time.Now())
+	for {
+		select {
+		case <-sigs:
+			w.status.Watching = false
+			if err := writeStatus(ctx, w.status); err != nil {
+				log.Debug("writing the sync status: %s\n", err)
+			}
+
+			log.Plain("\n")
+			log.Info("stopped watching\n")
+			return nil
+		case <-ticker.C:
+			w.tick(time.Now())
+		}
+	}
+}
+
+// AfterWrite is called after the local data is changed. It notifies a running
+// watcher of the change and, if automatic sync is enabled, syncs right away.
+// Errors are logged rather than returned so that the write itself succeeds.
+func AfterWrite(ctx context.DnoteCtx) {
+	if err := touchTrigger(ctx); err != nil {
+		log.Debug("touching the sync trigger: %s\n", err)
+	}
+
+	if !ctx.AutoSync || !client.IsAuthorized(ctx) {
+		return
+	}
+
+	l, err := acquireLock(ctx)
+	if err == errSyncInProgress {
+		// the running sync will push the change
+		return
+	} else if err != nil {
+		log.Errorf("automatically syncing: %s\n", errors.Wrap(err, "acquiring the sync lock"))
+		return
+	}
+	defer l.release()
+
+	if err := migrate.Run(ctx, migrate.RemoteSequence, migrate.RemoteMode); err != nil {
+		log.Errorf("automatically syncing: %s\n", errors.Wrap(err, "running remote migrations"))
+		return
+	}
+
+	if err := syncAndReport(ctx, syncOptions{}, &syncReport{}); err != nil {
+		log.Errorf("automatically syncing: %s\n", err)
+	}
+}
+
+// Run performs a single sync as the sync command does and returns its summary. It
+// is used to sync from outside the sync command, such as the local API server.
+func Run(ctx context.DnoteCtx) (Summary, error) {
+	if !client.IsAuthorized(ctx) {
+		return Summary{}, errors.New("not logged in")
+	}
+
+	l, err := acquireLock(ctx)
+	if err != nil {
+		return Summary{}, errors.Wrap(err, "acquiring the sync lock")
+	}
+	defer l.release()
+
+	if err := migrate.Run(ctx, migrate.RemoteSequence, migrate.RemoteMode); err != nil {
+		return Summary{}, errors.Wrap(err, "running remote migrations")
+	}
+
+	r := &syncReport{}
+	err = syncAndReport(ctx, syncOptions{}, r)
+
+	return r.Summary, err
+}
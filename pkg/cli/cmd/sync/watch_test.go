@@ -0,0 +1,310 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/pkg/errors"
+)
+
+func TestGetBackoff(t *testing.T) {
+	testCases := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{
+			failures: 0,
+			expected: 0,
+		},
+		{
+			failures: 1,
+			expected: 5 * time.Second,
+		},
+		{
+			failures: 2,
+			expected: 10 * time.Second,
+		},
+		{
+			failures: 4,
+			expected: 40 * time.Second,
+		},
+		{
+			failures: 8,
+			expected: 10 * time.Minute,
+		},
+		{
+			failures: 100,
+			expected: 10 * time.Minute,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			assert.Equal(t, getBackoff(tc.failures), tc.expected, "backoff mismatch")
+		})
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	t.Run("exclusive", func(t *testing.T) {
+		// set up
+		ctx := context.InitTestCtx(t, paths, nil)
+		defer context.TeardownTestCtx(t, ctx)
+
+		// execute
+		l, err := acquireLock(ctx)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "acquiring the lock"))
+		}
+
+		_, err = acquireLock(ctx)
+		assert.Equal(t, err, errSyncInProgress, "error mismatch while the lock is held")
+
+		l.release()
+
+		l, err = acquireLock(ctx)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "acquiring the lock after release"))
+		}
+		l.release()
+
+		// test
+		_, err = os.Stat(filepath.Join(ctx.Paths.Cache, consts.DnoteDirName, consts.SyncLockFilename))
+		assert.Equal(t, os.IsNotExist(err), true, "lock file was not removed")
+	})
+
+	testCases := []struct {
+		name     string
+		owner    lockOwner
+		age      time.Duration
+		expected error
+	}{
+		{
+			name:     "running on the same host",
+			owner:    lockOwner{Hostname: getLockOwner().Hostname, PID: os.Getpid()},
+			age:      lockStaleAfter + time.Second,
+			expected: errSyncInProgress,
+		},
+		{
+			name:     "exited on the same host",
+			owner:    lockOwner{Hostname: getLockOwner().Hostname, PID: 0},
+			age:      0,
+			expected: nil,
+		},
+		{
+			name:     "refreshed on another host",
+			owner:    lockOwner{Hostname: "other-host", PID: os.Getpid()},
+			age:      0,
+			expected: errSyncInProgress,
+		},
+		{
+			name:     "stale on another host",
+			owner:    lockOwner{Hostname: "other-host", PID: os.Getpid()},
+			age:      lockStaleAfter + time.Second,
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// set up
+			ctx := context.InitTestCtx(t, paths, nil)
+			defer context.TeardownTestCtx(t, ctx)
+
+			dir, err := getSyncDir(ctx)
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "getting the sync directory"))
+			}
+			path := filepath.Join(dir, consts.SyncLockFilename)
+			if err := createLockFile(path, tc.owner); err != nil {
+				t.Fatal(errors.Wrap(err, "creating a lock file"))
+			}
+			old := time.Now().Add(-tc.age)
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(errors.Wrap(err, "setting the lock time"))
+			}
+
+			// execute
+			l, err := acquireLock(ctx)
+
+			// test
+			assert.Equal(t, err, tc.expected, "error mismatch")
+			if l != nil {
+				l.release()
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	// execute
+	s, err := ReadStatus(ctx)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "reading the initial status"))
+	}
+	assert.Equal(t, s, Status{}, "initial status mismatch")
+
+	recordResult(&s, 1541108743, errors.New("network error"))
+	recordResult(&s, 1541108744, errors.New("network error"))
+	if err := writeStatus(ctx, s); err != nil {
+		t.Fatal(errors.Wrap(err, "writing the status"))
+	}
+
+	// test
+	got, err := ReadStatus(ctx)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "reading the status"))
+	}
+	assert.Equal(t, got.Failures, 2, "Failures mismatch")
+	assert.Equal(t, got.LastAttemptAt, int64(1541108744), "LastAttemptAt mismatch")
+	assert.Equal(t, got.LastSuccessAt, int64(0), "LastSuccessAt mismatch")
+	assert.Equal(t, got.LastError, "network error", "LastError mismatch")
+
+	recordResult(&got, 1541108745, nil)
+	assert.Equal(t, got.Failures, 0, "Failures mismatch after success")
+	assert.Equal(t, got.LastSuccessAt, int64(1541108745), "LastSuccessAt mismatch after success")
+	assert.Equal(t, got.LastError, "", "LastError mismatch after success")
+}
+
+func TestCountPendingChanges(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "js", 1, false, true)
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, usn, deleted, dirty, local_only) VALUES (?, ?, ?, ?, ?, ?)", "b2-uuid", "private", 0, false, true, true)
+	database.MustExec(t, "inserting b3", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b3-uuid", "css", 2, false, true)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1", 1, 0, false, true)
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "b2-uuid", "n2", 2, 0, false, true)
+	database.MustExec(t, "inserting n3", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n3-uuid", "b1-uuid", "n3", 3, 0, false, true)
+	database.MustExec(t, "inserting n4", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n4-uuid", "b1-uuid", "n4", 4, 3, false, false)
+
+	if err := recordFailure(db, 1541108743, resourceTypeNote, "n3-uuid", errors.New("network error")); err != nil {
+		t.Fatal(errors.Wrap(err, "recording n3 failure"))
+	}
+	if err := recordFailure(db, 1541108743, resourceTypeBook, "b3-uuid", errors.New("network error")); err != nil {
+		t.Fatal(errors.Wrap(err, "recording b3 failure"))
+	}
+
+	// execute
+	count, err := countPendingChanges(db)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	// test
+	assert.Equal(t, count, 2, "count mismatch")
+}
+
+func TestWatcherShouldSync(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	w, err := newWatcher(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "initializing the watcher"))
+	}
+
+	var checkCount int
+	var hasRemoteChanges bool
+	w.checkChanges = func(ctx context.DnoteCtx) (bool, error) {
+		checkCount++
+		return hasRemoteChanges, nil
+	}
+
+	now := time.Now()
+	w.lastCheckAt = now
+
+	// execute and test
+	ok, err := w.shouldSync(now)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "checking on start"))
+	}
+	assert.Equal(t, ok, true, "should sync on start")
+	w.pending = false
+
+	ok, err = w.shouldSync(now.Add(time.Second))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "checking without changes"))
+	}
+	assert.Equal(t, ok, false, "should not sync without changes")
+
+	if err := touchTrigger(ctx); err != nil {
+		t.Fatal(errors.Wrap(err, "touching the trigger"))
+	}
+	trigger, err := readTrigger(ctx)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "reading the trigger"))
+	}
+
+	ok, err = w.shouldSync(trigger.Add(time.Millisecond))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "checking during the debounce"))
+	}
+	assert.Equal(t, ok, false, "should not sync during the debounce")
+
+	ok, err = w.shouldSync(trigger.Add(watchDebounce))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "checking after the debounce"))
+	}
+	assert.Equal(t, ok, true, "should sync after the debounce")
+
+	ok, err = w.shouldSync(trigger.Add(watchDebounce + time.Second))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "checking after handling the trigger"))
+	}
+	assert.Equal(t, ok, false, "should not sync twice for the same trigger")
+	assert.Equal(t, checkCount, 0, "checked for changes before the interval")
+
+	hasRemoteChanges = true
+	ok, err = w.shouldSync(now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "checking after the interval"))
+	}
+	assert.Equal(t, ok, true, "should sync if the server has changes")
+	assert.Equal(t, checkCount, 1, "checkCount mismatch")
+
+	w.fail(now.Add(time.Minute), errors.New("network error"))
+	ok, err = w.shouldSync(now.Add(time.Minute + time.Second))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "checking during the backoff"))
+	}
+	assert.Equal(t, ok, false, "should not sync during the backoff")
+
+	ok, err = w.shouldSync(now.Add(time.Minute + backoffBase))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "checking after the backoff"))
+	}
+	assert.Equal(t, ok, true, "should retry after the backoff")
+}
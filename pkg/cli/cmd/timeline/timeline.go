@@ -0,0 +1,291 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package timeline
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/dates"
+	"github.com/dnote/dnote/pkg/cli/infra"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/ui"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var example = `
+ * List the notes across all books, the most recently added first
+ dnote log
+
+ * List the notes edited since yesterday, one line per note
+ dnote log --edited --since yesterday --oneline
+
+ * List the last 10 notes added to a book in March
+ dnote log -b js --since 2020-03-01 --until 2020-03-31 -n 10`
+
+var sinceFlag string
+var untilFlag string
+var bookFlag string
+var editedFlag bool
+var addedFlag bool
+var limitFlag int
+var onelineFlag bool
+var archivedFlag bool
+var noPagerFlag bool
+
+// NewCmd returns a new log command
+func NewCmd(ctx context.DnoteCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "log",
+		Short:   "List recently added or edited notes across books",
+		Example: example,
+		Args:    cobra.NoArgs,
+		RunE:    newRun(ctx),
+	}
+
+	f := cmd.Flags()
+	f.StringVarP(&sinceFlag, "since", "", "", "Show the notes after a date, such as 'yesterday' or '2 weeks ago'")
+	f.StringVarP(&untilFlag, "until", "", "", "Show the notes before a date")
+	f.StringVarP(&bookFlag, "book", "b", "", "Show the notes in a book only")
+	f.BoolVarP(&editedFlag, "edited", "", false, "Order the notes by the time they were last edited")
+	f.BoolVarP(&addedFlag, "added", "", false, "Order the notes by the time they were added (default)")
+	f.IntVarP(&limitFlag, "limit", "n", 0, "The maximum number of notes to show. 0 shows all notes")
+	f.BoolVarP(&onelineFlag, "oneline", "", false, "Show one line per note")
+	f.BoolVarP(&archivedFlag, "archived", "", false, "Include archived notes and books")
+	f.BoolVarP(&noPagerFlag, "no-pager", "", false, "Do not pipe the output into the pager")
+
+	return cmd
+}
+
+// entry is a note in the timeline
+type entry struct {
+	RowID     int
+	BookLabel string
+	Body      string
+	AddedOn   int64
+	EditedOn  int64
+}
+
+// filter is the set of conditions to select the notes in the timeline.
+// A zero since or until leaves the range open on that side.
+type filter struct {
+	since    int64
+	until    int64
+	bookUUID string
+	edited   bool
+	limit    int
+	archived bool
+}
+
+// timeExpr returns the SQL expression for the time by which the notes are ordered
+func timeExpr(edited bool) string {
+	if edited {
+		return "(CASE WHEN notes.edited_on > 0 THEN notes.edited_on ELSE notes.added_on END)"
+	}
+
+	return "notes.added_on"
+}
+
+// getEntries returns the notes matching the filter, the most recent first
+func getEntries(db *database.DB, f filter) ([]entry, error) {
+	ts := timeExpr(f.edited)
+
+	query := `SELECT notes.rowid, books.label, notes.body, notes.added_on, notes.edited_on
+	FROM notes
+	INNER JOIN books ON books.uuid = notes.book_uuid
+	WHERE notes.deleted = false`
+	args := []interface{}{}
+
+	if f.since != 0 {
+		query = fmt.Sprintf("%s AND %s >= ?", query, ts)
+		args = append(args, f.since)
+	}
+	if f.until != 0 {
+		query = fmt.Sprintf("%s AND %s <= ?", query, ts)
+		args = append(args, f.until)
+	}
+	if f.bookUUID != "" {
+		query = fmt.Sprintf("%s AND notes.book_uuid = ?", query)
+		args = append(args, f.bookUUID)
+	}
+	if !f.archived {
+		query = fmt.Sprintf("%s AND notes.archived = false AND books.archived = false", query)
+	}
+
+	query = fmt.Sprintf("%s ORDER BY %s DESC, notes.rowid DESC", query, ts)
+	if f.limit > 0 {
+		query = fmt.Sprintf("%s LIMIT ?", query)
+		args = append(args, f.limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying notes")
+	}
+	defer rows.Close()
+
+	ret := []entry{}
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.RowID, &e.BookLabel, &e.Body, &e.AddedOn, &e.EditedOn); err != nil {
+			return nil, errors.Wrap(err, "scanning a row")
+		}
+
+		ret = append(ret, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating notes")
+	}
+
+	return ret, nil
+}
+
+// getTitle returns the first line of the note body
+func getTitle(body string) string {
+	lines := strings.SplitN(strings.TrimSpace(body), "\n", 2)
+
+	return strings.TrimSpace(lines[0])
+}
+
+func formatTime(ts int64) string {
+	return time.Unix(0, ts).Format("Mon Jan 2, 2006 3:04pm")
+}
+
+// formatOneline formats the entry in a single line with the given time
+func formatOneline(e entry, ts int64) string {
+	rowid := log.ColorYellow.Sprintf("(%d)", e.RowID)
+	at := time.Unix(0, ts).Format("2006-01-02 15:04")
+
+	return fmt.Sprintf("%s %s %s %s\n", rowid, at, log.ColorGray.Sprintf("[%s]", e.BookLabel), getTitle(e.Body))
+}
+
+// formatFull formats the entry with its times and the whole body
+func formatFull(e entry) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s\n", log.ColorYellow.Sprintf("note %d", e.RowID), log.ColorGray.Sprintf("[%s]", e.BookLabel))
+	fmt.Fprintf(&b, "Added:  %s\n", formatTime(e.AddedOn))
+	if e.EditedOn != 0 {
+		fmt.Fprintf(&b, "Edited: %s\n", formatTime(e.EditedOn))
+	}
+	b.WriteString("\n")
+
+	body := strings.TrimRight(e.Body, "\r\n")
+	for _, line := range strings.Split(body, "\n") {
+		fmt.Fprintf(&b, "    %s\n", strings.TrimRight(line, "\r"))
+	}
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// render returns the output for the entries
+func render(entries []entry, oneline, edited bool) string {
+	var b strings.Builder
+
+	for _, e := range entries {
+		if oneline {
+			ts := e.AddedOn
+			if edited && e.EditedOn != 0 {
+				ts = e.EditedOn
+			}
+
+			b.WriteString(formatOneline(e, ts))
+		} else {
+			b.WriteString(formatFull(e))
+		}
+	}
+
+	return b.String()
+}
+
+// getFilter builds the filter from the flags
+func getFilter(ctx context.DnoteCtx) (filter, error) {
+	ret := filter{
+		edited:   editedFlag,
+		limit:    limitFlag,
+		archived: archivedFlag,
+	}
+
+	if editedFlag && addedFlag {
+		return ret, errors.New("--edited and --added cannot be used together")
+	}
+	if limitFlag < 0 {
+		return ret, errors.New("--limit cannot be negative")
+	}
+
+	now := ctx.Clock.Now()
+	if sinceFlag != "" {
+		t, err := dates.ParseBound(sinceFlag, now, false)
+		if err != nil {
+			return ret, errors.Wrap(err, "parsing --since")
+		}
+
+		ret.since = t.UnixNano()
+	}
+	if untilFlag != "" {
+		t, err := dates.ParseBound(untilFlag, now, true)
+		if err != nil {
+			return ret, errors.Wrap(err, "parsing --until")
+		}
+
+		ret.until = t.UnixNano()
+	}
+	if bookFlag != "" {
+		uuid, err := database.GetBookUUID(ctx.DB, bookFlag)
+		if err != nil {
+			return ret, errors.Wrap(err, "finding the book")
+		}
+
+		ret.bookUUID = uuid
+	}
+
+	return ret, nil
+}
+
+func newRun(ctx context.DnoteCtx) infra.RunEFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		f, err := getFilter(ctx)
+		if err != nil {
+			return err
+		}
+
+		entries, err := getEntries(ctx.DB, f)
+		if err != nil {
+			return errors.Wrap(err, "getting the notes")
+		}
+
+		if len(entries) == 0 {
+			log.Plain("no notes found\n")
+			return nil
+		}
+
+		content := render(entries, onelineFlag, editedFlag)
+		if noPagerFlag {
+			fmt.Print(content)
+			return nil
+		}
+
+		return ui.Page(content)
+	}
+}
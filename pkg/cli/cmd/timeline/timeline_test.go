@@ -0,0 +1,115 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package timeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/pkg/errors"
+)
+
+func TestGetEntries(t *testing.T) {
+	// set up
+	db := database.InitTestDB(t, "../../tmp/.dnote", nil)
+	defer database.TeardownTestDB(t, db)
+
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label) VALUES (?, ?)", "b1-uuid", "js")
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, archived) VALUES (?, ?, ?)", "b2-uuid", "css", true)
+
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on) VALUES (?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1 body", 100, 500)
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on) VALUES (?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", "n2 body", 200, 0)
+	database.MustExec(t, "inserting n3", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on) VALUES (?, ?, ?, ?, ?)", "n3-uuid", "b1-uuid", "n3 body", 300, 0)
+	// deleted
+	database.MustExec(t, "inserting n4", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, deleted) VALUES (?, ?, ?, ?, ?)", "n4-uuid", "b1-uuid", "", 400, true)
+	// archived note
+	database.MustExec(t, "inserting n5", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, archived) VALUES (?, ?, ?, ?, ?)", "n5-uuid", "b1-uuid", "n5 body", 250, true)
+	// in an archived book
+	database.MustExec(t, "inserting n6", db, "INSERT INTO notes (uuid, book_uuid, body, added_on) VALUES (?, ?, ?, ?)", "n6-uuid", "b2-uuid", "n6 body", 150)
+
+	testCases := []struct {
+		f        filter
+		expected []string
+	}{
+		{
+			f:        filter{},
+			expected: []string{"n3 body", "n2 body", "n1 body"},
+		},
+		{
+			f:        filter{edited: true},
+			expected: []string{"n1 body", "n3 body", "n2 body"},
+		},
+		{
+			f:        filter{since: 150, until: 300},
+			expected: []string{"n3 body", "n2 body"},
+		},
+		{
+			f:        filter{edited: true, since: 400},
+			expected: []string{"n1 body"},
+		},
+		{
+			f:        filter{limit: 2},
+			expected: []string{"n3 body", "n2 body"},
+		},
+		{
+			f:        filter{archived: true},
+			expected: []string{"n3 body", "n5 body", "n2 body", "n6 body", "n1 body"},
+		},
+		{
+			f:        filter{bookUUID: "b2-uuid", archived: true},
+			expected: []string{"n6 body"},
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			// execute
+			entries, err := getEntries(db, tc.f)
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "executing"))
+			}
+
+			// test
+			got := []string{}
+			for _, e := range entries {
+				got = append(got, e.Body)
+			}
+
+			assert.DeepEqual(t, got, tc.expected, "result mismatch")
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	entries := []entry{
+		{RowID: 1, BookLabel: "js", Body: "first line\nsecond line\n", AddedOn: 100, EditedOn: 200},
+	}
+
+	full := render(entries, false, false)
+	assert.Equal(t, strings.Contains(full, "    first line\n    second line\n"), true, "full body mismatch")
+	assert.Equal(t, strings.Contains(full, "Edited: "), true, "edited time mismatch")
+
+	oneline := render(entries, true, false)
+	assert.Equal(t, strings.Count(oneline, "\n"), 1, "line count mismatch")
+	assert.Equal(t, strings.Contains(oneline, "first line"), true, "title mismatch")
+	assert.Equal(t, strings.Contains(oneline, "second line"), false, "oneline should not include the rest of the body")
+}
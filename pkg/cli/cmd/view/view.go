@@ -42,6 +42,9 @@ var example = `
 
 var nameOnly bool
 var contentOnly bool
+var archived bool
+var raw bool
+var render bool
 
 func preRun(cmd *cobra.Command, args []string) error {
 	if len(args) > 2 {
@@ -65,6 +68,9 @@ func NewCmd(ctx context.DnoteCtx) *cobra.Command {
 	f := cmd.Flags()
 	f.BoolVarP(&nameOnly, "name-only", "", false, "print book names only")
 	f.BoolVarP(&contentOnly, "content-only", "", false, "print the note content only")
+	f.BoolVarP(&archived, "archived", "", false, "include archived books and notes")
+	f.BoolVarP(&raw, "raw", "", false, "print the note content as is without rendering the Markdown")
+	f.BoolVarP(&render, "render", "", false, "render the Markdown even if the output is not a terminal or with --content-only")
 
 	return cmd
 }
@@ -74,20 +80,20 @@ func newRun(ctx context.DnoteCtx) infra.RunEFunc {
 		var run infra.RunEFunc
 
 		if len(args) == 0 {
-			run = ls.NewRun(ctx, nameOnly)
+			run = ls.NewRun(ctx, nameOnly, archived)
 		} else if len(args) == 1 {
 			if nameOnly {
 				return errors.New("--name-only flag is only valid when viewing books")
 			}
 
 			if utils.IsNumber(args[0]) {
-				run = cat.NewRun(ctx, contentOnly)
+				run = cat.NewRun(ctx, contentOnly, raw, render)
 			} else {
-				run = ls.NewRun(ctx, false)
+				run = ls.NewRun(ctx, false, archived)
 			}
 		} else if len(args) == 2 {
 			// DEPRECATED: passing book name to view command is deprecated
-			run = cat.NewRun(ctx, false)
+			run = cat.NewRun(ctx, false, raw, render)
 		} else {
 			return errors.New("Incorrect number of arguments")
 		}
@@ -30,10 +30,30 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+// SyncBooks holds the names of the books to include in, or exclude from, sync
+type SyncBooks struct {
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
+// Backup holds the retention policy for the snapshots of the database
+type Backup struct {
+	// Keep is the number of the most recent snapshots to keep
+	Keep int `yaml:"keep,omitempty"`
+	// MaxAge is the number of days after which snapshots are removed
+	MaxAge int `yaml:"maxAge,omitempty"`
+	// DisableAuto turns off the snapshots taken before risky operations
+	DisableAuto bool `yaml:"disableAuto,omitempty"`
+}
+
 // Config holds dnote configuration
 type Config struct {
-	Editor      string `yaml:"editor"`
-	APIEndpoint string `yaml:"apiEndpoint"`
+	Editor      string    `yaml:"editor"`
+	APIEndpoint string    `yaml:"apiEndpoint"`
+	AutoSync    bool      `yaml:"autoSync,omitempty"`
+	SyncBooks   SyncBooks `yaml:"syncBooks,omitempty"`
+	GitDir      string    `yaml:"gitDir,omitempty"`
+	Backup      Backup    `yaml:"backup,omitempty"`
 }
 
 func checkLegacyPath(ctx context.DnoteCtx) (string, bool) {
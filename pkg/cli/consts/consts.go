@@ -32,6 +32,23 @@ var (
 	TmpContentFileExt = "md"
 	// ConfigFilename is the name of the config file
 	ConfigFilename = "dnoterc"
+	// SyncLockFilename is the name of the file held by a running sync
+	SyncLockFilename = "sync.lock"
+	// SyncStatusFilename is the name of the file containing the status of the sync
+	SyncStatusFilename = "sync-status.json"
+	// SyncTriggerFilename is the name of the file touched when local data changes
+	SyncTriggerFilename = "sync-trigger"
+	// SyncLogFilename is the name of the file containing the summaries of the recent syncs
+	SyncLogFilename = "sync-log.json"
+	// ServeTokenFilename is the name of the file containing the token for the local API server
+	ServeTokenFilename = "serve-token"
+
+	// BackupDirName is the name of the directory containing the snapshots of the database
+	BackupDirName = "backups"
+	// DraftDirName is the name of the directory containing the drafts of notes
+	DraftDirName = "drafts"
+	// HookDirName is the name of the directory containing the user-defined hooks
+	HookDirName = "hooks"
 
 	// SystemSchema is the key for schema in the system table
 	SystemSchema = "schema"
@@ -41,6 +58,10 @@ var (
 	SystemLastSyncAt = "last_sync_time"
 	// SystemLastMaxUSN is the user's max_usn from the server at the alst sync
 	SystemLastMaxUSN = "last_max_usn"
+	// SystemSyncFilter is the book filter used at the last sync
+	SystemSyncFilter = "sync_filter"
+	// SystemGitCommit is the commit of the git repository that matches the local data
+	SystemGitCommit = "git_commit"
 	// SystemLastUpgrade is the timestamp at which the system more recently checked for an upgrade
 	SystemLastUpgrade = "last_upgrade"
 	// SystemSessionKey is the session key
@@ -42,6 +42,10 @@ type DnoteCtx struct {
 	SessionKey       string
 	SessionKeyExpiry int64
 	Editor           string
+	AutoSync         bool
+	SyncInclude      []string
+	SyncExclude      []string
+	GitDir           string
 	Clock            clock.Clock
 }
 
@@ -0,0 +1,76 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// copyFile copies the database at the given path into the database of the connection,
+// or the other way around if toFile is true
+func (d *DB) copyFile(path string, toFile bool) error {
+	db, ok := d.Conn.(*sql.DB)
+	if !ok {
+		return errors.New("cannot copy the database in a transaction")
+	}
+
+	other, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return errors.Wrapf(err, "opening %s", path)
+	}
+	defer other.Close()
+
+	bg := context.Background()
+	conn, err := db.Conn(bg)
+	if err != nil {
+		return errors.Wrap(err, "getting a connection")
+	}
+	defer conn.Close()
+	otherConn, err := other.Conn(bg)
+	if err != nil {
+		return errors.Wrapf(err, "getting a connection to %s", path)
+	}
+	defer otherConn.Close()
+
+	if toFile {
+		return copyDatabase(otherConn, conn)
+	}
+
+	return copyDatabase(conn, otherConn)
+}
+
+// Backup copies the database into the database file at the given path while the
+// database remains in use
+func (d *DB) Backup(path string) error {
+	return d.copyFile(path, true)
+}
+
+// Restore replaces the content of the database with the database file at the given path
+func (d *DB) Restore(path string) error {
+	// opening a missing file would create an empty database and erase all data
+	if _, err := os.Stat(path); err != nil {
+		return errors.Wrapf(err, "checking %s", path)
+	}
+
+	return d.copyFile(path, false)
+}
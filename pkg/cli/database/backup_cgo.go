@@ -0,0 +1,39 @@
+// +build cgo
+
+package database
+
+import (
+	"database/sql"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// copyDatabase copies the main database of the source connection into the destination
+// connection using the online backup API of SQLite
+func copyDatabase(dest, src *sql.Conn) error {
+	return dest.Raw(func(destDriverConn interface{}) error {
+		return src.Raw(func(srcDriverConn interface{}) error {
+			destConn, ok := destDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("the destination is not a sqlite connection")
+			}
+			srcConn, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("the source is not a sqlite connection")
+			}
+
+			b, err := destConn.Backup("main", srcConn, "main")
+			if err != nil {
+				return errors.Wrap(err, "initializing the backup")
+			}
+
+			if _, err := b.Step(-1); err != nil {
+				b.Finish()
+				return errors.Wrap(err, "copying the pages")
+			}
+
+			return b.Finish()
+		})
+	})
+}
@@ -0,0 +1,14 @@
+// +build !cgo
+
+package database
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// copyDatabase is not supported without cgo, which SQLite requires
+func copyDatabase(dest, src *sql.Conn) error {
+	return errors.New("copying the database requires cgo")
+}
@@ -24,12 +24,14 @@ import (
 
 // Book holds a metadata and its notes
 type Book struct {
-	UUID    string `json:"uuid"`
-	Label   string `json:"label"`
-	USN     int    `json:"usn"`
-	Notes   []Note `json:"notes"`
-	Deleted bool   `json:"deleted"`
-	Dirty   bool   `json:"dirty"`
+	UUID     string `json:"uuid"`
+	Label    string `json:"label"`
+	USN      int    `json:"usn"`
+	Notes    []Note `json:"notes"`
+	Deleted  bool   `json:"deleted"`
+	Dirty    bool   `json:"dirty"`
+	Pinned   bool   `json:"pinned"`
+	Archived bool   `json:"archived"`
 }
 
 // Note represents a note
@@ -44,6 +46,10 @@ type Note struct {
 	Public   bool   `json:"public"`
 	Deleted  bool   `json:"deleted"`
 	Dirty    bool   `json:"dirty"`
+	DueOn    int64  `json:"due_on"`
+	RemindOn int64  `json:"remind_on"`
+	Pinned   bool   `json:"pinned"`
+	Archived bool   `json:"archived"`
 }
 
 // NewNote constructs a note with the given data
@@ -63,8 +69,8 @@ func NewNote(uuid, bookUUID, body string, addedOn, editedOn int64, usn int, publ
 
 // Insert inserts a new note
 func (n Note) Insert(db *DB) error {
-	_, err := db.Exec("INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, public, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		n.UUID, n.BookUUID, n.Body, n.AddedOn, n.EditedOn, n.USN, n.Public, n.Deleted, n.Dirty)
+	_, err := db.Exec("INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, public, deleted, dirty, due_on, remind_on, pinned, archived) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		n.UUID, n.BookUUID, n.Body, n.AddedOn, n.EditedOn, n.USN, n.Public, n.Deleted, n.Dirty, n.DueOn, n.RemindOn, n.Pinned, n.Archived)
 
 	if err != nil {
 		return errors.Wrapf(err, "inserting note with uuid %s", n.UUID)
@@ -75,8 +81,8 @@ func (n Note) Insert(db *DB) error {
 
 // Update updates the note with the given data
 func (n Note) Update(db *DB) error {
-	_, err := db.Exec("UPDATE notes SET book_uuid = ?, body = ?, added_on = ?, edited_on = ?, usn = ?, public = ?, deleted = ?, dirty = ? WHERE uuid = ?",
-		n.BookUUID, n.Body, n.AddedOn, n.EditedOn, n.USN, n.Public, n.Deleted, n.Dirty, n.UUID)
+	_, err := db.Exec("UPDATE notes SET book_uuid = ?, body = ?, added_on = ?, edited_on = ?, usn = ?, public = ?, deleted = ?, dirty = ?, due_on = ?, remind_on = ?, pinned = ?, archived = ? WHERE uuid = ?",
+		n.BookUUID, n.Body, n.AddedOn, n.EditedOn, n.USN, n.Public, n.Deleted, n.Dirty, n.DueOn, n.RemindOn, n.Pinned, n.Archived, n.UUID)
 
 	if err != nil {
 		return errors.Wrapf(err, "updating the note with uuid %s", n.UUID)
@@ -121,8 +127,8 @@ func NewBook(uuid, label string, usn int, deleted, dirty bool) Book {
 
 // Insert inserts a new book
 func (b Book) Insert(db *DB) error {
-	_, err := db.Exec("INSERT INTO books (uuid, label, usn, dirty, deleted) VALUES (?, ?, ?, ?, ?)",
-		b.UUID, b.Label, b.USN, b.Dirty, b.Deleted)
+	_, err := db.Exec("INSERT INTO books (uuid, label, usn, dirty, deleted, pinned, archived) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		b.UUID, b.Label, b.USN, b.Dirty, b.Deleted, b.Pinned, b.Archived)
 
 	if err != nil {
 		return errors.Wrapf(err, "inserting book with uuid %s", b.UUID)
@@ -133,8 +139,8 @@ func (b Book) Insert(db *DB) error {
 
 // Update updates the book with the given data
 func (b Book) Update(db *DB) error {
-	_, err := db.Exec("UPDATE books SET label = ?, usn = ?, dirty = ?, deleted = ? WHERE uuid = ?",
-		b.Label, b.USN, b.Dirty, b.Deleted, b.UUID)
+	_, err := db.Exec("UPDATE books SET label = ?, usn = ?, dirty = ?, deleted = ?, pinned = ?, archived = ? WHERE uuid = ?",
+		b.Label, b.USN, b.Dirty, b.Deleted, b.Pinned, b.Archived, b.UUID)
 
 	if err != nil {
 		return errors.Wrapf(err, "updating the book with uuid %s", b.UUID)
@@ -89,17 +89,19 @@ type NoteInfo struct {
 	Content   string
 	AddedOn   int64
 	EditedOn  int64
+	DueOn     int64
+	RemindOn  int64
 }
 
 // GetNoteInfo returns a NoteInfo for the note with the given noteRowID
 func GetNoteInfo(db *DB, noteRowID int) (NoteInfo, error) {
 	var ret NoteInfo
 
-	err := db.QueryRow(`SELECT books.label, notes.uuid, notes.body, notes.added_on, notes.edited_on, notes.rowid
+	err := db.QueryRow(`SELECT books.label, notes.uuid, notes.body, notes.added_on, notes.edited_on, notes.due_on, notes.remind_on, notes.rowid
 			FROM notes
 			INNER JOIN books ON books.uuid = notes.book_uuid
 			WHERE notes.rowid = ? AND notes.deleted = false`, noteRowID).
-		Scan(&ret.BookLabel, &ret.UUID, &ret.Content, &ret.AddedOn, &ret.EditedOn, &ret.RowID)
+		Scan(&ret.BookLabel, &ret.UUID, &ret.Content, &ret.AddedOn, &ret.EditedOn, &ret.DueOn, &ret.RemindOn, &ret.RowID)
 	if err == sql.ErrNoRows {
 		return ret, errors.Errorf("note %d not found", noteRowID)
 	} else if err != nil {
@@ -158,6 +160,30 @@ func UpdateBookName(db *DB, uuid string, name string) error {
 	return nil
 }
 
+// UpdateBookPinned pins or unpins a book and marks the book as dirty
+func UpdateBookPinned(db *DB, uuid string, pinned bool) error {
+	_, err := db.Exec(`UPDATE books
+		SET pinned = ?, dirty = ?
+		WHERE uuid = ?`, pinned, true, uuid)
+	if err != nil {
+		return errors.Wrap(err, "updating the book")
+	}
+
+	return nil
+}
+
+// UpdateBookArchived archives or unarchives a book and marks the book as dirty
+func UpdateBookArchived(db *DB, uuid string, archived bool) error {
+	_, err := db.Exec(`UPDATE books
+		SET archived = ?, dirty = ?
+		WHERE uuid = ?`, archived, true, uuid)
+	if err != nil {
+		return errors.Wrap(err, "updating the book")
+	}
+
+	return nil
+}
+
 // GetActiveNote gets the note which has the given rowid and is not deleted
 func GetActiveNote(db *DB, rowid int) (Note, error) {
 	var ret Note
@@ -172,7 +198,11 @@ func GetActiveNote(db *DB, rowid int) (Note, error) {
 		usn,
 		public,
 		deleted,
-		dirty
+		dirty,
+		due_on,
+		remind_on,
+		pinned,
+		archived
 	FROM notes WHERE rowid = ? AND deleted = false;`, rowid).Scan(
 		&ret.RowID,
 		&ret.UUID,
@@ -184,6 +214,10 @@ func GetActiveNote(db *DB, rowid int) (Note, error) {
 		&ret.Public,
 		&ret.Deleted,
 		&ret.Dirty,
+		&ret.DueOn,
+		&ret.RemindOn,
+		&ret.Pinned,
+		&ret.Archived,
 	)
 
 	if err == sql.ErrNoRows {
@@ -209,6 +243,62 @@ func UpdateNoteContent(db *DB, c clock.Clock, rowID int, content string) error {
 	return nil
 }
 
+// UpdateNotePublic changes the visibility of the note and marks the note as dirty
+func UpdateNotePublic(db *DB, c clock.Clock, rowID int, public bool) error {
+	ts := c.Now().UnixNano()
+
+	_, err := db.Exec(`UPDATE notes
+			SET public = ?, edited_on = ?, dirty = ?
+			WHERE rowid = ?`, public, ts, true, rowID)
+	if err != nil {
+		return errors.Wrap(err, "updating the note")
+	}
+
+	return nil
+}
+
+// UpdateNotePinned pins or unpins the note and marks the note as dirty
+func UpdateNotePinned(db *DB, c clock.Clock, rowID int, pinned bool) error {
+	ts := c.Now().UnixNano()
+
+	_, err := db.Exec(`UPDATE notes
+			SET pinned = ?, edited_on = ?, dirty = ?
+			WHERE rowid = ?`, pinned, ts, true, rowID)
+	if err != nil {
+		return errors.Wrap(err, "updating the note")
+	}
+
+	return nil
+}
+
+// UpdateNoteArchived archives or unarchives the note and marks the note as dirty
+func UpdateNoteArchived(db *DB, c clock.Clock, rowID int, archived bool) error {
+	ts := c.Now().UnixNano()
+
+	_, err := db.Exec(`UPDATE notes
+			SET archived = ?, edited_on = ?, dirty = ?
+			WHERE rowid = ?`, archived, ts, true, rowID)
+	if err != nil {
+		return errors.Wrap(err, "updating the note")
+	}
+
+	return nil
+}
+
+// UpdateNoteSchedule sets the due and reminder times of the note and marks the note as dirty
+func UpdateNoteSchedule(db *DB, c clock.Clock, rowID int, dueOn, remindOn int64) error {
+	ts := c.Now().UnixNano()
+
+	_, err := db.Exec(`UPDATE notes
+			SET due_on = ?, remind_on = ?, edited_on = ?, dirty = ?
+			WHERE rowid = ?`, dueOn, remindOn, ts, true, rowID)
+	if err != nil {
+		return errors.Wrap(err, "updating the note")
+	}
+
+	return nil
+}
+
 // UpdateNoteBook moves the note to a different book and marks the note as dirty
 func UpdateNoteBook(db *DB, c clock.Clock, rowID int, bookUUID string) error {
 	ts := c.Now().UnixNano()
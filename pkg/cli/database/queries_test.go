@@ -322,6 +322,71 @@ func TestUpdateNoteContent(t *testing.T) {
 	assert.Equal(t, dirty, true, "dirty mismatch")
 }
 
+func TestUpdateNotePublic(t *testing.T) {
+	// set up
+	db := InitTestDB(t, "../tmp/dnote-test.db", nil)
+	defer TeardownTestDB(t, db)
+
+	uuid := "n1-uuid"
+	MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, public, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", uuid, "b1-uuid", "n1 content", 1542058875, 0, 1, false, false, false)
+
+	var rowid int
+	MustScan(t, "getting rowid", db.QueryRow("SELECT rowid FROM notes WHERE uuid = ?", uuid), &rowid)
+
+	// execute
+	c := clock.NewMock()
+	now := time.Date(2017, time.March, 14, 21, 15, 0, 0, time.UTC)
+	c.SetNow(now)
+
+	err := UpdateNotePublic(db, c, rowid, true)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	var public bool
+	var editedOn int
+	var dirty bool
+
+	MustScan(t, "getting the note record", db.QueryRow("SELECT public, edited_on, dirty FROM notes WHERE rowid = ?", rowid), &public, &editedOn, &dirty)
+
+	assert.Equal(t, public, true, "public mismatch")
+	assert.Equal(t, int64(editedOn), now.UnixNano(), "editedOn mismatch")
+	assert.Equal(t, dirty, true, "dirty mismatch")
+}
+
+func TestUpdateNoteSchedule(t *testing.T) {
+	// set up
+	db := InitTestDB(t, "../tmp/dnote-test.db", nil)
+	defer TeardownTestDB(t, db)
+
+	uuid := "n1-uuid"
+	MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, public, deleted, dirty, due_on, remind_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", uuid, "b1-uuid", "n1 content", 1542058875, 0, 1, false, false, false, 1542058900, 1542058800)
+
+	var rowid int
+	MustScan(t, "getting rowid", db.QueryRow("SELECT rowid FROM notes WHERE uuid = ?", uuid), &rowid)
+
+	// execute
+	c := clock.NewMock()
+	now := time.Date(2017, time.March, 14, 21, 15, 0, 0, time.UTC)
+	c.SetNow(now)
+
+	err := UpdateNoteSchedule(db, c, rowid, 1542059000, 0)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	var dueOn, remindOn int64
+	var editedOn int
+	var dirty bool
+
+	MustScan(t, "getting the note record", db.QueryRow("SELECT due_on, remind_on, edited_on, dirty FROM notes WHERE rowid = ?", rowid), &dueOn, &remindOn, &editedOn, &dirty)
+
+	assert.Equal(t, dueOn, int64(1542059000), "dueOn mismatch")
+	assert.Equal(t, remindOn, int64(0), "remindOn mismatch")
+	assert.Equal(t, int64(editedOn), now.UnixNano(), "editedOn mismatch")
+	assert.Equal(t, dirty, true, "dirty mismatch")
+}
+
 func TestUpdateNoteBook(t *testing.T) {
 	// set up
 	db := InitTestDB(t, "../tmp/dnote-test.db", nil)
@@ -382,3 +447,60 @@ func TestUpdateBookName(t *testing.T) {
 	assert.Equal(t, b1.USN, 8, "USN mismatch")
 	assert.Equal(t, b1.Deleted, false, "Deleted mismatch")
 }
+
+func TestUpdateBookPinnedArchived(t *testing.T) {
+	// set up
+	db := InitTestDB(t, "../tmp/dnote-test.db", nil)
+	defer TeardownTestDB(t, db)
+
+	b1UUID := "b1-uuid"
+	MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", b1UUID, "b1-label", 8, false, false)
+
+	// execute
+	if err := UpdateBookPinned(db, b1UUID, true); err != nil {
+		t.Fatal(errors.Wrap(err, "pinning"))
+	}
+	if err := UpdateBookArchived(db, b1UUID, true); err != nil {
+		t.Fatal(errors.Wrap(err, "archiving"))
+	}
+
+	// test
+	var b1 Book
+	MustScan(t, "getting the book record", db.QueryRow("SELECT pinned, archived, dirty, usn FROM books WHERE uuid = ?", b1UUID), &b1.Pinned, &b1.Archived, &b1.Dirty, &b1.USN)
+	assert.Equal(t, b1.Pinned, true, "Pinned mismatch")
+	assert.Equal(t, b1.Archived, true, "Archived mismatch")
+	assert.Equal(t, b1.Dirty, true, "Dirty mismatch")
+	assert.Equal(t, b1.USN, 8, "USN mismatch")
+}
+
+func TestUpdateNotePinnedArchived(t *testing.T) {
+	// set up
+	db := InitTestDB(t, "../tmp/dnote-test.db", nil)
+	defer TeardownTestDB(t, db)
+
+	uuid := "n1-uuid"
+	MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, edited_on, usn, public, deleted, dirty, pinned, archived) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", uuid, "b1-uuid", "n1 content", 1542058875, 0, 1, false, false, false, true, false)
+
+	var rowid int
+	MustScan(t, "getting rowid", db.QueryRow("SELECT rowid FROM notes WHERE uuid = ?", uuid), &rowid)
+
+	// execute
+	c := clock.NewMock()
+	now := time.Date(2017, time.March, 14, 21, 15, 0, 0, time.UTC)
+	c.SetNow(now)
+
+	if err := UpdateNotePinned(db, c, rowid, false); err != nil {
+		t.Fatal(errors.Wrap(err, "unpinning"))
+	}
+	if err := UpdateNoteArchived(db, c, rowid, true); err != nil {
+		t.Fatal(errors.Wrap(err, "archiving"))
+	}
+
+	// test
+	var n1 Note
+	MustScan(t, "getting the note record", db.QueryRow("SELECT pinned, archived, edited_on, dirty FROM notes WHERE rowid = ?", rowid), &n1.Pinned, &n1.Archived, &n1.EditedOn, &n1.Dirty)
+	assert.Equal(t, n1.Pinned, false, "Pinned mismatch")
+	assert.Equal(t, n1.Archived, true, "Archived mismatch")
+	assert.Equal(t, n1.EditedOn, now.UnixNano(), "EditedOn mismatch")
+	assert.Equal(t, n1.Dirty, true, "Dirty mismatch")
+}
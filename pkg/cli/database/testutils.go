@@ -34,7 +34,7 @@ var defaultSchemaSQL = `CREATE TABLE books
 		(
 			uuid text PRIMARY KEY,
 			label text NOT NULL
-		, dirty bool DEFAULT false, usn int DEFAULT 0 NOT NULL, deleted bool DEFAULT false);
+		, dirty bool DEFAULT false, usn int DEFAULT 0 NOT NULL, deleted bool DEFAULT false, local_only bool DEFAULT false, pinned bool DEFAULT false, archived bool DEFAULT false);
 CREATE TABLE system
 		(
 			key string NOT NULL,
@@ -53,7 +53,7 @@ CREATE TABLE IF NOT EXISTS "notes"
 			dirty bool DEFAULT false,
 			usn int DEFAULT 0 NOT NULL,
 			deleted bool DEFAULT false
-		);
+		, base_body text, due_on integer DEFAULT 0, remind_on integer DEFAULT 0, pinned bool DEFAULT false, archived bool DEFAULT false);
 CREATE VIRTUAL TABLE note_fts USING fts5(content=notes, body, tokenize="porter unicode61 categories 'L* N* Co Ps Pe'")
 /* note_fts(body) */;
 CREATE TABLE IF NOT EXISTS 'note_fts_data'(id INTEGER PRIMARY KEY, block BLOB);
@@ -79,7 +79,16 @@ CREATE TABLE actions
 			timestamp integer NOT NULL
 		);
 CREATE UNIQUE INDEX idx_notes_uuid ON notes(uuid);
-CREATE INDEX idx_notes_book_uuid ON notes(book_uuid);`
+CREATE INDEX idx_notes_book_uuid ON notes(book_uuid);
+CREATE TABLE outbox
+		(
+			type text NOT NULL,
+			uuid text NOT NULL,
+			error text NOT NULL,
+			attempts integer NOT NULL DEFAULT 0,
+			last_attempt_at integer NOT NULL
+		);
+CREATE UNIQUE INDEX idx_outbox_type_uuid ON outbox(type, uuid);`
 
 // MustScan scans the given row and fails a test in case of any errors
 func MustScan(t *testing.T, message string, row *sql.Row, args ...interface{}) {
@@ -162,7 +171,7 @@ func OpenTestDB(t *testing.T, dnoteDir string) *DB {
 
 // MarkMigrationComplete marks all migrations as complete in the database
 func MarkMigrationComplete(t *testing.T, db *DB) {
-	if _, err := db.Exec("INSERT INTO system (key, value) VALUES (? , ?);", consts.SystemSchema, 12); err != nil {
+	if _, err := db.Exec("INSERT INTO system (key, value) VALUES (? , ?);", consts.SystemSchema, 17); err != nil {
 		t.Fatal(errors.Wrap(err, "inserting schema"))
 	}
 	if _, err := db.Exec("INSERT INTO system (key, value) VALUES (? , ?);", consts.SystemRemoteSchema, 1); err != nil {
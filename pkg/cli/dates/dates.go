@@ -0,0 +1,224 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package dates parses the natural language dates accepted by the commands
+package dates
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultHour is the hour of the day used for a date given without a time
+const DefaultHour = 9
+
+var relativeRe = regexp.MustCompile(`^in (\d+|an?) (minute|min|hour|hr|day|week|month)s?$`)
+var agoRe = regexp.MustCompile(`^(\d+|an?) (minute|min|hour|hr|day|week|month)s? ago$`)
+var clockRe = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s?(am|pm)?$`)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// parseClock parses a time of the day such as "3pm", "3:30pm" or "15:00"
+func parseClock(s string) (int, int, error) {
+	m := clockRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, errors.Errorf("invalid time '%s'", s)
+	}
+
+	hour, _ := strconv.Atoi(m[1])
+	min := 0
+	if m[2] != "" {
+		min, _ = strconv.Atoi(m[2])
+	}
+
+	switch m[3] {
+	case "am":
+		if hour < 1 || hour > 12 {
+			return 0, 0, errors.Errorf("invalid time '%s'", s)
+		}
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, errors.Errorf("invalid time '%s'", s)
+		}
+		if hour != 12 {
+			hour += 12
+		}
+	default:
+		// a bare number such as "3" is ambiguous without a minute or a meridiem
+		if m[2] == "" {
+			return 0, 0, errors.Errorf("invalid time '%s'", s)
+		}
+	}
+
+	if hour > 23 || min > 59 {
+		return 0, 0, errors.Errorf("invalid time '%s'", s)
+	}
+
+	return hour, min, nil
+}
+
+func atClock(t time.Time, hour, min int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), hour, min, 0, 0, t.Location())
+}
+
+// parseRelative parses a duration relative to now such as "in 3 days" or "2 weeks ago"
+func parseRelative(s string, now time.Time) (time.Time, bool, bool) {
+	sign := 1
+	m := relativeRe.FindStringSubmatch(s)
+	if m == nil {
+		sign = -1
+		m = agoRe.FindStringSubmatch(s)
+	}
+	if m == nil {
+		return time.Time{}, false, false
+	}
+
+	n := 1
+	if m[1] != "a" && m[1] != "an" {
+		n, _ = strconv.Atoi(m[1])
+	}
+	n *= sign
+
+	switch m[2] {
+	case "minute", "min":
+		return now.Add(time.Duration(n) * time.Minute), true, true
+	case "hour", "hr":
+		return now.Add(time.Duration(n) * time.Hour), true, true
+	case "day":
+		return now.AddDate(0, 0, n), false, true
+	case "week":
+		return now.AddDate(0, 0, 7*n), false, true
+	default:
+		return now.AddDate(0, n, 0), false, true
+	}
+}
+
+// parseDay parses a calendar day. It returns the day and whether the
+// result already carries a time of the day.
+func parseDay(s string, now time.Time) (time.Time, bool, error) {
+	switch s {
+	case "now":
+		return now, true, nil
+	case "today":
+		return now, false, nil
+	case "tomorrow":
+		return now.AddDate(0, 0, 1), false, nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), false, nil
+	}
+
+	if t, hasClock, ok := parseRelative(s, now); ok {
+		return t, hasClock, nil
+	}
+
+	if wd, ok := weekdays[strings.TrimPrefix(s, "next ")]; ok {
+		diff := (int(wd) - int(now.Weekday()) + 7) % 7
+		if diff == 0 {
+			diff = 7
+		}
+
+		return now.AddDate(0, 0, diff), false, nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02 15:04", s, now.Location()); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, false, nil
+	}
+
+	return time.Time{}, false, errors.Errorf("unrecognized date '%s'", s)
+}
+
+// Parse parses a date such as "tomorrow", "in 3 days", "next friday at 3pm"
+// or "2020-04-01 15:00" relative to now. A day given without a time of the
+// day falls on DefaultHour.
+func Parse(s string, now time.Time) (time.Time, error) {
+	return parse(s, now, func(day time.Time) time.Time {
+		return atClock(day, DefaultHour, 0)
+	})
+}
+
+// ParseBound parses a date like Parse for a bound of a range of dates. A day
+// given without a time of the day falls on the start of the day, or on the
+// end of the day if end is true, so that the bounds include the whole day.
+func ParseBound(s string, now time.Time, end bool) (time.Time, error) {
+	return parse(s, now, func(day time.Time) time.Time {
+		start := atClock(day, 0, 0)
+		if end {
+			return start.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+
+		return start
+	})
+}
+
+// parse parses a date and uses the given function to place a day given
+// without a time of the day
+func parse(s string, now time.Time, placeDay func(day time.Time) time.Time) (time.Time, error) {
+	s = strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	if s == "" {
+		return time.Time{}, errors.New("empty date")
+	}
+
+	dayPart, clockPart := s, ""
+	if strings.HasPrefix(s, "at ") {
+		dayPart, clockPart = "today", strings.TrimPrefix(s, "at ")
+	} else if idx := strings.LastIndex(s, " at "); idx != -1 {
+		dayPart, clockPart = s[:idx], s[idx+len(" at "):]
+	}
+
+	day, hasClock, err := parseDay(dayPart, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if clockPart != "" {
+		if hasClock {
+			return time.Time{}, errors.Errorf("'%s' already has a time", dayPart)
+		}
+
+		hour, min, err := parseClock(clockPart)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return atClock(day, hour, min), nil
+	}
+
+	if hasClock {
+		return day, nil
+	}
+
+	return placeDay(day), nil
+}
@@ -0,0 +1,196 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package dates
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dnote/dnote/pkg/assert"
+)
+
+func TestParse(t *testing.T) {
+	// Wednesday
+	now := time.Date(2020, time.April, 1, 14, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		input    string
+		expected time.Time
+	}{
+		{
+			input:    "now",
+			expected: now,
+		},
+		{
+			input:    "today",
+			expected: time.Date(2020, time.April, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "Tomorrow",
+			expected: time.Date(2020, time.April, 2, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "tomorrow at 3pm",
+			expected: time.Date(2020, time.April, 2, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "at 17:45",
+			expected: time.Date(2020, time.April, 1, 17, 45, 0, 0, time.UTC),
+		},
+		{
+			input:    "at 12am",
+			expected: time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "in 30 minutes",
+			expected: time.Date(2020, time.April, 1, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "in an hour",
+			expected: time.Date(2020, time.April, 1, 15, 30, 0, 0, time.UTC),
+		},
+		{
+			input:    "in 3 days",
+			expected: time.Date(2020, time.April, 4, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "in  2 weeks at 8:15am",
+			expected: time.Date(2020, time.April, 15, 8, 15, 0, 0, time.UTC),
+		},
+		{
+			input:    "in 1 month",
+			expected: time.Date(2020, time.May, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "friday",
+			expected: time.Date(2020, time.April, 3, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "next wednesday at 12pm",
+			expected: time.Date(2020, time.April, 8, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "2020-05-20",
+			expected: time.Date(2020, time.May, 20, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "2020-05-20 18:00",
+			expected: time.Date(2020, time.May, 20, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "2020-05-20 at 6:30pm",
+			expected: time.Date(2020, time.May, 20, 18, 30, 0, 0, time.UTC),
+		},
+		{
+			input:    "yesterday",
+			expected: time.Date(2020, time.March, 31, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "2 hours ago",
+			expected: time.Date(2020, time.April, 1, 12, 30, 0, 0, time.UTC),
+		},
+		{
+			input:    "a week ago",
+			expected: time.Date(2020, time.March, 25, 9, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			got, err := Parse(tc.input, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, got, tc.expected, "result mismatch")
+		})
+	}
+}
+
+func TestParseBound(t *testing.T) {
+	now := time.Date(2020, time.April, 1, 14, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		input    string
+		end      bool
+		expected time.Time
+	}{
+		{
+			input:    "today",
+			end:      false,
+			expected: time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "today",
+			end:      true,
+			expected: time.Date(2020, time.April, 1, 23, 59, 59, 999999999, time.UTC),
+		},
+		{
+			input:    "3 days ago",
+			end:      false,
+			expected: time.Date(2020, time.March, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "2020-03-01 at 5pm",
+			end:      true,
+			expected: time.Date(2020, time.March, 1, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "an hour ago",
+			end:      false,
+			expected: time.Date(2020, time.April, 1, 13, 30, 0, 0, time.UTC),
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			got, err := ParseBound(tc.input, now, tc.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, got, tc.expected, "result mismatch")
+		})
+	}
+}
+
+func TestParse_invalid(t *testing.T) {
+	now := time.Date(2020, time.April, 1, 14, 30, 0, 0, time.UTC)
+
+	testCases := []string{
+		"",
+		"someday",
+		"in three days",
+		"tomorrow at",
+		"tomorrow at 3",
+		"tomorrow at 13pm",
+		"at 24:00",
+		"now at 3pm",
+		"in 2 hours at 3pm",
+		"2020-13-01",
+	}
+
+	for idx, input := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			_, err := Parse(input, now)
+			assert.NotEqual(t, err, nil, fmt.Sprintf("error mismatch for '%s'", input))
+		})
+	}
+}
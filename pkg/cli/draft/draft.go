@@ -0,0 +1,476 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package draft keeps the content being written in an editor until it is saved
+package draft
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/ui"
+	"github.com/dnote/dnote/pkg/cli/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	// KindAdd is the kind of the drafts for new notes
+	KindAdd = "add"
+	// KindEdit is the kind of the drafts for editing existing notes
+	KindEdit = "edit"
+	// KindEditMeta is the kind of the drafts for editing existing notes along with
+	// their metadata in a front matter
+	KindEditMeta = "edit-meta"
+	// KindEditBook is the kind of the drafts for editing all notes in a book
+	KindEditBook = "edit-book"
+
+	metaExt    = ".json"
+	contentExt = ".md"
+	lockExt    = ".lock"
+)
+
+var (
+	// refreshInterval is how often a process editing a draft refreshes its lock
+	refreshInterval = 30 * time.Second
+	// staleAfter is the duration after which the lock held on another machine is
+	// considered to be left behind if it has not been refreshed. The liveness of
+	// the holder can only be checked on the same machine.
+	staleAfter = 2 * time.Minute
+)
+
+// errLocked is returned when the draft is being edited by another process
+var errLocked = errors.New("the draft is being edited by another process")
+
+// Draft is content being written in an editor that has not been saved as a note yet
+type Draft struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	// BookLabel is the book to which the note is added, or the book of the edited note
+	BookLabel string `json:"book_label"`
+	// NoteUUID is the uuid of the edited note
+	NoteUUID string `json:"note_uuid,omitempty"`
+	// NoteUUIDs is the uuids of the notes in the document of a book when the draft was
+	// created. It is nil for the drafts of other kinds, and for the drafts of a book
+	// created by an older version.
+	NoteUUIDs []string `json:"note_uuids"`
+	CreatedAt int64    `json:"created_at"`
+
+	dir string
+}
+
+// lockOwner identifies the process editing a draft
+type lockOwner struct {
+	Hostname string `json:"hostname"`
+	PID      int    `json:"pid"`
+}
+
+func getLockOwner() lockOwner {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Debug("getting the hostname: %s\n", err)
+	}
+
+	return lockOwner{Hostname: hostname, PID: os.Getpid()}
+}
+
+// getDir returns the path to the directory containing the drafts
+func getDir(ctx context.DnoteCtx) string {
+	return filepath.Join(ctx.Paths.Data, consts.DnoteDirName, consts.DraftDirName)
+}
+
+// ContentPath returns the path to the file containing the content of the draft
+func (d Draft) ContentPath() string {
+	return filepath.Join(d.dir, d.ID+contentExt)
+}
+
+func (d Draft) metaPath() string {
+	return filepath.Join(d.dir, d.ID+metaExt)
+}
+
+func (d Draft) lockPath() string {
+	return filepath.Join(d.dir, d.ID+lockExt)
+}
+
+// readLock returns the owner of the lock of the draft and whether the lock is held.
+// A lock is not held if its owner is no longer running.
+func (d Draft) readLock(self lockOwner) (lockOwner, bool) {
+	info, err := os.Stat(d.lockPath())
+	if err != nil {
+		return lockOwner{}, false
+	}
+
+	var owner lockOwner
+	if b, err := ioutil.ReadFile(d.lockPath()); err == nil {
+		if err := json.Unmarshal(b, &owner); err != nil {
+			log.Debug("unmarshalling the lock of the draft %s: %s\n", d.ID, err)
+		}
+	}
+
+	if owner.Hostname != "" && owner.Hostname == self.Hostname {
+		return owner, utils.ProcessExists(owner.PID)
+	}
+
+	return owner, time.Since(info.ModTime()) < staleAfter
+}
+
+// InUse returns true if the draft is being edited by a running process
+func (d Draft) InUse() bool {
+	_, held := d.readLock(getLockOwner())
+
+	return held
+}
+
+// createLock exclusively creates the lock file and writes the owner into it
+func createLock(path string, owner lockOwner) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := json.NewEncoder(f).Encode(owner); err != nil {
+		return errors.Wrap(err, "writing the owner")
+	}
+
+	return nil
+}
+
+// lock marks the draft as being edited by this process. It returns errLocked if
+// another running process is editing the draft.
+func (d Draft) lock() error {
+	self := getLockOwner()
+
+	for {
+		err := createLock(d.lockPath(), self)
+		if err == nil {
+			return nil
+		}
+		if !os.IsExist(err) {
+			return errors.Wrap(err, "creating the lock file")
+		}
+
+		owner, held := d.readLock(self)
+		if owner == self {
+			return nil
+		}
+		if held {
+			return errLocked
+		}
+
+		log.Debug("taking over the abandoned lock of the draft %s\n", d.ID)
+		if err := os.Remove(d.lockPath()); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "removing the abandoned lock")
+		}
+	}
+}
+
+// unlock marks the draft as no longer being edited if it is locked by this process
+func (d Draft) unlock() {
+	self := getLockOwner()
+	if owner, _ := d.readLock(self); owner != self {
+		return
+	}
+
+	if err := os.Remove(d.lockPath()); err != nil && !os.IsNotExist(err) {
+		log.Debug("removing the lock of the draft %s: %s\n", d.ID, err)
+	}
+}
+
+// Content returns the content of the draft
+func (d Draft) Content() (string, error) {
+	b, err := ioutil.ReadFile(d.ContentPath())
+	if err != nil {
+		return "", errors.Wrap(err, "reading the draft")
+	}
+
+	return string(b), nil
+}
+
+// Discard removes the draft
+func (d Draft) Discard() error {
+	if err := os.Remove(d.ContentPath()); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "removing the content")
+	}
+	if err := os.Remove(d.metaPath()); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "removing the metadata")
+	}
+	if err := os.Remove(d.lockPath()); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "removing the lock")
+	}
+
+	return nil
+}
+
+// refreshLock keeps the lock of the draft from looking abandoned on other machines
+func (d Draft) refreshLock() error {
+	now := time.Now()
+
+	return os.Chtimes(d.lockPath(), now, now)
+}
+
+// New creates a draft with the given initial content
+func New(ctx context.DnoteCtx, kind, bookLabel, noteUUID, content string) (Draft, error) {
+	return create(ctx, Draft{Kind: kind, BookLabel: bookLabel, NoteUUID: noteUUID}, content)
+}
+
+// create creates a draft with the metadata of the given draft and the initial content
+func create(ctx context.DnoteCtx, d Draft, content string) (Draft, error) {
+	dir := getDir(ctx)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Draft{}, errors.Wrap(err, "creating the draft directory")
+	}
+
+	d.CreatedAt = ctx.Clock.Now().UnixNano()
+	d.dir = dir
+
+	// the lock and the metadata file are created exclusively so that concurrent processes
+	// get different drafts, and the draft is locked before it can be listed
+	self := getLockOwner()
+	for {
+		uuid, err := utils.GenerateUUID()
+		if err != nil {
+			return Draft{}, errors.Wrap(err, "generating uuid")
+		}
+		d.ID = uuid[:8]
+
+		b, err := json.Marshal(d)
+		if err != nil {
+			return Draft{}, errors.Wrap(err, "marshalling the draft")
+		}
+
+		if err := createLock(d.lockPath(), self); os.IsExist(err) {
+			continue
+		} else if err != nil {
+			return Draft{}, errors.Wrap(err, "locking the draft")
+		}
+
+		f, err := os.OpenFile(d.metaPath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			d.unlock()
+			continue
+		} else if err != nil {
+			d.unlock()
+			return Draft{}, errors.Wrap(err, "creating the draft")
+		}
+
+		_, err = f.Write(b)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			d.Discard()
+			return Draft{}, errors.Wrap(err, "writing the draft")
+		}
+
+		break
+	}
+
+	if err := ioutil.WriteFile(d.ContentPath(), []byte(content), 0644); err != nil {
+		d.Discard()
+		return Draft{}, errors.Wrap(err, "writing the content")
+	}
+
+	return d, nil
+}
+
+func read(dir, metaName string) (Draft, error) {
+	path := filepath.Join(dir, metaName)
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Draft{}, errors.Wrap(err, "reading the draft")
+	}
+
+	var ret Draft
+	if err := json.Unmarshal(b, &ret); err != nil {
+		return Draft{}, errors.Wrap(err, "unmarshalling the draft")
+	}
+
+	ret.dir = dir
+
+	return ret, nil
+}
+
+// List returns the drafts from the oldest to the newest
+func List(ctx context.DnoteCtx) ([]Draft, error) {
+	dir := getDir(ctx)
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading the draft directory")
+	}
+
+	var ret []Draft
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), metaExt) {
+			continue
+		}
+
+		d, err := read(dir, f.Name())
+		if err != nil {
+			log.Debug("skipping the draft %s: %s\n", f.Name(), err)
+			continue
+		}
+
+		ret = append(ret, d)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].CreatedAt < ret[j].CreatedAt
+	})
+
+	return ret, nil
+}
+
+// Find returns the draft with the given id
+func Find(ctx context.DnoteCtx, id string) (Draft, error) {
+	drafts, err := List(ctx)
+	if err != nil {
+		return Draft{}, err
+	}
+
+	for _, d := range drafts {
+		if d.ID == id {
+			return d, nil
+		}
+	}
+
+	return Draft{}, errors.Errorf("draft '%s' not found", id)
+}
+
+// findAbandoned returns the drafts of the given kind for the given book or note that
+// are not being edited by a running process, from the newest to the oldest
+func findAbandoned(ctx context.DnoteCtx, kind, bookLabel, noteUUID string) ([]Draft, error) {
+	drafts, err := List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Draft
+	for i := len(drafts) - 1; i >= 0; i-- {
+		d := drafts[i]
+		if d.Kind != kind || d.InUse() {
+			continue
+		}
+
+		switch kind {
+		case KindAdd, KindEditBook:
+			if d.BookLabel != bookLabel {
+				continue
+			}
+		default:
+			if d.NoteUUID != noteUUID {
+				continue
+			}
+		}
+
+		ret = append(ret, d)
+	}
+
+	return ret, nil
+}
+
+// Get returns a draft to edit. If a draft for the same book or note was left behind,
+// it asks the user whether to resume it. Otherwise, a new draft with the given content
+// is created.
+func Get(ctx context.DnoteCtx, kind, bookLabel, noteUUID, content string) (Draft, error) {
+	return get(ctx, Draft{Kind: kind, BookLabel: bookLabel, NoteUUID: noteUUID}, content)
+}
+
+// GetBook returns a draft to edit all notes in a book, like Get. The uuids of the notes
+// in the document are kept in the draft so that a resumed draft is compared only to
+// the notes it was created from.
+func GetBook(ctx context.DnoteCtx, bookLabel string, noteUUIDs []string, content string) (Draft, error) {
+	if noteUUIDs == nil {
+		noteUUIDs = []string{}
+	}
+
+	return get(ctx, Draft{Kind: KindEditBook, BookLabel: bookLabel, NoteUUIDs: noteUUIDs}, content)
+}
+
+func get(ctx context.DnoteCtx, d Draft, content string) (Draft, error) {
+	abandoned, err := findAbandoned(ctx, d.Kind, d.BookLabel, d.NoteUUID)
+	if err != nil {
+		return Draft{}, errors.Wrap(err, "finding the unsaved drafts")
+	}
+
+	if len(abandoned) > 0 {
+		a := abandoned[0]
+		createdAt := time.Unix(0, a.CreatedAt).Format("Jan 2, 2006 3:04pm")
+
+		ok, err := ui.Confirm(fmt.Sprintf("resume the unsaved draft %s from %s?", a.ID, createdAt), true)
+		if err != nil {
+			return Draft{}, errors.Wrap(err, "getting confirmation")
+		}
+		if ok {
+			return a, nil
+		}
+
+		log.Plainf("The draft is kept. Run `dnote drafts discard %s` to remove it.\n", a.ID)
+	}
+
+	return create(ctx, d, content)
+}
+
+// Edit launches a text editor to edit the draft and returns the content after the
+// editor exits. The draft is locked by this process until it is discarded or kept.
+func (d Draft) Edit(ctx context.DnoteCtx) (string, error) {
+	if err := d.lock(); err == errLocked {
+		return "", errors.Errorf("draft %s is being edited by another process", d.ID)
+	} else if err != nil {
+		return "", errors.Wrap(err, "locking the draft")
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := d.refreshLock(); err != nil {
+					log.Debug("refreshing the lock of the draft: %s\n", err)
+				}
+			}
+		}
+	}()
+
+	return ui.EditFile(ctx, d.ContentPath())
+}
+
+// Keep tells the user that the content could not be saved and is kept in the draft,
+// and releases the draft so that it can be resumed
+func (d Draft) Keep() {
+	d.unlock()
+	log.Plainf("The content is kept in the draft %s. Run `dnote drafts resume %s` to try again.\n", d.ID, d.ID)
+}
@@ -0,0 +1,322 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package draft
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/clock"
+	"github.com/pkg/errors"
+)
+
+var paths context.Paths = context.Paths{
+	Home:   "../tmp",
+	Cache:  "../tmp",
+	Config: "../tmp",
+	Data:   "../tmp",
+}
+
+// writeLock replaces the lock of the draft with one held by the given owner
+func writeLock(t *testing.T, d Draft, owner lockOwner) {
+	if err := os.Remove(d.lockPath()); err != nil && !os.IsNotExist(err) {
+		t.Fatal(errors.Wrap(err, "removing the lock"))
+	}
+	if err := createLock(d.lockPath(), owner); err != nil {
+		t.Fatal(errors.Wrap(err, "creating the lock"))
+	}
+}
+
+// abandon makes the draft look like it was left behind by a process that is no longer running
+func abandon(t *testing.T, d Draft) {
+	self := getLockOwner()
+	writeLock(t, d, lockOwner{Hostname: self.Hostname, PID: 0})
+}
+
+func getIDs(drafts []Draft) []string {
+	var ret []string
+	for _, d := range drafts {
+		ret = append(ret, d.ID)
+	}
+
+	return ret
+}
+
+func TestNew(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	// execute
+	d1, err := New(ctx, KindAdd, "js", "", "")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating the first draft"))
+	}
+	d2, err := New(ctx, KindEdit, "css", "n1-uuid", "n1 body")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating the second draft"))
+	}
+
+	// test
+	assert.NotEqual(t, d1.ID, d2.ID, "ids mismatch")
+	assert.NotEqual(t, d1.ContentPath(), d2.ContentPath(), "content paths mismatch")
+
+	drafts, err := List(ctx)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "listing"))
+	}
+	assert.Equal(t, len(drafts), 2, "draft count mismatch")
+
+	found, err := Find(ctx, d2.ID)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "finding"))
+	}
+	assert.Equal(t, found.Kind, KindEdit, "kind mismatch")
+	assert.Equal(t, found.BookLabel, "css", "book label mismatch")
+	assert.Equal(t, found.NoteUUID, "n1-uuid", "note uuid mismatch")
+	assert.Equal(t, found.InUse(), true, "in use mismatch")
+
+	content, err := found.Content()
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "reading the content"))
+	}
+	assert.Equal(t, content, "n1 body", "content mismatch")
+}
+
+func TestDiscard(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	d1, err := New(ctx, KindAdd, "js", "", "d1 content")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating the first draft"))
+	}
+	d2, err := New(ctx, KindAdd, "js", "", "d2 content")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating the second draft"))
+	}
+
+	// execute
+	if err := d1.Discard(); err != nil {
+		t.Fatal(errors.Wrap(err, "discarding"))
+	}
+
+	// test
+	drafts, err := List(ctx)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "listing"))
+	}
+	assert.DeepEqual(t, getIDs(drafts), []string{d2.ID}, "drafts mismatch")
+
+	_, err = Find(ctx, d1.ID)
+	assert.NotEqual(t, err, nil, "error mismatch")
+
+	_, err = os.Stat(d1.ContentPath())
+	assert.Equal(t, os.IsNotExist(err), true, "content should have been removed")
+}
+
+func TestFindAbandoned(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	c := clock.NewMock()
+	ctx.Clock = c
+
+	c.SetNow(time.Date(2020, time.October, 18, 15, 30, 0, 0, time.UTC))
+	d1, err := New(ctx, KindAdd, "js", "", "")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating d1"))
+	}
+	d2, err := New(ctx, KindAdd, "css", "", "")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating d2"))
+	}
+	d3, err := New(ctx, KindEdit, "js", "n1-uuid", "")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating d3"))
+	}
+	// d4 is being edited
+	_, err = New(ctx, KindAdd, "js", "", "")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating d4"))
+	}
+	c.SetNow(time.Date(2020, time.October, 18, 15, 31, 0, 0, time.UTC))
+	d5, err := New(ctx, KindAdd, "js", "", "")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating d5"))
+	}
+
+	abandon(t, d1)
+	abandon(t, d2)
+	abandon(t, d3)
+	abandon(t, d5)
+
+	testCases := []struct {
+		kind      string
+		bookLabel string
+		noteUUID  string
+		expected  []string
+	}{
+		{
+			kind:      KindAdd,
+			bookLabel: "js",
+			expected:  []string{d5.ID, d1.ID},
+		},
+		{
+			kind:      KindAdd,
+			bookLabel: "css",
+			expected:  []string{d2.ID},
+		},
+		{
+			kind:      KindAdd,
+			bookLabel: "go",
+			expected:  nil,
+		},
+		{
+			kind:      KindEdit,
+			bookLabel: "js",
+			noteUUID:  "n1-uuid",
+			expected:  []string{d3.ID},
+		},
+		{
+			kind:      KindEdit,
+			bookLabel: "js",
+			noteUUID:  "n2-uuid",
+			expected:  nil,
+		},
+	}
+
+	for idx, tc := range testCases {
+		// execute
+		result, err := findAbandoned(ctx, tc.kind, tc.bookLabel, tc.noteUUID)
+		if err != nil {
+			t.Fatal(errors.Wrapf(err, "executing for test case %d", idx))
+		}
+
+		// test
+		assert.DeepEqual(t, getIDs(result), tc.expected, fmt.Sprintf("result mismatch for test case %d", idx))
+	}
+}
+
+func TestInUse(t *testing.T) {
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	self := getLockOwner()
+	other := lockOwner{Hostname: self.Hostname + "-other", PID: self.PID}
+
+	testCases := []struct {
+		owner    *lockOwner
+		age      time.Duration
+		expected bool
+	}{
+		{
+			owner:    &self,
+			expected: true,
+		},
+		{
+			owner:    &lockOwner{Hostname: self.Hostname, PID: 0},
+			expected: false,
+		},
+		{
+			// a lock held on another machine is checked by its age even if it is old
+			owner:    &other,
+			age:      time.Minute,
+			expected: true,
+		},
+		{
+			owner:    &other,
+			age:      staleAfter + time.Minute,
+			expected: false,
+		},
+		{
+			owner:    nil,
+			expected: false,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			d, err := New(ctx, KindAdd, "js", "", "")
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "creating a draft"))
+			}
+			defer d.Discard()
+
+			if tc.owner == nil {
+				d.unlock()
+			} else {
+				writeLock(t, d, *tc.owner)
+			}
+			ts := time.Now().Add(-tc.age)
+			if err := os.Chtimes(d.lockPath(), ts, ts); err != nil && !os.IsNotExist(err) {
+				t.Fatal(errors.Wrap(err, "changing the mtime"))
+			}
+
+			assert.Equal(t, d.InUse(), tc.expected, "result mismatch")
+		})
+	}
+}
+
+func TestLock(t *testing.T) {
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	self := getLockOwner()
+
+	d, err := New(ctx, KindAdd, "js", "", "")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating a draft"))
+	}
+
+	t.Run("held by this process", func(t *testing.T) {
+		assert.Equal(t, d.lock(), nil, "error mismatch")
+	})
+
+	t.Run("held by another process", func(t *testing.T) {
+		writeLock(t, d, lockOwner{Hostname: self.Hostname + "-other", PID: self.PID})
+
+		assert.Equal(t, d.lock(), errLocked, "error mismatch")
+
+		// keeping the draft does not release the lock of another process
+		d.Keep()
+		assert.Equal(t, d.InUse(), true, "in use mismatch")
+	})
+
+	t.Run("abandoned", func(t *testing.T) {
+		abandon(t, d)
+
+		assert.Equal(t, d.lock(), nil, "error mismatch")
+		owner, held := d.readLock(self)
+		assert.Equal(t, owner, self, "owner mismatch")
+		assert.Equal(t, held, true, "held mismatch")
+	})
+
+	t.Run("kept", func(t *testing.T) {
+		d.Keep()
+
+		assert.Equal(t, d.InUse(), false, "in use mismatch")
+	})
+}
@@ -0,0 +1,185 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package hooks runs the executables defined by the user in the hooks directory
+// before and after the commands change the notes
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// PreAdd runs before a note is added
+	PreAdd = "pre-add"
+	// PostAdd runs after a note is added
+	PostAdd = "post-add"
+	// PreEdit runs before notes are edited
+	PreEdit = "pre-edit"
+	// PostEdit runs after notes are edited
+	PostEdit = "post-edit"
+	// PostRemove runs after notes are removed
+	PostRemove = "post-remove"
+	// PreSync runs before a sync with the unsynced notes
+	PreSync = "pre-sync"
+	// PostSync runs after a sync with the summary of the sync
+	PostSync = "post-sync"
+)
+
+// Note is a note as described to the hooks
+type Note struct {
+	UUID      string `json:"uuid,omitempty"`
+	RowID     int    `json:"id,omitempty"`
+	BookLabel string `json:"book"`
+	Content   string `json:"content"`
+}
+
+// NoteFromInfo returns the note to describe to the hooks
+func NoteFromInfo(info database.NoteInfo) Note {
+	return Note{
+		UUID:      info.UUID,
+		RowID:     info.RowID,
+		BookLabel: info.BookLabel,
+		Content:   info.Content,
+	}
+}
+
+// Payload is the JSON written to the standard input of a hook
+type Payload struct {
+	Hook  string `json:"hook"`
+	Notes []Note `json:"notes"`
+	// Summary is the summary of the sync given to the post-sync hook
+	Summary interface{} `json:"summary,omitempty"`
+}
+
+// getDir returns the path to the directory containing the hooks
+func getDir(ctx context.DnoteCtx) string {
+	return filepath.Join(ctx.Paths.Config, consts.DnoteDirName, consts.HookDirName)
+}
+
+// find returns the path to the executable of the hook, or an empty string if the
+// hook is not defined
+func find(ctx context.DnoteCtx, name string) (string, error) {
+	path := filepath.Join(getDir(ctx), name)
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrapf(err, "checking the %s hook", name)
+	}
+
+	if info.IsDir() {
+		return "", nil
+	}
+	if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+		log.Debug("ignoring the hook %s because it is not executable\n", path)
+		return "", nil
+	}
+
+	return path, nil
+}
+
+// run runs the hook with the payload and returns what the hook printed to the
+// standard output. The output is nil if the hook is not defined.
+func run(ctx context.DnoteCtx, name string, p Payload) ([]byte, error) {
+	path, err := find(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	p.Hook = name
+	if p.Notes == nil {
+		p.Notes = []Note{}
+	}
+
+	input, err := json.Marshal(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding the payload")
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "DNOTE_HOOK="+name)
+
+	log.Debug("running the hook %s\n", path)
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, errors.Errorf("the %s hook exited with status %d", name, exitErr.ExitCode())
+		}
+
+		return nil, errors.Wrapf(err, "running the %s hook", name)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Pre runs the hook before a command changes the notes. An error is returned if
+// the hook exits with a non-zero status, and the command should be aborted. If the
+// hook prints a payload to the standard output, the notes are returned with the
+// content in the output.
+func Pre(ctx context.DnoteCtx, name string, notes []Note) ([]Note, error) {
+	out, err := run(ctx, name, Payload{Notes: notes})
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return notes, nil
+	}
+
+	var p Payload
+	if err := json.Unmarshal(out, &p); err != nil {
+		return nil, errors.Wrapf(err, "decoding the output of the %s hook", name)
+	}
+	if len(p.Notes) != len(notes) {
+		return nil, errors.Errorf("the %s hook printed %d notes instead of %d", name, len(p.Notes), len(notes))
+	}
+
+	ret := make([]Note, len(notes))
+	for i, n := range notes {
+		n.Content = p.Notes[i].Content
+		ret[i] = n
+	}
+
+	return ret, nil
+}
+
+// Post runs the hook after a command changed the notes. The change has already
+// been made, so errors are logged rather than returned.
+func Post(ctx context.DnoteCtx, name string, p Payload) {
+	if _, err := run(ctx, name, p); err != nil {
+		log.Errorf("%s\n", err)
+	}
+}
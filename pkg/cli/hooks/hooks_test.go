@@ -0,0 +1,167 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package hooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/pkg/errors"
+)
+
+var paths context.Paths = context.Paths{
+	Home:   "../tmp",
+	Cache:  "../tmp",
+	Config: "../tmp",
+	Data:   "../tmp",
+}
+
+// writeHook writes a shell script as the hook with the given name
+func writeHook(t *testing.T, ctx context.DnoteCtx, name, script string, perm os.FileMode) {
+	dir := getDir(ctx)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(errors.Wrap(err, "creating the hooks directory"))
+	}
+
+	content := fmt.Sprintf("#!/bin/sh\n%s\n", script)
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), perm); err != nil {
+		t.Fatal(errors.Wrap(err, "writing the hook"))
+	}
+}
+
+func TestPre(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks are tested with shell scripts")
+	}
+
+	notes := []Note{
+		{UUID: "n1-uuid", RowID: 1, BookLabel: "js", Content: "hello world"},
+		{BookLabel: "js", Content: "hello again"},
+	}
+
+	testCases := []struct {
+		script   string
+		expected []Note
+		err      bool
+	}{
+		{
+			script:   "cat > /dev/null",
+			expected: notes,
+		},
+		{
+			script: "sed 's/hello/bye/g'",
+			expected: []Note{
+				{UUID: "n1-uuid", RowID: 1, BookLabel: "js", Content: "bye world"},
+				{BookLabel: "js", Content: "bye again"},
+			},
+		},
+		{
+			script: "cat > /dev/null; echo 'invalid note' >&2; exit 1",
+			err:    true,
+		},
+		{
+			script: `cat > /dev/null; echo '{"notes": [{"content": "only one"}]}'`,
+			err:    true,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			// set up
+			ctx := context.InitTestCtx(t, paths, nil)
+			defer context.TeardownTestCtx(t, ctx)
+
+			writeHook(t, ctx, PreEdit, tc.script, 0755)
+
+			// execute
+			result, err := Pre(ctx, PreEdit, notes)
+
+			// test
+			if tc.err {
+				assert.NotEqual(t, err, nil, "error mismatch")
+				return
+			}
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "running the hook"))
+			}
+
+			assert.DeepEqual(t, result, tc.expected, "result mismatch")
+		})
+	}
+}
+
+func TestPre_undefined(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	notes := []Note{{BookLabel: "js", Content: "hello"}}
+
+	// a hook that is not executable is ignored
+	writeHook(t, ctx, PreSync, "exit 1", 0644)
+
+	for _, name := range []string{PreAdd, PreSync} {
+		result, err := Pre(ctx, name, notes)
+		if err != nil {
+			t.Fatal(errors.Wrapf(err, "running %s", name))
+		}
+
+		assert.DeepEqual(t, result, notes, fmt.Sprintf("%s result mismatch", name))
+	}
+}
+
+func TestPost(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks are tested with shell scripts")
+	}
+
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	outPath, err := filepath.Abs(filepath.Join(paths.Data, "out.json"))
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "getting the output path"))
+	}
+	writeHook(t, ctx, PostRemove, fmt.Sprintf("cat > '%s'", outPath), 0755)
+
+	// execute
+	Post(ctx, PostRemove, Payload{Notes: []Note{{UUID: "n1-uuid", RowID: 1, BookLabel: "js", Content: "n1"}}})
+
+	// test
+	b, err := ioutil.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "reading the output"))
+	}
+
+	var p Payload
+	if err := json.Unmarshal(b, &p); err != nil {
+		t.Fatal(errors.Wrap(err, "decoding the output"))
+	}
+
+	assert.Equal(t, p.Hook, PostRemove, "hook mismatch")
+	assert.DeepEqual(t, p.Notes, []Note{{UUID: "n1-uuid", RowID: 1, BookLabel: "js", Content: "n1"}}, "notes mismatch")
+}
@@ -88,6 +88,7 @@ func newCtx(versionTag string) (context.DnoteCtx, error) {
 		Paths:   paths,
 		Version: versionTag,
 		DB:      db,
+		Clock:   clock.New(),
 	}
 
 	return ctx, nil
@@ -157,6 +158,10 @@ func SetupCtx(ctx context.DnoteCtx) (context.DnoteCtx, error) {
 		SessionKeyExpiry: sessionKeyExpiry,
 		APIEndpoint:      cf.APIEndpoint,
 		Editor:           cf.Editor,
+		AutoSync:         cf.AutoSync,
+		SyncInclude:      cf.SyncBooks.Include,
+		SyncExclude:      cf.SyncBooks.Exclude,
+		GitDir:           cf.GitDir,
 		Clock:            clock.New(),
 	}
 
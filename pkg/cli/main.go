@@ -28,15 +28,28 @@ import (
 
 	// commands
 	"github.com/dnote/dnote/pkg/cli/cmd/add"
+	"github.com/dnote/dnote/pkg/cli/cmd/agenda"
+	"github.com/dnote/dnote/pkg/cli/cmd/archive"
+	"github.com/dnote/dnote/pkg/cli/cmd/backup"
+	"github.com/dnote/dnote/pkg/cli/cmd/book"
 	"github.com/dnote/dnote/pkg/cli/cmd/cat"
+	"github.com/dnote/dnote/pkg/cli/cmd/doctor"
+	"github.com/dnote/dnote/pkg/cli/cmd/drafts"
 	"github.com/dnote/dnote/pkg/cli/cmd/edit"
 	"github.com/dnote/dnote/pkg/cli/cmd/find"
+	"github.com/dnote/dnote/pkg/cli/cmd/git"
 	"github.com/dnote/dnote/pkg/cli/cmd/login"
 	"github.com/dnote/dnote/pkg/cli/cmd/logout"
 	"github.com/dnote/dnote/pkg/cli/cmd/ls"
+	"github.com/dnote/dnote/pkg/cli/cmd/mergedb"
+	"github.com/dnote/dnote/pkg/cli/cmd/pin"
 	"github.com/dnote/dnote/pkg/cli/cmd/remove"
 	"github.com/dnote/dnote/pkg/cli/cmd/root"
+	"github.com/dnote/dnote/pkg/cli/cmd/rpc"
+	"github.com/dnote/dnote/pkg/cli/cmd/serve"
+	"github.com/dnote/dnote/pkg/cli/cmd/status"
 	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/cmd/timeline"
 	"github.com/dnote/dnote/pkg/cli/cmd/version"
 	"github.com/dnote/dnote/pkg/cli/cmd/view"
 )
@@ -63,6 +76,21 @@ func main() {
 	root.Register(cat.NewCmd(*ctx))
 	root.Register(view.NewCmd(*ctx))
 	root.Register(find.NewCmd(*ctx))
+	root.Register(status.NewCmd(*ctx))
+	root.Register(book.NewCmd(*ctx))
+	root.Register(mergedb.NewCmd(*ctx))
+	root.Register(git.NewCmd(*ctx))
+	root.Register(backup.NewCmd(*ctx))
+	root.Register(doctor.NewCmd(*ctx))
+	root.Register(drafts.NewCmd(*ctx))
+	root.Register(agenda.NewCmd(*ctx))
+	root.Register(pin.NewCmd(*ctx))
+	root.Register(pin.NewUnpinCmd(*ctx))
+	root.Register(archive.NewCmd(*ctx))
+	root.Register(archive.NewUnarchiveCmd(*ctx))
+	root.Register(timeline.NewCmd(*ctx))
+	root.Register(serve.NewCmd(*ctx))
+	root.Register(rpc.NewCmd(*ctx))
 
 	if err := root.Execute(); err != nil {
 		log.Errorf("%s\n", err.Error())
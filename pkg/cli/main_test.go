@@ -24,6 +24,7 @@ import (
 	"os"
 	"os/exec"
 	"testing"
+	"time"
 
 	"github.com/dnote/dnote/pkg/assert"
 	"github.com/dnote/dnote/pkg/cli/consts"
@@ -135,6 +136,21 @@ func TestAddNote(t *testing.T) {
 		assert.NotEqual(t, note.AddedOn, int64(0), "Note added_on mismatch")
 	})
 
+	t.Run("due date and reminder", func(t *testing.T) {
+		// Set up and execute
+		testutils.RunDnoteCmd(t, opts, binaryName, "add", "js", "-c", "foo", "--due", "2020-06-01", "--remind", "2020-05-30 at 5pm")
+		defer testutils.RemoveDir(t, testDir)
+
+		db := database.OpenTestDB(t, testDir)
+
+		// Test
+		var dueOn, remindOn int64
+		database.MustScan(t, "getting note", db.QueryRow("SELECT due_on, remind_on FROM notes WHERE body = ?", "foo"), &dueOn, &remindOn)
+
+		assert.Equal(t, dueOn, time.Date(2020, time.June, 1, 9, 0, 0, 0, time.Local).UnixNano(), "Note due_on mismatch")
+		assert.Equal(t, remindOn, time.Date(2020, time.May, 30, 17, 0, 0, 0, time.Local).UnixNano(), "Note remind_on mismatch")
+	})
+
 	t.Run("existing book", func(t *testing.T) {
 		// Setup
 		db := database.InitTestDB(t, fmt.Sprintf("%s/%s/%s", testDir, consts.DnoteDirName, consts.DnoteDBFileName), nil)
@@ -499,3 +515,59 @@ func TestRemoveBook(t *testing.T) {
 		})
 	}
 }
+
+func TestPinArchive(t *testing.T) {
+	t.Run("pin and archive", func(t *testing.T) {
+		// Setup
+		db := database.InitTestDB(t, fmt.Sprintf("%s/%s/%s", testDir, consts.DnoteDirName, consts.DnoteDBFileName), nil)
+		testutils.Setup1(t, db)
+
+		// Execute
+		testutils.RunDnoteCmd(t, opts, binaryName, "pin", "js")
+		testutils.RunDnoteCmd(t, opts, binaryName, "archive", "1")
+		defer testutils.RemoveDir(t, testDir)
+
+		// Test
+		var b1, b2 database.Book
+		var n1 database.Note
+		database.MustScan(t, "getting b1",
+			db.QueryRow("SELECT pinned, archived, dirty FROM books WHERE uuid = ?", "js-book-uuid"), &b1.Pinned, &b1.Archived, &b1.Dirty)
+		database.MustScan(t, "getting b2",
+			db.QueryRow("SELECT pinned, archived, dirty FROM books WHERE uuid = ?", "linux-book-uuid"), &b2.Pinned, &b2.Archived, &b2.Dirty)
+		database.MustScan(t, "getting n1",
+			db.QueryRow("SELECT pinned, archived, dirty FROM notes WHERE uuid = ?", "43827b9a-c2b0-4c06-a290-97991c896653"), &n1.Pinned, &n1.Archived, &n1.Dirty)
+
+		assert.Equal(t, b1.Pinned, true, "b1 Pinned mismatch")
+		assert.Equal(t, b1.Archived, false, "b1 Archived mismatch")
+		assert.Equal(t, b1.Dirty, true, "b1 Dirty mismatch")
+
+		assert.Equal(t, b2.Pinned, false, "b2 Pinned mismatch")
+		assert.Equal(t, b2.Archived, false, "b2 Archived mismatch")
+		assert.Equal(t, b2.Dirty, false, "b2 Dirty mismatch")
+
+		assert.Equal(t, n1.Pinned, false, "n1 Pinned mismatch")
+		assert.Equal(t, n1.Archived, true, "n1 Archived mismatch")
+		assert.Equal(t, n1.Dirty, true, "n1 Dirty mismatch")
+	})
+
+	t.Run("unpin and unarchive", func(t *testing.T) {
+		// Setup
+		db := database.InitTestDB(t, fmt.Sprintf("%s/%s/%s", testDir, consts.DnoteDirName, consts.DnoteDBFileName), nil)
+		testutils.Setup1(t, db)
+		database.MustExec(t, "pinning and archiving b1", db, "UPDATE books SET pinned = ?, archived = ? WHERE uuid = ?", true, true, "js-book-uuid")
+
+		// Execute
+		testutils.RunDnoteCmd(t, opts, binaryName, "unpin", "js")
+		testutils.RunDnoteCmd(t, opts, binaryName, "unarchive", "js")
+		defer testutils.RemoveDir(t, testDir)
+
+		// Test
+		var b1 database.Book
+		database.MustScan(t, "getting b1",
+			db.QueryRow("SELECT pinned, archived, dirty FROM books WHERE uuid = ?", "js-book-uuid"), &b1.Pinned, &b1.Archived, &b1.Dirty)
+
+		assert.Equal(t, b1.Pinned, false, "b1 Pinned mismatch")
+		assert.Equal(t, b1.Archived, false, "b1 Archived mismatch")
+		assert.Equal(t, b1.Dirty, true, "b1 Dirty mismatch")
+	})
+}
@@ -0,0 +1,274 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package markdown
+
+import (
+	"strings"
+)
+
+// the SGR parameters of the tokens in the code blocks
+const (
+	colorKeyword = "34"
+	colorString  = "32"
+	colorNumber  = "36"
+	colorComment = "90"
+)
+
+// language describes the lexical syntax of a language for highlighting
+type language struct {
+	keywords     map[string]bool
+	ignoreCase   bool
+	lineComments []string
+	// blockComment is the pair of the delimiters of a block comment, if any
+	blockComment [2]string
+	quotes       string
+}
+
+func words(s string) map[string]bool {
+	ret := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		ret[w] = true
+	}
+
+	return ret
+}
+
+var golang = &language{
+	keywords: words(`break case chan const continue default defer else fallthrough for func go goto if
+		import interface map package range return select struct switch type var
+		true false nil iota`),
+	lineComments: []string{"//"},
+	blockComment: [2]string{"/*", "*/"},
+	quotes:       "\"'`",
+}
+
+var javascript = &language{
+	keywords: words(`async await break case catch class const continue debugger default delete do else
+		export extends finally for from function if import in instanceof interface let new of return
+		static super switch this throw try type typeof var void while yield true false null undefined`),
+	lineComments: []string{"//"},
+	blockComment: [2]string{"/*", "*/"},
+	quotes:       "\"'`",
+}
+
+var python = &language{
+	keywords: words(`and as assert async await break class continue def del elif else except finally for
+		from global if import in is lambda nonlocal not or pass raise return try while with yield
+		True False None self`),
+	lineComments: []string{"#"},
+	quotes:       "\"'",
+}
+
+var shell = &language{
+	keywords: words(`if then else elif fi case esac for while until do done in function return exit
+		export local readonly echo cd source`),
+	lineComments: []string{"#"},
+	quotes:       "\"'",
+}
+
+var clike = &language{
+	keywords: words(`auto break case catch char class const continue default delete do double else enum
+		extends final float for goto if implements import include int long namespace new package private
+		protected public return short signed sizeof static struct switch template this throw try typedef
+		union unsigned void volatile while true false null nullptr`),
+	lineComments: []string{"//"},
+	blockComment: [2]string{"/*", "*/"},
+	quotes:       "\"'",
+}
+
+var rust = &language{
+	keywords: words(`as async await break const continue crate else enum extern fn for if impl in let loop
+		match mod move mut pub ref return self Self static struct super trait type unsafe use where while
+		true false`),
+	lineComments: []string{"//"},
+	blockComment: [2]string{"/*", "*/"},
+	quotes:       "\"",
+}
+
+var ruby = &language{
+	keywords: words(`alias and begin break case class def defined? do else elsif end ensure false for if in
+		module next nil not or redo rescue retry return self super then true undef unless until when while
+		yield require`),
+	lineComments: []string{"#"},
+	quotes:       "\"'",
+}
+
+var sql = &language{
+	keywords: words(`select from where and or not insert into values update set delete create table drop
+		alter index on join left right inner outer group by order having limit offset as distinct null
+		is in like primary key references default begin commit rollback union all exists case when then
+		else end`),
+	ignoreCase:   true,
+	lineComments: []string{"--"},
+	blockComment: [2]string{"/*", "*/"},
+	quotes:       "'\"",
+}
+
+var data = &language{
+	keywords:     words(`true false null yes no`),
+	lineComments: []string{"#"},
+	quotes:       "\"'",
+}
+
+var languages = map[string]*language{
+	"go":         golang,
+	"golang":     golang,
+	"js":         javascript,
+	"javascript": javascript,
+	"jsx":        javascript,
+	"ts":         javascript,
+	"typescript": javascript,
+	"tsx":        javascript,
+	"py":         python,
+	"python":     python,
+	"sh":         shell,
+	"bash":       shell,
+	"shell":      shell,
+	"zsh":        shell,
+	"c":          clike,
+	"h":          clike,
+	"cpp":        clike,
+	"c++":        clike,
+	"cs":         clike,
+	"java":       clike,
+	"kotlin":     clike,
+	"swift":      clike,
+	"rs":         rust,
+	"rust":       rust,
+	"rb":         ruby,
+	"ruby":       ruby,
+	"sql":        sql,
+	"json":       data,
+	"yaml":       data,
+	"yml":        data,
+	"toml":       data,
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdent(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9'
+}
+
+// highlight colors the keywords, strings, numbers and comments in the lines of
+// the code in the language. The lines are returned as they are if the language
+// is not known.
+func highlight(lines []string, lang string) []string {
+	l, ok := languages[lang]
+	if !ok {
+		return lines
+	}
+
+	var ret []string
+
+	inComment := false
+	for _, line := range lines {
+		var b strings.Builder
+
+		i := 0
+		for i < len(line) {
+			if inComment {
+				end := strings.Index(line[i:], l.blockComment[1])
+				if end == -1 {
+					b.WriteString(ansi(colorComment, line[i:]))
+					i = len(line)
+				} else {
+					end += i + len(l.blockComment[1])
+					b.WriteString(ansi(colorComment, line[i:end]))
+					i = end
+					inComment = false
+				}
+				continue
+			}
+
+			rest := line[i:]
+
+			if l.blockComment[0] != "" && strings.HasPrefix(rest, l.blockComment[0]) {
+				inComment = true
+				b.WriteString(ansi(colorComment, l.blockComment[0]))
+				i += len(l.blockComment[0])
+				continue
+			}
+
+			isComment := false
+			for _, prefix := range l.lineComments {
+				if strings.HasPrefix(rest, prefix) {
+					isComment = true
+				}
+			}
+			if isComment {
+				b.WriteString(ansi(colorComment, rest))
+				break
+			}
+
+			c := line[i]
+			switch {
+			case strings.IndexByte(l.quotes, c) != -1:
+				j := i + 1
+				for j < len(line) && line[j] != c {
+					if line[j] == '\\' {
+						j++
+					}
+					j++
+				}
+				if j >= len(line) {
+					j = len(line) - 1
+				}
+
+				b.WriteString(ansi(colorString, line[i:j+1]))
+				i = j + 1
+			case c >= '0' && c <= '9' && (i == 0 || !isIdent(line[i-1])):
+				j := i
+				for j < len(line) && (isIdent(line[j]) || line[j] == '.') {
+					j++
+				}
+
+				b.WriteString(ansi(colorNumber, line[i:j]))
+				i = j
+			case isIdentStart(c):
+				j := i
+				for j < len(line) && isIdent(line[j]) {
+					j++
+				}
+
+				word := line[i:j]
+				key := word
+				if l.ignoreCase {
+					key = strings.ToLower(word)
+				}
+
+				if l.keywords[key] {
+					b.WriteString(ansi(colorKeyword, word))
+				} else {
+					b.WriteString(word)
+				}
+				i = j
+			default:
+				b.WriteByte(c)
+				i++
+			}
+		}
+
+		ret = append(ret, b.String())
+	}
+
+	return ret
+}
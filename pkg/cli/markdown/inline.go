@@ -0,0 +1,556 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package markdown
+
+import (
+	"strings"
+	"unicode"
+)
+
+// style is a set of the text styles
+type style int
+
+const (
+	styleBold style = 1 << iota
+	styleItalic
+	styleStrike
+	styleUnderline
+	styleCode
+	styleLink
+	styleHeading
+	styleDim
+	styleMarker
+)
+
+// segment is a run of inline text in the same style
+type segment struct {
+	text  string
+	style style
+	// url is the destination of a link
+	url string
+}
+
+// ansi wraps the string in the SGR escape sequence with the given parameters
+func ansi(params, s string) string {
+	return "\x1b[" + params + "m" + s + "\x1b[0m"
+}
+
+// sgr returns the SGR parameters for the style
+func sgr(st style) string {
+	var params []string
+
+	if st&(styleBold|styleHeading) != 0 {
+		params = append(params, "1")
+	}
+	if st&styleDim != 0 {
+		params = append(params, "2")
+	}
+	if st&styleItalic != 0 {
+		params = append(params, "3")
+	}
+	if st&(styleUnderline|styleLink) != 0 {
+		params = append(params, "4")
+	}
+	if st&styleStrike != 0 {
+		params = append(params, "9")
+	}
+
+	switch {
+	case st&styleHeading != 0:
+		params = append(params, "35")
+	case st&styleLink != 0:
+		params = append(params, "36")
+	case st&styleCode != 0:
+		params = append(params, "33")
+	case st&styleMarker != 0:
+		params = append(params, "34")
+	}
+
+	return strings.Join(params, ";")
+}
+
+// style applies the style to the string if the colors are enabled
+func (r renderer) style(s string, st style) string {
+	if !r.opts.Color || s == "" {
+		return s
+	}
+
+	params := sgr(st)
+	if params == "" {
+		return s
+	}
+
+	return ansi(params, s)
+}
+
+// hyperlink wraps the string in an OSC-8 hyperlink to the url
+func hyperlink(url, s string) string {
+	return "\x1b]8;;" + url + "\x1b\\" + s + "\x1b]8;;\x1b\\"
+}
+
+// renderSegments renders the segments into a string
+func (r renderer) renderSegments(segs []segment) string {
+	var b strings.Builder
+
+	for _, seg := range segs {
+		s := r.style(seg.text, seg.style)
+		if seg.url != "" && r.opts.Hyperlinks {
+			s = hyperlink(seg.url, s)
+		}
+
+		b.WriteString(s)
+	}
+
+	return b.String()
+}
+
+// parseInline parses the inline content and, unless the links are rendered as
+// hyperlinks, prints the link destinations next to the link texts.
+func (r renderer) parseInline(s string) []segment {
+	segs := parseInline(s)
+	if r.opts.Hyperlinks {
+		return segs
+	}
+
+	var ret []segment
+	for i := 0; i < len(segs); {
+		url := segs[i].url
+
+		j := i
+		var text strings.Builder
+		for j < len(segs) && segs[j].url == url {
+			text.WriteString(segs[j].text)
+			ret = append(ret, segs[j])
+			j++
+		}
+
+		if url != "" && text.String() != url && "mailto:"+text.String() != url {
+			ret = append(ret, segment{text: " (" + url + ")", style: styleDim})
+		}
+
+		i = j
+	}
+
+	return ret
+}
+
+// appendSegment appends the segment, merging it into the last one if they share the style
+func appendSegment(segs []segment, seg segment) []segment {
+	if seg.text == "" {
+		return segs
+	}
+
+	if n := len(segs); n > 0 && segs[n-1].style == seg.style && segs[n-1].url == seg.url {
+		segs[n-1].text += seg.text
+		return segs
+	}
+
+	return append(segs, seg)
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) != -1
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// runLen returns the length of the run of the character starting at i
+func runLen(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+
+	return n
+}
+
+// parseInline parses the inline content such as emphasis, code spans and links
+func parseInline(s string) []segment {
+	return parseSpan(s, 0, "")
+}
+
+func parseSpan(s string, st style, url string) []segment {
+	var ret []segment
+	var text strings.Builder
+
+	flush := func() {
+		ret = appendSegment(ret, segment{text: text.String(), style: st, url: url})
+		text.Reset()
+	}
+	add := func(segs []segment) {
+		flush()
+		for _, seg := range segs {
+			ret = appendSegment(ret, seg)
+		}
+	}
+
+	i := 0
+	for i < len(s) {
+		c := s[i]
+
+		switch {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+		case c == '`':
+			if content, next, ok := parseCodeSpan(s, i); ok {
+				add([]segment{{text: content, style: st | styleCode, url: url}})
+				i = next
+				continue
+			}
+
+			n := runLen(s, i, '`')
+			text.WriteString(s[i : i+n])
+			i += n
+			continue
+		case c == '!' && i+1 < len(s) && s[i+1] == '[' && url == "":
+			if label, dest, next, ok := parseLink(s, i+1); ok {
+				if label == "" {
+					label = dest
+				}
+
+				add(parseSpan(label, st|styleLink, dest))
+				i = next
+				continue
+			}
+		case c == '[' && url == "":
+			if label, dest, next, ok := parseLink(s, i); ok {
+				add(parseSpan(label, st|styleLink, dest))
+				i = next
+				continue
+			}
+		case c == '<' && url == "":
+			if label, dest, next, ok := parseAutolink(s, i); ok {
+				add([]segment{{text: label, style: st | styleLink, url: dest}})
+				i = next
+				continue
+			}
+		case c == 'h' && url == "" && (i == 0 || isSpace(s[i-1]) || s[i-1] == '('):
+			if dest, next, ok := parseBareURL(s, i); ok {
+				add([]segment{{text: dest, style: st | styleLink, url: dest}})
+				i = next
+				continue
+			}
+		case c == '*' || c == '_' || c == '~':
+			if inner, emph, next, ok := parseEmphasis(s, i); ok {
+				add(parseSpan(inner, st|emph, url))
+				i = next
+				continue
+			}
+
+			n := runLen(s, i, c)
+			text.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+
+		text.WriteByte(c)
+		i++
+	}
+
+	flush()
+
+	return ret
+}
+
+// parseCodeSpan parses the code span starting with the backticks at i
+func parseCodeSpan(s string, i int) (string, int, bool) {
+	n := runLen(s, i, '`')
+
+	for j := i + n; j < len(s); {
+		if s[j] != '`' {
+			j++
+			continue
+		}
+
+		m := runLen(s, j, '`')
+		if m != n {
+			j += m
+			continue
+		}
+
+		content := strings.Replace(s[i+n:j], "\n", " ", -1)
+		if len(content) >= 2 && content[0] == ' ' && content[len(content)-1] == ' ' && strings.TrimSpace(content) != "" {
+			content = content[1 : len(content)-1]
+		}
+
+		return content, j + n, true
+	}
+
+	return "", 0, false
+}
+
+// parseLink parses the inline link such as "[text](url)" starting at the bracket at i
+func parseLink(s string, i int) (string, string, int, bool) {
+	depth := 0
+	k := -1
+	for j := i; j < len(s) && k == -1; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				k = j
+			}
+		}
+	}
+	if k == -1 || k+1 >= len(s) || s[k+1] != '(' {
+		return "", "", 0, false
+	}
+
+	depth = 0
+	for j := k + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth > 0 {
+				continue
+			}
+
+			dest := strings.TrimSpace(s[k+2 : j])
+			if strings.HasPrefix(dest, "<") {
+				if end := strings.IndexByte(dest, '>'); end != -1 {
+					dest = dest[1:end]
+				}
+			} else if fields := strings.Fields(dest); len(fields) > 0 {
+				// the title of the link is not shown
+				dest = fields[0]
+			}
+
+			return s[i+1 : k], dest, j + 1, true
+		}
+	}
+
+	return "", "", 0, false
+}
+
+// parseAutolink parses the autolink such as "<https://www.getdnote.com>" starting at i
+func parseAutolink(s string, i int) (string, string, int, bool) {
+	end := strings.IndexByte(s[i:], '>')
+	if end == -1 {
+		return "", "", 0, false
+	}
+
+	inner := s[i+1 : i+end]
+	if inner == "" || strings.ContainsAny(inner, " \t\n<") {
+		return "", "", 0, false
+	}
+
+	if strings.Contains(inner, "://") || strings.HasPrefix(inner, "mailto:") {
+		return inner, inner, i + end + 1, true
+	}
+	if at := strings.IndexByte(inner, '@'); at > 0 && strings.Contains(inner[at:], ".") {
+		return inner, "mailto:" + inner, i + end + 1, true
+	}
+
+	return "", "", 0, false
+}
+
+// parseBareURL parses the URL starting at i that is not enclosed in a link
+func parseBareURL(s string, i int) (string, int, bool) {
+	rest := s[i:]
+	if !strings.HasPrefix(rest, "http://") && !strings.HasPrefix(rest, "https://") {
+		return "", 0, false
+	}
+
+	end := strings.IndexAny(rest, " \t\n<")
+	if end == -1 {
+		end = len(rest)
+	}
+
+	// the trailing punctuations most likely belong to the sentence
+	dest := strings.TrimRight(rest[:end], ".,:;!?\"')")
+	if strings.HasSuffix(dest, "://") {
+		return "", 0, false
+	}
+
+	return dest, i + len(dest), true
+}
+
+// parseEmphasis parses the emphasis, strong emphasis or strikethrough starting at
+// the delimiter run at i.
+func parseEmphasis(s string, i int) (string, style, int, bool) {
+	c := s[i]
+	n := runLen(s, i, c)
+
+	var emph style
+	switch {
+	case c == '~' && n == 2:
+		emph = styleStrike
+	case c == '~':
+		return "", 0, 0, false
+	case n == 1:
+		emph = styleItalic
+	case n == 2:
+		emph = styleBold
+	case n == 3:
+		emph = styleBold | styleItalic
+	default:
+		return "", 0, 0, false
+	}
+
+	// the opener must be followed by a non-space and, for underscores, must not be intraword
+	if i+n >= len(s) || isSpace(s[i+n]) {
+		return "", 0, 0, false
+	}
+	if c == '_' && i > 0 && isAlnum(s[i-1]) {
+		return "", 0, 0, false
+	}
+
+	for j := i + n; j < len(s); {
+		switch s[j] {
+		case '\\':
+			j += 2
+			continue
+		case '`':
+			if _, next, ok := parseCodeSpan(s, j); ok {
+				j = next
+				continue
+			}
+		case c:
+			m := runLen(s, j, c)
+			closes := m == n && !isSpace(s[j-1])
+			if c == '_' && j+m < len(s) && isAlnum(s[j+m]) {
+				closes = false
+			}
+
+			if closes {
+				return s[i+n : j], emph, j + m, true
+			}
+
+			j += m
+			continue
+		}
+
+		j++
+	}
+
+	return "", 0, 0, false
+}
+
+// runeWidth returns the number of the columns the rune occupies in a terminal
+func runeWidth(r rune) int {
+	switch {
+	case unicode.Is(unicode.Mn, r) || r == '\u200b':
+		return 0
+	case r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f,
+		r >= 0x1f900 && r <= 0x1f9ff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// textWidth returns the number of the columns the string occupies in a terminal
+func textWidth(s string) int {
+	ret := 0
+	for _, r := range s {
+		ret += runeWidth(r)
+	}
+
+	return ret
+}
+
+// segmentsWidth returns the number of the columns the segments occupy in a terminal
+func segmentsWidth(segs []segment) int {
+	ret := 0
+	for _, seg := range segs {
+		ret += textWidth(seg.text)
+	}
+
+	return ret
+}
+
+// wrap breaks the segments into lines that fit in the width. The newlines in the
+// segments are kept as line breaks. A zero width only breaks at the newlines.
+func wrap(segs []segment, width int) [][]segment {
+	var ret [][]segment
+
+	var line, word []segment
+	var space segment
+	lineWidth, wordWidth := 0, 0
+
+	flushWord := func() {
+		if len(word) == 0 {
+			return
+		}
+
+		if lineWidth > 0 && width > 0 && lineWidth+1+wordWidth > width {
+			ret = append(ret, line)
+			line, lineWidth = nil, 0
+		}
+		if lineWidth > 0 {
+			line = appendSegment(line, segment{text: " ", style: space.style, url: space.url})
+			lineWidth++
+		}
+
+		for _, seg := range word {
+			line = appendSegment(line, seg)
+		}
+		lineWidth += wordWidth
+
+		word, wordWidth = nil, 0
+	}
+
+	for _, seg := range segs {
+		for _, r := range seg.text {
+			switch r {
+			case '\n':
+				flushWord()
+				ret = append(ret, line)
+				line, lineWidth = nil, 0
+			case ' ', '\t':
+				flushWord()
+				space = seg
+			default:
+				word = appendSegment(word, segment{text: string(r), style: seg.style, url: seg.url})
+				wordWidth += runeWidth(r)
+			}
+		}
+	}
+
+	flushWord()
+	if len(line) > 0 {
+		ret = append(ret, line)
+	}
+
+	return ret
+}
@@ -0,0 +1,724 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package markdown renders Markdown documents for the terminal
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Options is the options for rendering a document
+type Options struct {
+	// Width is the width of the output that paragraphs are wrapped to. A zero width
+	// disables wrapping.
+	Width int
+	// Color enables the ANSI styles and colors
+	Color bool
+	// Hyperlinks turns links into OSC-8 hyperlinks. Otherwise the link destinations
+	// are printed next to the link texts.
+	Hyperlinks bool
+}
+
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading
+	blockCode
+	blockQuote
+	blockList
+	blockTable
+	blockRule
+)
+
+type align int
+
+const (
+	alignNone align = iota
+	alignLeft
+	alignCenter
+	alignRight
+)
+
+// block is a block-level element of a document
+type block struct {
+	kind blockKind
+	// text is the inline content of a paragraph or a heading
+	text string
+	// level is the level of a heading
+	level int
+	// lang and lines are the info string and the content of a code block
+	lang  string
+	lines []string
+	// children is the content of a block quote
+	children []block
+	// ordered, start, loose and items describe a list
+	ordered bool
+	start   int
+	loose   bool
+	items   [][]block
+	// header, aligns and rows describe a table
+	header []string
+	aligns []align
+	rows   [][]string
+}
+
+var headingRe = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+var fenceRe = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+var listRe = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])(?:( +)(.*))?$`)
+var quoteRe = regexp.MustCompile(`^ {0,3}> ?`)
+var setextRe = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+var delimCellRe = regexp.MustCompile(`^:?-+:?$`)
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// indentOf returns the number of the leading spaces
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// isRule reports if the line is a thematic break such as "---" or "* * *"
+func isRule(line string) bool {
+	if indentOf(line) > 3 {
+		return false
+	}
+
+	s := strings.Replace(strings.TrimSpace(line), " ", "", -1)
+	if len(s) < 3 {
+		return false
+	}
+
+	c := s[0]
+	if c != '-' && c != '*' && c != '_' {
+		return false
+	}
+
+	return strings.Count(s, string(c)) == len(s)
+}
+
+// splitRow splits a table row into the cells
+func splitRow(line string) []string {
+	s := strings.TrimSpace(line)
+	s = strings.TrimPrefix(s, "|")
+	if strings.HasSuffix(s, "|") && !strings.HasSuffix(s, "\\|") {
+		s = s[:len(s)-1]
+	}
+
+	var ret []string
+	var cell strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && s[i+1] == '|' {
+			cell.WriteByte('|')
+			i++
+		} else if s[i] == '|' {
+			ret = append(ret, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		} else {
+			cell.WriteByte(s[i])
+		}
+	}
+	ret = append(ret, strings.TrimSpace(cell.String()))
+
+	return ret
+}
+
+// parseDelimRow parses the delimiter row of a table such as "| :-- | --: |"
+func parseDelimRow(line string) ([]align, bool) {
+	if !strings.Contains(line, "-") {
+		return nil, false
+	}
+
+	var ret []align
+	for _, cell := range splitRow(line) {
+		if !delimCellRe.MatchString(cell) {
+			return nil, false
+		}
+
+		left := strings.HasPrefix(cell, ":")
+		right := strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			ret = append(ret, alignCenter)
+		case right:
+			ret = append(ret, alignRight)
+		case left:
+			ret = append(ret, alignLeft)
+		default:
+			ret = append(ret, alignNone)
+		}
+	}
+
+	return ret, true
+}
+
+// isTableStart reports if a table begins at the given line
+func isTableStart(lines []string, i int) bool {
+	if i+1 >= len(lines) || !strings.Contains(lines[i], "|") {
+		return false
+	}
+
+	aligns, ok := parseDelimRow(lines[i+1])
+
+	return ok && len(aligns) == len(splitRow(lines[i]))
+}
+
+// startsBlock reports if the line at i begins a block that interrupts a paragraph
+func startsBlock(lines []string, i int) bool {
+	line := lines[i]
+
+	return fenceRe.MatchString(line) || headingRe.MatchString(line) || quoteRe.MatchString(line) ||
+		isRule(line) || listRe.MatchString(line) || isTableStart(lines, i)
+}
+
+// parseBlocks parses the lines into blocks
+func parseBlocks(lines []string) []block {
+	var ret []block
+
+	i := 0
+	for i < len(lines) {
+		line := lines[i]
+
+		if isBlank(line) {
+			i++
+			continue
+		}
+
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			b, next := parseFence(lines, i, m)
+			ret = append(ret, b)
+			i = next
+			continue
+		}
+
+		if m := headingRe.FindStringSubmatch(line); m != nil {
+			ret = append(ret, block{kind: blockHeading, level: len(m[1]), text: m[2]})
+			i++
+			continue
+		}
+
+		if isRule(line) {
+			ret = append(ret, block{kind: blockRule})
+			i++
+			continue
+		}
+
+		if quoteRe.MatchString(line) {
+			b, next := parseQuote(lines, i)
+			ret = append(ret, b)
+			i = next
+			continue
+		}
+
+		if listRe.MatchString(line) {
+			b, next := parseList(lines, i)
+			ret = append(ret, b)
+			i = next
+			continue
+		}
+
+		if isTableStart(lines, i) {
+			b, next := parseTable(lines, i)
+			ret = append(ret, b)
+			i = next
+			continue
+		}
+
+		if indentOf(line) >= 4 {
+			b, next := parseIndentedCode(lines, i)
+			ret = append(ret, b)
+			i = next
+			continue
+		}
+
+		b, next := parseParagraph(lines, i)
+		ret = append(ret, b)
+		i = next
+	}
+
+	return ret
+}
+
+func parseFence(lines []string, i int, m []string) (block, int) {
+	indent := len(m[1])
+	fence := m[2]
+
+	b := block{kind: blockCode, lang: strings.ToLower(m[3])}
+
+	j := i + 1
+	for ; j < len(lines); j++ {
+		trimmed := strings.TrimSpace(lines[j])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			return b, j + 1
+		}
+
+		line := lines[j]
+		if n := indentOf(line); n < indent {
+			line = line[n:]
+		} else {
+			line = line[indent:]
+		}
+		b.lines = append(b.lines, line)
+	}
+
+	// an unclosed fence runs until the end of the document
+	return b, j
+}
+
+func parseIndentedCode(lines []string, i int) (block, int) {
+	b := block{kind: blockCode}
+
+	j := i
+	for ; j < len(lines); j++ {
+		if isBlank(lines[j]) {
+			b.lines = append(b.lines, "")
+		} else if indentOf(lines[j]) >= 4 {
+			b.lines = append(b.lines, lines[j][4:])
+		} else {
+			break
+		}
+	}
+
+	for len(b.lines) > 0 && b.lines[len(b.lines)-1] == "" {
+		b.lines = b.lines[:len(b.lines)-1]
+	}
+
+	return b, j
+}
+
+func parseQuote(lines []string, i int) (block, int) {
+	var inner []string
+
+	j := i
+	for ; j < len(lines); j++ {
+		line := lines[j]
+
+		if loc := quoteRe.FindStringIndex(line); loc != nil {
+			inner = append(inner, line[loc[1]:])
+		} else if !isBlank(line) && len(inner) > 0 && !isBlank(inner[len(inner)-1]) && !startsBlock(lines, j) {
+			// a lazy continuation of the paragraph in the quote
+			inner = append(inner, line)
+		} else {
+			break
+		}
+	}
+
+	return block{kind: blockQuote, children: parseBlocks(inner)}, j
+}
+
+// isOrderedMarker reports if the list marker is a number such as "1." or "2)"
+func isOrderedMarker(marker string) bool {
+	return marker[0] >= '0' && marker[0] <= '9'
+}
+
+func parseList(lines []string, i int) (block, int) {
+	first := listRe.FindStringSubmatch(lines[i])
+	ordered := isOrderedMarker(first[2])
+
+	b := block{kind: blockList, ordered: ordered, start: 1}
+	if ordered {
+		b.start, _ = strconv.Atoi(first[2][:len(first[2])-1])
+	}
+
+	j := i
+	for j < len(lines) {
+		m := listRe.FindStringSubmatch(lines[j])
+		if m == nil {
+			break
+		}
+		if isOrderedMarker(m[2]) != ordered {
+			break
+		}
+		if isRule(lines[j]) {
+			break
+		}
+
+		// the column where the content of the item starts
+		spaces := len(m[3])
+		if spaces > 4 || spaces == 0 {
+			spaces = 1
+		}
+		contentCol := len(m[1]) + len(m[2]) + spaces
+
+		content := []string{m[4]}
+		if spaces == 1 && len(m[3]) > 4 {
+			// an indented code block in the item
+			content[0] = strings.Repeat(" ", len(m[3])-1) + m[4]
+		}
+
+		j++
+		for j < len(lines) {
+			line := lines[j]
+
+			if isBlank(line) {
+				// the item continues if the next non-blank line is indented into it
+				k := j + 1
+				for k < len(lines) && isBlank(lines[k]) {
+					k++
+				}
+				if k < len(lines) && indentOf(lines[k]) >= contentCol {
+					for ; j < k; j++ {
+						content = append(content, "")
+					}
+					b.loose = true
+					continue
+				}
+
+				if k < len(lines) && indentOf(lines[k]) < contentCol {
+					if m := listRe.FindStringSubmatch(lines[k]); m != nil && isOrderedMarker(m[2]) == ordered {
+						b.loose = true
+					}
+				}
+				break
+			}
+
+			if indentOf(line) >= contentCol {
+				content = append(content, line[contentCol:])
+			} else if !startsBlock(lines, j) && !isBlank(content[len(content)-1]) {
+				// a lazy continuation of the paragraph in the item
+				content = append(content, strings.TrimSpace(line))
+			} else {
+				break
+			}
+
+			j++
+		}
+
+		b.items = append(b.items, parseBlocks(content))
+
+		// skip the blank lines between the items
+		k := j
+		for k < len(lines) && isBlank(lines[k]) {
+			k++
+		}
+		if k < len(lines) && k > j && listRe.MatchString(lines[k]) {
+			j = k
+		}
+	}
+
+	return b, j
+}
+
+func parseTable(lines []string, i int) (block, int) {
+	aligns, _ := parseDelimRow(lines[i+1])
+	b := block{kind: blockTable, header: splitRow(lines[i]), aligns: aligns}
+
+	j := i + 2
+	for ; j < len(lines); j++ {
+		if isBlank(lines[j]) || !strings.Contains(lines[j], "|") {
+			break
+		}
+
+		row := splitRow(lines[j])
+		for len(row) < len(b.header) {
+			row = append(row, "")
+		}
+		b.rows = append(b.rows, row[:len(b.header)])
+	}
+
+	return b, j
+}
+
+func parseParagraph(lines []string, i int) (block, int) {
+	var parts []string
+
+	j := i
+	for ; j < len(lines); j++ {
+		line := lines[j]
+		if isBlank(line) {
+			break
+		}
+
+		if len(parts) > 0 {
+			if m := setextRe.FindStringSubmatch(line); m != nil {
+				level := 2
+				if m[1][0] == '=' {
+					level = 1
+				}
+
+				return block{kind: blockHeading, level: level, text: joinParagraph(parts)}, j + 1
+			}
+
+			if startsBlock(lines, j) {
+				break
+			}
+		}
+
+		parts = append(parts, line)
+	}
+
+	return block{kind: blockParagraph, text: joinParagraph(parts)}, j
+}
+
+// joinParagraph joins the lines of a paragraph. A line ending with two spaces or
+// a backslash is followed by a hard line break which is kept as a newline.
+func joinParagraph(parts []string) string {
+	var b strings.Builder
+
+	for idx, part := range parts {
+		hardBreak := strings.HasSuffix(part, "  ") || strings.HasSuffix(part, "\\")
+		trimmed := strings.TrimSpace(part)
+
+		if idx == len(parts)-1 {
+			b.WriteString(trimmed)
+		} else if hardBreak {
+			b.WriteString(strings.TrimSuffix(trimmed, "\\"))
+			b.WriteString("\n")
+		} else {
+			b.WriteString(trimmed)
+			b.WriteString(" ")
+		}
+	}
+
+	return b.String()
+}
+
+// renderer renders blocks into lines
+type renderer struct {
+	opts Options
+}
+
+// Render renders the Markdown document for the terminal
+func Render(src string, opts Options) string {
+	src = strings.Replace(src, "\r\n", "\n", -1)
+	src = strings.Replace(src, "\t", "    ", -1)
+
+	blocks := parseBlocks(strings.Split(src, "\n"))
+
+	r := renderer{opts: opts}
+	lines := r.renderBlocks(blocks, opts.Width, 0, true)
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// renderBlocks renders the blocks within the width. The blocks are separated
+// by blank lines unless they are in a tight list.
+func (r renderer) renderBlocks(blocks []block, width, depth int, separate bool) []string {
+	var ret []string
+
+	for idx, b := range blocks {
+		if idx > 0 && separate {
+			ret = append(ret, "")
+		}
+
+		ret = append(ret, r.renderBlock(b, width, depth)...)
+	}
+
+	return ret
+}
+
+func (r renderer) renderBlock(b block, width, depth int) []string {
+	switch b.kind {
+	case blockHeading:
+		return r.renderHeading(b, width)
+	case blockCode:
+		return r.renderCode(b)
+	case blockQuote:
+		return r.renderQuote(b, width, depth)
+	case blockList:
+		return r.renderList(b, width, depth)
+	case blockTable:
+		return r.renderTable(b)
+	case blockRule:
+		n := width
+		if n <= 0 || n > 80 {
+			n = 80
+		}
+
+		return []string{r.style(strings.Repeat("─", n), styleDim)}
+	default:
+		return r.renderInline(r.parseInline(b.text), width)
+	}
+}
+
+// renderInline wraps the inline content within the width and renders each line
+func (r renderer) renderInline(segs []segment, width int) []string {
+	var ret []string
+
+	for _, line := range wrap(segs, width) {
+		ret = append(ret, r.renderSegments(line))
+	}
+
+	return ret
+}
+
+func (r renderer) renderHeading(b block, width int) []string {
+	st := styleHeading
+	if b.level == 1 {
+		st |= styleUnderline
+	}
+
+	segs := []segment{{text: strings.Repeat("#", b.level) + " ", style: st}}
+	for _, seg := range r.parseInline(b.text) {
+		seg.style |= st
+		segs = append(segs, seg)
+	}
+
+	return r.renderInline(segs, width)
+}
+
+func (r renderer) renderCode(b block) []string {
+	var lines []string
+	if r.opts.Color {
+		lines = highlight(b.lines, b.lang)
+	} else {
+		lines = b.lines
+	}
+
+	ret := []string{}
+	for _, line := range lines {
+		ret = append(ret, strings.TrimRight("    "+line, " "))
+	}
+
+	return ret
+}
+
+func (r renderer) renderQuote(b block, width, depth int) []string {
+	bar := r.style("│", styleDim)
+
+	var ret []string
+	for _, line := range r.renderBlocks(b.children, width-2, depth, true) {
+		if line == "" {
+			ret = append(ret, bar)
+		} else {
+			ret = append(ret, bar+" "+line)
+		}
+	}
+
+	return ret
+}
+
+func (r renderer) renderList(b block, width, depth int) []string {
+	var ret []string
+
+	bullet := "•"
+	if depth%2 == 1 {
+		bullet = "◦"
+	}
+
+	// the markers of an ordered list are padded to the same width
+	markerWidth := utf8.RuneCountInString(bullet)
+	if b.ordered {
+		markerWidth = len(fmt.Sprintf("%d.", b.start+len(b.items)-1))
+	}
+
+	for idx, item := range b.items {
+		if idx > 0 && b.loose {
+			ret = append(ret, "")
+		}
+
+		marker := bullet
+		if b.ordered {
+			marker = fmt.Sprintf("%*s", markerWidth, fmt.Sprintf("%d.", b.start+idx))
+		}
+		marker = r.style(marker, styleMarker)
+
+		lines := r.renderBlocks(item, width-markerWidth-1, depth+1, b.loose)
+		if len(lines) == 0 {
+			ret = append(ret, marker)
+			continue
+		}
+
+		pad := strings.Repeat(" ", markerWidth+1)
+		for lineIdx, line := range lines {
+			if lineIdx == 0 {
+				ret = append(ret, marker+" "+line)
+			} else if line == "" {
+				ret = append(ret, "")
+			} else {
+				ret = append(ret, pad+line)
+			}
+		}
+	}
+
+	return ret
+}
+
+// padCell pads the rendered cell to the width according to the alignment
+func padCell(s string, visible, width int, a align) string {
+	gap := width - visible
+	if gap <= 0 {
+		return s
+	}
+
+	switch a {
+	case alignRight:
+		return strings.Repeat(" ", gap) + s
+	case alignCenter:
+		left := gap / 2
+		return strings.Repeat(" ", left) + s + strings.Repeat(" ", gap-left)
+	default:
+		return s + strings.Repeat(" ", gap)
+	}
+}
+
+func (r renderer) renderTable(b block) []string {
+	rows := append([][]string{b.header}, b.rows...)
+
+	// render the cells first to find the width of each column
+	cells := make([][]string, len(rows))
+	visible := make([][]int, len(rows))
+	widths := make([]int, len(b.header))
+	for rowIdx, row := range rows {
+		for colIdx, cell := range row {
+			segs := r.parseInline(cell)
+			if rowIdx == 0 {
+				for i := range segs {
+					segs[i].style |= styleBold
+				}
+			}
+
+			w := segmentsWidth(segs)
+			cells[rowIdx] = append(cells[rowIdx], r.renderSegments(segs))
+			visible[rowIdx] = append(visible[rowIdx], w)
+			if w > widths[colIdx] {
+				widths[colIdx] = w
+			}
+		}
+	}
+
+	sep := r.style("│", styleDim)
+
+	var ret []string
+	for rowIdx := range rows {
+		var parts []string
+		for colIdx := range widths {
+			parts = append(parts, padCell(cells[rowIdx][colIdx], visible[rowIdx][colIdx], widths[colIdx], b.aligns[colIdx]))
+		}
+		ret = append(ret, strings.TrimRight(strings.Join(parts, " "+sep+" "), " "))
+
+		if rowIdx == 0 {
+			var rules []string
+			for _, w := range widths {
+				rules = append(rules, strings.Repeat("─", w))
+			}
+			ret = append(ret, r.style(strings.Join(rules, "─┼─"), styleDim))
+		}
+	}
+
+	return ret
+}
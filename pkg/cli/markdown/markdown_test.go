@@ -0,0 +1,121 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package markdown
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+)
+
+func TestRender(t *testing.T) {
+	testCases := []struct {
+		input    string
+		width    int
+		expected string
+	}{
+		{
+			input:    "",
+			expected: "",
+		},
+		{
+			input:    "# Title\n\nSome **bold**, _italic_ and `code` with snake_case.",
+			expected: "# Title\n\nSome bold, italic and code with snake_case.\n",
+		},
+		{
+			input:    "Title\n===\n\nSubtitle\n---",
+			expected: "# Title\n\n## Subtitle\n",
+		},
+		{
+			input:    "the quick brown fox jumps over the lazy dog",
+			width:    15,
+			expected: "the quick brown\nfox jumps over\nthe lazy dog\n",
+		},
+		{
+			input:    "line one  \nline two\\\nline three",
+			expected: "line one\nline two\nline three\n",
+		},
+		{
+			input:    "see [the docs](https://www.getdnote.com/docs \"Docs\") or <https://www.getdnote.com>",
+			expected: "see the docs (https://www.getdnote.com/docs) or https://www.getdnote.com\n",
+		},
+		{
+			input:    `\*not emphasis\* and 2 * 3 * 4`,
+			expected: "*not emphasis* and 2 * 3 * 4\n",
+		},
+		{
+			input:    "> quoted\nlazy\n>\n> second",
+			expected: "│ quoted lazy\n│\n│ second\n",
+		},
+		{
+			input:    "- one\n- two\n  - nested\n- three\n\n1. first\n2. second",
+			expected: "• one\n• two\n  ◦ nested\n• three\n\n1. first\n2. second\n",
+		},
+		{
+			input:    "- one\n\n- two",
+			expected: "• one\n\n• two\n",
+		},
+		{
+			input:    "9. nine\n10. ten",
+			expected: " 9. nine\n10. ten\n",
+		},
+		{
+			input:    "| Name | Qty |\n| :--- | --: |\n| apple | 3 |\n| kiwi | 12 |",
+			expected: "Name  │ Qty\n──────┼────\napple │   3\nkiwi  │  12\n",
+		},
+		{
+			input:    "```go\nfunc main() {}\n```\n\n    indented",
+			expected: "    func main() {}\n\n    indented\n",
+		},
+		{
+			input:    "a\n\n***\n\nb",
+			width:    5,
+			expected: "a\n\n─────\n\nb\n",
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			result := Render(tc.input, Options{Width: tc.width})
+
+			assert.Equal(t, result, tc.expected, "result mismatch")
+		})
+	}
+}
+
+func TestRender_Color(t *testing.T) {
+	result := Render("**bold** [link](https://www.getdnote.com)", Options{Color: true, Hyperlinks: true})
+
+	expected := "\x1b[1mbold\x1b[0m \x1b]8;;https://www.getdnote.com\x1b\\\x1b[4;36mlink\x1b[0m\x1b]8;;\x1b\\\n"
+	assert.Equal(t, result, expected, "result mismatch")
+}
+
+func TestHighlight(t *testing.T) {
+	result := highlight([]string{`x := "a" // b`, "/* c", "d */ return 1"}, "go")
+
+	expected := []string{
+		"x := " + ansi(colorString, `"a"`) + " " + ansi(colorComment, "// b"),
+		ansi(colorComment, "/*") + ansi(colorComment, " c"),
+		ansi(colorComment, "d */") + " " + ansi(colorKeyword, "return") + " " + ansi(colorNumber, "1"),
+	}
+	assert.DeepEqual(t, result, expected, "result mismatch")
+
+	assert.DeepEqual(t, highlight([]string{"return 1"}, "unknown"), []string{"return 1"}, "unknown language mismatch")
+}
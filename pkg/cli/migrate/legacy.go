@@ -317,7 +317,7 @@ type migrateToV2PostBook struct {
 type migrateToV2PreDnote map[string]migrateToV2PreBook
 type migrateToV2PostDnote map[string]migrateToV2PostBook
 
-//v3
+// v3
 var (
 	migrateToV3ActionAddNote = "add_note"
 	migrateToV3ActionAddBook = "add_book"
@@ -23,6 +23,7 @@ import (
 	"github.com/dnote/dnote/pkg/cli/consts"
 	"github.com/dnote/dnote/pkg/cli/context"
 	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/dnote/dnote/pkg/cli/snapshot"
 	"github.com/pkg/errors"
 )
 
@@ -47,6 +48,11 @@ var LocalSequence = []migration{
 	lm10,
 	lm11,
 	lm12,
+	lm13,
+	lm14,
+	lm15,
+	lm16,
+	lm17,
 }
 
 // RemoteSequence is a list of remote migrations to be run
@@ -145,6 +151,13 @@ func Run(ctx context.DnoteCtx, migrations []migration, mode int) error {
 
 	toRun := migrations[schema:]
 
+	// keep a copy of the existing data in case a migration fails or corrupts it
+	if len(toRun) > 0 && schema > 0 {
+		if err := snapshot.Auto(ctx, snapshot.ReasonMigration); err != nil {
+			return errors.Wrap(err, "backing up the database")
+		}
+	}
+
 	for _, m := range toRun {
 		if err := execute(ctx, m, schemaKey); err != nil {
 			return errors.Wrap(err, "running migration")
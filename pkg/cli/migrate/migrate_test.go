@@ -19,6 +19,7 @@
 package migrate
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"gopkg.in/yaml.v2"
@@ -33,6 +34,7 @@ import (
 	"github.com/dnote/dnote/pkg/cli/consts"
 	"github.com/dnote/dnote/pkg/cli/context"
 	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/snapshot"
 	"github.com/dnote/dnote/pkg/cli/testutils"
 	"github.com/pkg/errors"
 )
@@ -175,6 +177,12 @@ func TestRun_nonfresh(t *testing.T) {
 			var testRun1, testRun2 string
 			database.MustScan(t, "finding test run 1", db.QueryRow("SELECT name FROM migrate_run_test WHERE name = ?", "v3"), &testRun1)
 			database.MustScan(t, "finding test run 2", db.QueryRow("SELECT name FROM migrate_run_test WHERE name = ?", "v4"), &testRun2)
+
+			snapshots, err := snapshot.List(ctx)
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "listing snapshots"))
+			}
+			assert.Equal(t, len(snapshots), 1, "a snapshot should be taken before the migrations")
 		}()
 	}
 }
@@ -249,6 +257,12 @@ func TestRun_fresh(t *testing.T) {
 			database.MustScan(t, "finding test run 1", db.QueryRow("SELECT name FROM migrate_run_test WHERE name = ?", "v1"), &testRun1)
 			database.MustScan(t, "finding test run 2", db.QueryRow("SELECT name FROM migrate_run_test WHERE name = ?", "v2"), &testRun2)
 			database.MustScan(t, "finding test run 2", db.QueryRow("SELECT name FROM migrate_run_test WHERE name = ?", "v3"), &testRun3)
+
+			snapshots, err := snapshot.List(ctx)
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "listing snapshots"))
+			}
+			assert.Equal(t, len(snapshots), 0, "no snapshot should be taken for a new database")
 		}()
 	}
 }
@@ -1108,6 +1122,186 @@ func TestLocalMigration12(t *testing.T) {
 	assert.NotEqual(t, cf.APIEndpoint, "", "apiEndpoint was not populated")
 }
 
+func TestLocalMigration13(t *testing.T) {
+	// set up
+	opts := database.TestDBOptions{SchemaSQLPath: "./fixtures/local-13-pre-schema.sql", SkipMigration: true}
+	ctx := context.InitTestCtx(t, paths, &opts)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+
+	b1UUID := testutils.MustGenerateUUID(t)
+	database.MustExec(t, "inserting book 1", db, "INSERT INTO books (uuid, label) VALUES (?, ?)", b1UUID, "b1")
+
+	n1UUID := testutils.MustGenerateUUID(t)
+	database.MustExec(t, "inserting n1", db, `INSERT INTO notes
+		(uuid, book_uuid, body, added_on, edited_on, public, dirty, usn, deleted) VALUES
+		(?, ?, ?, ?, ?, ?, ?, ?, ?)`, n1UUID, b1UUID, "n1 Body", 1, 2, false, false, 20, false)
+	n2UUID := testutils.MustGenerateUUID(t)
+	database.MustExec(t, "inserting n2", db, `INSERT INTO notes
+		(uuid, book_uuid, body, added_on, edited_on, public, dirty, usn, deleted) VALUES
+		(?, ?, ?, ?, ?, ?, ?, ?, ?)`, n2UUID, b1UUID, "n2 Body", 3, 4, false, true, 21, false)
+	n3UUID := testutils.MustGenerateUUID(t)
+	database.MustExec(t, "inserting n3", db, `INSERT INTO notes
+		(uuid, book_uuid, body, added_on, edited_on, public, dirty, usn, deleted) VALUES
+		(?, ?, ?, ?, ?, ?, ?, ?, ?)`, n3UUID, b1UUID, "n3 Body", 5, 6, false, true, 0, false)
+
+	// Execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "beginning a transaction"))
+	}
+
+	err = lm13.run(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		t.Fatal(errors.Wrap(err, "failed to run"))
+	}
+
+	tx.Commit()
+
+	// Test
+	var n1Base, n2Base, n3Base sql.NullString
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT base_body FROM notes WHERE uuid = ?", n1UUID), &n1Base)
+	database.MustScan(t, "getting n2", db.QueryRow("SELECT base_body FROM notes WHERE uuid = ?", n2UUID), &n2Base)
+	database.MustScan(t, "getting n3", db.QueryRow("SELECT base_body FROM notes WHERE uuid = ?", n3UUID), &n3Base)
+
+	assert.Equal(t, n1Base, sql.NullString{String: "n1 Body", Valid: true}, "n1 base_body mismatch")
+	assert.Equal(t, n2Base.Valid, false, "n2 base_body should be null")
+	assert.Equal(t, n3Base.Valid, false, "n3 base_body should be null")
+}
+
+func TestLocalMigration14(t *testing.T) {
+	// set up
+	opts := database.TestDBOptions{SchemaSQLPath: "./fixtures/local-14-pre-schema.sql", SkipMigration: true}
+	ctx := context.InitTestCtx(t, paths, &opts)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+
+	b1UUID := testutils.MustGenerateUUID(t)
+	database.MustExec(t, "inserting book 1", db, "INSERT INTO books (uuid, label) VALUES (?, ?)", b1UUID, "b1")
+
+	// Execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "beginning a transaction"))
+	}
+
+	err = lm14.run(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		t.Fatal(errors.Wrap(err, "failed to run"))
+	}
+
+	tx.Commit()
+
+	// Test
+	var localOnly bool
+	database.MustScan(t, "getting b1", db.QueryRow("SELECT local_only FROM books WHERE uuid = ?", b1UUID), &localOnly)
+	assert.Equal(t, localOnly, false, "b1 local_only mismatch")
+}
+
+func TestLocalMigration15(t *testing.T) {
+	// set up
+	opts := database.TestDBOptions{SchemaSQLPath: "./fixtures/local-15-pre-schema.sql", SkipMigration: true}
+	ctx := context.InitTestCtx(t, paths, &opts)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+
+	// Execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "beginning a transaction"))
+	}
+
+	err = lm15.run(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		t.Fatal(errors.Wrap(err, "failed to run"))
+	}
+
+	tx.Commit()
+
+	// Test
+	database.MustExec(t, "inserting an outbox item", db, "INSERT INTO outbox (type, uuid, error, attempts, last_attempt_at) VALUES (?, ?, ?, ?, ?)", "note", "n1-uuid", "network error", 1, 1541108743)
+
+	_, err = db.Exec("INSERT INTO outbox (type, uuid, error, attempts, last_attempt_at) VALUES (?, ?, ?, ?, ?)", "note", "n1-uuid", "network error", 2, 1541108744)
+	assert.NotEqual(t, err, nil, "duplicate outbox item should not be allowed")
+}
+
+func TestLocalMigration16(t *testing.T) {
+	// set up
+	opts := database.TestDBOptions{SchemaSQLPath: "./fixtures/local-16-pre-schema.sql", SkipMigration: true}
+	ctx := context.InitTestCtx(t, paths, &opts)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+
+	b1UUID := testutils.MustGenerateUUID(t)
+	database.MustExec(t, "inserting book 1", db, "INSERT INTO books (uuid, label) VALUES (?, ?)", b1UUID, "b1")
+	n1UUID := testutils.MustGenerateUUID(t)
+	database.MustExec(t, "inserting note 1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on) VALUES (?, ?, ?, ?)", n1UUID, b1UUID, "n1 body", 1541108743)
+
+	// Execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "beginning a transaction"))
+	}
+
+	err = lm16.run(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		t.Fatal(errors.Wrap(err, "failed to run"))
+	}
+
+	tx.Commit()
+
+	// Test
+	var dueOn, remindOn int64
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT due_on, remind_on FROM notes WHERE uuid = ?", n1UUID), &dueOn, &remindOn)
+	assert.Equal(t, dueOn, int64(0), "n1 due_on mismatch")
+	assert.Equal(t, remindOn, int64(0), "n1 remind_on mismatch")
+}
+
+func TestLocalMigration17(t *testing.T) {
+	// set up
+	opts := database.TestDBOptions{SchemaSQLPath: "./fixtures/local-17-pre-schema.sql", SkipMigration: true}
+	ctx := context.InitTestCtx(t, paths, &opts)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+
+	b1UUID := testutils.MustGenerateUUID(t)
+	database.MustExec(t, "inserting book 1", db, "INSERT INTO books (uuid, label) VALUES (?, ?)", b1UUID, "b1")
+	n1UUID := testutils.MustGenerateUUID(t)
+	database.MustExec(t, "inserting note 1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on) VALUES (?, ?, ?, ?)", n1UUID, b1UUID, "n1 body", 1541108743)
+
+	// Execute
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "beginning a transaction"))
+	}
+
+	err = lm17.run(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		t.Fatal(errors.Wrap(err, "failed to run"))
+	}
+
+	tx.Commit()
+
+	// Test
+	var b1Pinned, b1Archived, n1Pinned, n1Archived bool
+	database.MustScan(t, "getting b1", db.QueryRow("SELECT pinned, archived FROM books WHERE uuid = ?", b1UUID), &b1Pinned, &b1Archived)
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT pinned, archived FROM notes WHERE uuid = ?", n1UUID), &n1Pinned, &n1Archived)
+	assert.Equal(t, b1Pinned, false, "b1 pinned mismatch")
+	assert.Equal(t, b1Archived, false, "b1 archived mismatch")
+	assert.Equal(t, n1Pinned, false, "n1 pinned mismatch")
+	assert.Equal(t, n1Archived, false, "n1 archived mismatch")
+}
+
 func TestRemoteMigration1(t *testing.T) {
 	// set up
 	opts := database.TestDBOptions{SchemaSQLPath: "./fixtures/remote-1-pre-schema.sql", SkipMigration: true}
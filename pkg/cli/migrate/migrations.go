@@ -550,15 +550,104 @@ var lm12 = migration{
 	},
 }
 
+var lm13 = migration{
+	name: "add-base-body-to-notes",
+	run: func(ctx context.DnoteCtx, tx *database.DB) error {
+		_, err := tx.Exec("ALTER TABLE notes ADD COLUMN base_body text;")
+		if err != nil {
+			return errors.Wrap(err, "adding base_body column to notes")
+		}
+
+		// Notes that are not dirty are identical to the copy in the server
+		_, err = tx.Exec("UPDATE notes SET base_body = body WHERE dirty = ? AND usn > 0", false)
+		if err != nil {
+			return errors.Wrap(err, "populating base_body")
+		}
+
+		return nil
+	},
+}
+
+var lm14 = migration{
+	name: "add-local-only-to-books",
+	run: func(ctx context.DnoteCtx, tx *database.DB) error {
+		_, err := tx.Exec("ALTER TABLE books ADD COLUMN local_only bool DEFAULT false;")
+		if err != nil {
+			return errors.Wrap(err, "adding local_only column to books")
+		}
+
+		return nil
+	},
+}
+
+var lm15 = migration{
+	name: "create-outbox",
+	run: func(ctx context.DnoteCtx, tx *database.DB) error {
+		_, err := tx.Exec(`CREATE TABLE outbox
+		(
+			type text NOT NULL,
+			uuid text NOT NULL,
+			error text NOT NULL,
+			attempts integer NOT NULL DEFAULT 0,
+			last_attempt_at integer NOT NULL
+		)`)
+		if err != nil {
+			return errors.Wrap(err, "creating outbox table")
+		}
+
+		_, err = tx.Exec("CREATE UNIQUE INDEX idx_outbox_type_uuid ON outbox(type, uuid);")
+		if err != nil {
+			return errors.Wrap(err, "creating index on outbox")
+		}
+
+		return nil
+	},
+}
+
+var lm16 = migration{
+	name: "add-due-on-and-remind-on-to-notes",
+	run: func(ctx context.DnoteCtx, tx *database.DB) error {
+		_, err := tx.Exec("ALTER TABLE notes ADD COLUMN due_on integer DEFAULT 0;")
+		if err != nil {
+			return errors.Wrap(err, "adding due_on column to notes")
+		}
+
+		_, err = tx.Exec("ALTER TABLE notes ADD COLUMN remind_on integer DEFAULT 0;")
+		if err != nil {
+			return errors.Wrap(err, "adding remind_on column to notes")
+		}
+
+		return nil
+	},
+}
+
+var lm17 = migration{
+	name: "add-pinned-and-archived-to-notes-and-books",
+	run: func(ctx context.DnoteCtx, tx *database.DB) error {
+		for _, table := range []string{"notes", "books"} {
+			_, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN pinned bool DEFAULT false;", table))
+			if err != nil {
+				return errors.Wrapf(err, "adding pinned column to %s", table)
+			}
+
+			_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN archived bool DEFAULT false;", table))
+			if err != nil {
+				return errors.Wrapf(err, "adding archived column to %s", table)
+			}
+		}
+
+		return nil
+	},
+}
+
 var rm1 = migration{
 	name: "sync-book-uuids-from-server",
 	run: func(ctx context.DnoteCtx, tx *database.DB) error {
-		sessionKey := ctx.SessionKey
-		if sessionKey == "" {
+		if !client.IsAuthorized(ctx) {
 			return errors.New("not logged in")
 		}
 
-		resp, err := client.GetBooks(ctx, sessionKey)
+		resp, err := client.GetBooks(ctx)
 		if err != nil {
 			return errors.Wrap(err, "getting books from the server")
 		}
@@ -0,0 +1,273 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package operations
+
+import (
+	"database/sql"
+
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/hooks"
+	"github.com/dnote/dnote/pkg/cli/snapshot"
+	"github.com/dnote/dnote/pkg/cli/utils"
+	"github.com/dnote/dnote/pkg/cli/validate"
+	"github.com/pkg/errors"
+)
+
+// Book is a book in the local database
+type Book struct {
+	RowID     int    `json:"id"`
+	UUID      string `json:"uuid"`
+	Name      string `json:"name"`
+	NoteCount int    `json:"note_count"`
+	Pinned    bool   `json:"pinned"`
+	Archived  bool   `json:"archived"`
+	LocalOnly bool   `json:"local_only"`
+}
+
+const bookQuery = `SELECT books.rowid, books.uuid, books.label, count(notes.uuid), books.pinned,
+		books.archived, coalesce(books.local_only, false)
+	FROM books
+	LEFT JOIN notes ON notes.book_uuid = books.uuid AND notes.deleted = false`
+
+func scanBook(s scanner) (Book, error) {
+	var ret Book
+	err := s.Scan(&ret.RowID, &ret.UUID, &ret.Name, &ret.NoteCount, &ret.Pinned, &ret.Archived, &ret.LocalOnly)
+
+	return ret, err
+}
+
+// ListBooks returns the books in the same order as the view command. The archived
+// books are included only if archived is true.
+func ListBooks(db *database.DB, archived bool) ([]Book, error) {
+	rows, err := db.Query(bookQuery+`
+	WHERE books.deleted = false AND (? OR books.archived = false)
+	GROUP BY books.uuid
+	ORDER BY books.pinned DESC, books.label ASC`, archived)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying books")
+	}
+	defer rows.Close()
+
+	ret := []Book{}
+	for rows.Next() {
+		b, err := scanBook(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning a row")
+		}
+
+		ret = append(ret, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating rows")
+	}
+
+	return ret, nil
+}
+
+// GetBook returns the book with the given name. It returns ErrNotFound if the book
+// does not exist.
+func GetBook(db *database.DB, name string) (Book, error) {
+	row := db.QueryRow(bookQuery+`
+	WHERE books.label = ? AND books.deleted = false
+	GROUP BY books.uuid`, name)
+
+	ret, err := scanBook(row)
+	if err == sql.ErrNoRows {
+		return ret, ErrNotFound
+	} else if err != nil {
+		return ret, errors.Wrap(err, "querying the book")
+	}
+
+	return ret, nil
+}
+
+// checkNewBookName returns an error if a book cannot be given the name
+func checkNewBookName(db *database.DB, name string) error {
+	if err := validate.BookName(name); err != nil {
+		return invalidf("invalid book name: %s", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT count(*) FROM books WHERE label = ?", name).Scan(&count); err != nil {
+		return errors.Wrap(err, "counting the books")
+	}
+	if count > 0 {
+		return invalidf("book '%s' already exists", name)
+	}
+
+	return nil
+}
+
+// CreateBook creates an empty book with the given name
+func CreateBook(ctx context.DnoteCtx, name string) (Book, error) {
+	if err := checkNewBookName(ctx.DB, name); err != nil {
+		return Book{}, err
+	}
+
+	uuid, err := utils.GenerateUUID()
+	if err != nil {
+		return Book{}, errors.Wrap(err, "generating uuid")
+	}
+
+	b := database.NewBook(uuid, name, 0, false, true)
+	if err := b.Insert(ctx.DB); err != nil {
+		return Book{}, errors.Wrap(err, "creating the book")
+	}
+
+	sync.AfterWrite(ctx)
+
+	return GetBook(ctx.DB, name)
+}
+
+// BookParams is the changes to a book. Nil fields are left unchanged.
+type BookParams struct {
+	Name     *string `json:"name"`
+	Pinned   *bool   `json:"pinned"`
+	Archived *bool   `json:"archived"`
+}
+
+func updateBook(tx *database.DB, book Book, p BookParams) error {
+	if p.Name != nil && *p.Name != book.Name {
+		if err := checkNewBookName(tx, *p.Name); err != nil {
+			return err
+		}
+
+		if err := database.UpdateBookName(tx, book.UUID, *p.Name); err != nil {
+			return errors.Wrap(err, "renaming the book")
+		}
+	}
+	if p.Pinned != nil && *p.Pinned != book.Pinned {
+		if err := database.UpdateBookPinned(tx, book.UUID, *p.Pinned); err != nil {
+			return errors.Wrap(err, "updating the pinned state")
+		}
+	}
+	if p.Archived != nil && *p.Archived != book.Archived {
+		if err := database.UpdateBookArchived(tx, book.UUID, *p.Archived); err != nil {
+			return errors.Wrap(err, "updating the archived state")
+		}
+	}
+
+	return nil
+}
+
+// UpdateBook applies the changes to the book with the given name
+func UpdateBook(ctx context.DnoteCtx, name string, p BookParams) (Book, error) {
+	book, err := GetBook(ctx.DB, name)
+	if err != nil {
+		return Book{}, err
+	}
+
+	tx, err := ctx.DB.Begin()
+	if err != nil {
+		return Book{}, errors.Wrap(err, "beginning a transaction")
+	}
+
+	if err := updateBook(tx, book, p); err != nil {
+		tx.Rollback()
+		return Book{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return Book{}, errors.Wrap(err, "committing a transaction")
+	}
+
+	sync.AfterWrite(ctx)
+
+	if p.Name != nil {
+		name = *p.Name
+	}
+
+	return GetBook(ctx.DB, name)
+}
+
+// getBookNotes returns the notes in the book to describe to the hooks
+func getBookNotes(db *database.DB, book Book) ([]hooks.Note, error) {
+	rows, err := db.Query("SELECT rowid, uuid, body FROM notes WHERE book_uuid = ? AND deleted = ? ORDER BY added_on ASC", book.UUID, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying notes")
+	}
+	defer rows.Close()
+
+	var ret []hooks.Note
+	for rows.Next() {
+		n := hooks.Note{BookLabel: book.Name}
+		if err := rows.Scan(&n.RowID, &n.UUID, &n.Content); err != nil {
+			return nil, errors.Wrap(err, "scanning a row")
+		}
+
+		ret = append(ret, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating rows")
+	}
+
+	return ret, nil
+}
+
+// DeleteBook removes the book with the given name and all its notes
+func DeleteBook(ctx context.DnoteCtx, name string) error {
+	book, err := GetBook(ctx.DB, name)
+	if err != nil {
+		return err
+	}
+
+	notes, err := getBookNotes(ctx.DB, book)
+	if err != nil {
+		return errors.Wrap(err, "getting the notes in the book")
+	}
+
+	if err := snapshot.Auto(ctx, snapshot.ReasonBulk); err != nil {
+		return errors.Wrap(err, "backing up the database")
+	}
+
+	tx, err := ctx.DB.Begin()
+	if err != nil {
+		return errors.Wrap(err, "beginning a transaction")
+	}
+
+	if _, err := tx.Exec("UPDATE notes SET deleted = ?, dirty = ?, body = ? WHERE book_uuid = ?", true, true, "", book.UUID); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "removing notes in the book")
+	}
+
+	// override the label with a random string so that the name can be reused
+	uniqLabel, err := utils.GenerateUUID()
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "generating uuid to override with")
+	}
+
+	if _, err := tx.Exec("UPDATE books SET deleted = ?, dirty = ?, label = ? WHERE uuid = ?", true, true, uniqLabel, book.UUID); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "removing the book")
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "committing a transaction")
+	}
+
+	hooks.Post(ctx, hooks.PostRemove, hooks.Payload{Notes: notes})
+	sync.AfterWrite(ctx)
+
+	return nil
+}
@@ -0,0 +1,123 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package operations
+
+import (
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/pkg/errors"
+)
+
+func TestListBooks(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "js", 0, false, false)
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, usn, deleted, dirty, pinned) VALUES (?, ?, ?, ?, ?, ?)", "b2-uuid", "linux", 0, false, false, true)
+	database.MustExec(t, "inserting b3", db, "INSERT INTO books (uuid, label, usn, deleted, dirty, archived) VALUES (?, ?, ?, ?, ?, ?)", "b3-uuid", "css", 0, false, false, true)
+	database.MustExec(t, "inserting b4", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b4-uuid", "b4-uuid", 0, true, true)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1", 1, 0, false, false)
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", "", 2, 0, true, true)
+
+	// execute
+	books, err := ListBooks(db, false)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "listing books"))
+	}
+	allBooks, err := ListBooks(db, true)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "listing all books"))
+	}
+
+	// test
+	var names []string
+	for _, b := range books {
+		names = append(names, b.Name)
+	}
+	assert.DeepEqual(t, names, []string{"linux", "js"}, "names mismatch")
+	assert.Equal(t, books[1].NoteCount, 1, "note count mismatch")
+	assert.Equal(t, len(allBooks), 3, "all books count mismatch")
+}
+
+func TestUpdateBook(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "js", 3, false, false)
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b2-uuid", "css", 4, false, false)
+
+	// execute
+	name := "javascript"
+	archived := true
+	book, err := UpdateBook(ctx, "js", BookParams{Name: &name, Archived: &archived})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "updating the book"))
+	}
+
+	// test
+	assert.Equal(t, book.Name, "javascript", "name mismatch")
+	assert.Equal(t, book.Archived, true, "archived mismatch")
+
+	var dirty bool
+	database.MustScan(t, "getting b1", db.QueryRow("SELECT dirty FROM books WHERE uuid = ?", "b1-uuid"), &dirty)
+	assert.Equal(t, dirty, true, "dirty mismatch")
+
+	taken := "css"
+	_, err = UpdateBook(ctx, "javascript", BookParams{Name: &taken})
+	_, ok := errors.Cause(err).(InvalidError)
+	assert.Equal(t, ok, true, "conflicting name error mismatch")
+}
+
+func TestDeleteBook(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "js", 3, false, false)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1", 1, 2, false, false)
+
+	// execute
+	if err := DeleteBook(ctx, "js"); err != nil {
+		t.Fatal(errors.Wrap(err, "deleting the book"))
+	}
+
+	// test
+	var bookDeleted, bookDirty bool
+	var label string
+	database.MustScan(t, "getting b1", db.QueryRow("SELECT label, deleted, dirty FROM books WHERE uuid = ?", "b1-uuid"), &label, &bookDeleted, &bookDirty)
+	assert.NotEqual(t, label, "js", "label mismatch")
+	assert.Equal(t, bookDeleted, true, "book deleted mismatch")
+	assert.Equal(t, bookDirty, true, "book dirty mismatch")
+
+	var noteDeleted, noteDirty bool
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT deleted, dirty FROM notes WHERE uuid = ?", "n1-uuid"), &noteDeleted, &noteDirty)
+	assert.Equal(t, noteDeleted, true, "note deleted mismatch")
+	assert.Equal(t, noteDirty, true, "note dirty mismatch")
+
+	_, err := GetBook(db, "js")
+	assert.Equal(t, errors.Cause(err), ErrNotFound, "get error mismatch")
+}
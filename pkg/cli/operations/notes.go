@@ -0,0 +1,444 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package operations
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/dnote/dnote/pkg/cli/cmd/sync"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/cli/hooks"
+	"github.com/dnote/dnote/pkg/cli/utils"
+	"github.com/dnote/dnote/pkg/cli/validate"
+	"github.com/pkg/errors"
+)
+
+// Note is a note in the local database
+type Note struct {
+	RowID     int    `json:"id"`
+	UUID      string `json:"uuid"`
+	BookUUID  string `json:"book_uuid"`
+	BookLabel string `json:"book"`
+	Content   string `json:"content"`
+	AddedOn   int64  `json:"added_on"`
+	EditedOn  int64  `json:"edited_on"`
+	Public    bool   `json:"public"`
+	Pinned    bool   `json:"pinned"`
+	Archived  bool   `json:"archived"`
+	DueOn     int64  `json:"due_on"`
+	RemindOn  int64  `json:"remind_on"`
+}
+
+func (n Note) hookNote() hooks.Note {
+	return hooks.Note{UUID: n.UUID, RowID: n.RowID, BookLabel: n.BookLabel, Content: n.Content}
+}
+
+const noteColumns = `notes.rowid, notes.uuid, notes.book_uuid, books.label, notes.body, notes.added_on,
+	notes.edited_on, notes.public, notes.pinned, notes.archived, notes.due_on, notes.remind_on`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanNote(s scanner) (Note, error) {
+	var ret Note
+	err := s.Scan(&ret.RowID, &ret.UUID, &ret.BookUUID, &ret.BookLabel, &ret.Content, &ret.AddedOn,
+		&ret.EditedOn, &ret.Public, &ret.Pinned, &ret.Archived, &ret.DueOn, &ret.RemindOn)
+
+	return ret, err
+}
+
+// NotesFilter is the filter for listing notes
+type NotesFilter struct {
+	Book string
+	// Query is the keywords for the full text search
+	Query string
+	// Archived includes the archived notes and the notes in the archived books
+	Archived bool
+	// Limit is the maximum number of the notes. Zero means no limit.
+	Limit int
+}
+
+// escapeQuery escapes the keywords for the full text search by quoting each term
+// so that they are treated as strings as defined by SQLite FTS5
+func escapeQuery(s string) string {
+	var terms []string
+	for _, term := range strings.Fields(s) {
+		terms = append(terms, fmt.Sprintf(`"%s"`, strings.Replace(term, `"`, `""`, -1)))
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// ListNotes returns the notes that match the filter. The search results are ordered
+// by the relevance, and other notes are ordered in the same way as the view command.
+func ListNotes(db *database.DB, f NotesFilter) ([]Note, error) {
+	query := fmt.Sprintf("SELECT %s FROM notes INNER JOIN books ON books.uuid = notes.book_uuid", noteColumns)
+	conds := []string{"notes.deleted = false"}
+	var args []interface{}
+	order := "notes.pinned DESC, notes.added_on ASC"
+
+	if q := escapeQuery(f.Query); q != "" {
+		query = fmt.Sprintf("%s INNER JOIN note_fts ON note_fts.rowid = notes.rowid", query)
+		conds = append(conds, "note_fts MATCH ?")
+		args = append(args, q)
+		order = "note_fts.rank"
+	}
+	if f.Book != "" {
+		conds = append(conds, "books.label = ?")
+		args = append(args, f.Book)
+	}
+	if !f.Archived {
+		conds = append(conds, "notes.archived = false AND books.archived = false")
+	}
+
+	query = fmt.Sprintf("%s WHERE %s ORDER BY %s", query, strings.Join(conds, " AND "), order)
+	if f.Limit > 0 {
+		query = fmt.Sprintf("%s LIMIT %d", query, f.Limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying notes")
+	}
+	defer rows.Close()
+
+	ret := []Note{}
+	for rows.Next() {
+		n, err := scanNote(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning a row")
+		}
+
+		ret = append(ret, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating rows")
+	}
+
+	return ret, nil
+}
+
+// GetNote returns the note with the given id. It returns ErrNotFound if the note
+// does not exist.
+func GetNote(db *database.DB, rowID int) (Note, error) {
+	row := db.QueryRow(fmt.Sprintf(`SELECT %s
+		FROM notes
+		INNER JOIN books ON books.uuid = notes.book_uuid
+		WHERE notes.rowid = ? AND notes.deleted = false`, noteColumns), rowID)
+
+	ret, err := scanNote(row)
+	if err == sql.ErrNoRows {
+		return ret, ErrNotFound
+	} else if err != nil {
+		return ret, errors.Wrap(err, "querying the note")
+	}
+
+	return ret, nil
+}
+
+// getOrCreateBook returns the uuid of the book with the label, creating the book if
+// it does not exist. A deleted book that has not been synced yet still holds the
+// label, and is restored.
+func getOrCreateBook(tx *database.DB, label string) (string, error) {
+	var ret string
+	var deleted bool
+	err := tx.QueryRow("SELECT uuid, deleted FROM books WHERE label = ?", label).Scan(&ret, &deleted)
+	if err == nil {
+		if deleted {
+			if _, err := tx.Exec("UPDATE books SET deleted = ?, dirty = ? WHERE uuid = ?", false, true, ret); err != nil {
+				return "", errors.Wrap(err, "restoring the book")
+			}
+		}
+
+		return ret, nil
+	} else if err != sql.ErrNoRows {
+		return "", errors.Wrap(err, "finding the book")
+	}
+
+	ret, err = utils.GenerateUUID()
+	if err != nil {
+		return "", errors.Wrap(err, "generating uuid")
+	}
+
+	b := database.NewBook(ret, label, 0, false, true)
+	if err := b.Insert(tx); err != nil {
+		return "", errors.Wrap(err, "creating the book")
+	}
+
+	return ret, nil
+}
+
+// NewNoteParams is a note to create
+type NewNoteParams struct {
+	Book    string
+	Content string
+	// DueOn and RemindOn are in unix nanoseconds. Zero means none.
+	DueOn    int64
+	RemindOn int64
+}
+
+// InsertNote inserts a new note into the book in the transaction and returns the uuid
+// of the note. It does not run the hooks.
+func InsertNote(tx *database.DB, bookUUID, content string, addedOn, dueOn, remindOn int64) (string, error) {
+	uuid, err := utils.GenerateUUID()
+	if err != nil {
+		return "", errors.Wrap(err, "generating uuid")
+	}
+
+	n := database.NewNote(uuid, bookUUID, content, addedOn, 0, 0, false, false, true)
+	n.DueOn = dueOn
+	n.RemindOn = remindOn
+	if err := n.Insert(tx); err != nil {
+		return "", errors.Wrap(err, "creating the note")
+	}
+
+	return uuid, nil
+}
+
+// CreateNote adds a note to the book, creating the book if it does not exist
+func CreateNote(ctx context.DnoteCtx, p NewNoteParams) (Note, error) {
+	if err := validate.BookName(p.Book); err != nil {
+		return Note{}, invalidf("invalid book name: %s", err)
+	}
+
+	notes, err := hooks.Pre(ctx, hooks.PreAdd, []hooks.Note{{BookLabel: p.Book, Content: p.Content}})
+	if err != nil {
+		return Note{}, err
+	}
+	content := notes[0].Content
+	if content == "" {
+		return Note{}, invalidf("content is empty")
+	}
+
+	tx, err := ctx.DB.Begin()
+	if err != nil {
+		return Note{}, errors.Wrap(err, "beginning a transaction")
+	}
+
+	bookUUID, err := getOrCreateBook(tx, p.Book)
+	if err != nil {
+		tx.Rollback()
+		return Note{}, err
+	}
+
+	uuid, err := InsertNote(tx, bookUUID, content, ctx.Clock.Now().UnixNano(), p.DueOn, p.RemindOn)
+	if err != nil {
+		tx.Rollback()
+		return Note{}, err
+	}
+
+	var rowID int
+	if err := tx.QueryRow("SELECT rowid FROM notes WHERE uuid = ?", uuid).Scan(&rowID); err != nil {
+		tx.Rollback()
+		return Note{}, errors.Wrap(err, "getting the note rowid")
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return Note{}, errors.Wrap(err, "committing a transaction")
+	}
+
+	ret, err := GetNote(ctx.DB, rowID)
+	if err != nil {
+		return Note{}, err
+	}
+
+	hooks.Post(ctx, hooks.PostAdd, hooks.Payload{Notes: []hooks.Note{ret.hookNote()}})
+	sync.AfterWrite(ctx)
+
+	return ret, nil
+}
+
+// NoteParams is the changes to a note. Nil fields are left unchanged.
+type NoteParams struct {
+	Book     *string `json:"book"`
+	Content  *string `json:"content"`
+	Public   *bool   `json:"public"`
+	Pinned   *bool   `json:"pinned"`
+	Archived *bool   `json:"archived"`
+	// DueOn and RemindOn are in unix nanoseconds. Zero removes them.
+	DueOn    *int64 `json:"due_on"`
+	RemindOn *int64 `json:"remind_on"`
+}
+
+func (p NoteParams) empty() bool {
+	return p.Book == nil && p.Content == nil && p.Public == nil && p.Pinned == nil &&
+		p.Archived == nil && p.DueOn == nil && p.RemindOn == nil
+}
+
+// changes returns true if applying the params changes the note
+func (p NoteParams) changes(note Note) bool {
+	return (p.Book != nil && *p.Book != note.BookLabel) ||
+		(p.Content != nil && *p.Content != note.Content) ||
+		(p.Public != nil && *p.Public != note.Public) ||
+		(p.Pinned != nil && *p.Pinned != note.Pinned) ||
+		(p.Archived != nil && *p.Archived != note.Archived) ||
+		(p.DueOn != nil && *p.DueOn != note.DueOn) ||
+		(p.RemindOn != nil && *p.RemindOn != note.RemindOn)
+}
+
+func updateNote(ctx context.DnoteCtx, tx *database.DB, note Note, p NoteParams) error {
+	if p.Book != nil && *p.Book != note.BookLabel {
+		bookUUID, err := database.GetBookUUID(tx, *p.Book)
+		if err != nil {
+			return invalidf("book '%s' not found", *p.Book)
+		}
+
+		if err := database.UpdateNoteBook(tx, ctx.Clock, note.RowID, bookUUID); err != nil {
+			return errors.Wrap(err, "moving the note")
+		}
+	}
+	if p.Content != nil && *p.Content != note.Content {
+		if err := database.UpdateNoteContent(tx, ctx.Clock, note.RowID, *p.Content); err != nil {
+			return errors.Wrap(err, "updating the content")
+		}
+	}
+	if p.Public != nil && *p.Public != note.Public {
+		if err := database.UpdateNotePublic(tx, ctx.Clock, note.RowID, *p.Public); err != nil {
+			return errors.Wrap(err, "updating the visibility")
+		}
+	}
+	if p.Pinned != nil && *p.Pinned != note.Pinned {
+		if err := database.UpdateNotePinned(tx, ctx.Clock, note.RowID, *p.Pinned); err != nil {
+			return errors.Wrap(err, "updating the pinned state")
+		}
+	}
+	if p.Archived != nil && *p.Archived != note.Archived {
+		if err := database.UpdateNoteArchived(tx, ctx.Clock, note.RowID, *p.Archived); err != nil {
+			return errors.Wrap(err, "updating the archived state")
+		}
+	}
+	if (p.DueOn != nil && *p.DueOn != note.DueOn) || (p.RemindOn != nil && *p.RemindOn != note.RemindOn) {
+		due, remind := note.DueOn, note.RemindOn
+		if p.DueOn != nil {
+			due = *p.DueOn
+		}
+		if p.RemindOn != nil {
+			remind = *p.RemindOn
+		}
+
+		if err := database.UpdateNoteSchedule(tx, ctx.Clock, note.RowID, due, remind); err != nil {
+			return errors.Wrap(err, "updating the schedule")
+		}
+	}
+
+	return nil
+}
+
+// UpdateNote applies the changes to the note with the given id. It returns
+// ErrNothingChanged if the changes leave the note as it is.
+func UpdateNote(ctx context.DnoteCtx, rowID int, p NoteParams) (Note, error) {
+	note, err := GetNote(ctx.DB, rowID)
+	if err != nil {
+		return Note{}, err
+	}
+
+	if !p.empty() {
+		after := note.hookNote()
+		if p.Book != nil {
+			after.BookLabel = *p.Book
+		}
+		if p.Content != nil {
+			after.Content = *p.Content
+		}
+
+		notes, err := hooks.Pre(ctx, hooks.PreEdit, []hooks.Note{after})
+		if err != nil {
+			return Note{}, err
+		}
+
+		// the hook can rewrite the content even if only other fields are changed
+		if content := notes[0].Content; p.Content != nil || content != note.Content {
+			p.Content = &content
+		}
+	}
+	if p.Content != nil && *p.Content == "" {
+		return Note{}, invalidf("content is empty")
+	}
+	if !p.changes(note) {
+		return Note{}, ErrNothingChanged
+	}
+
+	tx, err := ctx.DB.Begin()
+	if err != nil {
+		return Note{}, errors.Wrap(err, "beginning a transaction")
+	}
+
+	if err := updateNote(ctx, tx, note, p); err != nil {
+		tx.Rollback()
+		return Note{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return Note{}, errors.Wrap(err, "committing a transaction")
+	}
+
+	ret, err := GetNote(ctx.DB, rowID)
+	if err != nil {
+		return Note{}, err
+	}
+
+	hooks.Post(ctx, hooks.PostEdit, hooks.Payload{Notes: []hooks.Note{ret.hookNote()}})
+	sync.AfterWrite(ctx)
+
+	return ret, nil
+}
+
+// RemoveNote marks the note with the given uuid as deleted in the transaction and
+// clears its content. It does not run the hooks.
+func RemoveNote(tx *database.DB, uuid string) error {
+	if _, err := tx.Exec("UPDATE notes SET deleted = ?, dirty = ?, body = ? WHERE uuid = ?", true, true, "", uuid); err != nil {
+		return errors.Wrapf(err, "removing the note %s", uuid)
+	}
+
+	return nil
+}
+
+// DeleteNote removes the note with the given id and returns the removed note
+func DeleteNote(ctx context.DnoteCtx, rowID int) (Note, error) {
+	note, err := GetNote(ctx.DB, rowID)
+	if err != nil {
+		return Note{}, err
+	}
+
+	tx, err := ctx.DB.Begin()
+	if err != nil {
+		return Note{}, errors.Wrap(err, "beginning a transaction")
+	}
+
+	if err := RemoveNote(tx, note.UUID); err != nil {
+		tx.Rollback()
+		return Note{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return Note{}, errors.Wrap(err, "committing a transaction")
+	}
+
+	hooks.Post(ctx, hooks.PostRemove, hooks.Payload{Notes: []hooks.Note{note.hookNote()}})
+	sync.AfterWrite(ctx)
+
+	return note, nil
+}
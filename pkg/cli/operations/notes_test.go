@@ -0,0 +1,267 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package operations
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/pkg/errors"
+)
+
+var paths context.Paths = context.Paths{
+	Home:   "../tmp",
+	Cache:  "../tmp",
+	Config: "../tmp",
+	Data:   "../tmp",
+}
+
+func getUUIDs(notes []Note) []string {
+	ret := []string{}
+	for _, n := range notes {
+		ret = append(ret, n.UUID)
+	}
+
+	return ret
+}
+
+func TestListNotes(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "js", 0, false, false)
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, usn, deleted, dirty, archived) VALUES (?, ?, ?, ?, ?, ?)", "b2-uuid", "css", 0, false, false, true)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "closures in js", 1, 0, false, false)
+	database.MustExec(t, "inserting n2", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty, pinned) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", "n2-uuid", "b1-uuid", "promises", 2, 0, false, false, true)
+	database.MustExec(t, "inserting n3", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n3-uuid", "b2-uuid", "flexbox in css", 3, 0, false, false)
+	database.MustExec(t, "inserting n4", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n4-uuid", "b1-uuid", "", 4, 0, true, true)
+
+	testCases := []struct {
+		filter   NotesFilter
+		expected []string
+	}{
+		{
+			filter:   NotesFilter{},
+			expected: []string{"n2-uuid", "n1-uuid"},
+		},
+		{
+			filter:   NotesFilter{Archived: true},
+			expected: []string{"n2-uuid", "n1-uuid", "n3-uuid"},
+		},
+		{
+			filter:   NotesFilter{Archived: true, Book: "css"},
+			expected: []string{"n3-uuid"},
+		},
+		{
+			filter:   NotesFilter{Archived: true, Query: `in "`},
+			expected: []string{"n1-uuid", "n3-uuid"},
+		},
+		{
+			filter:   NotesFilter{Query: "in"},
+			expected: []string{"n1-uuid"},
+		},
+		{
+			filter:   NotesFilter{Archived: true, Limit: 1},
+			expected: []string{"n2-uuid"},
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			result, err := ListNotes(db, tc.filter)
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "listing notes"))
+			}
+
+			assert.DeepEqual(t, getUUIDs(result), tc.expected, "result mismatch")
+		})
+	}
+}
+
+func TestCreateNote(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	// execute
+	note, err := CreateNote(ctx, NewNoteParams{Book: "js", Content: "n1 content"})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating a note"))
+	}
+
+	// test
+	var bookUUID string
+	var bookDirty bool
+	database.MustScan(t, "getting the book", ctx.DB.QueryRow("SELECT uuid, dirty FROM books WHERE label = ?", "js"), &bookUUID, &bookDirty)
+	assert.Equal(t, bookDirty, true, "book dirty mismatch")
+
+	var body string
+	var usn int
+	var dirty bool
+	database.MustScan(t, "getting the note", ctx.DB.QueryRow("SELECT body, usn, dirty FROM notes WHERE uuid = ?", note.UUID), &body, &usn, &dirty)
+	assert.Equal(t, body, "n1 content", "body mismatch")
+	assert.Equal(t, usn, 0, "usn mismatch")
+	assert.Equal(t, dirty, true, "dirty mismatch")
+
+	assert.Equal(t, note.BookUUID, bookUUID, "note book uuid mismatch")
+	assert.Equal(t, note.BookLabel, "js", "note book mismatch")
+
+	_, err = CreateNote(ctx, NewNoteParams{Book: "js", Content: ""})
+	_, ok := errors.Cause(err).(InvalidError)
+	assert.Equal(t, ok, true, "empty content error mismatch")
+}
+
+func TestCreateNote_deletedBook(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+	// a deleted book that has not been synced yet
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "js", 1, true, true)
+
+	// execute
+	note, err := CreateNote(ctx, NewNoteParams{Book: "js", Content: "n1 content", DueOn: 10, RemindOn: 5})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating a note"))
+	}
+
+	// test
+	assert.Equal(t, note.BookUUID, "b1-uuid", "book uuid mismatch")
+	assert.Equal(t, note.DueOn, int64(10), "due_on mismatch")
+	assert.Equal(t, note.RemindOn, int64(5), "remind_on mismatch")
+
+	var deleted, dirty bool
+	database.MustScan(t, "getting b1", db.QueryRow("SELECT deleted, dirty FROM books WHERE uuid = ?", "b1-uuid"), &deleted, &dirty)
+	assert.Equal(t, deleted, false, "the deleted book should be restored")
+	assert.Equal(t, dirty, true, "book dirty mismatch")
+
+	notes, err := ListNotes(db, NotesFilter{Book: "js"})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "listing notes"))
+	}
+	assert.Equal(t, len(notes), 1, "the note should be listed")
+}
+
+func TestUpdateNote(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "js", 1, false, false)
+	database.MustExec(t, "inserting b2", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b2-uuid", "css", 2, false, false)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1 content", 1, 5, false, false)
+
+	var rowID int
+	database.MustScan(t, "getting n1 rowid", db.QueryRow("SELECT rowid FROM notes WHERE uuid = ?", "n1-uuid"), &rowID)
+
+	// execute
+	content := "n1 content edited"
+	book := "css"
+	pinned := true
+	note, err := UpdateNote(ctx, rowID, NoteParams{Content: &content, Book: &book, Pinned: &pinned})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "updating the note"))
+	}
+
+	// test
+	assert.Equal(t, note.Content, "n1 content edited", "content mismatch")
+	assert.Equal(t, note.BookLabel, "css", "book mismatch")
+	assert.Equal(t, note.Pinned, true, "pinned mismatch")
+
+	var usn int
+	var dirty bool
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT usn, dirty FROM notes WHERE uuid = ?", "n1-uuid"), &usn, &dirty)
+	assert.Equal(t, usn, 5, "usn mismatch")
+	assert.Equal(t, dirty, true, "dirty mismatch")
+
+	missing := "missing"
+	_, err = UpdateNote(ctx, rowID, NoteParams{Book: &missing})
+	_, ok := errors.Cause(err).(InvalidError)
+	assert.Equal(t, ok, true, "missing book error mismatch")
+
+	_, err = UpdateNote(ctx, rowID+100, NoteParams{Content: &content})
+	assert.Equal(t, errors.Cause(err), ErrNotFound, "missing note error mismatch")
+}
+
+func TestUpdateNote_schedule(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "js", 1, false, false)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty, due_on, remind_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1 content", 1, 5, false, false, 10, 5)
+
+	var rowID int
+	database.MustScan(t, "getting n1 rowid", db.QueryRow("SELECT rowid FROM notes WHERE uuid = ?", "n1-uuid"), &rowID)
+
+	// execute
+	var none int64
+	note, err := UpdateNote(ctx, rowID, NoteParams{RemindOn: &none})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "updating the note"))
+	}
+
+	// test
+	assert.Equal(t, note.DueOn, int64(10), "due_on mismatch")
+	assert.Equal(t, note.RemindOn, int64(0), "remind_on mismatch")
+
+	book := "js"
+	_, err = UpdateNote(ctx, rowID, NoteParams{Book: &book, RemindOn: &none})
+	assert.Equal(t, errors.Cause(err), ErrNothingChanged, "nothing changed error mismatch")
+}
+
+func TestDeleteNote(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	db := ctx.DB
+	database.MustExec(t, "inserting b1", db, "INSERT INTO books (uuid, label, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?)", "b1-uuid", "js", 1, false, false)
+	database.MustExec(t, "inserting n1", db, "INSERT INTO notes (uuid, book_uuid, body, added_on, usn, deleted, dirty) VALUES (?, ?, ?, ?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1 content", 1, 5, false, false)
+
+	var rowID int
+	database.MustScan(t, "getting n1 rowid", db.QueryRow("SELECT rowid FROM notes WHERE uuid = ?", "n1-uuid"), &rowID)
+
+	// execute
+	note, err := DeleteNote(ctx, rowID)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "deleting the note"))
+	}
+
+	// test
+	assert.Equal(t, note.Content, "n1 content", "returned content mismatch")
+
+	var body string
+	var deleted, dirty bool
+	database.MustScan(t, "getting n1", db.QueryRow("SELECT body, deleted, dirty FROM notes WHERE uuid = ?", "n1-uuid"), &body, &deleted, &dirty)
+	assert.Equal(t, body, "", "body mismatch")
+	assert.Equal(t, deleted, true, "deleted mismatch")
+	assert.Equal(t, dirty, true, "dirty mismatch")
+
+	_, err = GetNote(db, rowID)
+	assert.Equal(t, errors.Cause(err), ErrNotFound, "get error mismatch")
+}
@@ -0,0 +1,47 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package operations provides the operations on the local notes and books that are
+// shared by the commands and the other interfaces, such as the local API server. The
+// changes are marked dirty so that they are synced, and the hooks are run.
+package operations
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned if the note or the book does not exist
+var ErrNotFound = errors.New("not found")
+
+// InvalidError is returned if the given parameters are invalid
+type InvalidError struct {
+	Message string
+}
+
+func (e InvalidError) Error() string {
+	return e.Message
+}
+
+// ErrNothingChanged is returned if an update leaves the note as it is
+var ErrNothingChanged = InvalidError{Message: "Nothing changed"}
+
+func invalidf(format string, v ...interface{}) error {
+	return InvalidError{Message: fmt.Sprintf(format, v...)}
+}
@@ -22,27 +22,52 @@ package output
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/dnote/color"
 	"github.com/dnote/dnote/pkg/cli/database"
 	"github.com/dnote/dnote/pkg/cli/log"
 )
 
 // NoteInfo prints a note information
 func NoteInfo(info database.NoteInfo) {
-	log.Infof("book name: %s\n", info.BookLabel)
-	log.Infof("created at: %s\n", time.Unix(0, info.AddedOn).Format("Jan 2, 2006 3:04pm (MST)"))
+	fmt.Fprint(color.Output, FormatNoteInfo(info, info.Content))
+}
+
+// FormatNoteInfo returns a note information as printed by NoteInfo, showing the
+// given content in place of the note content
+func FormatNoteInfo(info database.NoteInfo, content string) string {
+	var b strings.Builder
+
+	bullet := log.ColorBlue.Sprint("•")
+	field := func(name, value string) {
+		fmt.Fprintf(&b, "  %s %s: %s\n", bullet, name, value)
+	}
+
+	field("book name", info.BookLabel)
+	field("created at", time.Unix(0, info.AddedOn).Format("Jan 2, 2006 3:04pm (MST)"))
 	if info.EditedOn != 0 {
-		log.Infof("updated at: %s\n", time.Unix(0, info.EditedOn).Format("Jan 2, 2006 3:04pm (MST)"))
+		field("updated at", time.Unix(0, info.EditedOn).Format("Jan 2, 2006 3:04pm (MST)"))
 	}
-	log.Infof("note id: %d\n", info.RowID)
-	log.Infof("note uuid: %s\n", info.UUID)
+	if info.DueOn != 0 {
+		field("due at", time.Unix(0, info.DueOn).Format("Jan 2, 2006 3:04pm (MST)"))
+	}
+	if info.RemindOn != 0 {
+		field("remind at", time.Unix(0, info.RemindOn).Format("Jan 2, 2006 3:04pm (MST)"))
+	}
+	field("note id", strconv.Itoa(info.RowID))
+	field("note uuid", info.UUID)
 
-	fmt.Printf("\n------------------------content------------------------\n")
-	fmt.Printf("%s", info.Content)
-	fmt.Printf("\n-------------------------------------------------------\n")
+	b.WriteString("\n------------------------content------------------------\n")
+	b.WriteString(content)
+	b.WriteString("\n-------------------------------------------------------\n")
+
+	return b.String()
 }
 
+// NoteContent prints a note content
 func NoteContent(info database.NoteInfo) {
 	fmt.Printf("%s", info.Content)
 }
@@ -0,0 +1,306 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package snapshot takes and restores the snapshots of the database
+package snapshot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dnote/dnote/pkg/cli/config"
+	"github.com/dnote/dnote/pkg/cli/consts"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ReasonManual is the reason for the snapshots taken by the user
+	ReasonManual = "manual"
+	// ReasonMigration is the reason for the snapshots taken before migrations
+	ReasonMigration = "migration"
+	// ReasonFullSync is the reason for the snapshots taken before full syncs
+	ReasonFullSync = "full-sync"
+	// ReasonBulk is the reason for the snapshots taken before changing many notes at once
+	ReasonBulk = "bulk"
+	// ReasonRestore is the reason for the snapshots taken before restoring another snapshot
+	ReasonRestore = "restore"
+
+	// defaultKeep is the number of snapshots kept if the policy does not specify it
+	defaultKeep = 10
+
+	timeLayout = "20060102-150405"
+	fileExt    = ".db"
+)
+
+// Snapshot is a copy of the database
+type Snapshot struct {
+	Name      string
+	Path      string
+	Reason    string
+	CreatedAt time.Time
+	Size      int64
+
+	// seq orders the snapshots taken in the same second
+	seq int
+}
+
+// Policy is the retention policy for snapshots
+type Policy struct {
+	// Keep is the number of the most recent snapshots to keep
+	Keep int
+	// MaxAge is the age after which snapshots are removed. Zero means no limit.
+	MaxAge time.Duration
+	// Auto is true if snapshots are taken before risky operations
+	Auto bool
+}
+
+// GetPolicy returns the retention policy in the config
+func GetPolicy(ctx context.DnoteCtx) Policy {
+	ret := Policy{Keep: defaultKeep, Auto: true}
+
+	cf, err := config.Read(ctx)
+	if err != nil {
+		log.Debug("reading the backup policy: %s\n", err)
+		return ret
+	}
+
+	if cf.Backup.Keep > 0 {
+		ret.Keep = cf.Backup.Keep
+	}
+	ret.MaxAge = time.Duration(cf.Backup.MaxAge) * 24 * time.Hour
+	ret.Auto = !cf.Backup.DisableAuto
+
+	return ret
+}
+
+// getDir returns the path to the directory containing the snapshots
+func getDir(ctx context.DnoteCtx) string {
+	return filepath.Join(ctx.Paths.Data, consts.DnoteDirName, consts.BackupDirName)
+}
+
+// parseName parses the name of a snapshot file, which is made of the time, an optional
+// sequence for the snapshots taken in the same second, and the reason
+func parseName(name string) (time.Time, int, string, bool) {
+	if !strings.HasSuffix(name, fileExt) {
+		return time.Time{}, 0, "", false
+	}
+
+	parts := strings.SplitN(strings.TrimSuffix(name, fileExt), "_", 2)
+	if len(parts) != 2 || len(parts[0]) < len(timeLayout) {
+		return time.Time{}, 0, "", false
+	}
+
+	t, err := time.Parse(timeLayout, parts[0][:len(timeLayout)])
+	if err != nil {
+		return time.Time{}, 0, "", false
+	}
+
+	seq := 1
+	if rest := parts[0][len(timeLayout):]; rest != "" {
+		seq, err = strconv.Atoi(strings.TrimPrefix(rest, "-"))
+		if err != nil {
+			return time.Time{}, 0, "", false
+		}
+	}
+
+	return t, seq, parts[1], true
+}
+
+// List returns the snapshots from the oldest to the newest
+func List(ctx context.DnoteCtx) ([]Snapshot, error) {
+	dir := getDir(ctx)
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading the backup directory")
+	}
+
+	var ret []Snapshot
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		createdAt, seq, reason, ok := parseName(f.Name())
+		if !ok {
+			continue
+		}
+
+		ret = append(ret, Snapshot{
+			Name:      f.Name(),
+			Path:      filepath.Join(dir, f.Name()),
+			Reason:    reason,
+			CreatedAt: createdAt,
+			Size:      f.Size(),
+			seq:       seq,
+		})
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].CreatedAt.Equal(ret[j].CreatedAt) {
+			return ret[i].seq < ret[j].seq
+		}
+
+		return ret[i].CreatedAt.Before(ret[j].CreatedAt)
+	})
+
+	return ret, nil
+}
+
+// Find returns the snapshot with the given name
+func Find(ctx context.DnoteCtx, name string) (Snapshot, error) {
+	snapshots, err := List(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	for _, s := range snapshots {
+		if s.Name == name || strings.TrimSuffix(s.Name, fileExt) == name {
+			return s, nil
+		}
+	}
+
+	return Snapshot{}, errors.Errorf("snapshot '%s' not found", name)
+}
+
+// Create takes a snapshot of the database
+func Create(ctx context.DnoteCtx, reason string) (Snapshot, error) {
+	dir := getDir(ctx)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Snapshot{}, errors.Wrap(err, "creating the backup directory")
+	}
+
+	now := ctx.Clock.Now().UTC()
+	ts := now.Format(timeLayout)
+
+	// number the snapshots taken in the same second so that they keep their order
+	existing, err := List(ctx)
+	if err != nil {
+		return Snapshot{}, errors.Wrap(err, "listing the snapshots")
+	}
+	seq := 1
+	for _, s := range existing {
+		if s.CreatedAt.Equal(now.Truncate(time.Second)) && s.seq >= seq {
+			seq = s.seq + 1
+		}
+	}
+
+	name := fmt.Sprintf("%s_%s%s", ts, reason, fileExt)
+	if seq > 1 {
+		name = fmt.Sprintf("%s-%d_%s%s", ts, seq, reason, fileExt)
+	}
+	path := filepath.Join(dir, name)
+
+	// write to a temporary file so that a failed backup does not leave a partial snapshot
+	tmpPath := filepath.Join(dir, "."+name)
+	if err := ctx.DB.Backup(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return Snapshot{}, errors.Wrap(err, "copying the database")
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return Snapshot{}, errors.Wrap(err, "saving the snapshot")
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return Snapshot{}, errors.Wrap(err, "getting the snapshot file info")
+	}
+
+	log.Debug("created a snapshot %s\n", path)
+
+	return Snapshot{
+		Name:      name,
+		Path:      path,
+		Reason:    reason,
+		CreatedAt: now.Truncate(time.Second),
+		Size:      fi.Size(),
+		seq:       seq,
+	}, nil
+}
+
+// Auto takes a snapshot before a risky operation, unless automatic snapshots are
+// turned off, and removes the snapshots beyond the retention policy
+func Auto(ctx context.DnoteCtx, reason string) error {
+	policy := GetPolicy(ctx)
+	if !policy.Auto {
+		return nil
+	}
+
+	if _, err := Create(ctx, reason); err != nil {
+		return errors.Wrap(err, "taking a snapshot")
+	}
+
+	if _, err := Prune(ctx, policy); err != nil {
+		return errors.Wrap(err, "removing old snapshots")
+	}
+
+	return nil
+}
+
+// Prune removes the snapshots beyond the given retention policy and returns them
+func Prune(ctx context.DnoteCtx, policy Policy) ([]Snapshot, error) {
+	snapshots, err := List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := ctx.Clock.Now()
+
+	var ret []Snapshot
+	for idx, s := range snapshots {
+		tooMany := policy.Keep > 0 && len(snapshots)-idx > policy.Keep
+		tooOld := policy.MaxAge > 0 && now.Sub(s.CreatedAt) > policy.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		if err := os.Remove(s.Path); err != nil {
+			return ret, errors.Wrapf(err, "removing %s", s.Name)
+		}
+
+		ret = append(ret, s)
+	}
+
+	return ret, nil
+}
+
+// Restore replaces the content of the database with the given snapshot. A snapshot of
+// the current database is taken first so that the restore can be undone.
+func Restore(ctx context.DnoteCtx, s Snapshot) (Snapshot, error) {
+	current, err := Create(ctx, ReasonRestore)
+	if err != nil {
+		return Snapshot{}, errors.Wrap(err, "taking a snapshot of the current database")
+	}
+
+	if err := ctx.DB.Restore(s.Path); err != nil {
+		return Snapshot{}, errors.Wrapf(err, "restoring %s", s.Name)
+	}
+
+	return current, nil
+}
@@ -0,0 +1,220 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/cli/config"
+	"github.com/dnote/dnote/pkg/cli/context"
+	"github.com/dnote/dnote/pkg/cli/database"
+	"github.com/dnote/dnote/pkg/clock"
+	"github.com/pkg/errors"
+)
+
+var paths context.Paths = context.Paths{
+	Home:   "../tmp",
+	Cache:  "../tmp",
+	Config: "../tmp",
+	Data:   "../tmp",
+}
+
+func getNames(snapshots []Snapshot) []string {
+	var ret []string
+	for _, s := range snapshots {
+		ret = append(ret, s.Name)
+	}
+
+	return ret
+}
+
+func TestCreateRestore(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	c := clock.NewMock()
+	c.SetNow(time.Date(2020, time.October, 18, 15, 30, 0, 0, time.UTC))
+	ctx.Clock = c
+
+	database.MustExec(t, "inserting b1", ctx.DB, "INSERT INTO books (uuid, label) VALUES (?, ?)", "b1-uuid", "js")
+	database.MustExec(t, "inserting n1", ctx.DB, "INSERT INTO notes (uuid, book_uuid, body, added_on) VALUES (?, ?, ?, ?)", "n1-uuid", "b1-uuid", "n1 body", 1541108743)
+
+	// execute
+	s1, err := Create(ctx, ReasonManual)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating the first snapshot"))
+	}
+	s2, err := Create(ctx, ReasonFullSync)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "creating the second snapshot"))
+	}
+
+	database.MustExec(t, "removing n1", ctx.DB, "DELETE FROM notes WHERE uuid = ?", "n1-uuid")
+
+	current, err := Restore(ctx, s1)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "restoring"))
+	}
+
+	// test
+	assert.Equal(t, s1.Name, "20201018-153000_manual.db", "first snapshot name mismatch")
+	assert.Equal(t, s2.Name, "20201018-153000-2_full-sync.db", "second snapshot name mismatch")
+	assert.Equal(t, current.Name, "20201018-153000-3_restore.db", "restore snapshot name mismatch")
+
+	var body string
+	database.MustScan(t, "getting the restored note", ctx.DB.QueryRow("SELECT body FROM notes WHERE uuid = ?", "n1-uuid"), &body)
+	assert.Equal(t, body, "n1 body", "restored body mismatch")
+
+	snapshots, err := List(ctx)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "listing"))
+	}
+	assert.DeepEqual(t, getNames(snapshots), []string{s1.Name, s2.Name, current.Name}, "snapshots mismatch")
+	assert.Equal(t, snapshots[1].Reason, ReasonFullSync, "reason mismatch")
+	assert.Equal(t, snapshots[1].CreatedAt, s1.CreatedAt, "created at mismatch")
+
+	found, err := Find(ctx, "20201018-153000-2_full-sync")
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "finding"))
+	}
+	assert.Equal(t, found.Name, s2.Name, "found snapshot mismatch")
+}
+
+func TestRestore_missing(t *testing.T) {
+	// set up
+	ctx := context.InitTestCtx(t, paths, nil)
+	defer context.TeardownTestCtx(t, ctx)
+
+	database.MustExec(t, "inserting b1", ctx.DB, "INSERT INTO books (uuid, label) VALUES (?, ?)", "b1-uuid", "js")
+
+	// execute
+	_, err := Restore(ctx, Snapshot{Name: "missing.db", Path: "../tmp/missing.db"})
+
+	// test
+	assert.NotEqual(t, err, nil, "restoring a missing snapshot should fail")
+
+	var count int
+	database.MustScan(t, "counting books", ctx.DB.QueryRow("SELECT count(*) FROM books"), &count)
+	assert.Equal(t, count, 1, "the database should not change")
+	_, err = os.Stat("../tmp/missing.db")
+	assert.Equal(t, os.IsNotExist(err), true, "the missing snapshot should not be created")
+}
+
+func TestPrune(t *testing.T) {
+	testCases := []struct {
+		policy   Policy
+		expected []string
+	}{
+		{
+			policy:   Policy{Keep: 2},
+			expected: []string{"20201016-000000_bulk.db", "20201018-000000_migration.db"},
+		},
+		{
+			policy:   Policy{Keep: 10, MaxAge: 4 * 24 * time.Hour},
+			expected: []string{"20201015-000000_manual.db", "20201016-000000_bulk.db", "20201018-000000_migration.db"},
+		},
+		{
+			policy:   Policy{Keep: 1, MaxAge: 4 * 24 * time.Hour},
+			expected: []string{"20201018-000000_migration.db"},
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			// set up
+			ctx := context.InitTestCtx(t, paths, nil)
+			defer context.TeardownTestCtx(t, ctx)
+
+			c := clock.NewMock()
+			ctx.Clock = c
+			for _, d := range []int{10, 15, 16, 18} {
+				c.SetNow(time.Date(2020, time.October, d, 0, 0, 0, 0, time.UTC))
+
+				reason := map[int]string{10: ReasonFullSync, 15: ReasonManual, 16: ReasonBulk, 18: ReasonMigration}[d]
+				if _, err := Create(ctx, reason); err != nil {
+					t.Fatal(errors.Wrap(err, "creating a snapshot"))
+				}
+			}
+			c.SetNow(time.Date(2020, time.October, 18, 12, 0, 0, 0, time.UTC))
+
+			// execute
+			if _, err := Prune(ctx, tc.policy); err != nil {
+				t.Fatal(errors.Wrap(err, "executing"))
+			}
+
+			// test
+			snapshots, err := List(ctx)
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "listing"))
+			}
+			assert.DeepEqual(t, getNames(snapshots), tc.expected, "snapshots mismatch")
+		})
+	}
+}
+
+func TestAuto(t *testing.T) {
+	testCases := []struct {
+		backup   config.Backup
+		expected int
+	}{
+		{
+			backup:   config.Backup{},
+			expected: 3,
+		},
+		{
+			backup:   config.Backup{Keep: 2},
+			expected: 2,
+		},
+		{
+			backup:   config.Backup{DisableAuto: true},
+			expected: 0,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			// set up
+			ctx := context.InitTestCtx(t, paths, nil)
+			defer context.TeardownTestCtx(t, ctx)
+
+			if err := config.Write(ctx, config.Config{Backup: tc.backup}); err != nil {
+				t.Fatal(errors.Wrap(err, "writing the config"))
+			}
+
+			// execute
+			for i := 0; i < 3; i++ {
+				if err := Auto(ctx, ReasonBulk); err != nil {
+					t.Fatal(errors.Wrap(err, "executing"))
+				}
+			}
+
+			// test
+			snapshots, err := List(ctx)
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "listing"))
+			}
+			assert.Equal(t, len(snapshots), tc.expected, "snapshot count mismatch")
+		})
+	}
+}
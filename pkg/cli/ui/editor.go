@@ -32,20 +32,25 @@ import (
 	"github.com/pkg/errors"
 )
 
-// GetTmpContentPath returns the path to the temporary file containing
-// content being added or edited
+// GetTmpContentPath creates an empty temporary file for the content being added
+// or edited, and returns its path
 func GetTmpContentPath(ctx context.DnoteCtx) (string, error) {
 	for i := 0; ; i++ {
 		filename := fmt.Sprintf("%s_%d.%s", consts.TmpContentFileBase, i, consts.TmpContentFileExt)
 		candidate := fmt.Sprintf("%s/%s", ctx.Paths.Cache, filename)
 
-		ok, err := utils.FileExists(candidate)
-		if err != nil {
-			return "", errors.Wrapf(err, "checking if file exists at %s", candidate)
+		// create the file exclusively so that concurrent processes do not share it
+		f, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		} else if err != nil {
+			return "", errors.Wrapf(err, "creating a file at %s", candidate)
 		}
-		if !ok {
-			return candidate, nil
+		if err := f.Close(); err != nil {
+			return "", errors.Wrapf(err, "closing the file at %s", candidate)
 		}
+
+		return candidate, nil
 	}
 }
 
@@ -85,9 +90,9 @@ func newEditorCmd(ctx context.DnoteCtx, fpath string) (*exec.Cmd, error) {
 	return exec.Command(args[0], args[1:]...), nil
 }
 
-// GetEditorInput gets the user input by launching a text editor and waiting for
-// it to exit
-func GetEditorInput(ctx context.DnoteCtx, fpath string) (string, error) {
+// EditFile launches a text editor to edit the file at the given path and returns
+// the content of the file after the editor exits. The file is created if it does not exist.
+func EditFile(ctx context.DnoteCtx, fpath string) (string, error) {
 	ok, err := utils.FileExists(fpath)
 	if err != nil {
 		return "", errors.Wrapf(err, "checking if the file exists at %s", fpath)
@@ -127,12 +132,21 @@ func GetEditorInput(ctx context.DnoteCtx, fpath string) (string, error) {
 		return "", errors.Wrap(err, "reading the temporary content file")
 	}
 
+	return string(b), nil
+}
+
+// GetEditorInput gets the user input by launching a text editor and waiting for
+// it to exit. The file is removed afterwards.
+func GetEditorInput(ctx context.DnoteCtx, fpath string) (string, error) {
+	raw, err := EditFile(ctx, fpath)
+	if err != nil {
+		return "", err
+	}
+
 	err = os.Remove(fpath)
 	if err != nil {
 		return "", errors.Wrap(err, "removing the temporary content file")
 	}
 
-	raw := string(b)
-
 	return raw, nil
 }
@@ -0,0 +1,88 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/dnote/color"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// getPager returns the pager in $PAGER, falling back to less if it is installed
+func getPager() string {
+	if pager := strings.TrimSpace(os.Getenv("PAGER")); pager != "" {
+		return pager
+	}
+
+	if _, err := exec.LookPath("less"); err == nil {
+		return "less"
+	}
+
+	return ""
+}
+
+// Page shows the content through the pager in $PAGER or less. The content is
+// printed as is if no pager is found or if the standard output is not a terminal.
+func Page(content string) error {
+	pager := getPager()
+	if pager == "" || !terminal.IsTerminal(int(os.Stdout.Fd())) {
+		if _, err := fmt.Fprint(color.Output, content); err != nil {
+			return errors.Wrap(err, "printing the content")
+		}
+
+		return nil
+	}
+
+	args := strings.Fields(pager)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(content)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// let less show the colors and quit if the content fits on one screen, as git does
+	if os.Getenv("LESS") == "" {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "running the pager '%s'", pager)
+	}
+
+	return nil
+}
+
+// PageLong shows the content through the pager only if it does not fit in the
+// terminal. Otherwise the content is printed as is.
+func PageLong(content string) error {
+	_, height, ok := TerminalSize()
+	if ok && strings.Count(content, "\n") < height {
+		if _, err := fmt.Fprint(color.Output, content); err != nil {
+			return errors.Wrap(err, "printing the content")
+		}
+
+		return nil
+	}
+
+	return Page(content)
+}
@@ -95,3 +95,19 @@ func Confirm(question string, optimistic bool) (bool, error) {
 
 	return confirmed, nil
 }
+
+// TerminalSize returns the width and the height of the terminal attached to the
+// standard output. It returns false if the standard output is not a terminal.
+func TerminalSize() (int, int, bool) {
+	fd := int(os.Stdout.Fd())
+	if !terminal.IsTerminal(fd) {
+		return 0, 0, false
+	}
+
+	width, height, err := terminal.GetSize(fd)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return width, height, true
+}
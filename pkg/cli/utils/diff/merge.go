@@ -0,0 +1,143 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package diff
+
+import (
+	"strings"
+)
+
+// MergeHunk is a region of the result of a three-way merge. If Conflict is true,
+// the region was changed differently on both sides and Local and Remote hold
+// each side's version. Otherwise, Text holds the merged content.
+type MergeHunk struct {
+	Conflict bool
+	Text     string
+	Local    string
+	Remote   string
+}
+
+// splitLines splits the given string into lines, keeping the line breaks
+func splitLines(s string) []string {
+	ret := strings.SplitAfter(s, "\n")
+
+	// SplitAfter yields an empty string after the trailing line break
+	if ret[len(ret)-1] == "" {
+		ret = ret[:len(ret)-1]
+	}
+
+	return ret
+}
+
+// matchLines returns, for each line in base, the index of the matching line in
+// other, or -1 if the line was removed or changed in other.
+func matchLines(base, other string) []int {
+	ret := []int{}
+
+	var otherIdx int
+	for _, d := range Do(base, other) {
+		lines := splitLines(d.Text)
+
+		switch d.Type {
+		case DiffEqual:
+			for range lines {
+				ret = append(ret, otherIdx)
+				otherIdx++
+			}
+		case DiffDelete:
+			for range lines {
+				ret = append(ret, -1)
+			}
+		case DiffInsert:
+			otherIdx += len(lines)
+		}
+	}
+
+	return ret
+}
+
+// appendHunk appends the text to the hunks, joining it with the last hunk
+// if both are free of conflicts
+func appendHunk(hunks []MergeHunk, text string) []MergeHunk {
+	if text == "" {
+		return hunks
+	}
+
+	last := len(hunks) - 1
+	if last >= 0 && !hunks[last].Conflict {
+		hunks[last].Text += text
+		return hunks
+	}
+
+	return append(hunks, MergeHunk{Text: text})
+}
+
+// Merge performs a line-by-line three-way merge of the local and the remote
+// versions of a text, given their common ancestor base. Changes that touch
+// different regions of base are combined, and a conflicting hunk is returned
+// only where both sides changed the same region differently.
+func Merge(base, local, remote string) []MergeHunk {
+	baseLines := splitLines(base)
+	localLines := splitLines(local)
+	remoteLines := splitLines(remote)
+
+	localMatch := matchLines(base, local)
+	remoteMatch := matchLines(base, remote)
+
+	hunks := []MergeHunk{}
+
+	var i, l, r int
+	for i < len(baseLines) || l < len(localLines) || r < len(remoteLines) {
+		// a base line that is kept on both sides
+		if i < len(baseLines) && localMatch[i] == l && remoteMatch[i] == r {
+			hunks = appendHunk(hunks, baseLines[i])
+			i++
+			l++
+			r++
+			continue
+		}
+
+		// find the next base line that is kept on both sides. Everything before
+		// it was changed on at least one side.
+		j := i
+		for j < len(baseLines) && (localMatch[j] == -1 || remoteMatch[j] == -1) {
+			j++
+		}
+
+		nextL, nextR := len(localLines), len(remoteLines)
+		if j < len(baseLines) {
+			nextL, nextR = localMatch[j], remoteMatch[j]
+		}
+
+		b := strings.Join(baseLines[i:j], "")
+		lc := strings.Join(localLines[l:nextL], "")
+		rc := strings.Join(remoteLines[r:nextR], "")
+
+		if lc == b {
+			hunks = appendHunk(hunks, rc)
+		} else if rc == b || lc == rc {
+			hunks = appendHunk(hunks, lc)
+		} else {
+			hunks = append(hunks, MergeHunk{Conflict: true, Local: lc, Remote: rc})
+		}
+
+		i, l, r = j, nextL, nextR
+	}
+
+	return hunks
+}
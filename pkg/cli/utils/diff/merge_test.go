@@ -0,0 +1,154 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package diff
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+)
+
+func TestMerge(t *testing.T) {
+	testCases := []struct {
+		base     string
+		local    string
+		remote   string
+		expected []MergeHunk
+	}{
+		{
+			base:     "",
+			local:    "",
+			remote:   "",
+			expected: []MergeHunk{},
+		},
+		{
+			base:   "foo\nbar\n",
+			local:  "foo\nbar\n",
+			remote: "foo\nbar\n",
+			expected: []MergeHunk{
+				{Text: "foo\nbar\n"},
+			},
+		},
+		{
+			// only local changed
+			base:   "foo\nbar\n",
+			local:  "foo\nbaz\n",
+			remote: "foo\nbar\n",
+			expected: []MergeHunk{
+				{Text: "foo\nbaz\n"},
+			},
+		},
+		{
+			// only remote changed
+			base:   "foo\nbar\n",
+			local:  "foo\nbar\n",
+			remote: "quz\nbar\n",
+			expected: []MergeHunk{
+				{Text: "quz\nbar\n"},
+			},
+		},
+		{
+			// both changed different paragraphs
+			base:   "foo\n\nbar\n\nbaz\n",
+			local:  "foo local\n\nbar\n\nbaz\n",
+			remote: "foo\n\nbar\n\nbaz remote\n",
+			expected: []MergeHunk{
+				{Text: "foo local\n\nbar\n\nbaz remote\n"},
+			},
+		},
+		{
+			// both appended and prepended on different ends
+			base:   "foo\nbar\n",
+			local:  "quz\nfoo\nbar\n",
+			remote: "foo\nbar\nqux\n",
+			expected: []MergeHunk{
+				{Text: "quz\nfoo\nbar\nqux\n"},
+			},
+		},
+		{
+			// both made the same change
+			base:   "foo\nbar\n",
+			local:  "foo\nbaz\n",
+			remote: "foo\nbaz\n",
+			expected: []MergeHunk{
+				{Text: "foo\nbaz\n"},
+			},
+		},
+		{
+			// one side deleted a line that the other side did not touch
+			base:   "foo\nbar\nbaz\nqux\n",
+			local:  "foo\nbaz\nqux\n",
+			remote: "foo\nbar\nbaz\nqux quz\n",
+			expected: []MergeHunk{
+				{Text: "foo\nbaz\nqux quz\n"},
+			},
+		},
+		{
+			// both changed the same line differently
+			base:   "foo\nbar\nbaz\n",
+			local:  "foo\nbar local\nbaz\n",
+			remote: "foo\nbar remote\nbaz\n",
+			expected: []MergeHunk{
+				{Text: "foo\n"},
+				{Conflict: true, Local: "bar local\n", Remote: "bar remote\n"},
+				{Text: "baz\n"},
+			},
+		},
+		{
+			// conflict and a clean change in the same text
+			base:   "foo\n\nbar\n\nbaz\n",
+			local:  "foo local\n\nbar local\n\nbaz\n",
+			remote: "foo\n\nbar remote\n\nbaz remote\n",
+			expected: []MergeHunk{
+				{Text: "foo local\n\n"},
+				{Conflict: true, Local: "bar local\n", Remote: "bar remote\n"},
+				{Text: "\nbaz remote\n"},
+			},
+		},
+		{
+			// one side deleted a line that the other side changed
+			base:   "foo\nbar\nbaz\n",
+			local:  "foo\nbaz\n",
+			remote: "foo\nbar remote\nbaz\n",
+			expected: []MergeHunk{
+				{Text: "foo\n"},
+				{Conflict: true, Local: "", Remote: "bar remote\n"},
+				{Text: "baz\n"},
+			},
+		},
+		{
+			// empty base
+			base:   "",
+			local:  "foo\n",
+			remote: "bar\n",
+			expected: []MergeHunk{
+				{Conflict: true, Local: "foo\n", Remote: "bar\n"},
+			},
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			result := Merge(tc.base, tc.local, tc.remote)
+
+			assert.DeepEqual(t, result, tc.expected, "result mismatch")
+		})
+	}
+}
@@ -0,0 +1,19 @@
+// +build !windows
+
+package utils
+
+import (
+	"syscall"
+)
+
+// ProcessExists returns true if a process with the given pid is running
+func ProcessExists(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	// signal 0 only checks whether the process can be signalled
+	err := syscall.Kill(pid, 0)
+
+	return err == nil || err == syscall.EPERM
+}
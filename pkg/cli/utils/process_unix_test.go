@@ -0,0 +1,23 @@
+// +build !windows
+
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/pkg/errors"
+)
+
+func TestProcessExists(t *testing.T) {
+	assert.Equal(t, ProcessExists(os.Getpid()), true, "current process mismatch")
+	assert.Equal(t, ProcessExists(0), false, "zero pid mismatch")
+
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(errors.Wrap(err, "running a process"))
+	}
+	assert.Equal(t, ProcessExists(cmd.Process.Pid), false, "exited process mismatch")
+}
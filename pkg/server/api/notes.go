@@ -142,6 +142,10 @@ func (a *API) getNotes(w http.ResponseWriter, r *http.Request) {
 		handlers.DoError(w, "No authenticated user found", nil, http.StatusInternalServerError)
 		return
 	}
+	if handlers.CheckNotModified(w, r, handlers.UserETag(user)) {
+		return
+	}
+
 	query := r.URL.Query()
 
 	respondGetNotes(a.App.DB, user.ID, query, w)
@@ -45,6 +45,7 @@ func (a *API) init() error {
 
 func applyMiddleware(h http.HandlerFunc, rateLimit bool) http.Handler {
 	ret := h
+	ret = handlers.Compress(ret)
 	ret = handlers.Logging(ret)
 
 	if rateLimit && os.Getenv("GO_ENV") != "TEST" {
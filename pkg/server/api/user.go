@@ -253,6 +253,7 @@ func (a *API) verifyEmail(w http.ResponseWriter, r *http.Request) {
 type emailPreferernceParams struct {
 	InactiveReminder *bool `json:"inactive_reminder"`
 	ProductUpdate    *bool `json:"product_update"`
+	NoteReminder     *bool `json:"note_reminder"`
 }
 
 func (p emailPreferernceParams) getInactiveReminder() bool {
@@ -271,6 +272,14 @@ func (p emailPreferernceParams) getProductUpdate() bool {
 	return *p.ProductUpdate
 }
 
+func (p emailPreferernceParams) getNoteReminder() bool {
+	if p.NoteReminder == nil {
+		return false
+	}
+
+	return *p.NoteReminder
+}
+
 func (a *API) updateEmailPreference(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(helpers.KeyUser).(database.User)
 	if !ok {
@@ -298,6 +307,9 @@ func (a *API) updateEmailPreference(w http.ResponseWriter, r *http.Request) {
 	if params.ProductUpdate != nil {
 		pref.ProductUpdate = params.getProductUpdate()
 	}
+	if params.NoteReminder != nil {
+		pref.NoteReminder = params.getNoteReminder()
+	}
 
 	if err := tx.Save(&pref).Error; err != nil {
 		tx.Rollback()
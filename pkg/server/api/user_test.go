@@ -447,6 +447,32 @@ func TestUpdateEmailPreference(t *testing.T) {
 		assert.Equal(t, preference.InactiveReminder, true, "preference mismatch")
 	})
 
+	t.Run("note reminder", func(t *testing.T) {
+		defer testutils.ClearData(testutils.DB)
+
+		// Setup
+		server := MustNewServer(t, &app.App{
+			Clock: clock.NewMock(),
+		})
+		defer server.Close()
+
+		u := testutils.SetupUserData()
+		testutils.SetupEmailPreferenceData(u, true)
+
+		// Execute
+		dat := `{"note_reminder": false}`
+		req := testutils.MakeReq(server.URL, "PATCH", "/account/email-preference", dat)
+		res := testutils.HTTPAuthDo(t, req, u)
+
+		// Test
+		assert.StatusCodeEquals(t, res, http.StatusOK, "")
+
+		var preference database.EmailPreference
+		testutils.MustExec(t, testutils.DB.Where("user_id = ?", u.ID).First(&preference), "finding account")
+		assert.Equal(t, preference.NoteReminder, false, "note reminder mismatch")
+		assert.Equal(t, preference.InactiveReminder, true, "inactive reminder mismatch")
+	})
+
 	t.Run("with an unused token", func(t *testing.T) {
 		defer testutils.ClearData(testutils.DB)
 
@@ -684,6 +710,7 @@ func TestGetEmailPreference(t *testing.T) {
 	expected := presenters.EmailPreference{
 		InactiveReminder: pref.InactiveReminder,
 		ProductUpdate:    pref.ProductUpdate,
+		NoteReminder:     pref.NoteReminder,
 		CreatedAt:        presenters.FormatTS(pref.CreatedAt),
 		UpdatedAt:        presenters.FormatTS(pref.UpdatedAt),
 	}
@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/dnote/dnote/pkg/server/app"
 	"github.com/dnote/dnote/pkg/server/database"
 	"github.com/dnote/dnote/pkg/server/handlers"
 	"github.com/dnote/dnote/pkg/server/helpers"
@@ -34,7 +35,9 @@ import (
 )
 
 type createBookPayload struct {
-	Name string `json:"name"`
+	Name     string `json:"name"`
+	Pinned   bool   `json:"pinned"`
+	Archived bool   `json:"archived"`
 }
 
 // CreateBookResp is the response from create book api
@@ -83,7 +86,7 @@ func (a *API) CreateBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	book, err := a.App.CreateBook(user, params.Name)
+	book, err := a.App.CreateBook(user, params.Name, params.Pinned, params.Archived)
 	if err != nil {
 		handlers.DoError(w, "inserting book", err, http.StatusInternalServerError)
 	}
@@ -136,6 +139,10 @@ func (a *API) GetBooks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if handlers.CheckNotModified(w, r, handlers.UserETag(user)) {
+		return
+	}
+
 	query := r.URL.Query()
 
 	respondWithBooks(a.App.DB, user.ID, query, w)
@@ -168,7 +175,9 @@ func (a *API) GetBook(w http.ResponseWriter, r *http.Request) {
 }
 
 type updateBookPayload struct {
-	Name *string `json:"name"`
+	Name     *string `json:"name"`
+	Pinned   *bool   `json:"pinned"`
+	Archived *bool   `json:"archived"`
 }
 
 // UpdateBookResp is the response from create book api
@@ -201,7 +210,11 @@ func (a *API) UpdateBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	book, err = a.App.UpdateBook(tx, user, book, params.Name)
+	book, err = a.App.UpdateBook(tx, user, book, app.UpdateBookParams{
+		Label:    params.Name,
+		Pinned:   params.Pinned,
+		Archived: params.Archived,
+	})
 	if err != nil {
 		tx.Rollback()
 		handlers.DoError(w, "updating a book", err, http.StatusInternalServerError)
@@ -36,6 +36,10 @@ type updateNotePayload struct {
 	BookUUID *string `json:"book_uuid"`
 	Content  *string `json:"content"`
 	Public   *bool   `json:"public"`
+	DueOn    *int64  `json:"due_on"`
+	RemindOn *int64  `json:"remind_on"`
+	Pinned   *bool   `json:"pinned"`
+	Archived *bool   `json:"archived"`
 }
 
 type updateNoteResp struct {
@@ -44,7 +48,7 @@ type updateNoteResp struct {
 }
 
 func validateUpdateNotePayload(p updateNotePayload) bool {
-	return p.BookUUID != nil || p.Content != nil || p.Public != nil
+	return p.BookUUID != nil || p.Content != nil || p.Public != nil || p.DueOn != nil || p.RemindOn != nil || p.Pinned != nil || p.Archived != nil
 }
 
 // UpdateNote updates note
@@ -82,6 +86,10 @@ func (a *API) UpdateNote(w http.ResponseWriter, r *http.Request) {
 		BookUUID: params.BookUUID,
 		Content:  params.Content,
 		Public:   params.Public,
+		DueOn:    params.DueOn,
+		RemindOn: params.RemindOn,
+		Pinned:   params.Pinned,
+		Archived: params.Archived,
 	})
 	if err != nil {
 		tx.Rollback()
@@ -154,6 +162,10 @@ type createNotePayload struct {
 	Content  string `json:"content"`
 	AddedOn  *int64 `json:"added_on"`
 	EditedOn *int64 `json:"edited_on"`
+	DueOn    int64  `json:"due_on"`
+	RemindOn int64  `json:"remind_on"`
+	Pinned   bool   `json:"pinned"`
+	Archived bool   `json:"archived"`
 }
 
 func validateCreateNotePayload(p createNotePayload) error {
@@ -197,7 +209,7 @@ func (a *API) CreateNote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := getClientType(r)
-	note, err := a.App.CreateNote(user, params.BookUUID, params.Content, params.AddedOn, params.EditedOn, false, client)
+	note, err := a.App.CreateNote(user, params.BookUUID, params.Content, params.AddedOn, params.EditedOn, params.DueOn, params.RemindOn, params.Pinned, params.Archived, false, client)
 	if err != nil {
 		handlers.DoError(w, "creating note", err, http.StatusInternalServerError)
 		return
@@ -52,7 +52,7 @@ func TestCreateNote(t *testing.T) {
 	testutils.MustExec(t, testutils.DB.Save(&b1), "preparing b1")
 
 	// Execute
-	dat := fmt.Sprintf(`{"book_uuid": "%s", "content": "note content"}`, b1.UUID)
+	dat := fmt.Sprintf(`{"book_uuid": "%s", "content": "note content", "due_on": 1541108743000000000, "remind_on": 1541008743000000000}`, b1.UUID)
 	req := testutils.MakeReq(server.URL, "POST", "/v3/notes", dat)
 	res := testutils.HTTPAuthDo(t, req, user)
 
@@ -81,6 +81,8 @@ func TestCreateNote(t *testing.T) {
 	assert.Equal(t, noteRecord.BookUUID, b1.UUID, "note book_uuid mismatch")
 	assert.Equal(t, noteRecord.Body, "note content", "note content mismatch")
 	assert.Equal(t, noteRecord.USN, 102, "note usn mismatch")
+	assert.Equal(t, noteRecord.DueOn, int64(1541108743000000000), "note due_on mismatch")
+	assert.Equal(t, noteRecord.RemindOn, int64(1541008743000000000), "note remind_on mismatch")
 }
 
 func TestUpdateNote(t *testing.T) {
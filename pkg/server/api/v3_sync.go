@@ -22,7 +22,6 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"sort"
 	"strconv"
 	"time"
 
@@ -37,6 +36,18 @@ import (
 // before which clients must perform a full-sync rather than incremental sync.
 const fullSyncBefore = 0
 
+const (
+	// fragmentLimit is the maximum number of items in a sync fragment
+	fragmentLimit = 100
+	// streamingFragmentLimit is the maximum number of items in a sync fragment
+	// for the clients that support streaming
+	streamingFragmentLimit = 1000
+)
+
+// SyncStreamingHeader is the request header with which a client advertises that it
+// applies sync fragments as they arrive rather than buffering them
+const SyncStreamingHeader = "X-Sync-Streaming"
+
 // SyncFragment contains a piece of information about the server's state.
 // It is used to transfer the server's state to the client gradually without having to
 // transfer the whole state at once.
@@ -60,8 +71,12 @@ type SyncFragNote struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	AddedOn   int64     `json:"added_on"`
 	EditedOn  int64     `json:"edited_on"`
+	DueOn     int64     `json:"due_on"`
+	RemindOn  int64     `json:"remind_on"`
 	Body      string    `json:"content"`
 	Public    bool      `json:"public"`
+	Pinned    bool      `json:"pinned"`
+	Archived  bool      `json:"archived"`
 	Deleted   bool      `json:"deleted"`
 }
 
@@ -74,8 +89,12 @@ func NewFragNote(note database.Note) SyncFragNote {
 		UpdatedAt: note.UpdatedAt,
 		AddedOn:   note.AddedOn,
 		EditedOn:  note.EditedOn,
+		DueOn:     note.DueOn,
+		RemindOn:  note.RemindOn,
 		Body:      note.Body,
 		Public:    note.Public,
+		Pinned:    note.Pinned,
+		Archived:  note.Archived,
 		Deleted:   note.Deleted,
 		BookUUID:  note.BookUUID,
 	}
@@ -90,6 +109,8 @@ type SyncFragBook struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	AddedOn   int64     `json:"added_on"`
 	Label     string    `json:"label"`
+	Pinned    bool      `json:"pinned"`
+	Archived  bool      `json:"archived"`
 	Deleted   bool      `json:"deleted"`
 }
 
@@ -102,15 +123,12 @@ func NewFragBook(book database.Book) SyncFragBook {
 		UpdatedAt: book.UpdatedAt,
 		AddedOn:   book.AddedOn,
 		Label:     book.Label,
+		Pinned:    book.Pinned,
+		Archived:  book.Archived,
 		Deleted:   book.Deleted,
 	}
 }
 
-type usnItem struct {
-	usn int
-	val interface{}
-}
-
 type queryParamError struct {
 	key     string
 	value   string
@@ -121,36 +139,70 @@ func (e *queryParamError) Error() string {
 	return fmt.Sprintf("invalid query param %s=%s. %s", e.key, e.value, e.message)
 }
 
-func (a *API) newFragment(userID, userMaxUSN, afterUSN, limit int) (SyncFragment, error) {
+// findFragNotes finds the notes with the given uuids for the user, keyed by uuid
+func (a *API) findFragNotes(userID int, uuids []string) (map[string]database.Note, error) {
+	ret := map[string]database.Note{}
+	if len(uuids) == 0 {
+		return ret, nil
+	}
+
 	var notes []database.Note
-	if err := a.App.DB.Where("user_id = ? AND usn > ? AND usn <= ?", userID, afterUSN, userMaxUSN).Order("usn ASC").Limit(limit).Find(&notes).Error; err != nil {
-		return SyncFragment{}, nil
+	if err := a.App.DB.Where("user_id = ? AND uuid IN (?)", userID, uuids).Find(&notes).Error; err != nil {
+		return ret, errors.Wrap(err, "finding notes")
 	}
-	var books []database.Book
-	if err := a.App.DB.Where("user_id = ? AND usn > ? AND usn <= ?", userID, afterUSN, userMaxUSN).Order("usn ASC").Limit(limit).Find(&books).Error; err != nil {
-		return SyncFragment{}, nil
+	for _, note := range notes {
+		ret[note.UUID] = note
 	}
 
-	var items []usnItem
-	for _, note := range notes {
-		i := usnItem{
-			usn: note.USN,
-			val: note,
-		}
-		items = append(items, i)
+	return ret, nil
+}
+
+// findFragBooks finds the books with the given uuids for the user, keyed by uuid
+func (a *API) findFragBooks(userID int, uuids []string) (map[string]database.Book, error) {
+	ret := map[string]database.Book{}
+	if len(uuids) == 0 {
+		return ret, nil
+	}
+
+	var books []database.Book
+	if err := a.App.DB.Where("user_id = ? AND uuid IN (?)", userID, uuids).Find(&books).Error; err != nil {
+		return ret, errors.Wrap(err, "finding books")
 	}
 	for _, book := range books {
-		i := usnItem{
-			usn: book.USN,
-			val: book,
+		ret[book.UUID] = book
+	}
+
+	return ret, nil
+}
+
+// newFragment makes a sync fragment from the change journal entries after the given usn.
+// An entity is included only at its latest change, so that superseded entries are skipped.
+func (a *API) newFragment(userID, userMaxUSN, afterUSN, limit int) (SyncFragment, error) {
+	var changes []database.Change
+	if err := a.App.DB.Where("user_id = ? AND usn > ? AND usn <= ?", userID, afterUSN, userMaxUSN).Order("usn ASC").Limit(limit).Find(&changes).Error; err != nil {
+		return SyncFragment{}, errors.Wrap(err, "finding changes")
+	}
+
+	var noteUUIDs, bookUUIDs []string
+	for _, change := range changes {
+		switch change.EntityType {
+		case database.EntityTypeNote:
+			noteUUIDs = append(noteUUIDs, change.EntityUUID)
+		case database.EntityTypeBook:
+			bookUUIDs = append(bookUUIDs, change.EntityUUID)
+		default:
+			return SyncFragment{}, errors.Errorf("unknown entity type %s", change.EntityType)
 		}
-		items = append(items, i)
 	}
 
-	// order by usn in ascending order
-	sort.Slice(items, func(i, j int) bool {
-		return items[i].usn < items[j].usn
-	})
+	notes, err := a.findFragNotes(userID, noteUUIDs)
+	if err != nil {
+		return SyncFragment{}, errors.Wrap(err, "getting notes")
+	}
+	books, err := a.findFragBooks(userID, bookUUIDs)
+	if err != nil {
+		return SyncFragment{}, errors.Wrap(err, "getting books")
+	}
 
 	fragNotes := []SyncFragNote{}
 	fragBooks := []SyncFragBook{}
@@ -158,34 +210,32 @@ func (a *API) newFragment(userID, userMaxUSN, afterUSN, limit int) (SyncFragment
 	fragExpungedBooks := []string{}
 
 	fragMaxUSN := 0
-	for i := 0; i < limit; i++ {
-		if i > len(items)-1 {
-			break
-		}
-
-		item := items[i]
-
-		fragMaxUSN = item.usn
-
-		switch v := item.val.(type) {
-		case database.Note:
-			note := item.val.(database.Note)
+	for _, change := range changes {
+		fragMaxUSN = change.USN
+
+		switch change.EntityType {
+		case database.EntityTypeNote:
+			note, ok := notes[change.EntityUUID]
+			if !ok || note.USN != change.USN {
+				continue
+			}
 
 			if note.Deleted {
 				fragExpungedNotes = append(fragExpungedNotes, note.UUID)
 			} else {
 				fragNotes = append(fragNotes, NewFragNote(note))
 			}
-		case database.Book:
-			book := item.val.(database.Book)
+		case database.EntityTypeBook:
+			book, ok := books[change.EntityUUID]
+			if !ok || book.USN != change.USN {
+				continue
+			}
 
 			if book.Deleted {
 				fragExpungedBooks = append(fragExpungedBooks, book.UUID)
 			} else {
 				fragBooks = append(fragBooks, NewFragBook(book))
 			}
-		default:
-			return SyncFragment{}, errors.Errorf("unknown internal item type %s", v)
 		}
 	}
 
@@ -202,7 +252,7 @@ func (a *API) newFragment(userID, userMaxUSN, afterUSN, limit int) (SyncFragment
 	return ret, nil
 }
 
-func parseGetSyncFragmentQuery(q url.Values) (afterUSN, limit int, err error) {
+func parseGetSyncFragmentQuery(q url.Values, maxLimit int) (afterUSN, limit int, err error) {
 	afterUSNStr := q.Get("after_usn")
 	limitStr := q.Get("limit")
 
@@ -225,23 +275,34 @@ func parseGetSyncFragmentQuery(q url.Values) (afterUSN, limit int, err error) {
 			return
 		}
 
-		if l > 100 {
+		if l > maxLimit {
 			err = &queryParamError{
 				key:     "limit",
 				value:   limitStr,
-				message: "maximum value is 100",
+				message: fmt.Sprintf("maximum value is %d", maxLimit),
 			}
 			return
 		}
 
 		limit = l
 	} else {
-		limit = 100
+		limit = maxLimit
 	}
 
 	return
 }
 
+// getFragmentLimit returns the maximum number of items in a sync fragment for the client
+// that made the request. Clients that apply the fragments as they arrive advertise it with
+// a header, and can receive larger fragments.
+func getFragmentLimit(r *http.Request) int {
+	if r.Header.Get(SyncStreamingHeader) == "true" {
+		return streamingFragmentLimit
+	}
+
+	return fragmentLimit
+}
+
 // GetSyncFragmentResp represents a response from GetSyncFragment handler
 type GetSyncFragmentResp struct {
 	Fragment SyncFragment `json:"fragment"`
@@ -255,7 +316,7 @@ func (a *API) GetSyncFragment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	afterUSN, limit, err := parseGetSyncFragmentQuery(r.URL.Query())
+	afterUSN, limit, err := parseGetSyncFragmentQuery(r.URL.Query(), getFragmentLimit(r))
 	if err != nil {
 		handlers.DoError(w, "parsing query params", err, http.StatusInternalServerError)
 		return
@@ -288,6 +349,12 @@ func (a *API) GetSyncState(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the state changes only when max_usn changes, apart from the current time, which
+	// the client reads from the Date header of a 304 response
+	if handlers.CheckNotModified(w, r, handlers.UserETag(user)) {
+		return
+	}
+
 	response := GetSyncStateResp{
 		FullSyncBefore: fullSyncBefore,
 		MaxUSN:         user.MaxUSN,
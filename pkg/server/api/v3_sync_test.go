@@ -25,47 +25,56 @@ import (
 	"testing"
 
 	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/server/database"
+	"github.com/dnote/dnote/pkg/server/testutils"
 	"github.com/pkg/errors"
 )
 
 func TestParseGetSyncFragmentQuery(t *testing.T) {
 	testCases := []struct {
 		input    string
+		maxLimit int
 		afterUSN int
 		limit    int
 		err      error
 	}{
 		{
+			maxLimit: 100,
 			input:    `after_usn=50&limit=50`,
 			afterUSN: 50,
 			limit:    50,
 			err:      nil,
 		},
 		{
+			maxLimit: 100,
 			input:    `limit=50`,
 			afterUSN: 0,
 			limit:    50,
 			err:      nil,
 		},
 		{
+			maxLimit: 100,
 			input:    `after_usn=50`,
 			afterUSN: 50,
 			limit:    100,
 			err:      nil,
 		},
 		{
+			maxLimit: 100,
 			input:    `after_usn=50&limit=100`,
 			afterUSN: 50,
 			limit:    100,
 			err:      nil,
 		},
 		{
+			maxLimit: 100,
 			input:    "",
 			afterUSN: 0,
 			limit:    100,
 			err:      nil,
 		},
 		{
+			maxLimit: 100,
 			input:    "limit=101",
 			afterUSN: 0,
 			limit:    0,
@@ -75,6 +84,31 @@ func TestParseGetSyncFragmentQuery(t *testing.T) {
 				message: "maximum value is 100",
 			},
 		},
+		{
+			maxLimit: 1000,
+			input:    "",
+			afterUSN: 0,
+			limit:    1000,
+			err:      nil,
+		},
+		{
+			maxLimit: 1000,
+			input:    "limit=101",
+			afterUSN: 0,
+			limit:    101,
+			err:      nil,
+		},
+		{
+			maxLimit: 1000,
+			input:    "limit=1001",
+			afterUSN: 0,
+			limit:    0,
+			err: &queryParamError{
+				key:     "limit",
+				value:   "1001",
+				message: "maximum value is 1000",
+			},
+		},
 	}
 
 	for idx, tc := range testCases {
@@ -83,7 +117,7 @@ func TestParseGetSyncFragmentQuery(t *testing.T) {
 			t.Fatal(errors.Wrap(err, "parsing test input"))
 		}
 
-		afterUSN, limit, err := parseGetSyncFragmentQuery(q)
+		afterUSN, limit, err := parseGetSyncFragmentQuery(q, tc.maxLimit)
 		ok := reflect.DeepEqual(err, tc.err)
 		assert.Equal(t, ok, true, fmt.Sprintf("err mismatch for test case %d. Expected: %+v. Got: %+v", idx, tc.err, err))
 
@@ -91,3 +125,78 @@ func TestParseGetSyncFragmentQuery(t *testing.T) {
 		assert.Equal(t, limit, tc.limit, fmt.Sprintf("limit mismatch for test case %d", idx))
 	}
 }
+
+func TestNewFragment(t *testing.T) {
+	defer testutils.ClearData(testutils.DB)
+
+	user := testutils.SetupUserData()
+	testutils.MustExec(t, testutils.DB.Model(&user).Update("max_usn", 5), "preparing user max_usn")
+	anotherUser := testutils.SetupUserData()
+	testutils.MustExec(t, testutils.DB.Model(&anotherUser).Update("max_usn", 1), "preparing another user max_usn")
+
+	b1 := database.Book{UserID: user.ID, Label: "b1", USN: 1}
+	testutils.MustExec(t, testutils.DB.Save(&b1), "preparing b1")
+	b2 := database.Book{UserID: user.ID, Label: "b2", USN: 5}
+	testutils.MustExec(t, testutils.DB.Save(&b2), "preparing b2")
+	b3 := database.Book{UserID: anotherUser.ID, Label: "b3", USN: 1}
+	testutils.MustExec(t, testutils.DB.Save(&b3), "preparing b3")
+	n1 := database.Note{UserID: user.ID, BookUUID: b1.UUID, Body: "n1 content", USN: 4}
+	testutils.MustExec(t, testutils.DB.Save(&n1), "preparing n1")
+	n2 := database.Note{UserID: user.ID, BookUUID: b1.UUID, Deleted: true, USN: 3}
+	testutils.MustExec(t, testutils.DB.Save(&n2), "preparing n2")
+
+	changes := []database.Change{
+		{UserID: user.ID, USN: 1, EntityType: database.EntityTypeBook, EntityUUID: b1.UUID, Operation: database.ChangeOperationCreate},
+		{UserID: user.ID, USN: 2, EntityType: database.EntityTypeNote, EntityUUID: n1.UUID, Operation: database.ChangeOperationCreate},
+		{UserID: user.ID, USN: 3, EntityType: database.EntityTypeNote, EntityUUID: n2.UUID, Operation: database.ChangeOperationDelete},
+		{UserID: user.ID, USN: 4, EntityType: database.EntityTypeNote, EntityUUID: n1.UUID, Operation: database.ChangeOperationUpdate},
+		{UserID: user.ID, USN: 5, EntityType: database.EntityTypeBook, EntityUUID: b2.UUID, Operation: database.ChangeOperationCreate},
+		{UserID: anotherUser.ID, USN: 1, EntityType: database.EntityTypeBook, EntityUUID: b3.UUID, Operation: database.ChangeOperationCreate},
+	}
+	for idx, change := range changes {
+		testutils.MustExec(t, testutils.DB.Save(&change), fmt.Sprintf("preparing change %d", idx))
+	}
+
+	a := NewTestAPI(nil)
+
+	t.Run("first fragment", func(t *testing.T) {
+		frag, err := a.newFragment(user.ID, 5, 0, 3)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "executing"))
+		}
+
+		assert.Equal(t, frag.FragMaxUSN, 3, "FragMaxUSN mismatch")
+		assert.Equal(t, frag.UserMaxUSN, 5, "UserMaxUSN mismatch")
+		assert.Equal(t, len(frag.Books), 1, "books length mismatch")
+		assert.Equal(t, frag.Books[0].UUID, b1.UUID, "book uuid mismatch")
+		// n1 is superseded by a later change
+		assert.Equal(t, len(frag.Notes), 0, "notes length mismatch")
+		assert.DeepEqual(t, frag.ExpungedNotes, []string{n2.UUID}, "expunged notes mismatch")
+		assert.DeepEqual(t, frag.ExpungedBooks, []string{}, "expunged books mismatch")
+	})
+
+	t.Run("second fragment", func(t *testing.T) {
+		frag, err := a.newFragment(user.ID, 5, 3, 100)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "executing"))
+		}
+
+		assert.Equal(t, frag.FragMaxUSN, 5, "FragMaxUSN mismatch")
+		assert.Equal(t, len(frag.Notes), 1, "notes length mismatch")
+		assert.Equal(t, frag.Notes[0].UUID, n1.UUID, "note uuid mismatch")
+		assert.Equal(t, frag.Notes[0].Body, "n1 content", "note body mismatch")
+		assert.Equal(t, len(frag.Books), 1, "books length mismatch")
+		assert.Equal(t, frag.Books[0].UUID, b2.UUID, "book uuid mismatch")
+	})
+
+	t.Run("no more changes", func(t *testing.T) {
+		frag, err := a.newFragment(user.ID, 5, 5, 100)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "executing"))
+		}
+
+		assert.Equal(t, frag.FragMaxUSN, 0, "FragMaxUSN mismatch")
+		assert.Equal(t, len(frag.Notes), 0, "notes length mismatch")
+		assert.Equal(t, len(frag.Books), 0, "books length mismatch")
+	})
+}
@@ -26,7 +26,7 @@ import (
 )
 
 // CreateBook creates a book with the next usn and updates the user's max_usn
-func (a *App) CreateBook(user database.User, name string) (database.Book, error) {
+func (a *App) CreateBook(user database.User, name string, pinned, archived bool) (database.Book, error) {
 	tx := a.DB.Begin()
 
 	nextUSN, err := incrementUserUSN(tx, user.ID)
@@ -45,6 +45,8 @@ func (a *App) CreateBook(user database.User, name string) (database.Book, error)
 		UserID:    user.ID,
 		Label:     name,
 		AddedOn:   a.Clock.Now().UnixNano(),
+		Pinned:    pinned,
+		Archived:  archived,
 		USN:       nextUSN,
 		Encrypted: false,
 	}
@@ -52,6 +54,10 @@ func (a *App) CreateBook(user database.User, name string) (database.Book, error)
 		tx.Rollback()
 		return book, errors.Wrap(err, "inserting book")
 	}
+	if err := recordChange(tx, user.ID, nextUSN, database.EntityTypeBook, book.UUID, database.ChangeOperationCreate); err != nil {
+		tx.Rollback()
+		return book, errors.Wrap(err, "recording the change")
+	}
 
 	tx.Commit()
 
@@ -77,12 +83,22 @@ func (a *App) DeleteBook(tx *gorm.DB, user database.User, book database.Book) (d
 		}).Error; err != nil {
 		return book, errors.Wrap(err, "deleting book")
 	}
+	if err := recordChange(tx, user.ID, nextUSN, database.EntityTypeBook, book.UUID, database.ChangeOperationDelete); err != nil {
+		return book, errors.Wrap(err, "recording the change")
+	}
 
 	return book, nil
 }
 
+// UpdateBookParams is the parameters for updating a book
+type UpdateBookParams struct {
+	Label    *string
+	Pinned   *bool
+	Archived *bool
+}
+
 // UpdateBook updaates the book, the usn and the user's max_usn
-func (a *App) UpdateBook(tx *gorm.DB, user database.User, book database.Book, label *string) (database.Book, error) {
+func (a *App) UpdateBook(tx *gorm.DB, user database.User, book database.Book, p UpdateBookParams) (database.Book, error) {
 	if user.ID != book.UserID {
 		return book, errors.New("Not allowed")
 	}
@@ -92,8 +108,14 @@ func (a *App) UpdateBook(tx *gorm.DB, user database.User, book database.Book, la
 		return book, errors.Wrap(err, "incrementing user max_usn")
 	}
 
-	if label != nil {
-		book.Label = *label
+	if p.Label != nil {
+		book.Label = *p.Label
+	}
+	if p.Pinned != nil {
+		book.Pinned = *p.Pinned
+	}
+	if p.Archived != nil {
+		book.Archived = *p.Archived
 	}
 
 	book.USN = nextUSN
@@ -105,6 +127,9 @@ func (a *App) UpdateBook(tx *gorm.DB, user database.User, book database.Book, la
 	if err := tx.Save(&book).Error; err != nil {
 		return book, errors.Wrap(err, "updating the book")
 	}
+	if err := recordChange(tx, user.ID, nextUSN, database.EntityTypeBook, book.UUID, database.ChangeOperationUpdate); err != nil {
+		return book, errors.Wrap(err, "recording the change")
+	}
 
 	return book, nil
 }
@@ -66,7 +66,7 @@ func TestCreateBook(t *testing.T) {
 				Clock: clock.NewMock(),
 			})
 
-			book, err := a.CreateBook(user, tc.label)
+			book, err := a.CreateBook(user, tc.label, false, false)
 			if err != nil {
 				t.Fatal(errors.Wrap(err, "creating book"))
 			}
@@ -166,15 +166,20 @@ func TestDeleteBook(t *testing.T) {
 
 func TestUpdateBook(t *testing.T) {
 	js := "js"
+	truthy := true
 
 	testCases := []struct {
-		usn             int
-		userUSN         int
-		label           string
-		payloadLabel    *string
-		expectedUSN     int
-		expectedUserUSN int
-		expectedLabel   string
+		usn              int
+		userUSN          int
+		label            string
+		payloadLabel     *string
+		payloadPinned    *bool
+		payloadArchived  *bool
+		expectedUSN      int
+		expectedUserUSN  int
+		expectedLabel    string
+		expectedPinned   bool
+		expectedArchived bool
 	}{
 		{
 			userUSN:         1,
@@ -194,6 +199,18 @@ func TestUpdateBook(t *testing.T) {
 			expectedUserUSN: 9,
 			expectedLabel:   "js",
 		},
+		{
+			userUSN:          4,
+			usn:              2,
+			label:            "js",
+			payloadPinned:    &truthy,
+			payloadArchived:  &truthy,
+			expectedUSN:      5,
+			expectedUserUSN:  5,
+			expectedLabel:    "js",
+			expectedPinned:   true,
+			expectedArchived: true,
+		},
 	}
 
 	for idx, tc := range testCases {
@@ -215,7 +232,11 @@ func TestUpdateBook(t *testing.T) {
 			})
 
 			tx := testutils.DB.Begin()
-			book, err := a.UpdateBook(tx, user, b, tc.payloadLabel)
+			book, err := a.UpdateBook(tx, user, b, UpdateBookParams{
+				Label:    tc.payloadLabel,
+				Pinned:   tc.payloadPinned,
+				Archived: tc.payloadArchived,
+			})
 			if err != nil {
 				tx.Rollback()
 				t.Fatal(errors.Wrap(err, "updating book"))
@@ -236,6 +257,8 @@ func TestUpdateBook(t *testing.T) {
 			assert.Equal(t, bookRecord.Label, tc.expectedLabel, "book label mismatch")
 			assert.Equal(t, bookRecord.USN, tc.expectedUSN, "book label mismatch")
 			assert.Equal(t, bookRecord.EditedOn, c.Now().UnixNano(), "book edited_on mismatch")
+			assert.Equal(t, bookRecord.Pinned, tc.expectedPinned, "book pinned mismatch")
+			assert.Equal(t, bookRecord.Archived, tc.expectedArchived, "book archived mismatch")
 			assert.Equal(t, book.UserID, user.ID, "returned book user_id mismatch")
 			assert.Equal(t, book.Label, tc.expectedLabel, "returned book label mismatch")
 			assert.Equal(t, book.USN, tc.expectedUSN, "returned book usn mismatch")
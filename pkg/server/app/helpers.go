@@ -38,3 +38,20 @@ func incrementUserUSN(tx *gorm.DB, userID int) (int, error) {
 
 	return user.MaxUSN, nil
 }
+
+// recordChange records a change to an entity in the change journal. It must be called in the
+// same transaction as incrementUserUSN so that the journal stays consistent with the entities.
+func recordChange(tx *gorm.DB, userID, usn int, entityType, entityUUID, operation string) error {
+	change := database.Change{
+		UserID:     userID,
+		USN:        usn,
+		EntityType: entityType,
+		EntityUUID: entityUUID,
+		Operation:  operation,
+	}
+	if err := tx.Create(&change).Error; err != nil {
+		return errors.Wrapf(err, "recording the change to %s %s", entityType, entityUUID)
+	}
+
+	return nil
+}
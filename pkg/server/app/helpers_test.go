@@ -68,3 +68,38 @@ func TestIncremenetUserUSN(t *testing.T) {
 		}()
 	}
 }
+
+func TestRecordChange(t *testing.T) {
+	defer testutils.ClearData(testutils.DB)
+
+	user := testutils.SetupUserData()
+	b1 := database.Book{UserID: user.ID, Label: "js", USN: 3}
+	testutils.MustExec(t, testutils.DB.Save(&b1), "preparing b1")
+
+	// execute
+	tx := testutils.DB.Begin()
+	if err := recordChange(tx, user.ID, 3, database.EntityTypeBook, b1.UUID, database.ChangeOperationUpdate); err != nil {
+		tx.Rollback()
+		t.Fatal(errors.Wrap(err, "recording the change"))
+	}
+	tx.Commit()
+
+	// recording another change with the same usn should fail
+	tx = testutils.DB.Begin()
+	err := recordChange(tx, user.ID, 3, database.EntityTypeBook, b1.UUID, database.ChangeOperationDelete)
+	tx.Rollback()
+	assert.NotEqual(t, err, nil, "duplicate usn should not be allowed")
+
+	// test
+	var changeCount int
+	var changeRecord database.Change
+	testutils.MustExec(t, testutils.DB.Model(&database.Change{}).Count(&changeCount), "counting changes")
+	testutils.MustExec(t, testutils.DB.First(&changeRecord), "finding change")
+
+	assert.Equal(t, changeCount, 1, "change count mismatch")
+	assert.Equal(t, changeRecord.UserID, user.ID, "UserID mismatch")
+	assert.Equal(t, changeRecord.USN, 3, "USN mismatch")
+	assert.Equal(t, changeRecord.EntityType, database.EntityTypeBook, "EntityType mismatch")
+	assert.Equal(t, changeRecord.EntityUUID, b1.UUID, "EntityUUID mismatch")
+	assert.Equal(t, changeRecord.Operation, database.ChangeOperationUpdate, "Operation mismatch")
+}
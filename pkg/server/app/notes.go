@@ -27,7 +27,7 @@ import (
 
 // CreateNote creates a note with the next usn and updates the user's max_usn.
 // It returns the created note.
-func (a *App) CreateNote(user database.User, bookUUID, content string, addedOn *int64, editedOn *int64, public bool, client string) (database.Note, error) {
+func (a *App) CreateNote(user database.User, bookUUID, content string, addedOn *int64, editedOn *int64, dueOn, remindOn int64, pinned, archived, public bool, client string) (database.Note, error) {
 	tx := a.DB.Begin()
 
 	nextUSN, err := incrementUserUSN(tx, user.ID)
@@ -61,9 +61,13 @@ func (a *App) CreateNote(user database.User, bookUUID, content string, addedOn *
 		UserID:    user.ID,
 		AddedOn:   noteAddedOn,
 		EditedOn:  noteEditedOn,
+		DueOn:     dueOn,
+		RemindOn:  remindOn,
 		USN:       nextUSN,
 		Body:      content,
 		Public:    public,
+		Pinned:    pinned,
+		Archived:  archived,
 		Encrypted: false,
 		Client:    client,
 	}
@@ -71,6 +75,10 @@ func (a *App) CreateNote(user database.User, bookUUID, content string, addedOn *
 		tx.Rollback()
 		return note, errors.Wrap(err, "inserting note")
 	}
+	if err := recordChange(tx, user.ID, nextUSN, database.EntityTypeNote, note.UUID, database.ChangeOperationCreate); err != nil {
+		tx.Rollback()
+		return note, errors.Wrap(err, "recording the change")
+	}
 
 	tx.Commit()
 
@@ -82,6 +90,10 @@ type UpdateNoteParams struct {
 	BookUUID *string
 	Content  *string
 	Public   *bool
+	DueOn    *int64
+	RemindOn *int64
+	Pinned   *bool
+	Archived *bool
 }
 
 // GetBookUUID gets the bookUUID from the UpdateNoteParams
@@ -111,6 +123,42 @@ func (r UpdateNoteParams) GetPublic() bool {
 	return *r.Public
 }
 
+// GetDueOn gets the due_on field from the UpdateNoteParams
+func (r UpdateNoteParams) GetDueOn() int64 {
+	if r.DueOn == nil {
+		return 0
+	}
+
+	return *r.DueOn
+}
+
+// GetRemindOn gets the remind_on field from the UpdateNoteParams
+func (r UpdateNoteParams) GetRemindOn() int64 {
+	if r.RemindOn == nil {
+		return 0
+	}
+
+	return *r.RemindOn
+}
+
+// GetPinned gets the pinned field from the UpdateNoteParams
+func (r UpdateNoteParams) GetPinned() bool {
+	if r.Pinned == nil {
+		return false
+	}
+
+	return *r.Pinned
+}
+
+// GetArchived gets the archived field from the UpdateNoteParams
+func (r UpdateNoteParams) GetArchived() bool {
+	if r.Archived == nil {
+		return false
+	}
+
+	return *r.Archived
+}
+
 // UpdateNote creates a note with the next usn and updates the user's max_usn
 func (a *App) UpdateNote(tx *gorm.DB, user database.User, note database.Note, p *UpdateNoteParams) (database.Note, error) {
 	nextUSN, err := incrementUserUSN(tx, user.ID)
@@ -127,6 +175,23 @@ func (a *App) UpdateNote(tx *gorm.DB, user database.User, note database.Note, p
 	if p.Public != nil {
 		note.Public = p.GetPublic()
 	}
+	if p.DueOn != nil {
+		note.DueOn = p.GetDueOn()
+	}
+	if p.RemindOn != nil {
+		// A changed reminder is to be sent again
+		if note.RemindOn != p.GetRemindOn() {
+			note.RemindedOn = 0
+		}
+
+		note.RemindOn = p.GetRemindOn()
+	}
+	if p.Pinned != nil {
+		note.Pinned = p.GetPinned()
+	}
+	if p.Archived != nil {
+		note.Archived = p.GetArchived()
+	}
 
 	note.USN = nextUSN
 	note.EditedOn = a.Clock.Now().UnixNano()
@@ -137,6 +202,9 @@ func (a *App) UpdateNote(tx *gorm.DB, user database.User, note database.Note, p
 	if err := tx.Save(&note).Error; err != nil {
 		return note, errors.Wrap(err, "editing note")
 	}
+	if err := recordChange(tx, user.ID, nextUSN, database.EntityTypeNote, note.UUID, database.ChangeOperationUpdate); err != nil {
+		return note, errors.Wrap(err, "recording the change")
+	}
 
 	return note, nil
 }
@@ -156,6 +224,9 @@ func (a *App) DeleteNote(tx *gorm.DB, user database.User, note database.Note) (d
 		}).Error; err != nil {
 		return note, errors.Wrap(err, "deleting note")
 	}
+	if err := recordChange(tx, user.ID, nextUSN, database.EntityTypeNote, note.UUID, database.ChangeOperationDelete); err != nil {
+		return note, errors.Wrap(err, "recording the change")
+	}
 
 	return note, nil
 }
@@ -90,7 +90,7 @@ func TestCreateNote(t *testing.T) {
 			})
 
 			tx := testutils.DB.Begin()
-			if _, err := a.CreateNote(user, b1.UUID, "note content", tc.addedOn, tc.editedOn, false, ""); err != nil {
+			if _, err := a.CreateNote(user, b1.UUID, "note content", tc.addedOn, tc.editedOn, 0, 0, false, false, false, ""); err != nil {
 				tx.Rollback()
 				t.Fatal(errors.Wrap(err, "deleting note"))
 			}
@@ -116,6 +116,13 @@ func TestCreateNote(t *testing.T) {
 			assert.Equal(t, noteRecord.EditedOn, tc.expectedEditedOn, "note EditedOn mismatch")
 
 			assert.Equal(t, userRecord.MaxUSN, tc.expectedUSN, "user max_usn mismatch")
+
+			var changeRecord database.Change
+			testutils.MustExec(t, testutils.DB.Where("user_id = ?", user.ID).First(&changeRecord), fmt.Sprintf("finding change for test case %d", idx))
+			assert.Equal(t, changeRecord.USN, tc.expectedUSN, "change usn mismatch")
+			assert.Equal(t, changeRecord.EntityType, database.EntityTypeNote, "change entity type mismatch")
+			assert.Equal(t, changeRecord.EntityUUID, noteRecord.UUID, "change entity uuid mismatch")
+			assert.Equal(t, changeRecord.Operation, database.ChangeOperationCreate, "change operation mismatch")
 		}()
 	}
 }
@@ -191,6 +198,62 @@ func TestUpdateNote(t *testing.T) {
 	}
 }
 
+func TestUpdateNote_reminder(t *testing.T) {
+	testCases := []struct {
+		remindOn           int64
+		expectedRemindedOn int64
+	}{
+		// the same reminder is not sent again
+		{
+			remindOn:           1541108743000000000,
+			expectedRemindedOn: 1541108800000000000,
+		},
+		// a changed reminder is sent again
+		{
+			remindOn:           1541208743000000000,
+			expectedRemindedOn: 0,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			defer testutils.ClearData(testutils.DB)
+
+			user := testutils.SetupUserData()
+
+			b1 := database.Book{UserID: user.ID, Label: "js", Deleted: false}
+			testutils.MustExec(t, testutils.DB.Save(&b1), "preparing b1 for test case")
+
+			note := database.Note{UserID: user.ID, Body: "test content", BookUUID: b1.UUID, RemindOn: 1541108743000000000, RemindedOn: 1541108800000000000}
+			testutils.MustExec(t, testutils.DB.Save(&note), "preparing note for test case")
+
+			a := NewTest(&App{
+				Clock: clock.NewMock(),
+			})
+
+			dueOn := int64(1541308743000000000)
+			remindOn := tc.remindOn
+
+			tx := testutils.DB.Begin()
+			if _, err := a.UpdateNote(tx, user, note, &UpdateNoteParams{
+				DueOn:    &dueOn,
+				RemindOn: &remindOn,
+			}); err != nil {
+				tx.Rollback()
+				t.Fatal(errors.Wrap(err, "updating note"))
+			}
+			tx.Commit()
+
+			var noteRecord database.Note
+			testutils.MustExec(t, testutils.DB.First(&noteRecord), "finding note for test case")
+
+			assert.Equal(t, noteRecord.DueOn, dueOn, "note DueOn mismatch")
+			assert.Equal(t, noteRecord.RemindOn, tc.remindOn, "note RemindOn mismatch")
+			assert.Equal(t, noteRecord.RemindedOn, tc.expectedRemindedOn, "note RemindedOn mismatch")
+		})
+	}
+}
+
 func TestDeleteNote(t *testing.T) {
 	testCases := []struct {
 		userUSN     int
@@ -256,6 +319,12 @@ func TestDeleteNote(t *testing.T) {
 			assert.Equal(t, ret.Body, "", "note content mismatch")
 			assert.Equal(t, ret.Deleted, true, "note deleted flag mismatch")
 			assert.Equal(t, ret.USN, tc.expectedUSN, "note label mismatch")
+
+			var changeRecord database.Change
+			testutils.MustExec(t, testutils.DB.Where("user_id = ?", user.ID).First(&changeRecord), fmt.Sprintf("finding change for test case %d", idx))
+			assert.Equal(t, changeRecord.USN, tc.expectedUSN, "change usn mismatch")
+			assert.Equal(t, changeRecord.EntityUUID, note.UUID, "change entity uuid mismatch")
+			assert.Equal(t, changeRecord.Operation, database.ChangeOperationDelete, "change operation mismatch")
 		}()
 	}
 }
@@ -27,6 +27,22 @@ const (
 	TokenTypeEmailPreference = "email_preference"
 )
 
+const (
+	// EntityTypeNote is a type of an entity in the change journal for notes
+	EntityTypeNote = "note"
+	// EntityTypeBook is a type of an entity in the change journal for books
+	EntityTypeBook = "book"
+)
+
+const (
+	// ChangeOperationCreate is an operation in the change journal for creating an entity
+	ChangeOperationCreate = "create"
+	// ChangeOperationUpdate is an operation in the change journal for updating an entity
+	ChangeOperationUpdate = "update"
+	// ChangeOperationDelete is an operation in the change journal for deleting an entity
+	ChangeOperationDelete = "delete"
+)
+
 const (
 	// BookDomainAll incidates that all books are eligible to be the source books
 	BookDomainAll = "all"
@@ -47,6 +47,7 @@ func InitSchema(db *gorm.DB) {
 		Token{},
 		EmailPreference{},
 		Session{},
+		Change{},
 	).Error; err != nil {
 		panic(err)
 	}
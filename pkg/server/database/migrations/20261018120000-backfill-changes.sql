@@ -0,0 +1,20 @@
+-- backfill-changes.sql populates the change journal with the latest change of the existing
+-- notes and books, so that sync fragments can be served from the journal.
+
+-- +migrate Up
+
+INSERT INTO changes (created_at, updated_at, user_id, usn, entity_type, entity_uuid, operation)
+SELECT now(), now(), user_id, usn, 'note', uuid, CASE WHEN deleted THEN 'delete' ELSE 'update' END
+FROM notes
+WHERE usn > 0
+ON CONFLICT (user_id, usn) DO NOTHING;
+
+INSERT INTO changes (created_at, updated_at, user_id, usn, entity_type, entity_uuid, operation)
+SELECT now(), now(), user_id, usn, 'book', uuid, CASE WHEN deleted THEN 'delete' ELSE 'update' END
+FROM books
+WHERE usn > 0
+ON CONFLICT (user_id, usn) DO NOTHING;
+
+-- +migrate Down
+
+DELETE FROM changes;
@@ -38,6 +38,8 @@ type Book struct {
 	Notes     []Note `json:"notes" gorm:"foreignkey:book_uuid"`
 	AddedOn   int64  `json:"added_on"`
 	EditedOn  int64  `json:"edited_on"`
+	Pinned    bool   `json:"pinned" gorm:"default:false"`
+	Archived  bool   `json:"archived" gorm:"default:false"`
 	USN       int    `json:"-" gorm:"index"`
 	Deleted   bool   `json:"-" gorm:"default:false"`
 	Encrypted bool   `json:"-" gorm:"default:false"`
@@ -46,20 +48,38 @@ type Book struct {
 // Note is a model for a note
 type Note struct {
 	Model
-	UUID      string `json:"uuid" gorm:"index;type:uuid;default:uuid_generate_v4()"`
-	Book      Book   `json:"book" gorm:"foreignkey:BookUUID"`
-	User      User   `json:"user"`
-	UserID    int    `json:"user_id" gorm:"index"`
-	BookUUID  string `json:"book_uuid" gorm:"index;type:uuid"`
-	Body      string `json:"content"`
-	AddedOn   int64  `json:"added_on"`
-	EditedOn  int64  `json:"edited_on"`
-	TSV       string `json:"-" gorm:"type:tsvector"`
-	Public    bool   `json:"public" gorm:"default:false"`
-	USN       int    `json:"-" gorm:"index"`
-	Deleted   bool   `json:"-" gorm:"default:false"`
-	Encrypted bool   `json:"-" gorm:"default:false"`
-	Client    string `gorm:"index"`
+	UUID       string `json:"uuid" gorm:"index;type:uuid;default:uuid_generate_v4()"`
+	Book       Book   `json:"book" gorm:"foreignkey:BookUUID"`
+	User       User   `json:"user"`
+	UserID     int    `json:"user_id" gorm:"index"`
+	BookUUID   string `json:"book_uuid" gorm:"index;type:uuid"`
+	Body       string `json:"content"`
+	AddedOn    int64  `json:"added_on"`
+	EditedOn   int64  `json:"edited_on"`
+	DueOn      int64  `json:"due_on"`
+	RemindOn   int64  `json:"remind_on" gorm:"index"`
+	RemindedOn int64  `json:"-"`
+	TSV        string `json:"-" gorm:"type:tsvector"`
+	Public     bool   `json:"public" gorm:"default:false"`
+	Pinned     bool   `json:"pinned" gorm:"default:false"`
+	Archived   bool   `json:"archived" gorm:"default:false"`
+	USN        int    `json:"-" gorm:"index"`
+	Deleted    bool   `json:"-" gorm:"default:false"`
+	Encrypted  bool   `json:"-" gorm:"default:false"`
+	Client     string `gorm:"index"`
+}
+
+// Change is an entry in the change journal which records every change made to the notes
+// and books of a user, in the order of usn. Sync fragments are served from the journal.
+// An entry whose usn is older than the current usn of its entity has been superseded
+// by a later change and can be compacted without affecting the clients.
+type Change struct {
+	Model
+	UserID     int    `gorm:"unique_index:idx_changes_user_id_usn"`
+	USN        int    `gorm:"unique_index:idx_changes_user_id_usn"`
+	EntityType string `gorm:"not null"`
+	EntityUUID string `gorm:"type:uuid;not null"`
+	Operation  string `gorm:"not null"`
 }
 
 // User is a model for a user
@@ -103,6 +123,7 @@ type EmailPreference struct {
 	UserID           int  `gorm:"index" json:"-"`
 	InactiveReminder bool `json:"inactive_reminder" gorm:"default:false"`
 	ProductUpdate    bool `json:"product_update" gorm:"default:true"`
+	NoteReminder     bool `json:"note_reminder" gorm:"default:true"`
 }
 
 // Session represents a user session
@@ -0,0 +1,126 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package handlers
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dnote/dnote/pkg/server/log"
+)
+
+// gzipResponseWriter is a response writer that compresses the body with gzip
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gw *gzip.Writer
+	// passThrough is true if the response has no body to compress
+	passThrough bool
+	wroteHeader bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if code == http.StatusNoContent || code == http.StatusNotModified || w.Header().Get("Content-Encoding") != "" {
+		w.passThrough = true
+	} else {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passThrough {
+		return w.ResponseWriter.Write(b)
+	}
+
+	if w.gw == nil {
+		w.gw = gzip.NewWriter(w.ResponseWriter)
+	}
+
+	return w.gw.Write(b)
+}
+
+// close flushes the compressed body. If the response was declared to be gzip encoded
+// but nothing was written, it writes an empty gzip stream so that the body stays valid.
+func (w *gzipResponseWriter) close() error {
+	if !w.wroteHeader || w.passThrough {
+		return nil
+	}
+
+	if w.gw == nil {
+		w.gw = gzip.NewWriter(w.ResponseWriter)
+	}
+
+	return w.gw.Close()
+}
+
+// acceptsGzip checks if the client accepts a gzip encoded response
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(part, ";")
+		if strings.TrimSpace(params[0]) != "gzip" {
+			continue
+		}
+
+		// a quality value of 0 means the encoding is not acceptable
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && kv[0] == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// Compress is a middleware to compress the response with gzip if the client accepts it
+func Compress(inner http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if !acceptsGzip(r) {
+			inner.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		inner.ServeHTTP(gw, r)
+
+		// the response has already been sent, so the error can only be logged
+		if err := gw.close(); err != nil {
+			log.ErrorWrap(err, "compressing the response")
+		}
+	}
+}
@@ -0,0 +1,150 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package handlers
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/pkg/errors"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	testCases := []struct {
+		acceptEncoding string
+		expected       bool
+	}{
+		{
+			acceptEncoding: "",
+			expected:       false,
+		},
+		{
+			acceptEncoding: "gzip",
+			expected:       true,
+		},
+		{
+			acceptEncoding: "deflate, gzip;q=0.5",
+			expected:       true,
+		},
+		{
+			acceptEncoding: "gzip;q=0",
+			expected:       false,
+		},
+		{
+			acceptEncoding: "br",
+			expected:       false,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			r, err := http.NewRequest("GET", "/", nil)
+			if err != nil {
+				t.Fatal(errors.Wrap(err, "constructing request"))
+			}
+			r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+
+			assert.Equal(t, acceptsGzip(r), tc.expected, "result mismatch")
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	payload := map[string]string{"foo": "bar"}
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RespondJSON(w, http.StatusOK, payload)
+	}))
+
+	t.Run("gzip accepted", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, w.Code, http.StatusOK, "status code mismatch")
+		assert.Equal(t, w.Header().Get("Content-Encoding"), "gzip", "Content-Encoding mismatch")
+		assert.Equal(t, w.Header().Get("Vary"), "Accept-Encoding", "Vary mismatch")
+
+		gr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "reading the gzip body"))
+		}
+		body, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "decompressing the body"))
+		}
+		assert.Equal(t, string(body), "{\"foo\":\"bar\"}\n", "body mismatch")
+	})
+
+	t.Run("gzip not accepted", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, w.Header().Get("Content-Encoding"), "", "Content-Encoding mismatch")
+		assert.Equal(t, w.Body.String(), "{\"foo\":\"bar\"}\n", "body mismatch")
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		notModified := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotModified)
+		}))
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+
+		notModified.ServeHTTP(w, r)
+
+		assert.Equal(t, w.Code, http.StatusNotModified, "status code mismatch")
+		assert.Equal(t, w.Header().Get("Content-Encoding"), "", "Content-Encoding mismatch")
+		assert.Equal(t, w.Body.Len(), 0, "body should be empty")
+	})
+	t.Run("empty body", func(t *testing.T) {
+		empty := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+
+		empty.ServeHTTP(w, r)
+
+		assert.Equal(t, w.Code, http.StatusOK, "status code mismatch")
+		assert.Equal(t, w.Header().Get("Content-Encoding"), "gzip", "Content-Encoding mismatch")
+
+		gr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "reading the gzip body"))
+		}
+		body, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "decompressing the body"))
+		}
+		assert.Equal(t, len(body), 0, "body should be empty")
+	})
+}
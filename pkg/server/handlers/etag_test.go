@@ -0,0 +1,92 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/server/database"
+)
+
+func TestUserETag(t *testing.T) {
+	user := database.User{Model: database.Model{ID: 3}, MaxUSN: 42}
+
+	assert.Equal(t, UserETag(user), `W/"3-42"`, "result mismatch")
+}
+
+func TestCheckNotModified(t *testing.T) {
+	etag := `W/"3-42"`
+
+	testCases := []struct {
+		ifNoneMatch  string
+		expected     bool
+		expectedCode int
+	}{
+		{
+			ifNoneMatch:  "",
+			expected:     false,
+			expectedCode: http.StatusOK,
+		},
+		{
+			ifNoneMatch:  `W/"3-42"`,
+			expected:     true,
+			expectedCode: http.StatusNotModified,
+		},
+		{
+			ifNoneMatch:  `"3-42"`,
+			expected:     true,
+			expectedCode: http.StatusNotModified,
+		},
+		{
+			ifNoneMatch:  `W/"3-41", W/"3-42"`,
+			expected:     true,
+			expectedCode: http.StatusNotModified,
+		},
+		{
+			ifNoneMatch:  `W/"3-41"`,
+			expected:     false,
+			expectedCode: http.StatusOK,
+		},
+		{
+			ifNoneMatch:  "*",
+			expected:     true,
+			expectedCode: http.StatusNotModified,
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tc.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+
+			got := CheckNotModified(w, r, etag)
+
+			assert.Equal(t, got, tc.expected, "result mismatch")
+			assert.Equal(t, w.Code, tc.expectedCode, "status code mismatch")
+			assert.Equal(t, w.Header().Get("ETag"), etag, "ETag mismatch")
+		})
+	}
+}
@@ -103,6 +103,7 @@ func (r *Runner) schedule(ch chan error) {
 	// Schedule jobs
 	cr := cron.New()
 	scheduleJob(cr, "0 8 * * *", func() { r.RemindNoRecentNotes() })
+	scheduleJob(cr, "*/15 * * * *", func() { r.RemindNotes() })
 	cr.Start()
 
 	ch <- nil
@@ -151,3 +152,26 @@ func (r *Runner) RemindNoRecentNotes() {
 		m.ErrorWrap(err, "error processing no recent note reminder job")
 	}
 }
+
+// RemindNotes sends emails for the notes whose reminders are due
+func (r *Runner) RemindNotes() {
+	c := remind.Context{
+		DB:           r.DB,
+		Clock:        r.Clock,
+		EmailTmpl:    r.EmailTmpl,
+		EmailBackend: r.EmailBackend,
+		Config:       r.Config,
+	}
+
+	result, err := remind.DoNotes(c)
+	m := log.WithFields(log.Fields{
+		"success_count":   result.SuccessCount,
+		"failed_user_ids": result.FailedUserIDs,
+	})
+
+	if err == nil {
+		m.Info("successfully processed note reminder job")
+	} else {
+		m.ErrorWrap(err, "error processing note reminder job")
+	}
+}
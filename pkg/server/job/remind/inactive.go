@@ -44,14 +44,22 @@ type inactiveUserInfo struct {
 	sampleNoteUUID string
 }
 
-func (c *Context) sampleUserNote(userID int) (database.Note, error) {
+// sampleUserNote returns a random note of the user that is neither deleted nor archived,
+// and a boolean indicating if such note exists.
+func (c *Context) sampleUserNote(userID int) (database.Note, bool, error) {
 	var ret database.Note
 	// FIXME: ordering by random() requires a sequential scan on the whole table and does not scale
-	if err := c.DB.Where("user_id = ?", userID).Order("random() DESC").First(&ret).Error; err != nil {
-		return ret, errors.Wrap(err, "getting a random note")
+	conn := c.DB.Joins("INNER JOIN books ON books.uuid = notes.book_uuid").
+		Where("notes.user_id = ? AND NOT notes.deleted AND NOT notes.archived AND NOT books.archived", userID).
+		Order("random() DESC").First(&ret)
+	if conn.RecordNotFound() {
+		return ret, false, nil
+	}
+	if err := conn.Error; err != nil {
+		return ret, false, errors.Wrap(err, "getting a random note")
 	}
 
-	return ret, nil
+	return ret, true, nil
 }
 
 func (c *Context) getInactiveUserInfo() ([]inactiveUserInfo, error) {
@@ -86,10 +94,14 @@ GROUP BY notes.user_id, accounts.email`, threshold).Rows()
 		}
 
 		if recentNoteCount == 0 && totalNoteCount > 0 {
-			note, err := c.sampleUserNote(userID)
+			note, ok, err := c.sampleUserNote(userID)
 			if err != nil {
 				return nil, errors.Wrap(err, "sampling user note")
 			}
+			// the user has only archived notes
+			if !ok {
+				continue
+			}
 
 			ret = append(ret, inactiveUserInfo{
 				userID:         userID,
@@ -105,6 +105,25 @@ func TestDoInactive(t *testing.T) {
 	testutils.MustExec(t, testutils.DB.Save(&n3), "preparing n3")
 	testutils.MustExec(t, testutils.DB.Model(&n3).Update("created_at", t1.AddDate(0, 0, -15)), "preparing n3")
 
+	// u4 is an inactive user whose notes are all archived
+	u4 := testutils.SetupUserData()
+	a4 := testutils.SetupAccountData(u4, "chuck@example.com", "pass1234")
+	testutils.MustExec(t, testutils.DB.Model(&a4).Update("email_verified", true), "setting email verified")
+	testutils.MustExec(t, testutils.DB.Save(&database.EmailPreference{UserID: u4.ID, InactiveReminder: true}), "preparing email preference")
+
+	b4 := database.Book{
+		UserID: u4.ID,
+		Label:  "go",
+	}
+	testutils.MustExec(t, testutils.DB.Save(&b4), "preparing b4")
+	n4 := database.Note{
+		BookUUID: b4.UUID,
+		UserID:   u4.ID,
+		Archived: true,
+	}
+	testutils.MustExec(t, testutils.DB.Save(&n4), "preparing n4")
+	testutils.MustExec(t, testutils.DB.Model(&n4).Update("created_at", t1.AddDate(0, 0, -15)), "preparing n4")
+
 	c := clock.NewMock()
 	c.SetNow(t1)
 	be := &testutils.MockEmailbackendImplementation{}
@@ -0,0 +1,193 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package remind
+
+import (
+	"strings"
+
+	"github.com/dnote/dnote/pkg/server/app"
+	"github.com/dnote/dnote/pkg/server/database"
+	"github.com/dnote/dnote/pkg/server/log"
+	"github.com/dnote/dnote/pkg/server/mailer"
+	"github.com/pkg/errors"
+)
+
+// maxTitleLength is the maximum number of characters of a note title in the reminder emails
+const maxTitleLength = 80
+
+type noteReminderInfo struct {
+	userID  int
+	email   string
+	noteIDs []int
+	notes   []mailer.NoteReminderItem
+}
+
+// getNoteTitle returns the first line of the note body
+func getNoteTitle(note database.Note) string {
+	// TODO: remove after all users are migrated
+	if note.Encrypted {
+		return ""
+	}
+
+	lines := strings.SplitN(strings.TrimSpace(note.Body), "\n", 2)
+	title := []rune(strings.TrimSpace(lines[0]))
+	if len(title) > maxTitleLength {
+		return string(title[:maxTitleLength]) + "..."
+	}
+
+	return string(title)
+}
+
+// getNoteReminderInfo returns the notes whose reminders are due and have not been
+// sent, grouped by the users who can be notified
+func (c *Context) getNoteReminderInfo() ([]noteReminderInfo, error) {
+	ret := []noteReminderInfo{}
+
+	now := c.Clock.Now().UnixNano()
+
+	rows, err := c.DB.Raw(`
+SELECT
+	notes.id,
+	notes.uuid,
+	notes.user_id,
+	notes.body,
+	notes.encrypted,
+	books.label,
+	accounts.email
+FROM notes
+INNER JOIN books ON books.uuid = notes.book_uuid
+INNER JOIN accounts ON accounts.user_id = notes.user_id
+INNER JOIN email_preferences ON email_preferences.user_id = notes.user_id
+WHERE notes.deleted IS FALSE
+	AND notes.remind_on > 0 AND notes.remind_on <= ? AND notes.remind_on > notes.reminded_on
+	AND accounts.email IS NOT NULL AND accounts.email_verified IS TRUE
+	AND email_preferences.note_reminder IS TRUE
+ORDER BY notes.user_id ASC, notes.remind_on ASC, notes.id ASC`, now).Rows()
+	if err != nil {
+		return ret, errors.Wrap(err, "executing note reminder SQL query")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var note database.Note
+		var bookLabel, email string
+		if err := rows.Scan(&note.ID, &note.UUID, &note.UserID, &note.Body, &note.Encrypted, &bookLabel, &email); err != nil {
+			return nil, errors.Wrap(err, "scanning a row")
+		}
+
+		if len(ret) == 0 || ret[len(ret)-1].userID != note.UserID {
+			ret = append(ret, noteReminderInfo{
+				userID: note.UserID,
+				email:  email,
+			})
+		}
+
+		info := &ret[len(ret)-1]
+		info.noteIDs = append(info.noteIDs, note.ID)
+		info.notes = append(info.notes, mailer.NoteReminderItem{
+			UUID:      note.UUID,
+			BookLabel: bookLabel,
+			Title:     getNoteTitle(note),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating rows")
+	}
+
+	return ret, nil
+}
+
+func (c *Context) processNoteReminder(info noteReminderInfo) error {
+	sender, err := app.GetSenderEmail(c.Config, "noreply@getdnote.com")
+	if err != nil {
+		return errors.Wrap(err, "getting sender email")
+	}
+
+	tok, err := mailer.GetToken(c.DB, info.userID, database.TokenTypeEmailPreference)
+	if err != nil {
+		return errors.Wrap(err, "getting email token")
+	}
+
+	tmplData := mailer.NoteReminderTmplData{
+		Notes:  info.notes,
+		WebURL: c.Config.WebURL,
+		Token:  tok.Value,
+	}
+	body, err := c.EmailTmpl.Execute(mailer.EmailTypeNoteReminder, mailer.EmailKindText, tmplData)
+	if err != nil {
+		return errors.Wrap(err, "executing note reminder email template")
+	}
+
+	// The notes are reminded again in the next run if the email cannot be queued
+	tx := c.DB.Begin()
+	if err := tx.Model(&database.Note{}).Where("id IN (?)", info.noteIDs).
+		UpdateColumn("reminded_on", c.Clock.Now().UnixNano()).Error; err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "marking the notes as reminded")
+	}
+	if err := tx.Create(&database.Notification{
+		Type:   mailer.EmailTypeNoteReminder,
+		UserID: info.userID,
+	}).Error; err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "creating notification")
+	}
+
+	if err := c.EmailBackend.Queue("Your Dnote reminders", sender, []string{info.email}, mailer.EmailKindText, body); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "queueing email")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return errors.Wrap(err, "committing a transaction")
+	}
+
+	return nil
+}
+
+// DoNotes sends an email to each user with the notes whose reminders are due
+func DoNotes(c Context) (Result, error) {
+	log.Info("performing note reminder")
+
+	result := Result{}
+	items, err := c.getNoteReminderInfo()
+	if err != nil {
+		return result, errors.Wrap(err, "getting note reminder information")
+	}
+
+	log.WithFields(log.Fields{
+		"user_count": len(items),
+	}).Info("counted users with due reminders")
+
+	for _, item := range items {
+		err := c.processNoteReminder(item)
+
+		if err == nil {
+			result.SuccessCount = result.SuccessCount + 1
+		} else {
+			log.WithFields(log.Fields{
+				"user_id": item.userID,
+			}).ErrorWrap(err, "Could not process note reminder")
+
+			result.FailedUserIDs = append(result.FailedUserIDs, item.userID)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,147 @@
+/* Copyright (C) 2019, 2020 Monomax Software Pty Ltd
+ *
+ * This file is part of Dnote.
+ *
+ * Dnote is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Dnote is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Dnote.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package remind
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnote/dnote/pkg/assert"
+	"github.com/dnote/dnote/pkg/clock"
+	"github.com/dnote/dnote/pkg/server/database"
+	"github.com/dnote/dnote/pkg/server/mailer"
+	"github.com/dnote/dnote/pkg/server/testutils"
+	"github.com/pkg/errors"
+)
+
+func TestGetNoteTitle(t *testing.T) {
+	testCases := []struct {
+		body     string
+		expected string
+	}{
+		{
+			body:     "  foo\nbar",
+			expected: "foo",
+		},
+		{
+			body:     strings.Repeat("a", maxTitleLength),
+			expected: strings.Repeat("a", maxTitleLength),
+		},
+		{
+			body:     strings.Repeat("a", maxTitleLength+1),
+			expected: strings.Repeat("a", maxTitleLength) + "...",
+		},
+		{
+			body:     strings.Repeat("가", maxTitleLength+1),
+			expected: strings.Repeat("가", maxTitleLength) + "...",
+		},
+	}
+
+	for idx, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", idx), func(t *testing.T) {
+			assert.Equal(t, getNoteTitle(database.Note{Body: tc.body}), tc.expected, "title mismatch")
+		})
+	}
+}
+
+func TestDoNotes(t *testing.T) {
+	defer testutils.ClearData(testutils.DB)
+
+	now := time.Now()
+	past := now.Add(-time.Hour).UnixNano()
+	future := now.Add(time.Hour).UnixNano()
+
+	// setup sets up a user with a verified email and a book
+	setup := func(t *testing.T, email string, noteReminder bool) (database.User, database.Book) {
+		u := testutils.SetupUserData()
+		a := testutils.SetupAccountData(u, email, "pass1234")
+		testutils.MustExec(t, testutils.DB.Model(&a).Update("email_verified", true), "setting email verified")
+		pref := database.EmailPreference{UserID: u.ID}
+		testutils.MustExec(t, testutils.DB.Save(&pref), "preparing email preference")
+		testutils.MustExec(t, testutils.DB.Model(&pref).Update(map[string]interface{}{"note_reminder": noteReminder}), "updating email preference")
+
+		b := database.Book{
+			UserID: u.ID,
+			Label:  "js",
+		}
+		testutils.MustExec(t, testutils.DB.Save(&b), "preparing book")
+
+		return u, b
+	}
+
+	// alice has two due reminders, a future reminder, a sent reminder and a deleted note
+	alice, aliceBook := setup(t, "alice@example.com", true)
+	n1 := database.Note{UserID: alice.ID, BookUUID: aliceBook.UUID, Body: "n1 title\nn1 body", RemindOn: past}
+	testutils.MustExec(t, testutils.DB.Save(&n1), "preparing n1")
+	n2 := database.Note{UserID: alice.ID, BookUUID: aliceBook.UUID, Body: "n2 title", RemindOn: past}
+	testutils.MustExec(t, testutils.DB.Save(&n2), "preparing n2")
+	n3 := database.Note{UserID: alice.ID, BookUUID: aliceBook.UUID, Body: "n3 title", RemindOn: future}
+	testutils.MustExec(t, testutils.DB.Save(&n3), "preparing n3")
+	n4 := database.Note{UserID: alice.ID, BookUUID: aliceBook.UUID, Body: "n4 title", RemindOn: past, RemindedOn: past + 1}
+	testutils.MustExec(t, testutils.DB.Save(&n4), "preparing n4")
+	n5 := database.Note{UserID: alice.ID, BookUUID: aliceBook.UUID, Body: "", RemindOn: past, Deleted: true}
+	testutils.MustExec(t, testutils.DB.Save(&n5), "preparing n5")
+
+	// bob has a due reminder but disabled the note reminder email preference
+	bob, bobBook := setup(t, "bob@example.com", false)
+	n6 := database.Note{UserID: bob.ID, BookUUID: bobBook.UUID, Body: "n6 title", RemindOn: past}
+	testutils.MustExec(t, testutils.DB.Save(&n6), "preparing n6")
+
+	c := clock.NewMock()
+	c.SetNow(now)
+	be := &testutils.MockEmailbackendImplementation{}
+
+	// Execute
+	con := getTestContext(c, be)
+	result, err := DoNotes(con)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "performing"))
+	}
+
+	// Test
+	assert.Equal(t, result.SuccessCount, 1, "success count mismatch")
+	assert.Equalf(t, len(be.Emails), 1, "email queue count mismatch")
+	assert.DeepEqual(t, be.Emails[0].To, []string{"alice@example.com"}, "email address mismatch")
+
+	body := be.Emails[0].Body
+	assert.Equal(t, strings.Contains(body, "n1 title [js]"), true, "n1 missing in the email")
+	assert.Equal(t, strings.Contains(body, "n2 title [js]"), true, "n2 missing in the email")
+	assert.Equal(t, strings.Contains(body, "n3 title"), false, "n3 should not be in the email")
+	assert.Equal(t, strings.Contains(body, "n4 title"), false, "n4 should not be in the email")
+
+	var n1Record, n3Record database.Note
+	testutils.MustExec(t, testutils.DB.Where("id = ?", n1.ID).First(&n1Record), "finding n1")
+	testutils.MustExec(t, testutils.DB.Where("id = ?", n3.ID).First(&n3Record), "finding n3")
+	assert.Equal(t, n1Record.RemindedOn, now.UnixNano(), "n1 RemindedOn mismatch")
+	assert.Equal(t, n3Record.RemindedOn, int64(0), "n3 RemindedOn mismatch")
+
+	var notificationCount int
+	testutils.MustExec(t, testutils.DB.Model(&database.Notification{}).Where("type = ?", mailer.EmailTypeNoteReminder).Count(&notificationCount), "counting notifications")
+	assert.Equal(t, notificationCount, 1, "notification count mismatch")
+
+	// a second run does not send the same reminders again
+	be.Clear()
+	if _, err := DoNotes(con); err != nil {
+		t.Fatal(errors.Wrap(err, "performing again"))
+	}
+
+	assert.Equalf(t, len(be.Emails), 0, "email queue count mismatch for the second run")
+}
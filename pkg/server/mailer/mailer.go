@@ -44,6 +44,8 @@ var (
 	EmailTypeInactiveReminder = "inactive"
 	// EmailTypeSubscriptionConfirmation represents an inactivity reminder email
 	EmailTypeSubscriptionConfirmation = "subscription_confirmation"
+	// EmailTypeNoteReminder represents a reminder email for the notes whose reminders are due
+	EmailTypeNoteReminder = "note_reminder"
 )
 
 var (
@@ -115,6 +117,10 @@ func NewTemplates(srcDir *string) Templates {
 	if err != nil {
 		panic(errors.Wrap(err, "initializing password reset template"))
 	}
+	noteReminderText, err := initTextTmpl(box, EmailTypeNoteReminder)
+	if err != nil {
+		panic(errors.Wrap(err, "initializing note reminder template"))
+	}
 
 	T := Templates{}
 	T.set(EmailTypeResetPassword, EmailKindText, passwordResetText)
@@ -123,6 +129,7 @@ func NewTemplates(srcDir *string) Templates {
 	T.set(EmailTypeWelcome, EmailKindText, welcomeText)
 	T.set(EmailTypeInactiveReminder, EmailKindText, inactiveReminderText)
 	T.set(EmailTypeSubscriptionConfirmation, EmailKindText, subscriptionConfirmationText)
+	T.set(EmailTypeNoteReminder, EmailKindText, noteReminderText)
 
 	return T
 }
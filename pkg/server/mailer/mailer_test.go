@@ -104,3 +104,33 @@ func TestResetPasswordEmail(t *testing.T) {
 		})
 	}
 }
+
+func TestNoteReminderEmail(t *testing.T) {
+	tmplPath := os.Getenv("DNOTE_TEST_EMAIL_TEMPLATE_DIR")
+	tmpl := NewTemplates(&tmplPath)
+
+	dat := NoteReminderTmplData{
+		Notes: []NoteReminderItem{
+			{UUID: "n1-uuid", BookLabel: "chores", Title: "renew the passport"},
+			{UUID: "n2-uuid", BookLabel: "js", Title: ""},
+		},
+		WebURL: "http://localhost:3000",
+		Token:  "someRandomToken",
+	}
+	body, err := tmpl.Execute(EmailTypeNoteReminder, EmailKindText, dat)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "executing"))
+	}
+
+	for _, s := range []string{
+		"renew the passport [chores]",
+		"http://localhost:3000/notes/n1-uuid",
+		"(untitled) [js]",
+		"http://localhost:3000/notes/n2-uuid",
+		"token=someRandomToken",
+	} {
+		if ok := strings.Contains(body, s); !ok {
+			t.Errorf("email body did not contain %s", s)
+		}
+	}
+}
@@ -102,6 +102,24 @@ func (c Context) inactiveHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(body))
 }
 
+func (c Context) noteReminderHandler(w http.ResponseWriter, r *http.Request) {
+	data := mailer.NoteReminderTmplData{
+		Notes: []mailer.NoteReminderItem{
+			{UUID: "some-uuid-1", BookLabel: "chores", Title: "renew the passport"},
+			{UUID: "some-uuid-2", BookLabel: "js", Title: "read about generators"},
+		},
+		WebURL: "http://localhost:3000",
+		Token:  "some-random-token",
+	}
+	body, err := c.Tmpl.Execute(mailer.EmailTypeNoteReminder, mailer.EmailKindText, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte(body))
+}
+
 func (c Context) homeHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Email development server is running."))
 }
@@ -135,5 +153,6 @@ func main() {
 	http.HandleFunc("/password-reset-alert", ctx.passwordResetAlertHandler)
 	http.HandleFunc("/welcome", ctx.welcomeHandler)
 	http.HandleFunc("/inactive-reminder", ctx.inactiveHandler)
+	http.HandleFunc("/note-reminder", ctx.noteReminderHandler)
 	log.Fatal(http.ListenAndServe(":2300", nil))
 }
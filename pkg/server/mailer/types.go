@@ -55,3 +55,17 @@ type EmailTypeSubscriptionConfirmationTmplData struct {
 	AccountEmail string
 	WebURL       string
 }
+
+// NoteReminderItem is a note in the note reminder emails
+type NoteReminderItem struct {
+	UUID      string
+	BookLabel string
+	Title     string
+}
+
+// NoteReminderTmplData is a template data for note reminder emails
+type NoteReminderTmplData struct {
+	Notes  []NoteReminderItem
+	WebURL string
+	Token  string
+}
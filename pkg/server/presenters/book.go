@@ -31,6 +31,8 @@ type Book struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Label     string    `json:"label"`
+	Pinned    bool      `json:"pinned"`
+	Archived  bool      `json:"archived"`
 }
 
 // PresentBook presents a book
@@ -41,6 +43,8 @@ func PresentBook(book database.Book) Book {
 		CreatedAt: FormatTS(book.CreatedAt),
 		UpdatedAt: FormatTS(book.UpdatedAt),
 		Label:     book.Label,
+		Pinned:    book.Pinned,
+		Archived:  book.Archived,
 	}
 }
 
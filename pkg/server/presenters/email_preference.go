@@ -28,6 +28,7 @@ import (
 type EmailPreference struct {
 	InactiveReminder bool      `json:"inactive_reminder"`
 	ProductUpdate    bool      `json:"product_update"`
+	NoteReminder     bool      `json:"note_reminder"`
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
 }
@@ -37,6 +38,7 @@ func PresentEmailPreference(p database.EmailPreference) EmailPreference {
 	ret := EmailPreference{
 		InactiveReminder: p.InactiveReminder,
 		ProductUpdate:    p.ProductUpdate,
+		NoteReminder:     p.NoteReminder,
 		CreatedAt:        FormatTS(p.CreatedAt),
 		UpdatedAt:        FormatTS(p.UpdatedAt),
 	}
@@ -31,7 +31,11 @@ type Note struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Body      string    `json:"content"`
 	AddedOn   int64     `json:"added_on"`
+	DueOn     int64     `json:"due_on"`
+	RemindOn  int64     `json:"remind_on"`
 	Public    bool      `json:"public"`
+	Pinned    bool      `json:"pinned"`
+	Archived  bool      `json:"archived"`
 	USN       int       `json:"usn"`
 	Book      NoteBook  `json:"book"`
 	User      NoteUser  `json:"user"`
@@ -57,7 +61,11 @@ func PresentNote(note database.Note) Note {
 		UpdatedAt: FormatTS(note.UpdatedAt),
 		Body:      note.Body,
 		AddedOn:   note.AddedOn,
+		DueOn:     note.DueOn,
+		RemindOn:  note.RemindOn,
 		Public:    note.Public,
+		Pinned:    note.Pinned,
+		Archived:  note.Archived,
 		USN:       note.USN,
 		Book: NoteBook{
 			UUID:  note.Book.UUID,
@@ -82,6 +82,9 @@ func ClearData(db *gorm.DB) {
 	if err := db.Delete(&database.Session{}).Error; err != nil {
 		panic(errors.Wrap(err, "Failed to clear sessions"))
 	}
+	if err := db.Delete(&database.Change{}).Error; err != nil {
+		panic(errors.Wrap(err, "Failed to clear changes"))
+	}
 }
 
 // SetupUserData creates and returns a new user for testing purposes
@@ -27,7 +27,8 @@ import styles from './Form.scss';
 
 enum Action {
   setInactiveReminder,
-  setProductUpdate
+  setProductUpdate,
+  setNoteReminder
 }
 
 function formReducer(state, action): EmailPrefData {
@@ -42,6 +43,11 @@ function formReducer(state, action): EmailPrefData {
         ...state,
         productUpdate: action.data
       };
+    case Action.setNoteReminder:
+      return {
+        ...state,
+        noteReminder: action.data
+      };
     default:
       return state;
   }
@@ -76,6 +82,7 @@ const Form: React.FunctionComponent<Props> = ({
       .updateEmailPreference({
         inactiveReminder: formState.inactiveReminder,
         productUpdate: formState.productUpdate,
+        noteReminder: formState.noteReminder,
         token
       })
       .then(updatedPreference => {
@@ -114,6 +121,24 @@ const Form: React.FunctionComponent<Props> = ({
               I stop learning new things
             </label>
           </li>
+          <li>
+            <input
+              type="checkbox"
+              id="note-reminder"
+              checked={formState.noteReminder}
+              onChange={e => {
+                const { checked } = e.target;
+
+                formDispatch({
+                  type: Action.setNoteReminder,
+                  data: checked
+                });
+              }}
+            />
+            <label className={styles.label} htmlFor="note-reminder">
+              A reminder on my note is due
+            </label>
+          </li>
         </ul>
       </div>
 
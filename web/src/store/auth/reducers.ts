@@ -46,7 +46,8 @@ export const initialState: AuthState = {
     isFetched: false,
     data: {
       inactiveReminder: false,
-      productUpdate: false
+      productUpdate: false,
+      noteReminder: false
     },
     errorMessage: ''
   }